@@ -9,9 +9,13 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"gosh/internal/config"
+	"gosh/internal/parser"
 	"gosh/internal/shell"
+
+	"github.com/chzyer/readline"
 )
 
 const (
@@ -31,9 +35,34 @@ var (
 	configFlag  = flag.String("config", "", "Path to configuration file")
 	debugFlag   = flag.Bool("debug", false, "Enable debug mode")
 	helpFlag    = flag.Bool("help", false, "Show help information")
+	commandFlag = flag.String("c", "", "Run the given command and exit")
+	interactive = flag.Bool("i", false, "Run interactively even when stdin is not a terminal")
+	loginFlag   bool
+
+	// Shell options, as with set
+	errexitFlag = flag.Bool("e", false, "Exit when a command fails (set -e)")
+	nounsetFlag = flag.Bool("u", false, "Treat unset variables as an error (set -u)")
+	xtraceFlag  = flag.Bool("x", false, "Print commands before running them (set -x)")
+	noexecFlag  = flag.Bool("n", false, "Check a script for syntax errors without running it (set -n)")
+	optionFlags []string
 )
 
+// registerFlags defines the flags that are set through a variable or a
+// function rather than returned by the flag package
+func registerFlags() {
+	flag.BoolVar(&loginFlag, "l", false, "Run as a login shell")
+	flag.BoolVar(&loginFlag, "login", false, "Run as a login shell")
+	flag.Func("o", "Turn on a shell option, as with set -o (repeatable)", func(name string) error {
+		if _, ok := config.LookupOption(config.OptionSet, name); !ok {
+			return fmt.Errorf("%s: invalid option name", name)
+		}
+		optionFlags = append(optionFlags, name)
+		return nil
+	})
+}
+
 func main() {
+	registerFlags()
 	flag.Parse()
 
 	// Handle version flag
@@ -60,16 +89,60 @@ func main() {
 		cfg.Debug = true
 	}
 
+	// Apply the shell options given on the command line
+	cfg.Errexit = cfg.Errexit || *errexitFlag
+	cfg.Nounset = cfg.Nounset || *nounsetFlag
+	cfg.Xtrace = cfg.Xtrace || *xtraceFlag
+	cfg.Noexec = cfg.Noexec || *noexecFlag
+	for _, name := range optionFlags {
+		if err := cfg.SetOption(name, true); err != nil {
+			log.Fatalf("Invalid shell option: %v", err)
+		}
+	}
+
 	// Create and start the shell
 	sh, err := shell.New(cfg)
 	if err != nil {
 		log.Fatalf("Failed to create shell: %v", err)
 	}
 
+	// A login shell is started with -l, or by login(1) with a name
+	// starting with a dash
+	sh.SetLogin(loginFlag || strings.HasPrefix(os.Args[0], "-"))
+
+	// Arguments after the options name a script and its positional
+	// parameters; with -c they are $0 and the positional parameters
+	args := flag.Args()
+	switch {
+	case isFlagSet("c"):
+		if len(args) > 0 {
+			sh.SetArgs(args[0], args[1:])
+		}
+		os.Exit(sh.RunCommand(*commandFlag))
+	case len(args) > 0:
+		sh.SetArgs(args[0], args[1:])
+		os.Exit(sh.RunFile(args[0]))
+	case !*interactive && !readline.IsTerminal(int(os.Stdin.Fd())):
+		os.Exit(sh.RunStdin())
+	}
+
 	// Run the shell
-	if err := sh.Run(); err != nil {
+	status, err := sh.Run()
+	if err != nil {
 		log.Fatalf("Shell execution failed: %v", err)
 	}
+	os.Exit(status)
+}
+
+// isFlagSet reports whether the named flag was given on the command line
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
 }
 
 // initializeConfig sets up the configuration for gosh
@@ -112,21 +185,31 @@ func showHelp() {
 	fmt.Printf("gosh - A modern shell written in Go (version %s)\n\n", Version)
 	fmt.Println("Usage:")
 	fmt.Println("  gosh [options]")
+	fmt.Println("  gosh [options] script [args...]")
+	fmt.Println("  gosh [options] -c command [name [args...]]")
+	fmt.Println()
+	fmt.Println("Without a script or -c, commands are read from stdin: interactively")
+	fmt.Println("on a terminal, and as a script otherwise.")
 	fmt.Println()
 	fmt.Println("Options:")
 	fmt.Println("  -version     Show version information")
 	fmt.Println("  -config      Path to configuration file")
 	fmt.Println("  -debug       Enable debug mode")
 	fmt.Println("  -help        Show this help message")
+	fmt.Println("  -c           Run the given command and exit")
+	fmt.Println("  -i           Run interactively even when stdin is not a terminal")
+	fmt.Println("  -l, -login   Run as a login shell")
+	fmt.Println("  -e, -u, -x   Turn on errexit, nounset or xtrace, as with set")
+	fmt.Println("  -n           Check a script for syntax errors without running it")
+	fmt.Println("  -o name      Turn on a shell option, as with set -o (repeatable)")
 	fmt.Println()
 	fmt.Println("Configuration:")
-	fmt.Println("  Gosh looks for configuration files in the following order:")
-	fmt.Println("  1. ~/.config/gosh/goshrc")
-	fmt.Println("  2. ~/.goshrc")
-	fmt.Println("  3. ~/.gosh_profile (login shells)")
+	fmt.Println("  Settings are read from ~/.config/gosh/config. At startup gosh runs:")
+	fmt.Println("  1. ~/.gosh_profile (login shells)")
+	fmt.Println("  2. ~/.config/gosh/goshrc, or else ~/.goshrc (interactive shells)")
 	fmt.Println()
-	fmt.Println("Built-in Commands:")
-	fmt.Println("  cd, pwd, exit, help, history, alias, export")
+	fmt.Println("Built-in Commands (run help in gosh for a description of each):")
+	printWrapped(strings.Join(parser.BuiltinNames(), ", "), "  ", 72)
 	fmt.Println()
 	fmt.Println("Features:")
 	fmt.Println("  - Tab completion for commands and files")
@@ -137,3 +220,20 @@ func showHelp() {
 	fmt.Println()
 	fmt.Println("For more information, visit: https://github.com/yourusername/gosh")
 }
+
+// printWrapped prints text as indented lines of at most width columns,
+// breaking it between words.
+func printWrapped(text, indent string, width int) {
+	line := indent
+	for _, word := range strings.Fields(text) {
+		if line != indent && len(line)+1+len(word) > width {
+			fmt.Println(line)
+			line = indent
+		}
+		if line != indent {
+			line += " "
+		}
+		line += word
+	}
+	fmt.Println(line)
+}
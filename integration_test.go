@@ -19,10 +19,7 @@ import (
 
 // TestShellBasicCommands tests basic shell functionality
 func TestShellBasicCommands(t *testing.T) {
-	// Build gosh if not already built
-	if err := buildGosh(); err != nil {
-		t.Fatalf("Failed to build gosh: %v", err)
-	}
+	binary := buildGosh(t)
 
 	tests := []struct {
 		name     string
@@ -58,14 +55,14 @@ func TestShellBasicCommands(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			output, err := runGoshCommand(tt.input)
+			output, err := runGoshCommand(binary, tt.input)
 			if (err != nil) != tt.wantErr {
-				t.Errorf("runGoshCommand() error = %v, wantErr %v", err, tt.wantErr)
+				t.Errorf("runGoshCommand(binary, ) error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 
 			if !strings.Contains(output, tt.expected) {
-				t.Errorf("runGoshCommand() output = %q, want to contain %q", output, tt.expected)
+				t.Errorf("runGoshCommand(binary, ) output = %q, want to contain %q", output, tt.expected)
 			}
 		})
 	}
@@ -73,9 +70,7 @@ func TestShellBasicCommands(t *testing.T) {
 
 // TestShellConfiguration tests configuration loading and application
 func TestShellConfiguration(t *testing.T) {
-	if err := buildGosh(); err != nil {
-		t.Fatalf("Failed to build gosh: %v", err)
-	}
+	binary := buildGosh(t)
 
 	// Create a temporary config file
 	tmpDir := t.TempDir()
@@ -115,14 +110,15 @@ export TEST_VAR=integration_test
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			output, err := runGoshCommand(tt.input)
+			// Only interactive shells run .goshrc
+			output, err := runGoshCommand(binary, tt.input, "-i")
 			if err != nil {
-				t.Errorf("runGoshCommand() error = %v", err)
+				t.Errorf("runGoshCommand(binary, ) error = %v", err)
 				return
 			}
 
 			if !strings.Contains(output, tt.expected) {
-				t.Errorf("runGoshCommand() output = %q, want to contain %q", output, tt.expected)
+				t.Errorf("runGoshCommand(binary, ) output = %q, want to contain %q", output, tt.expected)
 			}
 		})
 	}
@@ -130,9 +126,7 @@ export TEST_VAR=integration_test
 
 // TestShellHistory tests command history functionality
 func TestShellHistory(t *testing.T) {
-	if err := buildGosh(); err != nil {
-		t.Fatalf("Failed to build gosh: %v", err)
-	}
+	binary := buildGosh(t)
 
 	// Create a temporary directory for history
 	tmpDir := t.TempDir()
@@ -145,7 +139,7 @@ func TestShellHistory(t *testing.T) {
 
 	// First session: add some commands to history
 	input1 := "echo first command\necho second command\nexit\n"
-	_, err := runGoshCommand(input1)
+	_, err := runGoshCommand(binary, input1, "-i")
 	if err != nil {
 		t.Fatalf("First session failed: %v", err)
 	}
@@ -157,7 +151,7 @@ func TestShellHistory(t *testing.T) {
 
 	// Second session: check history
 	input2 := "history\nexit\n"
-	output, err := runGoshCommand(input2)
+	output, err := runGoshCommand(binary, input2, "-i")
 	if err != nil {
 		t.Fatalf("Second session failed: %v", err)
 	}
@@ -170,9 +164,7 @@ func TestShellHistory(t *testing.T) {
 
 // TestShellDirectoryNavigation tests cd and pwd commands
 func TestShellDirectoryNavigation(t *testing.T) {
-	if err := buildGosh(); err != nil {
-		t.Fatalf("Failed to build gosh: %v", err)
-	}
+	binary := buildGosh(t)
 
 	// Create a temporary directory structure
 	tmpDir := t.TempDir()
@@ -184,9 +176,9 @@ func TestShellDirectoryNavigation(t *testing.T) {
 
 	// Test cd and pwd
 	input := fmt.Sprintf("cd %s\npwd\nexit\n", subDir)
-	output, err := runGoshCommand(input)
+	output, err := runGoshCommand(binary, input)
 	if err != nil {
-		t.Fatalf("runGoshCommand() error = %v", err)
+		t.Fatalf("runGoshCommand(binary, ) error = %v", err)
 	}
 
 	if !strings.Contains(output, "testdir") {
@@ -196,24 +188,25 @@ func TestShellDirectoryNavigation(t *testing.T) {
 
 // TestShellErrorHandling tests error handling and recovery
 func TestShellErrorHandling(t *testing.T) {
-	if err := buildGosh(); err != nil {
-		t.Fatalf("Failed to build gosh: %v", err)
-	}
+	binary := buildGosh(t)
 
 	tests := []struct {
-		name     string
-		input    string
-		expected string
+		name       string
+		input      string
+		expected   string
+		wantStatus int
 	}{
 		{
-			name:     "non-existent command",
-			input:    "nonexistentcommand123\nexit\n",
-			expected: "command not found",
+			name:       "non-existent command",
+			input:      "nonexistentcommand123\nexit\n",
+			expected:   "command not found",
+			wantStatus: 127,
 		},
 		{
-			name:     "invalid cd",
-			input:    "cd /nonexistent/directory\nexit\n",
-			expected: "no such file or directory",
+			name:       "invalid cd",
+			input:      "cd /nonexistent/directory\nexit\n",
+			expected:   "no such file or directory",
+			wantStatus: 1,
 		},
 		{
 			name:     "continue after error",
@@ -224,15 +217,125 @@ func TestShellErrorHandling(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			output, err := runGoshCommand(tt.input)
-			// We expect the command to succeed even if individual commands fail
-			if err != nil {
-				t.Errorf("runGoshCommand() should not fail on command errors: %v", err)
-				return
+			output, err := runGoshCommand(binary, tt.input)
+			// The shell goes on after a failed command, and exit without
+			// a status exits with the status of the last one
+			status := 0
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				status = exitErr.ExitCode()
+			} else if err != nil {
+				t.Fatalf("runGoshCommand(binary, ) error = %v", err)
 			}
 
+			if status != tt.wantStatus {
+				t.Errorf("exit status = %d, want %d", status, tt.wantStatus)
+			}
 			if !strings.Contains(strings.ToLower(output), strings.ToLower(tt.expected)) {
-				t.Errorf("runGoshCommand() output = %q, want to contain %q", output, tt.expected)
+				t.Errorf("runGoshCommand(binary, ) output = %q, want to contain %q", output, tt.expected)
+			}
+		})
+	}
+}
+
+// TestShellScriptMode tests running scripts from files, -c and stdin
+func TestShellScriptMode(t *testing.T) {
+	binary := buildGosh(t)
+
+	tmpDir := t.TempDir()
+	script := filepath.Join(tmpDir, "script.gosh")
+	content := "#!" + binary + "\necho \"$0 has $# args: $@\"\nnonexistentcommand123\nsh -c 'exit 3'\n"
+	if err := os.WriteFile(script, []byte(content), 0755); err != nil {
+		t.Fatalf("Failed to create script: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		command    string
+		args       []string
+		input      string
+		expected   string
+		wantStatus int
+	}{
+		{
+			name:       "script file with arguments",
+			command:    binary,
+			args:       []string{script, "one", "two"},
+			expected:   script + " has 2 args: one two",
+			wantStatus: 3,
+		},
+		{
+			name:       "script errors name the line",
+			command:    binary,
+			args:       []string{script},
+			expected:   script + ":3: command not found: nonexistentcommand123",
+			wantStatus: 3,
+		},
+		{
+			name:       "shebang",
+			command:    script,
+			args:       []string{"one"},
+			expected:   script + " has 1 args: one",
+			wantStatus: 3,
+		},
+		{
+			name:     "command string",
+			command:  binary,
+			args:     []string{"-c", "echo $0 $1", "name", "arg"},
+			expected: "name arg",
+		},
+		{
+			name:       "command string status",
+			command:    binary,
+			args:       []string{"-c", "echo ok; false"},
+			expected:   "ok",
+			wantStatus: 1,
+		},
+		{
+			name:     "failing command is not an error",
+			command:  binary,
+			args:     []string{"-c", "grep -q gosh /dev/null; echo status $?"},
+			expected: "status 1",
+		},
+		{
+			name:       "script from stdin",
+			command:    binary,
+			input:      "echo from stdin\nsh -c 'exit 4'\n",
+			expected:   "from stdin",
+			wantStatus: 4,
+		},
+		{
+			name:     "commands share stdin with the script",
+			command:  binary,
+			input:    "head -c 5\nhello\necho after\n",
+			expected: "helloafter",
+		},
+		{
+			name:       "missing script",
+			command:    binary,
+			args:       []string{filepath.Join(tmpDir, "missing.gosh")},
+			expected:   "no such file or directory",
+			wantStatus: 127,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := exec.Command(tt.command, tt.args...)
+			cmd.Stdin = strings.NewReader(tt.input)
+			output, err := cmd.CombinedOutput()
+
+			status := 0
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				status = exitErr.ExitCode()
+			} else if err != nil {
+				t.Fatalf("Command failed: %v", err)
+			}
+
+			if status != tt.wantStatus {
+				t.Errorf("exit status = %d, want %d", status, tt.wantStatus)
+			}
+			if !strings.Contains(string(output), tt.expected) {
+				t.Errorf("Output = %q, want to contain %q", string(output), tt.expected)
 			}
 		})
 	}
@@ -240,9 +343,7 @@ func TestShellErrorHandling(t *testing.T) {
 
 // TestShellVersionAndHelp tests version and help flags
 func TestShellVersionAndHelp(t *testing.T) {
-	if err := buildGosh(); err != nil {
-		t.Fatalf("Failed to build gosh: %v", err)
-	}
+	binary := buildGosh(t)
 
 	tests := []struct {
 		name     string
@@ -263,7 +364,7 @@ func TestShellVersionAndHelp(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			cmd := exec.Command("./build/gosh", tt.args...)
+			cmd := exec.Command(binary, tt.args...)
 			output, err := cmd.CombinedOutput()
 			if err != nil {
 				t.Errorf("Command failed: %v, output: %s", err, output)
@@ -279,15 +380,13 @@ func TestShellVersionAndHelp(t *testing.T) {
 
 // TestShellInteractiveFeatures tests interactive features
 func TestShellInteractiveFeatures(t *testing.T) {
-	if err := buildGosh(); err != nil {
-		t.Fatalf("Failed to build gosh: %v", err)
-	}
+	binary := buildGosh(t)
 
 	// Test that shell starts and can handle basic interaction
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, "./build/gosh")
+	cmd := exec.CommandContext(ctx, binary)
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
 		t.Fatalf("Failed to create stdin pipe: %v", err)
@@ -328,26 +427,26 @@ func TestShellInteractiveFeatures(t *testing.T) {
 
 // Helper functions
 
-// buildGosh builds the gosh binary if it doesn't exist
-func buildGosh() error {
-	if _, err := os.Stat("./build/gosh"); err == nil {
-		return nil // Already built
-	}
+// buildGosh builds the gosh binary from the code under test into a
+// temporary directory and returns its path
+func buildGosh(t *testing.T) string {
+	t.Helper()
 
-	cmd := exec.Command("make", "build")
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("build failed: %v, output: %s", err, output)
+	binary := filepath.Join(t.TempDir(), "gosh")
+	cmd := exec.Command("go", "build", "-o", binary, "./cmd")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("Failed to build gosh: %v, output: %s", err, output)
 	}
-	return nil
+	return binary
 }
 
-// runGoshCommand runs gosh with the given input and returns the output
-func runGoshCommand(input string) (string, error) {
+// runGoshCommand runs the gosh binary with the given input and arguments and
+// returns the output
+func runGoshCommand(binary, input string, args ...string) (string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, "./build/gosh")
+	cmd := exec.CommandContext(ctx, binary, args...)
 	cmd.Stdin = strings.NewReader(input)
 
 	output, err := cmd.CombinedOutput()
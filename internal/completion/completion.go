@@ -12,6 +12,7 @@ import (
 	"strings"
 
 	"gosh/internal/config"
+	"gosh/internal/parser"
 )
 
 const (
@@ -22,6 +23,8 @@ const (
 // Manager handles tab completion functionality
 type Manager struct {
 	config *config.Config
+	// functionNames returns the names of the shell functions defined so far
+	functionNames func() []string
 }
 
 // New creates a new completion manager
@@ -31,6 +34,12 @@ func New(cfg *config.Config) (*Manager, error) {
 	}, nil
 }
 
+// SetFunctionNames sets where the names of shell functions come from, so
+// that they are completed as commands
+func (m *Manager) SetFunctionNames(names func() []string) {
+	m.functionNames = names
+}
+
 // Complete provides completions for the given input
 func (m *Manager) Complete(input string, cursorPos int) ([]string, error) {
 	if !m.config.CompletionEnabled {
@@ -67,11 +76,7 @@ func (m *Manager) completeCommand(prefix string) ([]string, error) {
 	var completions []string
 
 	// Add built-in commands
-	builtins := []string{
-		"cd", "pwd", "exit", "help", "history", "alias", "export",
-	}
-
-	for _, builtin := range builtins {
+	for _, builtin := range parser.BuiltinNames() {
 		if strings.HasPrefix(builtin, prefix) {
 			completions = append(completions, builtin)
 		}
@@ -84,6 +89,15 @@ func (m *Manager) completeCommand(prefix string) ([]string, error) {
 		}
 	}
 
+	// Add shell functions
+	if m.functionNames != nil {
+		for _, name := range m.functionNames() {
+			if strings.HasPrefix(name, prefix) {
+				completions = append(completions, name)
+			}
+		}
+	}
+
 	// Add commands from PATH
 	pathCompletions := m.completeFromPath(prefix)
 	completions = append(completions, pathCompletions...)
@@ -95,12 +109,15 @@ func (m *Manager) completeCommand(prefix string) ([]string, error) {
 	return completions, nil
 }
 
-// completeFromPath finds executable commands in PATH
+// completeFromPath finds executable commands in PATH. It searches the
+// shell's PATH as it is now, so that a PATH set in .goshrc or at the prompt
+// applies as it does when commands run.
 func (m *Manager) completeFromPath(prefix string) []string {
 	var completions []string
 	seen := make(map[string]bool)
 
-	for _, dir := range m.config.PathDirs {
+	path, _ := m.config.Variables.Lookup("PATH")
+	for _, dir := range filepath.SplitList(path) {
 		if dir == "" {
 			continue
 		}
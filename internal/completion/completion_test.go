@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"slices"
 	"sort"
 	"strings"
 	"testing"
@@ -101,6 +102,50 @@ func TestCompleteCommand(t *testing.T) {
 	}
 }
 
+func TestCompleteCommandFunctions(t *testing.T) {
+	mgr, _ := New(config.Default())
+	mgr.SetFunctionNames(func() []string { return []string{"greet", "goodbye"} })
+
+	completions, err := mgr.completeCommand("gre")
+	if err != nil {
+		t.Fatalf("completeCommand() failed: %v", err)
+	}
+	if !slices.Contains(completions, "greet") {
+		t.Errorf("completeCommand() = %v, missing function greet", completions)
+	}
+	if slices.Contains(completions, "goodbye") {
+		t.Errorf("completeCommand() = %v, should not contain goodbye", completions)
+	}
+}
+
+func TestCompleteCommandPath(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "gosh-test-tool"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatalf("WriteFile() failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "gosh-test-data"), nil, 0644); err != nil {
+		t.Fatalf("WriteFile() failed: %v", err)
+	}
+
+	cfg := config.Default()
+	mgr, _ := New(cfg)
+	if completions, _ := mgr.completeCommand("gosh-test-"); len(completions) != 0 {
+		t.Errorf("completeCommand() = %v before PATH changed, want none", completions)
+	}
+
+	// PATH set after startup, as in .goshrc, is searched
+	if err := cfg.Variables.Set("PATH", dir); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	completions, err := mgr.completeCommand("gosh-test-")
+	if err != nil {
+		t.Fatalf("completeCommand() failed: %v", err)
+	}
+	if want := []string{"gosh-test-tool"}; !reflect.DeepEqual(completions, want) {
+		t.Errorf("completeCommand() = %v, want %v", completions, want)
+	}
+}
+
 func TestCompleteFile(t *testing.T) {
 	// Create a temporary directory structure for testing
 	tmpDir := t.TempDir()
@@ -7,6 +7,7 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"os/user"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -24,6 +25,21 @@ type Config struct {
 	Debug       bool   `json:"debug"`
 	ShowWelcome bool   `json:"show_welcome"`
 
+	// Execution settings
+	Errexit   bool `json:"errexit"`
+	Nounset   bool `json:"nounset"`
+	Xtrace    bool `json:"xtrace"`
+	Noexec    bool `json:"noexec"`
+	Noglob    bool `json:"noglob"`
+	Pipefail  bool `json:"pipefail"`
+	Noclobber bool `json:"noclobber"`
+
+	// Globbing settings
+	Globstar bool `json:"globstar"`
+	Nullglob bool `json:"nullglob"`
+	Failglob bool `json:"failglob"`
+	Dotglob  bool `json:"dotglob"`
+
 	// Prompt settings
 	PromptFormat  string `json:"prompt_format"`
 	ShowGitInfo   bool   `json:"show_git_info"`
@@ -47,8 +63,8 @@ type Config struct {
 	GitShowBranch bool `json:"git_show_branch"`
 	GitShowAhead  bool `json:"git_show_ahead"`
 
-	// Environment variables
-	Environment map[string]string `json:"environment"`
+	// Shell variables, seeded from the environment gosh was started with
+	Variables *Variables `json:"-"`
 
 	// Aliases
 	Aliases map[string]string `json:"aliases"`
@@ -90,8 +106,8 @@ func Default() *Config {
 		GitShowBranch: true,
 		GitShowAhead:  true,
 
-		// Environment variables
-		Environment: make(map[string]string),
+		// Shell variables
+		Variables: VariablesFromEnviron(os.Environ()),
 
 		// Aliases
 		Aliases: map[string]string{
@@ -105,32 +121,32 @@ func Default() *Config {
 	}
 }
 
-// Load loads configuration from the specified directory
-func Load(configDir string) (*Config, error) {
-	cfg := Default()
-	cfg.ConfigDir = configDir
+// Clone returns a deep copy of the configuration, used to give a subshell
+// its own variables and aliases
+func (c *Config) Clone() *Config {
+	clone := *c
 
-	// Try to load from various config file locations
-	configFiles := []string{
-		filepath.Join(configDir, "config"),
-		filepath.Join(configDir, "goshrc"),
-	}
+	clone.Variables = c.Variables.Clone()
 
-	// Also check home directory for .goshrc
-	if homeDir, err := os.UserHomeDir(); err == nil {
-		configFiles = append(configFiles, filepath.Join(homeDir, ".goshrc"))
+	clone.Aliases = make(map[string]string, len(c.Aliases))
+	for key, value := range c.Aliases {
+		clone.Aliases[key] = value
 	}
 
-	var loaded bool
-	for _, configFile := range configFiles {
-		if err := cfg.loadFromFile(configFile); err == nil {
-			loaded = true
-			break
-		}
-	}
+	clone.PathDirs = append([]string(nil), c.PathDirs...)
+
+	return &clone
+}
+
+// Load loads the settings file from the specified directory. Startup
+// scripts such as ~/.goshrc are not read here: the shell runs them as
+// scripts once it has started.
+func Load(configDir string) (*Config, error) {
+	cfg := Default()
+	cfg.ConfigDir = configDir
 
-	if !loaded {
-		return nil, os.ErrNotExist
+	if err := cfg.loadFromFile(filepath.Join(configDir, "config")); err != nil {
+		return nil, err
 	}
 
 	return cfg, nil
@@ -207,12 +223,26 @@ func (c *Config) parseExport(line string) error {
 	}
 
 	key := strings.TrimSpace(parts[0])
-	value := strings.Trim(strings.TrimSpace(parts[1]), "\"'")
+	value := parseValue(parts[1])
 
-	c.Environment[key] = value
+	if err := c.Variables.Set(key, value); err != nil {
+		return err
+	}
+	c.Variables.Declare(key, AttrExport)
+	c.ApplyVariable(key, value)
 	return nil
 }
 
+// ApplyVariable applies a GOSH_ variable, such as
+// GOSH_PROMPT_FORMAT, to the matching setting. It reports whether the
+// variable names a setting.
+func (c *Config) ApplyVariable(key, value string) bool {
+	if !strings.HasPrefix(key, "GOSH_") {
+		return false
+	}
+	return c.setConfigValue(strings.TrimPrefix(key, "GOSH_"), value) == nil
+}
+
 // parseAlias parses alias statements
 func (c *Config) parseAlias(line string) error {
 	parts := strings.SplitN(line, "=", KeyValueParts)
@@ -227,15 +257,28 @@ func (c *Config) parseAlias(line string) error {
 	return nil
 }
 
-// parseSet parses set statements for gosh-specific settings
+// parseSet parses set statements: shell options written as for the set
+// built-in, such as set -o pipefail, or gosh-specific settings written
+// set KEY=value
 func (c *Config) parseSet(line string) error {
+	if strings.HasPrefix(line, "-") || strings.HasPrefix(line, "+") {
+		_, replace, err := c.SetFlags(strings.Fields(line))
+		if err != nil {
+			return fmt.Errorf("set: %w", err)
+		}
+		if replace {
+			return fmt.Errorf("invalid set statement: %s", line)
+		}
+		return nil
+	}
+
 	parts := strings.SplitN(line, "=", KeyValueParts)
 	if len(parts) != KeyValueParts {
 		return fmt.Errorf("invalid set statement: %s", line)
 	}
 
 	key := strings.TrimSpace(parts[0])
-	value := strings.Trim(strings.TrimSpace(parts[1]), "\"'")
+	value := parseValue(parts[1])
 
 	return c.setConfigValue(key, value)
 }
@@ -248,16 +291,15 @@ func (c *Config) parseAssignment(line string) error {
 	}
 
 	key := strings.TrimSpace(parts[0])
-	value := strings.Trim(strings.TrimSpace(parts[1]), "\"'")
+	value := parseValue(parts[1])
 
 	// Check if it's a gosh-specific setting
 	if strings.HasPrefix(key, "GOSH_") {
 		return c.setConfigValue(strings.TrimPrefix(key, "GOSH_"), value)
 	}
 
-	// Otherwise, treat as environment variable
-	c.Environment[key] = value
-	return nil
+	// Otherwise, treat as a shell variable
+	return c.Variables.Set(key, value)
 }
 
 // setConfigValue sets a configuration value by key
@@ -301,6 +343,24 @@ func (c *Config) setCoreSettings(key, value string) error {
 	case "SHOW_WELCOME":
 		c.ShowWelcome = parseBool(value)
 		return nil
+	case "PIPEFAIL":
+		c.Pipefail = parseBool(value)
+		return nil
+	case "NOCLOBBER":
+		c.Noclobber = parseBool(value)
+		return nil
+	case "GLOBSTAR":
+		c.Globstar = parseBool(value)
+		return nil
+	case "NULLGLOB":
+		c.Nullglob = parseBool(value)
+		return nil
+	case "FAILGLOB":
+		c.Failglob = parseBool(value)
+		return nil
+	case "DOTGLOB":
+		c.Dotglob = parseBool(value)
+		return nil
 	default:
 		return fmt.Errorf("not a core setting")
 	}
@@ -385,6 +445,52 @@ func (c *Config) setGitSettings(key, value string) error {
 	}
 }
 
+// parseValue removes the quotes around an assigned value. Unquoted values
+// have their tildes expanded, as the shell would.
+func parseValue(raw string) string {
+	value := strings.TrimSpace(raw)
+	if strings.HasPrefix(value, "\"") || strings.HasPrefix(value, "'") {
+		return strings.Trim(value, "\"'")
+	}
+	return ExpandTilde(value)
+}
+
+// ExpandTilde replaces a leading ~ or ~user in value, and in each element of
+// a colon-separated list such as PATH, with the home directory. An element
+// naming an unknown user is left as it is.
+func ExpandTilde(value string) string {
+	elems := strings.Split(value, ":")
+	for i, elem := range elems {
+		if !strings.HasPrefix(elem, "~") {
+			continue
+		}
+
+		end := strings.IndexByte(elem, '/')
+		if end < 0 {
+			end = len(elem)
+		}
+		if home, ok := HomeDir(elem[1:end]); ok {
+			elems[i] = home + elem[end:]
+		}
+	}
+	return strings.Join(elems, ":")
+}
+
+// HomeDir returns the home directory of the named user, or of the current
+// user if name is empty
+func HomeDir(name string) (string, bool) {
+	if name == "" {
+		home, err := os.UserHomeDir()
+		return home, err == nil
+	}
+
+	u, err := user.Lookup(name)
+	if err != nil {
+		return "", false
+	}
+	return u.HomeDir, true
+}
+
 // parseBool parses a boolean value from string
 func parseBool(value string) bool {
 	switch strings.ToLower(value) {
@@ -35,9 +35,14 @@ func TestDefault(t *testing.T) {
 		t.Errorf("Expected alias 'll' to be 'ls -la', got %v", cfg.Aliases["ll"])
 	}
 
-	// Test environment map is initialized
-	if cfg.Environment == nil {
-		t.Error("Expected Environment map to be initialized")
+	// Test variables are imported from the environment
+	if cfg.Variables == nil {
+		t.Fatal("Expected Variables to be initialized")
+	}
+	if path, ok := os.LookupEnv("PATH"); ok {
+		if v, _ := cfg.Variables.Get("PATH"); v.Value != path || !v.Has(AttrExport) {
+			t.Errorf("Expected PATH to be imported as an exported variable, got %+v", v)
+		}
 	}
 }
 
@@ -78,6 +83,10 @@ func TestParseBool(t *testing.T) {
 
 func TestParseExport(t *testing.T) {
 	cfg := Default()
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skipf("no home directory: %v", err)
+	}
 
 	tests := []struct {
 		name     string
@@ -107,6 +116,34 @@ func TestParseExport(t *testing.T) {
 			checkKey: "SHELL",
 			checkVal: "/bin/bash",
 		},
+		{
+			name:     "tilde export",
+			input:    "GOPATH=~/go",
+			wantErr:  false,
+			checkKey: "GOPATH",
+			checkVal: home + "/go",
+		},
+		{
+			name:     "tilde in a path list",
+			input:    "PATH=~/bin:/usr/bin:~",
+			wantErr:  false,
+			checkKey: "PATH",
+			checkVal: home + "/bin:/usr/bin:" + home,
+		},
+		{
+			name:     "quoted tilde export",
+			input:    `NOTES="~/notes"`,
+			wantErr:  false,
+			checkKey: "NOTES",
+			checkVal: "~/notes",
+		},
+		{
+			name:     "unknown user",
+			input:    "X=~nosuchuser-gosh/x",
+			wantErr:  false,
+			checkKey: "X",
+			checkVal: "~nosuchuser-gosh/x",
+		},
 		{
 			name:    "invalid export",
 			input:   "INVALID",
@@ -123,8 +160,8 @@ func TestParseExport(t *testing.T) {
 			}
 
 			if !tt.wantErr {
-				if val, ok := cfg.Environment[tt.checkKey]; !ok || val != tt.checkVal {
-					t.Errorf("Expected Environment[%s] = %s, got %s", tt.checkKey, tt.checkVal, val)
+				if v, _ := cfg.Variables.Get(tt.checkKey); v.Value != tt.checkVal || !v.Has(AttrExport) {
+					t.Errorf("Expected exported %s = %s, got %+v", tt.checkKey, tt.checkVal, v)
 				}
 			}
 		})
@@ -210,6 +247,13 @@ func TestSetConfigValue(t *testing.T) {
 			wantErr: false,
 			check:   func(c *Config) bool { return c.PromptFormat == "%u$ " },
 		},
+		{
+			name:    "set globstar",
+			key:     "GLOBSTAR",
+			value:   "true",
+			wantErr: false,
+			check:   func(c *Config) bool { return c.Globstar },
+		},
 		{
 			name:    "unknown key",
 			key:     "UNKNOWN_KEY",
@@ -258,8 +302,8 @@ GOSH_HISTORY_SIZE=5000
 	}
 
 	// Check that values were loaded correctly
-	if cfg.Environment["TEST_VAR"] != "test_value" {
-		t.Errorf("Expected TEST_VAR=test_value, got %s", cfg.Environment["TEST_VAR"])
+	if value, _ := cfg.Variables.Lookup("TEST_VAR"); value != "test_value" {
+		t.Errorf("Expected TEST_VAR=test_value, got %s", value)
 	}
 
 	if cfg.Aliases["test_alias"] != "echo test" {
@@ -313,6 +357,16 @@ func TestParseLine(t *testing.T) {
 			line:    "GOSH_PROMPT_FORMAT='%u$ '",
 			wantErr: false,
 		},
+		{
+			name:    "set shell options",
+			line:    "set -euo pipefail",
+			wantErr: false,
+		},
+		{
+			name:    "set invalid shell option",
+			line:    "set -o nosuchoption",
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -335,6 +389,21 @@ func TestLoad_NonExistentFile(t *testing.T) {
 	}
 }
 
+func TestExportGoshSetting(t *testing.T) {
+	cfg := Default()
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skipf("no home directory: %v", err)
+	}
+
+	if err := cfg.parseExport("GOSH_HISTORY_FILE=~/.gosh_history"); err != nil {
+		t.Fatalf("parseExport() failed: %v", err)
+	}
+	if want := home + "/.gosh_history"; cfg.HistoryFile != want {
+		t.Errorf("HistoryFile = %q, want %q", cfg.HistoryFile, want)
+	}
+}
+
 func TestParseAssignment(t *testing.T) {
 	cfg := Default()
 
@@ -369,3 +438,27 @@ func TestParseAssignment(t *testing.T) {
 		})
 	}
 }
+
+func TestClone(t *testing.T) {
+	cfg := Default()
+	_ = cfg.Variables.Set("SHARED", "original")
+
+	clone := cfg.Clone()
+	_ = clone.Variables.Set("SHARED", "changed")
+	clone.Aliases["new"] = "echo new"
+	clone.PathDirs[0] = "/changed"
+	clone.Debug = true
+
+	if value, _ := cfg.Variables.Lookup("SHARED"); value != "original" {
+		t.Errorf("Clone shares Variables with the original")
+	}
+	if _, ok := cfg.Aliases["new"]; ok {
+		t.Errorf("Clone shares Aliases with the original")
+	}
+	if len(cfg.PathDirs) > 0 && cfg.PathDirs[0] == "/changed" {
+		t.Errorf("Clone shares PathDirs with the original")
+	}
+	if cfg.Debug {
+		t.Errorf("Clone shares settings with the original")
+	}
+}
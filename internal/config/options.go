@@ -0,0 +1,160 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// OptionKind tells which built-in manages a shell option
+type OptionKind int
+
+const (
+	// OptionSet is an option of set -o, which may also have a letter
+	OptionSet OptionKind = iota
+	// OptionShopt is an option of shopt
+	OptionShopt
+)
+
+// Option describes a shell option
+type Option struct {
+	Name string
+	// Letter turns the option on with set -x style arguments and shows it
+	// in $-, or is 0 if it has none
+	Letter byte
+	Kind   OptionKind
+}
+
+// Options lists the shell options of each kind in the order they are
+// printed
+var Options = []Option{
+	{Name: "errexit", Letter: 'e'},
+	{Name: "noclobber", Letter: 'C'},
+	{Name: "noexec", Letter: 'n'},
+	{Name: "noglob", Letter: 'f'},
+	{Name: "nounset", Letter: 'u'},
+	{Name: "pipefail"},
+	{Name: "xtrace", Letter: 'x'},
+	{Name: "dotglob", Kind: OptionShopt},
+	{Name: "failglob", Kind: OptionShopt},
+	{Name: "globstar", Kind: OptionShopt},
+	{Name: "nullglob", Kind: OptionShopt},
+}
+
+// LookupOption finds the option of the given kind called name
+func LookupOption(kind OptionKind, name string) (Option, bool) {
+	for _, opt := range Options {
+		if opt.Kind == kind && opt.Name == name {
+			return opt, true
+		}
+	}
+	return Option{}, false
+}
+
+// lookupLetter finds the option set with the given letter
+func lookupLetter(letter byte) (Option, bool) {
+	for _, opt := range Options {
+		if opt.Letter != 0 && opt.Letter == letter {
+			return opt, true
+		}
+	}
+	return Option{}, false
+}
+
+// optionField returns the setting that holds the option called name, or
+// nil if there is no such option
+func (c *Config) optionField(name string) *bool {
+	switch name {
+	case "errexit":
+		return &c.Errexit
+	case "noclobber":
+		return &c.Noclobber
+	case "noexec":
+		return &c.Noexec
+	case "noglob":
+		return &c.Noglob
+	case "nounset":
+		return &c.Nounset
+	case "pipefail":
+		return &c.Pipefail
+	case "xtrace":
+		return &c.Xtrace
+	case "dotglob":
+		return &c.Dotglob
+	case "failglob":
+		return &c.Failglob
+	case "globstar":
+		return &c.Globstar
+	case "nullglob":
+		return &c.Nullglob
+	default:
+		return nil
+	}
+}
+
+// OptionEnabled reports whether the option called name is on
+func (c *Config) OptionEnabled(name string) bool {
+	field := c.optionField(name)
+	return field != nil && *field
+}
+
+// SetOption turns the option called name on or off
+func (c *Config) SetOption(name string, on bool) error {
+	field := c.optionField(name)
+	if field == nil {
+		return fmt.Errorf("%s: invalid option name", name)
+	}
+	*field = on
+	return nil
+}
+
+// OptionLetters returns the letters of the options that are on, as $-
+// shows them
+func (c *Config) OptionLetters() string {
+	var b strings.Builder
+	for _, opt := range Options {
+		if opt.Letter != 0 && c.OptionEnabled(opt.Name) {
+			b.WriteByte(opt.Letter)
+		}
+	}
+	return b.String()
+}
+
+// SetFlags applies the options at the start of the arguments of set: -e
+// turns errexit on and +e off, letters may be combined as in -eu, and
+// -o name or +o name names an option. It returns the arguments that
+// follow the options, which become the positional parameters if replace
+// is set: when there are any, or when -- ends the options.
+func (c *Config) SetFlags(args []string) (rest []string, replace bool, err error) {
+	for len(args) > 0 {
+		arg := args[0]
+		if arg == "--" {
+			return args[1:], true, nil
+		}
+		if len(arg) < 2 || (arg[0] != '-' && arg[0] != '+') {
+			return args, true, nil
+		}
+		args = args[1:]
+
+		on := arg[0] == '-'
+		for i := 1; i < len(arg); i++ {
+			if arg[i] != 'o' {
+				opt, ok := lookupLetter(arg[i])
+				if !ok {
+					return nil, false, fmt.Errorf("%c%c: invalid option", arg[0], arg[i])
+				}
+				*c.optionField(opt.Name) = on
+				continue
+			}
+
+			if len(args) == 0 {
+				return nil, false, fmt.Errorf("%co: option requires an argument", arg[0])
+			}
+			if _, ok := LookupOption(OptionSet, args[0]); !ok {
+				return nil, false, fmt.Errorf("%s: invalid option name", args[0])
+			}
+			*c.optionField(args[0]) = on
+			args = args[1:]
+		}
+	}
+	return nil, false, nil
+}
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSetFlags(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		wantRest    []string
+		wantReplace bool
+		wantOn      []string
+		wantLetters string
+		wantErr     bool
+	}{
+		{
+			name:        "single letter",
+			args:        []string{"-e"},
+			wantOn:      []string{"errexit"},
+			wantLetters: "e",
+		},
+		{
+			name:        "combined letters and a named option",
+			args:        []string{"-euo", "pipefail"},
+			wantOn:      []string{"errexit", "nounset", "pipefail"},
+			wantLetters: "eu",
+		},
+		{
+			name:        "plus turns an option off",
+			args:        []string{"-ex", "+e"},
+			wantOn:      []string{"xtrace"},
+			wantLetters: "x",
+		},
+		{
+			name:        "arguments after the options",
+			args:        []string{"-f", "a", "-b"},
+			wantRest:    []string{"a", "-b"},
+			wantReplace: true,
+			wantOn:      []string{"noglob"},
+			wantLetters: "f",
+		},
+		{
+			name:        "double dash",
+			args:        []string{"-n", "--"},
+			wantRest:    []string{},
+			wantReplace: true,
+			wantOn:      []string{"noexec"},
+			wantLetters: "n",
+		},
+		{
+			name:    "unknown letter",
+			args:    []string{"-q"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown name",
+			args:    []string{"-o", "nosuchoption"},
+			wantErr: true,
+		},
+		{
+			name:    "missing name",
+			args:    []string{"+o"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			rest, replace, err := cfg.SetFlags(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SetFlags() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if replace != tt.wantReplace || !slices.Equal(rest, tt.wantRest) {
+				t.Errorf("SetFlags() = %q, %v, want %q, %v", rest, replace, tt.wantRest, tt.wantReplace)
+			}
+			for _, opt := range Options {
+				if opt.Kind != OptionSet || opt.Name == "noclobber" {
+					continue
+				}
+				if got, want := cfg.OptionEnabled(opt.Name), slices.Contains(tt.wantOn, opt.Name); got != want {
+					t.Errorf("%s = %v, want %v", opt.Name, got, want)
+				}
+			}
+			if got := cfg.OptionLetters(); got != tt.wantLetters {
+				t.Errorf("OptionLetters() = %q, want %q", got, tt.wantLetters)
+			}
+		})
+	}
+}
+
+func TestSetOption(t *testing.T) {
+	cfg := Default()
+	if err := cfg.SetOption("nullglob", true); err != nil {
+		t.Fatalf("SetOption() error = %v", err)
+	}
+	if !cfg.Nullglob || !cfg.OptionEnabled("nullglob") {
+		t.Error("SetOption() did not turn nullglob on")
+	}
+	if err := cfg.SetOption("nosuchoption", true); err == nil {
+		t.Error("SetOption() accepted an unknown option")
+	}
+}
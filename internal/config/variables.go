@@ -0,0 +1,322 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Attr is a set of variable attributes, as given by declare
+type Attr uint8
+
+const (
+	// AttrExport passes the variable to the environment of commands
+	AttrExport Attr = 1 << iota
+	// AttrReadOnly prevents the variable from being assigned or unset
+	AttrReadOnly
+	// AttrInteger evaluates values assigned to the variable as arithmetic
+	AttrInteger
+	// AttrArray marks the variable as an indexed array
+	AttrArray
+	// AttrAssoc marks the variable as an associative array
+	AttrAssoc
+)
+
+// Variable is a shell variable and its attributes
+type Variable struct {
+	Value string
+	Attrs Attr
+	// Set is false for a variable that was given attributes, as with
+	// export NAME, but no value
+	Set bool
+	// Elements holds the elements of an array by key. The keys of an
+	// indexed array are decimal numbers.
+	Elements map[string]string
+}
+
+// IsArray reports whether the variable is an indexed or associative array
+func (v Variable) IsArray() bool {
+	return v.Attrs&(AttrArray|AttrAssoc) != 0
+}
+
+// Keys returns the keys of an array's elements, in numeric order for an
+// indexed array and sorted for an associative one. A set variable that is
+// not an array has the single key 0.
+func (v Variable) Keys() []string {
+	if !v.IsArray() {
+		if v.Set {
+			return []string{"0"}
+		}
+		return nil
+	}
+
+	keys := make([]string, 0, len(v.Elements))
+	for key := range v.Elements {
+		keys = append(keys, key)
+	}
+	if v.Has(AttrAssoc) {
+		sort.Strings(keys)
+	} else {
+		sort.Slice(keys, func(i, j int) bool {
+			a, _ := strconv.Atoi(keys[i])
+			b, _ := strconv.Atoi(keys[j])
+			return a < b
+		})
+	}
+	return keys
+}
+
+// Values returns the values of an array's elements in the order of Keys.
+// A set variable that is not an array has its value as the only element.
+func (v Variable) Values() []string {
+	keys := v.Keys()
+	values := make([]string, len(keys))
+	for i, key := range keys {
+		values[i], _ = v.Element(key)
+	}
+	return values
+}
+
+// Element returns the element of an array with the given key. A variable
+// that is not an array has its value as element 0.
+func (v Variable) Element(key string) (string, bool) {
+	if !v.IsArray() {
+		return v.Value, v.Set && key == "0"
+	}
+	value, ok := v.Elements[key]
+	return value, ok
+}
+
+// copy returns the variable with its own copy of the elements
+func (v Variable) copy() Variable {
+	if v.Elements != nil {
+		v.Elements = maps.Clone(v.Elements)
+	}
+	return v
+}
+
+// Has reports whether the variable has all of the attributes in attrs
+func (v Variable) Has(attrs Attr) bool {
+	return v.Attrs&attrs == attrs
+}
+
+// ReadOnlyError is returned when a read-only variable is assigned or unset
+type ReadOnlyError struct {
+	Name string
+}
+
+// Error implements the error interface for ReadOnlyError
+func (e *ReadOnlyError) Error() string {
+	return fmt.Sprintf("%s: readonly variable", e.Name)
+}
+
+// Variables holds the shell variables. Only exported variables reach the
+// environment of the commands the shell runs.
+type Variables struct {
+	vars map[string]Variable
+}
+
+// NewVariables creates an empty variable store
+func NewVariables() *Variables {
+	return &Variables{vars: make(map[string]Variable)}
+}
+
+// VariablesFromEnviron creates a variable store holding the entries of an
+// environment, in the key=value form of os.Environ, as exported variables
+func VariablesFromEnviron(environ []string) *Variables {
+	v := NewVariables()
+	for _, entry := range environ {
+		name, value, ok := strings.Cut(entry, "=")
+		if !ok || name == "" {
+			continue
+		}
+		v.vars[name] = Variable{Value: value, Attrs: AttrExport, Set: true}
+	}
+	return v
+}
+
+// Clone returns a copy of the variables that can be changed independently
+func (v *Variables) Clone() *Variables {
+	clone := NewVariables()
+	for name, variable := range v.vars {
+		clone.vars[name] = variable.copy()
+	}
+	return clone
+}
+
+// Lookup returns the value of a variable and reports whether it is set. The
+// value of an array is its element 0.
+func (v *Variables) Lookup(name string) (string, bool) {
+	variable, ok := v.vars[name]
+	if !ok || !variable.Set {
+		return "", false
+	}
+	return variable.Element("0")
+}
+
+// Get returns a variable with its attributes and reports whether it exists,
+// with or without a value. The variable may be kept and restored later.
+func (v *Variables) Get(name string) (Variable, bool) {
+	variable, ok := v.vars[name]
+	return variable.copy(), ok
+}
+
+// Set assigns a value to a variable, keeping its attributes. Assigning to
+// an array sets its element 0.
+func (v *Variables) Set(name, value string) error {
+	if v.vars[name].IsArray() {
+		return v.SetElement(name, "0", value)
+	}
+
+	variable := v.vars[name]
+	if variable.Has(AttrReadOnly) {
+		return &ReadOnlyError{Name: name}
+	}
+	variable.Value = value
+	variable.Set = true
+	v.vars[name] = variable
+	return nil
+}
+
+// SetElement assigns a value to the element of an array with the given
+// key. A variable that is not an array becomes an indexed array, with its
+// value as element 0.
+func (v *Variables) SetElement(name, key, value string) error {
+	variable := v.vars[name]
+	if variable.Has(AttrReadOnly) {
+		return &ReadOnlyError{Name: name}
+	}
+	if !variable.IsArray() {
+		variable = toArray(variable)
+	}
+	if variable.Elements == nil {
+		variable.Elements = make(map[string]string)
+	}
+	variable.Elements[key] = value
+	variable.Set = true
+	v.vars[name] = variable
+	return nil
+}
+
+// SetArray replaces the elements of an array. A variable that is not an
+// array becomes an indexed array.
+func (v *Variables) SetArray(name string, elements map[string]string) error {
+	variable := v.vars[name]
+	if variable.Has(AttrReadOnly) {
+		return &ReadOnlyError{Name: name}
+	}
+	if !variable.IsArray() {
+		variable.Attrs |= AttrArray
+	}
+	variable.Value = ""
+	variable.Elements = maps.Clone(elements)
+	if variable.Elements == nil {
+		variable.Elements = make(map[string]string)
+	}
+	variable.Set = true
+	v.vars[name] = variable
+	return nil
+}
+
+// UnsetElement removes the element of an array with the given key
+func (v *Variables) UnsetElement(name, key string) error {
+	variable, ok := v.vars[name]
+	if !ok {
+		return nil
+	}
+	if variable.Has(AttrReadOnly) {
+		return &ReadOnlyError{Name: name}
+	}
+	if !variable.IsArray() {
+		if key == "0" {
+			delete(v.vars, name)
+		}
+		return nil
+	}
+	delete(variable.Elements, key)
+	return nil
+}
+
+// toArray turns a variable that is not an array into an indexed array
+// holding its value as element 0
+func toArray(variable Variable) Variable {
+	variable.Elements = make(map[string]string)
+	if variable.Set {
+		variable.Elements["0"] = variable.Value
+	}
+	variable.Value = ""
+	variable.Attrs |= AttrArray
+	return variable
+}
+
+// Declare adds attributes to a variable, creating it without a value if it
+// does not exist. A variable given the array attributes becomes an array.
+func (v *Variables) Declare(name string, attrs Attr) {
+	variable := v.vars[name]
+	if attrs&(AttrArray|AttrAssoc) != 0 && !variable.IsArray() {
+		variable = toArray(variable)
+		variable.Attrs &^= AttrArray
+	}
+	variable.Attrs |= attrs
+	v.vars[name] = variable
+}
+
+// Undeclare removes attributes from a variable. The read-only attribute
+// cannot be removed.
+func (v *Variables) Undeclare(name string, attrs Attr) error {
+	variable, ok := v.vars[name]
+	if !ok {
+		return nil
+	}
+	if attrs&AttrReadOnly != 0 && variable.Has(AttrReadOnly) {
+		return &ReadOnlyError{Name: name}
+	}
+	variable.Attrs &^= attrs
+	v.vars[name] = variable
+	return nil
+}
+
+// Unset removes a variable and its attributes
+func (v *Variables) Unset(name string) error {
+	if v.vars[name].Has(AttrReadOnly) {
+		return &ReadOnlyError{Name: name}
+	}
+	delete(v.vars, name)
+	return nil
+}
+
+// Restore puts back a variable saved with Get, or removes it if it did not
+// exist, regardless of its attributes. It undoes temporary assignments.
+func (v *Variables) Restore(name string, saved Variable, ok bool) {
+	if ok {
+		v.vars[name] = saved
+	} else {
+		delete(v.vars, name)
+	}
+}
+
+// Names returns the names of all variables in order
+func (v *Variables) Names() []string {
+	names := make([]string, 0, len(v.vars))
+	for name := range v.vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Environ returns the exported variables that are set, in the key=value
+// form used for the environment of a process, sorted by name. It is never
+// nil, since a nil environment makes a process inherit that of gosh.
+func (v *Variables) Environ() []string {
+	environ := []string{}
+	for _, name := range v.Names() {
+		// Arrays cannot be passed in the environment
+		if variable := v.vars[name]; variable.Set && variable.Has(AttrExport) && !variable.IsArray() {
+			environ = append(environ, name+"="+variable.Value)
+		}
+	}
+	return environ
+}
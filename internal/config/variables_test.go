@@ -0,0 +1,164 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestVariablesFromEnviron(t *testing.T) {
+	vars := VariablesFromEnviron([]string{"HOME=/home/gosh", "EMPTY=", "EQ=a=b", "invalid"})
+
+	tests := []struct {
+		name  string
+		value string
+		ok    bool
+	}{
+		{"HOME", "/home/gosh", true},
+		{"EMPTY", "", true},
+		{"EQ", "a=b", true},
+		{"invalid", "", false},
+	}
+	for _, tt := range tests {
+		value, ok := vars.Lookup(tt.name)
+		if value != tt.value || ok != tt.ok {
+			t.Errorf("Lookup(%q) = %q, %v, want %q, %v", tt.name, value, ok, tt.value, tt.ok)
+		}
+	}
+
+	want := []string{"EMPTY=", "EQ=a=b", "HOME=/home/gosh"}
+	if got := vars.Environ(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Environ() = %q, want %q", got, want)
+	}
+}
+
+func TestVariablesEnviron(t *testing.T) {
+	vars := NewVariables()
+	_ = vars.Set("LOCAL", "shell only")
+	_ = vars.Set("SHARED", "passed on")
+	vars.Declare("SHARED", AttrExport)
+	vars.Declare("DECLARED", AttrExport)
+
+	want := []string{"SHARED=passed on"}
+	if got := vars.Environ(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Environ() = %q, want %q", got, want)
+	}
+
+	if _, ok := vars.Lookup("DECLARED"); ok {
+		t.Error("Lookup(DECLARED) reports a variable without a value as set")
+	}
+	if _, ok := vars.Get("DECLARED"); !ok {
+		t.Error("Get(DECLARED) does not report the declared variable")
+	}
+
+	if err := vars.Undeclare("SHARED", AttrExport); err != nil {
+		t.Fatalf("Undeclare() failed: %v", err)
+	}
+	if got := vars.Environ(); len(got) != 0 {
+		t.Errorf("Environ() after Undeclare = %q, want nothing", got)
+	}
+}
+
+func TestVariablesReadOnly(t *testing.T) {
+	vars := NewVariables()
+	_ = vars.Set("CONST", "1")
+	vars.Declare("CONST", AttrReadOnly)
+
+	var roErr *ReadOnlyError
+	if err := vars.Set("CONST", "2"); !errors.As(err, &roErr) || roErr.Name != "CONST" {
+		t.Errorf("Set() error = %v, want a ReadOnlyError", err)
+	}
+	if err := vars.Unset("CONST"); !errors.As(err, &roErr) {
+		t.Errorf("Unset() error = %v, want a ReadOnlyError", err)
+	}
+	if err := vars.Undeclare("CONST", AttrReadOnly); !errors.As(err, &roErr) {
+		t.Errorf("Undeclare() error = %v, want a ReadOnlyError", err)
+	}
+	if value, _ := vars.Lookup("CONST"); value != "1" {
+		t.Errorf("CONST = %q, want 1", value)
+	}
+}
+
+func TestVariablesRestore(t *testing.T) {
+	vars := NewVariables()
+	_ = vars.Set("X", "outer")
+	vars.Declare("X", AttrReadOnly)
+
+	saved, ok := vars.Get("X")
+	_ = vars.Unset("Y")
+	savedY, okY := vars.Get("Y")
+	vars.Restore("X", Variable{Value: "temporary", Set: true}, true)
+	_ = vars.Set("Y", "temporary")
+
+	vars.Restore("X", saved, ok)
+	vars.Restore("Y", savedY, okY)
+
+	if v, _ := vars.Get("X"); v.Value != "outer" || !v.Has(AttrReadOnly) {
+		t.Errorf("X = %+v, want the read-only outer value", v)
+	}
+	if _, ok := vars.Get("Y"); ok {
+		t.Error("Y still exists after Restore")
+	}
+}
+
+func TestVariablesClone(t *testing.T) {
+	vars := NewVariables()
+	_ = vars.Set("A", "1")
+	_ = vars.SetArray("ARR", map[string]string{"0": "a"})
+
+	clone := vars.Clone()
+	_ = clone.Set("A", "2")
+	_ = clone.Set("B", "3")
+	_ = clone.SetElement("ARR", "0", "b")
+
+	if value, _ := vars.Lookup("A"); value != "1" {
+		t.Errorf("A = %q after changing the clone, want 1", value)
+	}
+	if value, _ := vars.Lookup("ARR"); value != "a" {
+		t.Errorf("ARR = %q after changing the clone, want a", value)
+	}
+	if want := []string{"A", "ARR"}; !reflect.DeepEqual(vars.Names(), want) {
+		t.Errorf("Names() = %q, want %q", vars.Names(), want)
+	}
+}
+
+func TestVariablesArrays(t *testing.T) {
+	vars := NewVariables()
+	_ = vars.Set("S", "scalar")
+	_ = vars.SetElement("S", "2", "two")
+	_ = vars.SetElement("S", "10", "ten")
+
+	v, _ := vars.Get("S")
+	if !v.Has(AttrArray) {
+		t.Errorf("S attributes = %v, want an indexed array", v.Attrs)
+	}
+	if want := []string{"0", "2", "10"}; !reflect.DeepEqual(v.Keys(), want) {
+		t.Errorf("Keys() = %q, want %q", v.Keys(), want)
+	}
+	if want := []string{"scalar", "two", "ten"}; !reflect.DeepEqual(v.Values(), want) {
+		t.Errorf("Values() = %q, want %q", v.Values(), want)
+	}
+	if value, _ := vars.Lookup("S"); value != "scalar" {
+		t.Errorf("Lookup(S) = %q, want element 0", value)
+	}
+
+	_ = vars.UnsetElement("S", "0")
+	if _, ok := vars.Lookup("S"); ok {
+		t.Error("Lookup(S) is set after unsetting element 0")
+	}
+
+	vars.Declare("M", AttrAssoc)
+	_ = vars.SetArray("M", map[string]string{"b": "2", "a": "1"})
+	m, _ := vars.Get("M")
+	if m.Has(AttrArray) || !m.Has(AttrAssoc) {
+		t.Errorf("M attributes = %v, want an associative array", m.Attrs)
+	}
+	if want := []string{"a", "b"}; !reflect.DeepEqual(m.Keys(), want) {
+		t.Errorf("Keys() = %q, want %q", m.Keys(), want)
+	}
+
+	vars.Declare("S", AttrExport)
+	if environ := vars.Environ(); len(environ) != 0 {
+		t.Errorf("Environ() = %q, want no arrays", environ)
+	}
+}
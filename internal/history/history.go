@@ -212,6 +212,15 @@ func (m *Manager) Clear() error {
 	return nil
 }
 
+// Save writes the history to the configured file, if history is saved, so
+// that nothing is lost when the shell exits
+func (m *Manager) Save() error {
+	if !m.config.SaveHistory {
+		return nil
+	}
+	return m.save()
+}
+
 // load loads history from the configured file
 func (m *Manager) load() error {
 	if m.config.HistoryFile == "" {
@@ -398,6 +398,40 @@ func TestSaveAndLoad(t *testing.T) {
 	}
 }
 
+func TestSave(t *testing.T) {
+	tmpDir := t.TempDir()
+	historyFile := filepath.Join(tmpDir, "test_history")
+
+	cfg := config.Default()
+	cfg.SaveHistory = false
+	cfg.HistoryFile = historyFile
+	mgr, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	mgr.Add("ls")
+
+	// Nothing is written while history is not saved
+	if err := mgr.Save(); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+	if _, err := os.Stat(historyFile); !os.IsNotExist(err) {
+		t.Fatalf("history file exists with SaveHistory off: %v", err)
+	}
+
+	cfg.SaveHistory = true
+	if err := mgr.Save(); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+	mgr2, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() for loading failed: %v", err)
+	}
+	if entries := mgr2.GetAll(); len(entries) != 1 || entries[0].Command != "ls" {
+		t.Errorf("Loaded entries %v, want [ls]", entries)
+	}
+}
+
 func TestGetStats(t *testing.T) {
 	cfg := config.Default()
 	cfg.SaveHistory = false
@@ -0,0 +1,804 @@
+// Package jobs provides job control for gosh.
+// It keeps the table of background and stopped jobs, runs the processes of
+// each job in their own process group and hands the terminal to the job in
+// the foreground.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+)
+
+// State is the run state of a job
+type State int
+
+const (
+	// Running jobs have processes that are executing
+	Running State = iota
+	// Stopped jobs were suspended, for example with Ctrl+Z
+	Stopped
+	// Done jobs have finished
+	Done
+)
+
+// String returns the name of the state as shown by the jobs built-in
+func (s State) String() string {
+	switch s {
+	case Running:
+		return "Running"
+	case Stopped:
+		return "Stopped"
+	default:
+		return "Done"
+	}
+}
+
+// syntheticPidBase is above the largest process ID Linux hands out. Jobs
+// that finish without starting a process, such as a background built-in,
+// are given an ID above it so that $! and wait can still name them.
+const syntheticPidBase = 1 << 22
+
+// syntheticPids counts the IDs given to jobs without a process
+var syntheticPids atomic.Int64
+
+// ErrNoJobControl is returned by operations that need job control when the
+// shell does not have a terminal
+var ErrNoJobControl = errors.New("no job control")
+
+// Job is a command line run by the shell, in the foreground or background
+type Job struct {
+	// ID is the job number, assigned when the job enters the table
+	ID int
+	// Command is the command line as the user wrote it
+	Command string
+
+	manager *Manager
+
+	mu         sync.Mutex
+	state      State
+	pgid       int
+	pids       []int
+	foreground bool
+	status     int
+	err        error
+	// changed marks a state change not yet reported to the user
+	changed bool
+	// inShell marks a job whose commands the shell runs itself, rather than
+	// a copy of it. Its processes that stop move to a job of their own, so
+	// that the rest of its commands never go on in the background.
+	inShell bool
+	// detached is the job its stopped processes last moved to
+	detached *Job
+	// live counts the processes moved to the job that have not exited
+	live int
+	// killedBy is the signal that interrupted the job's commands, if any
+	killedBy os.Signal
+	// interrupt cancels the context the job's commands run with
+	interrupt context.CancelFunc
+
+	done      chan struct{}
+	stopped   chan struct{}
+	started   chan struct{}
+	startOnce sync.Once
+}
+
+// State returns the current state of the job
+func (j *Job) State() State {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.state
+}
+
+// Pgid returns the process group of the job, or 0 if it has none
+func (j *Job) Pgid() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.pgid
+}
+
+// Pid returns the most recently started process of the job. A job that
+// finished without starting one has an ID of its own; one that is still
+// running without a process has 0.
+func (j *Job) Pid() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if len(j.pids) == 0 {
+		return 0
+	}
+	return j.pids[len(j.pids)-1]
+}
+
+// Done returns a channel that is closed when the job finishes
+func (j *Job) Done() <-chan struct{} {
+	return j.done
+}
+
+// Started returns a channel that is closed once the job has started its
+// first process, or has finished without starting one
+func (j *Job) Started() <-chan struct{} {
+	return j.started
+}
+
+// Signal sends sig to the job: to its process group with job control, and
+// otherwise to each process it started. A signal that ends a process by
+// default also interrupts the commands the shell runs for the job, and a
+// stopped job is continued so that it can act on the signal.
+func (j *Job) Signal(sig os.Signal) error {
+	j.mu.Lock()
+	state, pgid, pids, interrupt := j.state, j.pgid, slices.Clone(j.pids), j.interrupt
+	j.mu.Unlock()
+
+	if state == Done {
+		return errors.New("job has terminated")
+	}
+	if pgid != 0 {
+		if err := signalGroup(pgid, sig); err != nil {
+			return err
+		}
+		if state == Stopped {
+			_ = continueGroup(pgid)
+		}
+	} else {
+		for _, pid := range pids {
+			if process, err := os.FindProcess(pid); err == nil {
+				_ = process.Signal(sig)
+			}
+		}
+	}
+
+	if terminates(sig) && interrupt != nil {
+		j.mu.Lock()
+		j.killedBy = sig
+		j.mu.Unlock()
+		interrupt()
+	}
+	return nil
+}
+
+// Result returns the exit status and error of the job's commands once it
+// is done
+func (j *Job) Result() (int, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.status, j.err
+}
+
+// Run starts the process built by build as part of the job and waits for
+// it to exit. With job control the process joins the job's process group,
+// and stops are recorded on the job while the wait goes on. A nil job runs
+// the process on its own.
+func (j *Job) Run(build func() *exec.Cmd) (syscall.WaitStatus, error) {
+	var status syscall.WaitStatus
+
+	if j == nil || !j.manager.JobControl() {
+		cmd := build()
+		if err := cmd.Start(); err != nil {
+			return status, err
+		}
+		if j != nil {
+			j.addProcess(cmd.Process.Pid)
+		}
+
+		err := cmd.Wait()
+		if cmd.ProcessState == nil {
+			return status, err
+		}
+		status, _ = cmd.ProcessState.Sys().(syscall.WaitStatus)
+		return status, nil
+	}
+
+	// A process that moves to a job of its own outlives the commands that
+	// started it, so it is not interrupted as they end
+	var detached atomic.Bool
+	cmd, pgid, err := j.start(func() *exec.Cmd {
+		cmd := build()
+		if cancel := cmd.Cancel; cancel != nil {
+			cmd.Cancel = func() error {
+				if detached.Load() {
+					return nil
+				}
+				return cancel()
+			}
+		}
+		return cmd
+	})
+	if err != nil {
+		return status, err
+	}
+
+	var moved *Job
+	status, err = waitProcess(cmd.Process.Pid, func() bool {
+		if !j.inShell {
+			j.markStopped()
+			return true
+		}
+		detached.Store(true)
+		moved = j.detach(cmd.Process.Pid, pgid)
+		return false
+	})
+	if moved != nil {
+		// The stopped process is waited for as part of the job it moved to
+		go moved.reap(cmd)
+		return status, nil
+	}
+
+	// The process has been reaped already; Wait only releases what cmd
+	// holds, such as the goroutines copying its output
+	_ = cmd.Wait()
+	return status, err
+}
+
+// start starts a process in the job's process group and returns it with
+// the group. The first process leads the group and, for a foreground job,
+// takes the terminal.
+func (j *Job) start(build func() *exec.Cmd) (*exec.Cmd, int, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.pgid != 0 {
+		cmd := build()
+		setProcessGroup(cmd, j.pgid, false, j.manager.tty)
+		err := cmd.Start()
+		if err == nil {
+			j.addProcessLocked(cmd.Process.Pid)
+			return cmd, j.pgid, nil
+		}
+		if !errors.Is(err, syscall.EPERM) {
+			return nil, 0, err
+		}
+		// The group ended with its last process, so this one leads a new one
+	}
+
+	cmd := build()
+	setProcessGroup(cmd, 0, j.foreground, j.manager.tty)
+	if err := cmd.Start(); err != nil {
+		return nil, 0, err
+	}
+	j.pgid = cmd.Process.Pid
+	j.addProcessLocked(cmd.Process.Pid)
+	return cmd, j.pgid, nil
+}
+
+// detach moves the stopped process pid of the group pgid to a stopped job
+// of its own, as other shells do, and returns that job. The other processes
+// of the group join the same job, the next process of j starts a new group
+// and the shell takes the terminal back.
+func (j *Job) detach(pid, pgid int) *Job {
+	j.mu.Lock()
+	moved := j.detached
+	added := moved == nil || moved.pgid != pgid
+	if added {
+		moved = j.manager.newJob(j.Command)
+		moved.pgid = pgid
+		moved.state = Stopped
+		moved.changed = true
+		// Ctrl+C reaches its processes from the terminal
+		moved.interrupt = func() {}
+		j.detached = moved
+	}
+	if j.pgid == pgid {
+		j.pgid = 0
+	}
+	j.mu.Unlock()
+
+	moved.mu.Lock()
+	moved.live++
+	moved.addProcessLocked(pid)
+	moved.mu.Unlock()
+
+	if added {
+		j.manager.add(moved)
+		j.manager.reclaimTerminal()
+	}
+	return moved
+}
+
+// reap waits for a process that moved to the job to exit. The job finishes
+// with its last process, with the status of the one started last.
+func (j *Job) reap(cmd *exec.Cmd) {
+	pid := cmd.Process.Pid
+	status, err := waitProcess(pid, func() bool {
+		j.markStopped()
+		return true
+	})
+	_ = cmd.Wait()
+
+	j.mu.Lock()
+	j.live--
+	if j.pids[len(j.pids)-1] == pid {
+		j.status = exitCode(status)
+	}
+	last, code := j.live == 0, j.status
+	j.mu.Unlock()
+
+	if last {
+		j.finish(code, err)
+	}
+}
+
+// exitCode returns the status of a finished process, 128+N for one killed
+// by signal N
+func exitCode(status syscall.WaitStatus) int {
+	if status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return status.ExitStatus()
+}
+
+// addProcess records a started process of the job
+func (j *Job) addProcess(pid int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.addProcessLocked(pid)
+}
+
+// addProcessLocked records a started process; j.mu must be held
+func (j *Job) addProcessLocked(pid int) {
+	j.pids = append(j.pids, pid)
+	j.startOnce.Do(func() { close(j.started) })
+}
+
+// markStopped records that a process of the job was stopped
+func (j *Job) markStopped() {
+	j.mu.Lock()
+	if j.state == Running {
+		j.state = Stopped
+		j.changed = true
+	}
+	j.mu.Unlock()
+
+	select {
+	case j.stopped <- struct{}{}:
+	default:
+	}
+}
+
+// finish records the result of the job's commands. Commands interrupted
+// by a signal sent to the job end with status 128+N for signal N.
+func (j *Job) finish(status int, err error) {
+	j.mu.Lock()
+	if n, ok := j.killedBy.(syscall.Signal); ok && errors.Is(err, context.Canceled) {
+		status, err = 128+int(n), nil
+	}
+	if len(j.pids) == 0 {
+		j.pids = append(j.pids, syntheticPidBase+int(syntheticPids.Add(1)))
+	}
+	j.state = Done
+	j.status = status
+	j.err = err
+	j.changed = true
+	j.mu.Unlock()
+
+	j.startOnce.Do(func() { close(j.started) })
+	close(j.done)
+}
+
+// Manager keeps the job table and controls the terminal
+type Manager struct {
+	mu sync.Mutex
+	// jobs is ordered by use: the current job is last, the previous one
+	// before it
+	jobs []*Job
+	// last is the most recently started background job, for $!
+	last *Job
+	// tty is the controlling terminal, or -1 without job control
+	tty       int
+	shellPgid int
+	// foreground is the job the shell is waiting for, if any
+	foreground *Job
+}
+
+// New creates a job manager with job control disabled
+func New() *Manager {
+	return &Manager{tty: -1}
+}
+
+// EnableJobControl turns on job control, with tty as the descriptor of the
+// shell's controlling terminal
+func (m *Manager) EnableJobControl(tty int) error {
+	pgid, err := enableJobControl(tty)
+	if err != nil {
+		return fmt.Errorf("job control: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tty = tty
+	m.shellPgid = pgid
+	return nil
+}
+
+// JobControl reports whether job control is enabled
+func (m *Manager) JobControl() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.tty >= 0
+}
+
+// Foreground runs a command line as a foreground job and waits until it
+// finishes, returning the exit status and error of run. A process of the
+// job that is stopped moves to a job of its own in the table, and run goes
+// on as it ends with status 128+N; the stop is reported with the next
+// Report.
+func (m *Manager) Foreground(ctx context.Context, command string, run func(context.Context) (int, error)) (int, error) {
+	job := m.newJob(command)
+	job.foreground = true
+	job.inShell = true
+	m.start(ctx, job, run)
+	return m.waitForeground(job)
+}
+
+// Background starts a command line as a background job and returns it
+// without waiting. The job outlives the command line that started it, so
+// it does not end when that is interrupted.
+func (m *Manager) Background(ctx context.Context, command string, run func(context.Context) (int, error)) *Job {
+	job := m.newJob(command)
+	m.add(job)
+	m.mu.Lock()
+	m.last = job
+	m.mu.Unlock()
+
+	m.start(context.WithoutCancel(ctx), job, run)
+	return job
+}
+
+// start runs the commands of a job in a goroutine, with a context that
+// Interrupt cancels
+func (m *Manager) start(ctx context.Context, job *Job, run func(context.Context) (int, error)) {
+	ctx, cancel := context.WithCancel(ctx)
+	job.interrupt = cancel
+
+	go func() {
+		defer cancel()
+		job.finish(run(WithJob(ctx, job)))
+	}()
+}
+
+// Interrupt stops the job in the foreground, as Ctrl+C does: the commands
+// the shell runs itself end, and its processes are interrupted. Jobs in
+// the background go on.
+func (m *Manager) Interrupt() {
+	m.mu.Lock()
+	job := m.foreground
+	m.mu.Unlock()
+
+	if job != nil {
+		job.interrupt()
+	}
+}
+
+// Last returns the most recently started background job, or nil if none
+// has been started. The job is kept after it finishes or is disowned.
+func (m *Manager) Last() *Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.last
+}
+
+// Resume continues a stopped job in the foreground or background. In the
+// foreground it waits like Foreground.
+func (m *Manager) Resume(job *Job, foreground bool) (int, error) {
+	if !m.JobControl() {
+		return 1, ErrNoJobControl
+	}
+
+	job.mu.Lock()
+	if job.state == Done {
+		job.mu.Unlock()
+		m.remove(job)
+		return 1, errors.New("job has terminated")
+	}
+	job.state = Running
+	job.foreground = foreground
+	job.changed = false
+	pgid := job.pgid
+	job.mu.Unlock()
+
+	// Drop a stop that was already reported
+	select {
+	case <-job.stopped:
+	default:
+	}
+	m.add(job)
+
+	if foreground && pgid != 0 {
+		if err := setForeground(m.tty, pgid); err != nil {
+			return 1, err
+		}
+	}
+	if pgid != 0 {
+		if err := continueGroup(pgid); err != nil {
+			return 1, err
+		}
+	}
+
+	if foreground {
+		return m.waitForeground(job)
+	}
+	return 0, nil
+}
+
+// waitForeground waits for a foreground job to finish or stop, then takes
+// the terminal back for the shell
+func (m *Manager) waitForeground(job *Job) (int, error) {
+	m.mu.Lock()
+	m.foreground = job
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		m.foreground = nil
+		m.mu.Unlock()
+	}()
+	defer m.reclaimTerminal()
+
+	select {
+	case <-job.done:
+		m.remove(job)
+		return job.Result()
+	case <-job.stopped:
+		job.mu.Lock()
+		job.foreground = false
+		job.mu.Unlock()
+		m.add(job)
+		return 0, nil
+	}
+}
+
+// reclaimTerminal gives the terminal back to the shell
+func (m *Manager) reclaimTerminal() {
+	m.mu.Lock()
+	tty, pgid := m.tty, m.shellPgid
+	m.mu.Unlock()
+
+	if tty >= 0 {
+		_ = setForeground(tty, pgid)
+	}
+}
+
+// Wait waits for a job to finish, removes it from the table and returns
+// its result
+func (m *Manager) Wait(ctx context.Context, job *Job) (int, error) {
+	select {
+	case <-job.done:
+		m.remove(job)
+		return job.Result()
+	case <-ctx.Done():
+		return 1, ctx.Err()
+	}
+}
+
+// WaitAll waits for every job in the table to finish
+func (m *Manager) WaitAll(ctx context.Context) error {
+	for _, job := range m.Jobs() {
+		_, _ = m.Wait(ctx, job)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Disown removes a job from the table without affecting its processes
+func (m *Manager) Disown(job *Job) {
+	m.remove(job)
+}
+
+// Jobs returns the jobs in the table, ordered by job number
+func (m *Manager) Jobs() []*Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	jobs := append([]*Job(nil), m.jobs...)
+	sort.Slice(jobs, func(a, b int) bool { return jobs[a].ID < jobs[b].ID })
+	return jobs
+}
+
+// Find looks up a job by a job specification: %n or n for job number n,
+// %% or %+ for the current job, %- for the previous one, %string for the
+// job whose command starts with string and %?string for the one whose
+// command contains it. An empty spec means the current job.
+func (m *Manager) Find(spec string) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := strings.TrimPrefix(spec, "%")
+	switch key {
+	case "", "%", "+":
+		if len(m.jobs) == 0 {
+			return nil, errors.New("no current job")
+		}
+		return m.jobs[len(m.jobs)-1], nil
+	case "-":
+		if len(m.jobs) < 2 {
+			return nil, fmt.Errorf("%s: no such job", spec)
+		}
+		return m.jobs[len(m.jobs)-2], nil
+	}
+
+	if id, err := strconv.Atoi(key); err == nil {
+		for _, job := range m.jobs {
+			if job.ID == id {
+				return job, nil
+			}
+		}
+		return nil, fmt.Errorf("%s: no such job", spec)
+	}
+
+	if !strings.HasPrefix(spec, "%") {
+		return nil, fmt.Errorf("%s: no such job", spec)
+	}
+
+	var found *Job
+	for _, job := range m.jobs {
+		var match bool
+		if text, ok := strings.CutPrefix(key, "?"); ok {
+			match = strings.Contains(job.Command, text)
+		} else {
+			match = strings.HasPrefix(job.Command, key)
+		}
+		if !match {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("%s: ambiguous job spec", spec)
+		}
+		found = job
+	}
+	if found == nil {
+		return nil, fmt.Errorf("%s: no such job", spec)
+	}
+	return found, nil
+}
+
+// FindPid returns the job that started the process pid, or nil
+func (m *Manager) FindPid(pid int) *Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, job := range m.jobs {
+		job.mu.Lock()
+		for _, p := range job.pids {
+			if p == pid {
+				job.mu.Unlock()
+				return job
+			}
+		}
+		job.mu.Unlock()
+	}
+	return nil
+}
+
+// Format returns the line the jobs built-in prints for a job, such as
+// "[1]+  Running                 sleep 100 &". The long form includes the
+// process group.
+func (m *Manager) Format(job *Job, long bool) string {
+	m.mu.Lock()
+	marker := ' '
+	if n := len(m.jobs); n > 0 && m.jobs[n-1] == job {
+		marker = '+'
+	} else if n > 1 && m.jobs[n-2] == job {
+		marker = '-'
+	}
+	m.mu.Unlock()
+
+	state := job.State()
+	status := state.String()
+	code, _ := job.Result()
+	command := job.Command
+	switch {
+	case state == Done && code != 0:
+		status = fmt.Sprintf("Exit %d", code)
+	case state == Running:
+		command += " &"
+	}
+
+	if long {
+		return fmt.Sprintf("[%d]%c %d %-24s%s", job.ID, marker, job.Pgid(), status, command)
+	}
+	return fmt.Sprintf("[%d]%c  %-24s%s", job.ID, marker, status, command)
+}
+
+// List writes the lines of the given jobs to w. Their state changes count
+// as reported, and jobs that are done leave the table.
+func (m *Manager) List(w io.Writer, jobs []*Job, long bool) error {
+	for _, job := range jobs {
+		if _, err := fmt.Fprintln(w, m.Format(job, long)); err != nil {
+			return err
+		}
+
+		job.mu.Lock()
+		job.changed = false
+		done := job.state == Done
+		job.mu.Unlock()
+
+		if done {
+			m.remove(job)
+		}
+	}
+	return nil
+}
+
+// Report writes a line for each job whose state changed since it was last
+// reported, as the shell does before showing a prompt
+func (m *Manager) Report(w io.Writer) error {
+	var changed []*Job
+	for _, job := range m.Jobs() {
+		job.mu.Lock()
+		if job.changed {
+			changed = append(changed, job)
+		}
+		job.mu.Unlock()
+	}
+	return m.List(w, changed, false)
+}
+
+// newJob creates a job that is not yet in the table
+func (m *Manager) newJob(command string) *Job {
+	return &Job{
+		Command: command,
+		manager: m,
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}, 1),
+		started: make(chan struct{}),
+	}
+}
+
+// add puts a job in the table, or moves it there, as the current job
+func (m *Manager) add(job *Job) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if job.ID == 0 {
+		job.ID = 1
+		for _, other := range m.jobs {
+			if other.ID >= job.ID {
+				job.ID = other.ID + 1
+			}
+		}
+	}
+
+	m.removeLocked(job)
+	m.jobs = append(m.jobs, job)
+}
+
+// remove takes a job out of the table
+func (m *Manager) remove(job *Job) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.removeLocked(job)
+}
+
+// removeLocked takes a job out of the table; m.mu must be held
+func (m *Manager) removeLocked(job *Job) {
+	for i, other := range m.jobs {
+		if other == job {
+			m.jobs = append(m.jobs[:i], m.jobs[i+1:]...)
+			return
+		}
+	}
+}
+
+type contextKey struct{}
+
+// WithJob returns a context that runs processes as part of job
+func WithJob(ctx context.Context, job *Job) context.Context {
+	return context.WithValue(ctx, contextKey{}, job)
+}
+
+// FromContext returns the job that processes started with ctx belong to,
+// or nil
+func FromContext(ctx context.Context) *Job {
+	job, _ := ctx.Value(contextKey{}).(*Job)
+	return job
+}
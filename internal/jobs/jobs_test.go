@@ -0,0 +1,174 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os/exec"
+	"testing"
+)
+
+func TestBackgroundAndWait(t *testing.T) {
+	m := New()
+	ctx := context.Background()
+
+	release := make(chan struct{})
+	job := m.Background(ctx, "sleep 1", func(ctx context.Context) (int, error) {
+		<-release
+		return 3, nil
+	})
+
+	if job.ID != 1 || job.State() != Running {
+		t.Fatalf("job = %d %v, want 1 Running", job.ID, job.State())
+	}
+	if got := m.Format(job, false); got != "[1]+  Running                 sleep 1 &" {
+		t.Errorf("Format() = %q", got)
+	}
+
+	close(release)
+	if status, err := m.Wait(ctx, job); status != 3 || err != nil {
+		t.Errorf("Wait() = %d, %v, want exit status 3", status, err)
+	}
+	if len(m.Jobs()) != 0 {
+		t.Errorf("Jobs() = %d jobs after Wait, want 0", len(m.Jobs()))
+	}
+}
+
+func TestReport(t *testing.T) {
+	m := New()
+	ctx := context.Background()
+
+	ok := m.Background(ctx, "true", func(context.Context) (int, error) { return 0, nil })
+	failed := m.Background(ctx, "false", func(context.Context) (int, error) { return 1, nil })
+	<-ok.Done()
+	<-failed.Done()
+
+	var out bytes.Buffer
+	if err := m.Report(&out); err != nil {
+		t.Fatalf("Report() failed: %v", err)
+	}
+
+	want := "[1]-  Done                    true\n[2]+  Exit 1                  false\n"
+	if out.String() != want {
+		t.Errorf("Report() = %q, want %q", out.String(), want)
+	}
+
+	out.Reset()
+	if err := m.Report(&out); err != nil || out.Len() != 0 {
+		t.Errorf("second Report() = %q, %v, want nothing", out.String(), err)
+	}
+}
+
+func TestFind(t *testing.T) {
+	m := New()
+	ctx := context.Background()
+	block := make(chan struct{})
+	defer close(block)
+
+	for _, command := range []string{"make build", "vim notes.txt", "make test"} {
+		m.Background(ctx, command, func(context.Context) (int, error) {
+			<-block
+			return 0, nil
+		})
+	}
+
+	tests := []struct {
+		spec    string
+		want    int
+		wantErr bool
+	}{
+		{spec: "", want: 3},
+		{spec: "%%", want: 3},
+		{spec: "%+", want: 3},
+		{spec: "%-", want: 2},
+		{spec: "%1", want: 1},
+		{spec: "2", want: 2},
+		{spec: "%vim", want: 2},
+		{spec: "%?notes", want: 2},
+		{spec: "%make", wantErr: true},
+		{spec: "%4", wantErr: true},
+		{spec: "%emacs", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			job, err := m.Find(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Find(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			}
+			if err == nil && job.ID != tt.want {
+				t.Errorf("Find(%q) = job %d, want %d", tt.spec, job.ID, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunWithoutJobControl(t *testing.T) {
+	m := New()
+	ctx := context.Background()
+
+	job := m.Background(ctx, "sh -c 'exit 4'", func(ctx context.Context) (int, error) {
+		status, err := FromContext(ctx).Run(func() *exec.Cmd {
+			return exec.Command("sh", "-c", "exit 4")
+		})
+		if err != nil {
+			return 1, err
+		}
+		return status.ExitStatus(), nil
+	})
+
+	<-job.Started()
+	if job.Pid() == 0 {
+		t.Error("Pid() = 0 after the process started")
+	}
+	if status, err := m.Wait(ctx, job); status != 4 || err != nil {
+		t.Errorf("Wait() = %d, %v, want exit status 4", status, err)
+	}
+
+	if _, err := m.Resume(job, true); !errors.Is(err, ErrNoJobControl) {
+		t.Error("Resume() without job control should fail with ErrNoJobControl")
+	}
+}
+
+func TestInterrupt(t *testing.T) {
+	m := New()
+	ctx := context.Background()
+
+	release := make(chan struct{})
+	background := m.Background(ctx, "loop", func(ctx context.Context) (int, error) {
+		select {
+		case <-ctx.Done():
+			return 1, ctx.Err()
+		case <-release:
+			return 0, nil
+		}
+	})
+
+	go func() {
+		// Interrupt once the foreground job is waited for
+		for {
+			m.mu.Lock()
+			waiting := m.foreground != nil
+			m.mu.Unlock()
+			if waiting {
+				m.Interrupt()
+				return
+			}
+		}
+	}()
+	status, err := m.Foreground(ctx, "loop", func(ctx context.Context) (int, error) {
+		<-ctx.Done()
+		return 1, ctx.Err()
+	})
+	if status != 1 || !errors.Is(err, context.Canceled) {
+		t.Errorf("Foreground() = %d, %v, want the job interrupted", status, err)
+	}
+
+	// Neither the end of the foreground job nor the interrupt reaches the
+	// background job
+	m.Interrupt()
+	close(release)
+	if status, err := m.Wait(ctx, background); status != 0 || err != nil {
+		t.Errorf("Wait() = %d, %v, want the background job to finish", status, err)
+	}
+}
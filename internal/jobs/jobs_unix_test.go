@@ -0,0 +1,71 @@
+//go:build unix
+
+package jobs
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"syscall"
+	"testing"
+)
+
+func TestStoppedProcessLeavesShellJob(t *testing.T) {
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer devNull.Close()
+
+	// Job control without a terminal: processes get groups of their own
+	// but never take the terminal
+	m := New()
+	m.tty = int(devNull.Fd())
+	ctx := context.Background()
+
+	// variables stands for the shell's state, which the command line sets
+	// once its process is done
+	variables := make(map[string]int)
+	job := m.newJob("sleep 10; next")
+	job.inShell = true
+	m.start(ctx, job, func(ctx context.Context) (int, error) {
+		status, err := FromContext(ctx).Run(func() *exec.Cmd {
+			return exec.Command("sleep", "10")
+		})
+		variables["status"] = exitCode(status)
+		if status.Stopped() {
+			variables["status"] = 128 + int(status.StopSignal())
+		}
+		return variables["status"], err
+	})
+
+	<-job.Started()
+	if err := syscall.Kill(job.Pid(), syscall.SIGSTOP); err != nil {
+		t.Fatalf("Kill() failed: %v", err)
+	}
+	// The command line goes on as the process stops
+	if status, err := m.waitForeground(job); status != 128+int(syscall.SIGSTOP) || err != nil {
+		t.Errorf("waitForeground() = %d, %v, want the status of the stop", status, err)
+	}
+
+	stopped, err := m.Find("%%")
+	if err != nil {
+		t.Fatalf("Find() failed: %v", err)
+	}
+	if stopped == job || stopped.State() != Stopped || stopped.Pid() != job.Pid() {
+		t.Fatalf("Find() = %q %v, want the stopped process in a job of its own", stopped.Command, stopped.State())
+	}
+
+	// Only the process goes on in the background, while the shell uses its
+	// state again
+	if _, err := m.Resume(stopped, false); err != nil {
+		t.Fatalf("Resume() failed: %v", err)
+	}
+	variables["status"] = 0
+	if err := syscall.Kill(stopped.Pid(), syscall.SIGTERM); err != nil {
+		t.Fatalf("Kill() failed: %v", err)
+	}
+	if status, err := m.Wait(ctx, stopped); status != 128+int(syscall.SIGTERM) || err != nil {
+		t.Errorf("Wait() = %d, %v, want the status of SIGTERM", status, err)
+	}
+}
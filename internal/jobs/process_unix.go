@@ -0,0 +1,107 @@
+//go:build unix
+
+package jobs
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// terminalMu serializes changes of the terminal's foreground group, which
+// briefly ignore SIGTTOU
+var terminalMu sync.Mutex
+
+// enableJobControl gives the terminal to the shell's process group and
+// returns that group
+func enableJobControl(tty int) (int, error) {
+	// Ctrl+Z must not suspend the shell itself. The signal is caught rather
+	// than ignored so that commands started by the shell still stop on it.
+	signal.Notify(make(chan os.Signal, 1), syscall.SIGTSTP)
+
+	pgid := syscall.Getpgrp()
+	if err := setForeground(tty, pgid); err != nil {
+		return 0, err
+	}
+	return pgid, nil
+}
+
+// setForeground makes pgid the foreground process group of the terminal
+func setForeground(tty, pgid int) error {
+	terminalMu.Lock()
+	defer terminalMu.Unlock()
+
+	// The kernel sends SIGTTOU to a background process that changes the
+	// foreground group, so it is ignored for the duration of the call only;
+	// ignoring it for good would pass that on to every command
+	signal.Ignore(syscall.SIGTTOU)
+	defer signal.Reset(syscall.SIGTTOU)
+
+	id := int32(pgid)
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(tty), uintptr(syscall.TIOCSPGRP), uintptr(unsafe.Pointer(&id)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// setProcessGroup places the process of cmd in the group pgid, or in a new
+// group it leads when pgid is 0. A foreground process also takes the
+// terminal tty before it runs.
+func setProcessGroup(cmd *exec.Cmd, pgid int, foreground bool, tty int) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setpgid:    true,
+		Pgid:       pgid,
+		Foreground: foreground,
+		Ctty:       tty,
+	}
+}
+
+// waitProcess waits for the process pid to exit, calling onStop each time
+// it is stopped on the way. When onStop returns false, the wait ends with
+// the status of the stopped process.
+func waitProcess(pid int, onStop func() bool) (syscall.WaitStatus, error) {
+	for {
+		var status syscall.WaitStatus
+		_, err := syscall.Wait4(pid, &status, syscall.WUNTRACED, nil)
+		if errors.Is(err, syscall.EINTR) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		if status.Stopped() && onStop() {
+			continue
+		}
+		return status, nil
+	}
+}
+
+// continueGroup sends SIGCONT to every process of a group
+func continueGroup(pgid int) error {
+	return syscall.Kill(-pgid, syscall.SIGCONT)
+}
+
+// signalGroup sends sig to every process of a group
+func signalGroup(pgid int, sig os.Signal) error {
+	n, ok := sig.(syscall.Signal)
+	if !ok {
+		return errors.New("unsupported signal")
+	}
+	return syscall.Kill(-pgid, n)
+}
+
+// terminates reports whether sig ends a process that does not catch it
+func terminates(sig os.Signal) bool {
+	switch sig {
+	case syscall.Signal(0), syscall.SIGCHLD, syscall.SIGCONT, syscall.SIGSTOP, syscall.SIGTSTP,
+		syscall.SIGTTIN, syscall.SIGTTOU, syscall.SIGURG, syscall.SIGWINCH:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,42 @@
+//go:build windows
+
+package jobs
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// enableJobControl reports that job control is not available on Windows
+func enableJobControl(_ int) (int, error) {
+	return 0, ErrNoJobControl
+}
+
+// setForeground is never reached without job control
+func setForeground(_, _ int) error {
+	return ErrNoJobControl
+}
+
+// setProcessGroup is never reached without job control
+func setProcessGroup(_ *exec.Cmd, _ int, _ bool, _ int) {}
+
+// waitProcess is never reached without job control
+func waitProcess(_ int, _ func() bool) (syscall.WaitStatus, error) {
+	return syscall.WaitStatus{}, ErrNoJobControl
+}
+
+// continueGroup is never reached without job control
+func continueGroup(_ int) error {
+	return ErrNoJobControl
+}
+
+// signalGroup is never reached without job control
+func signalGroup(_ int, _ os.Signal) error {
+	return ErrNoJobControl
+}
+
+// terminates reports whether sig ends a process. Windows can only kill one.
+func terminates(sig os.Signal) bool {
+	return sig == os.Kill
+}
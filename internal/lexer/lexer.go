@@ -0,0 +1,694 @@
+// Package lexer provides the lexical analysis of shell input for gosh.
+// It splits command lines and scripts into typed tokens (words, operators,
+// redirections and reserved words) with their byte positions, and reads the
+// bodies of here-documents.
+package lexer
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Kind classifies a token
+type Kind int
+
+const (
+	// Word is an ordinary word such as a command name or argument. Its value
+	// is the source text, with quotes and escapes still in place.
+	Word Kind = iota
+	// Reserved is an unquoted word with a special meaning at the start of a
+	// command, such as "if" or "{". Elsewhere it is an ordinary word.
+	Reserved
+	// Redirect is a redirection operator such as > or <<
+	Redirect
+	// Newline is an unquoted line break
+	Newline
+	// Semi is the ; command separator
+	Semi
+	// DSemi is the ;; case clause terminator
+	DSemi
+	// Amp is the & background operator
+	Amp
+	// AndIf is the && operator
+	AndIf
+	// OrIf is the || operator
+	OrIf
+	// Pipe is the | operator
+	Pipe
+	// PipeAll is the |& operator, which also pipes stderr
+	PipeAll
+	// LParen is an opening parenthesis
+	LParen
+	// RParen is a closing parenthesis
+	RParen
+	// Arith is a (( ... )) arithmetic expression. Its value is the source
+	// text including the parentheses.
+	Arith
+	// EOF marks the end of the input
+	EOF
+)
+
+// ErrIncomplete is wrapped by errors for input that ends inside a construct
+// that continues on the next line, such as a quoted string or a
+// here-document
+var ErrIncomplete = errors.New("unexpected end of input")
+
+// reservedWords are the words recognized as Reserved when unquoted
+var reservedWords = map[string]bool{
+	"!": true, "{": true, "}": true, "[[": true, "]]": true,
+	"case": true, "do": true, "done": true, "elif": true, "else": true,
+	"esac": true, "fi": true, "for": true, "function": true, "if": true,
+	"in": true, "select": true, "then": true, "until": true, "while": true,
+}
+
+// operators lists the operators, longest first so that they match greedily
+var operators = []struct {
+	text string
+	kind Kind
+}{
+	{"<<<", Redirect}, {"<<-", Redirect}, {"&>>", Redirect},
+	{"<<", Redirect}, {">>", Redirect}, {">|", Redirect}, {"<>", Redirect},
+	{"<&", Redirect}, {">&", Redirect}, {"&>", Redirect},
+	{";;", DSemi}, {"||", OrIf}, {"|&", PipeAll}, {"&&", AndIf},
+	{"<", Redirect}, {">", Redirect}, {";", Semi}, {"|", Pipe},
+	{"&", Amp}, {"(", LParen}, {")", RParen},
+}
+
+// Pos is a location in the input
+type Pos struct {
+	// Offset is the byte offset from the start of the input
+	Offset int
+	// Line and Col are 1-based; Col counts bytes
+	Line int
+	Col  int
+}
+
+// String formats the position as line:column
+func (p Pos) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Col)
+}
+
+// Token is a single lexical unit of shell input
+type Token struct {
+	Kind Kind
+	// Value is the source text of the token
+	Value string
+	Pos   Pos
+	// Fd is the explicit descriptor of a redirection such as 2>, or -1
+	Fd int
+	// Quoted reports that a word contains quotes or escapes
+	Quoted bool
+	// HereDoc holds the body of a << or <<- redirection
+	HereDoc string
+}
+
+// Error is a lexical error located in the input
+type Error struct {
+	Pos Pos
+	Msg string
+	// Incomplete marks errors that more input could resolve
+	Incomplete bool
+	// Want is text that more input must contain to resolve an incomplete
+	// error, such as the closing quote, or "" if any input might
+	Want string
+}
+
+// Error implements the error interface for Error
+func (e *Error) Error() string {
+	return e.Msg
+}
+
+// Unwrap lets errors.Is match ErrIncomplete for incomplete input
+func (e *Error) Unwrap() error {
+	if e.Incomplete {
+		return ErrIncomplete
+	}
+	return nil
+}
+
+// Lexer splits shell input into tokens
+type Lexer struct {
+	src        string
+	offset     int
+	lineStarts []int
+	tokens     []Token
+	// pending holds the indices of here-document operators on the current
+	// line whose bodies start after the next newline
+	pending []int
+}
+
+// New creates a lexer for src
+func New(src string) *Lexer {
+	lineStarts := []int{0}
+	for i := 0; i < len(src); i++ {
+		if src[i] == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+	return &Lexer{src: src, lineStarts: lineStarts}
+}
+
+// Tokenize splits src into tokens, ending with an EOF token
+func Tokenize(src string) ([]Token, error) {
+	return New(src).Tokens()
+}
+
+// Tokens lexes the whole input
+func (l *Lexer) Tokens() ([]Token, error) {
+	for {
+		tok, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		l.tokens = append(l.tokens, tok)
+
+		switch tok.Kind {
+		case EOF:
+			if len(l.pending) > 0 {
+				return nil, l.unterminatedHereDoc()
+			}
+			return l.tokens, nil
+		case Newline:
+			if err := l.readHereDocs(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// PosAt converts a byte offset into a position
+func (l *Lexer) PosAt(offset int) Pos {
+	line := 0
+	for line+1 < len(l.lineStarts) && l.lineStarts[line+1] <= offset {
+		line++
+	}
+	return Pos{Offset: offset, Line: line + 1, Col: offset - l.lineStarts[line] + 1}
+}
+
+// next lexes the token at the current offset
+func (l *Lexer) next() (Token, error) {
+	l.skipBlanks()
+	if l.offset >= len(l.src) {
+		return Token{Kind: EOF, Pos: l.PosAt(l.offset), Fd: -1}, nil
+	}
+
+	start := l.offset
+	if l.src[start] == '\n' {
+		l.offset++
+		return Token{Kind: Newline, Value: "\n", Pos: l.PosAt(start), Fd: -1}, nil
+	}
+
+	if strings.HasPrefix(l.src[start:], "((") {
+		end := ArithEnd(l.src, start)
+		if end == -1 {
+			return Token{}, l.incomplete(start, ")", "unexpected end of input looking for the match of `(('")
+		}
+		if end > 0 {
+			l.offset = end
+			return Token{Kind: Arith, Value: l.src[start:end], Pos: l.PosAt(start), Fd: -1}, nil
+		}
+	}
+
+	if tok, ok := l.operator(start, -1); ok {
+		return tok, nil
+	}
+
+	end, err := l.wordEnd(start)
+	if err != nil {
+		return Token{}, err
+	}
+	l.offset = end
+	value := l.src[start:end]
+
+	// A word of digits directly before < or > is the descriptor being
+	// redirected, as in 2>
+	if isDigits(value) && end < len(l.src) && (l.src[end] == '<' || l.src[end] == '>') {
+		if tok, ok := l.operator(end, atoi(value)); ok {
+			tok.Pos = l.PosAt(start)
+			return tok, nil
+		}
+	}
+
+	tok := Token{Kind: Word, Value: value, Pos: l.PosAt(start), Fd: -1}
+	tok.Quoted = strings.ContainsAny(value, "'\"\\")
+	if !tok.Quoted && reservedWords[value] {
+		tok.Kind = Reserved
+	}
+	return tok, nil
+}
+
+// IsReserved reports whether word is a reserved word when it is unquoted
+func IsReserved(word string) bool {
+	return reservedWords[word]
+}
+
+// operator lexes the operator at offset start, if there is one
+func (l *Lexer) operator(start, fd int) (Token, bool) {
+	for _, op := range operators {
+		if strings.HasPrefix(l.src[start:], op.text) {
+			if fd >= 0 && op.text[0] == '&' {
+				continue
+			}
+			l.offset = start + len(op.text)
+			tok := Token{Kind: op.kind, Value: op.text, Pos: l.PosAt(start), Fd: fd}
+			if op.text == "<<" || op.text == "<<-" {
+				l.pending = append(l.pending, len(l.tokens))
+			}
+			return tok, true
+		}
+	}
+	return Token{}, false
+}
+
+// skipBlanks skips spaces, tabs, backslash-newlines and comments
+func (l *Lexer) skipBlanks() {
+	for l.offset < len(l.src) {
+		switch c := l.src[l.offset]; {
+		case c == ' ' || c == '\t':
+			l.offset++
+		case c == '\\' && l.offset+1 < len(l.src) && l.src[l.offset+1] == '\n':
+			l.offset += 2
+		case c == '#':
+			for l.offset < len(l.src) && l.src[l.offset] != '\n' {
+				l.offset++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// wordEnd returns the offset just past the word starting at start
+func (l *Lexer) wordEnd(start int) (int, error) {
+	i := start
+	for i < len(l.src) {
+		c := l.src[i]
+		if c == '(' && isArrayAssignment(l.src[start:i]) {
+			// The list of an array assignment, as in arr=(a b c), is part
+			// of the word
+			end := closingEnd(l.src, i, '(', ')', false)
+			if end < 0 {
+				return 0, l.incomplete(i, ")", "unexpected end of input looking for the match of `('")
+			}
+			return end, nil
+		}
+		if isMeta(c) {
+			return i, nil
+		}
+
+		end := i + 1
+		switch c {
+		case '\\':
+			if i+1 >= len(l.src) {
+				return 0, l.incomplete(i, "", "unexpected end of input after `\\'")
+			}
+			end = i + 2
+		case '\'', '"':
+			end = QuoteEnd(l.src, i)
+		case '`':
+			end = BacktickEnd(l.src, i)
+		case '$':
+			end = DollarEnd(l.src, i)
+		}
+
+		if end < 0 {
+			open := opening(l.src, i)
+			return 0, l.incomplete(i, closing(open), fmt.Sprintf("unexpected end of input looking for the match of `%s'", open))
+		}
+		i = end
+	}
+	return i, nil
+}
+
+// readHereDocs reads the bodies of the here-documents started on the line
+// that just ended. The bodies follow the line in order.
+func (l *Lexer) readHereDocs() error {
+	pending := l.pending
+	l.pending = nil
+
+	for _, index := range pending {
+		if index+1 >= len(l.tokens) || !isWordKind(l.tokens[index+1].Kind) {
+			// Reported as a syntax error by the parser
+			continue
+		}
+		op := &l.tokens[index]
+		delimiter := RemoveQuotes(l.tokens[index+1].Value)
+		stripTabs := op.Value == "<<-"
+
+		var body strings.Builder
+		found := false
+		for l.offset < len(l.src) {
+			end := strings.IndexByte(l.src[l.offset:], '\n')
+			if end < 0 {
+				end = len(l.src) - l.offset
+			}
+			text := l.src[l.offset : l.offset+end]
+			l.offset += end
+			if l.offset < len(l.src) {
+				l.offset++
+			}
+
+			if stripTabs {
+				text = strings.TrimLeft(text, "\t")
+			}
+			if text == delimiter {
+				found = true
+				break
+			}
+			body.WriteString(text)
+			body.WriteString("\n")
+		}
+
+		if !found {
+			l.pending = []int{index}
+			return l.unterminatedHereDoc()
+		}
+		op.HereDoc = body.String()
+	}
+	return nil
+}
+
+// unterminatedHereDoc reports the first here-document still waiting for
+// its delimiter
+func (l *Lexer) unterminatedHereDoc() error {
+	op := l.tokens[l.pending[0]]
+	delimiter := ""
+	if l.pending[0]+1 < len(l.tokens) {
+		delimiter = RemoveQuotes(l.tokens[l.pending[0]+1].Value)
+	}
+	return &Error{
+		Pos:        op.Pos,
+		Msg:        fmt.Sprintf("here-document delimited by %q is not terminated", delimiter),
+		Incomplete: true,
+		Want:       delimiter,
+	}
+}
+
+// incomplete builds an error for input that ends too early, before the
+// text want
+func (l *Lexer) incomplete(offset int, want, msg string) error {
+	return &Error{Pos: l.PosAt(offset), Msg: msg, Incomplete: true, Want: want}
+}
+
+// QuoteEnd returns the offset just past the single- or double-quoted string
+// that starts at src[start], or -1 if it is not terminated
+func QuoteEnd(src string, start int) int {
+	if src[start] == '\'' {
+		end := strings.IndexByte(src[start+1:], '\'')
+		if end < 0 {
+			return -1
+		}
+		return start + end + 2
+	}
+
+	i := start + 1
+	for i < len(src) {
+		switch src[i] {
+		case '"':
+			return i + 1
+		case '\\':
+			i += 2
+		case '`':
+			if i = BacktickEnd(src, i); i < 0 {
+				return -1
+			}
+		case '$':
+			if i = DollarEnd(src, i); i < 0 {
+				return -1
+			}
+		default:
+			i++
+		}
+	}
+	return -1
+}
+
+// BacktickEnd returns the offset just past the backquoted command that
+// starts at src[start], or -1 if it is not terminated
+func BacktickEnd(src string, start int) int {
+	for i := start + 1; i < len(src); i++ {
+		switch src[i] {
+		case '\\':
+			i++
+		case '`':
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// DollarEnd returns the offset just past the expansion that starts with the
+// $ at src[start]: $name, ${...}, $(...) or $((...)). A $ that starts no
+// expansion covers only itself. It returns -1 if a bracket is not closed.
+func DollarEnd(src string, start int) int {
+	i := start + 1
+	if i >= len(src) {
+		return i
+	}
+
+	switch c := src[i]; {
+	case c == '{':
+		return closingEnd(src, i, '{', '}', false)
+	case c == '(':
+		// Arithmetic, as in $((...)), holds no commands
+		arithmetic := i+1 < len(src) && src[i+1] == '('
+		return closingEnd(src, i, '(', ')', !arithmetic)
+	case c == '_' || isAlpha(c):
+		for i < len(src) && (src[i] == '_' || isAlpha(src[i]) || isDigit(src[i])) {
+			i++
+		}
+		return i
+	case isDigit(c) || strings.IndexByte("@*#?$!-", c) >= 0:
+		return i + 1
+	default:
+		return i
+	}
+}
+
+// ArithEnd returns the offset just past the )) that closes the (( at
+// src[start]. It returns 0 if the parentheses close separately, as in
+// ((cd /tmp) ), which makes them nested subshells, and -1 if they are not
+// closed at all.
+func ArithEnd(src string, start int) int {
+	depth := 0
+	i := start + 2
+	for i < len(src) {
+		switch c := src[i]; {
+		case c == '(':
+			depth++
+			i++
+		case c == ')':
+			if depth > 0 {
+				depth--
+				i++
+				continue
+			}
+			if i+1 < len(src) && src[i+1] == ')' {
+				return i + 2
+			}
+			return 0
+		case c == '\\':
+			i += 2
+		case c == '\'' || c == '"':
+			if i = QuoteEnd(src, i); i < 0 {
+				return -1
+			}
+		case c == '`':
+			if i = BacktickEnd(src, i); i < 0 {
+				return -1
+			}
+		case c == '$':
+			if i = DollarEnd(src, i); i < 0 {
+				return -1
+			}
+		default:
+			i++
+		}
+	}
+	return -1
+}
+
+// closingEnd returns the offset just past the bracket that closes the one at
+// src[start], skipping over quoted text and nested expansions. When the
+// brackets hold commands, as in $(...), the ) that ends a case pattern
+// closes nothing.
+func closingEnd(src string, start int, open, closing byte, commands bool) int {
+	depth := 0
+	// cases holds the depth at which each open case started, and command
+	// whether a word at i would start a command
+	var cases []int
+	command := false
+	i := start
+	for i < len(src) {
+		switch c := src[i]; {
+		case c == open:
+			depth++
+			i++
+			command = true
+		case c == closing && len(cases) > 0 && cases[len(cases)-1] == depth:
+			i++
+			command = true
+		case c == closing:
+			depth--
+			i++
+			if depth == 0 {
+				return i
+			}
+			command = false
+		case commands && command && isAlpha(c):
+			end := i
+			for end < len(src) && isAlpha(src[end]) {
+				end++
+			}
+			word := src[i:end]
+			if end < len(src) && !isMeta(src[end]) {
+				word = ""
+			}
+			switch word {
+			case "case":
+				cases = append(cases, depth)
+			case "esac":
+				if len(cases) > 0 {
+					cases = cases[:len(cases)-1]
+				}
+			}
+			i = end
+			command = word != "case" && word != "esac" && reservedWords[word]
+		case c == '\\':
+			i += 2
+			command = false
+		case c == '\'' || c == '"':
+			if i = QuoteEnd(src, i); i < 0 {
+				return -1
+			}
+			command = false
+		case c == '`':
+			if i = BacktickEnd(src, i); i < 0 {
+				return -1
+			}
+			command = false
+		case c == '$':
+			if i = DollarEnd(src, i); i < 0 {
+				return -1
+			}
+			command = false
+		case c == ' ' || c == '\t':
+			i++
+		default:
+			i++
+			command = strings.IndexByte(";&|\n", c) >= 0
+		}
+	}
+	return -1
+}
+
+// RemoveQuotes removes quotes and backslash escapes from a word without
+// expanding it, as is done for here-document delimiters
+func RemoveQuotes(word string) string {
+	var b strings.Builder
+	for i := 0; i < len(word); i++ {
+		switch c := word[i]; c {
+		case '\\':
+			if i+1 < len(word) {
+				i++
+				b.WriteByte(word[i])
+			}
+		case '\'', '"':
+			end := strings.IndexByte(word[i+1:], c)
+			if end < 0 {
+				b.WriteString(word[i+1:])
+				return b.String()
+			}
+			b.WriteString(word[i+1 : i+1+end])
+			i += end + 1
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// opening describes the construct that starts at src[i] for error messages
+func opening(src string, i int) string {
+	if src[i] == '$' && i+1 < len(src) {
+		return src[i : i+2]
+	}
+	return src[i : i+1]
+}
+
+// closing returns the text that ends a quote or expansion started by open,
+// as returned by opening
+func closing(open string) string {
+	switch open {
+	case "'", "$'":
+		return "'"
+	case `"`, `$"`:
+		return `"`
+	case "`":
+		return "`"
+	case "${":
+		return "}"
+	case "$(":
+		return ")"
+	}
+	return ""
+}
+
+// isArrayAssignment reports whether word is the NAME= or NAME+= that starts
+// an array assignment when a parenthesis follows it
+func isArrayAssignment(word string) bool {
+	name, ok := strings.CutSuffix(word, "=")
+	if !ok {
+		return false
+	}
+	name = strings.TrimSuffix(name, "+")
+	if name == "" || isDigit(name[0]) {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if c := name[i]; !isAlpha(c) && !isDigit(c) && c != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// isMeta reports whether c ends an unquoted word
+func isMeta(c byte) bool {
+	return strings.IndexByte(" \t\n;&|<>()", c) >= 0
+}
+
+// isWordKind reports whether kind is a word, reserved or not
+func isWordKind(kind Kind) bool {
+	return kind == Word || kind == Reserved
+}
+
+// isAlpha reports whether c is an ASCII letter
+func isAlpha(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// isDigit reports whether c is an ASCII digit
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// isDigits reports whether s is a non-empty run of digits
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !isDigit(s[i]) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// atoi converts a run of digits, as checked by isDigits
+func atoi(s string) int {
+	n := 0
+	for i := 0; i < len(s); i++ {
+		n = n*10 + int(s[i]-'0')
+	}
+	return n
+}
@@ -0,0 +1,271 @@
+package lexer
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		kinds  []Kind
+		values []string
+	}{
+		{
+			name:   "simple command",
+			input:  "ls -la",
+			kinds:  []Kind{Word, Word, EOF},
+			values: []string{"ls", "-la", ""},
+		},
+		{
+			name:   "quotes are kept",
+			input:  `echo "a b" 'c'd`,
+			kinds:  []Kind{Word, Word, Word, EOF},
+			values: []string{"echo", `"a b"`, "'c'd", ""},
+		},
+		{
+			name:   "operators without spaces",
+			input:  "a&&b||c;d|e|&f",
+			kinds:  []Kind{Word, AndIf, Word, OrIf, Word, Semi, Word, Pipe, Word, PipeAll, Word, EOF},
+			values: []string{"a", "&&", "b", "||", "c", ";", "d", "|", "e", "|&", "f", ""},
+		},
+		{
+			name:   "array assignment",
+			input:  "arr=(a \"b c\"\n d) arr+=($(ls)) f=x(y)",
+			kinds:  []Kind{Word, Word, Word, LParen, Word, RParen, EOF},
+			values: []string{"arr=(a \"b c\"\n d)", "arr+=($(ls))", "f=x", "(", "y", ")", ""},
+		},
+		{
+			name:   "grouping",
+			input:  "(cd /tmp)\n{ ls; }",
+			kinds:  []Kind{LParen, Word, Word, RParen, Newline, Reserved, Word, Semi, Reserved, EOF},
+			values: []string{"(", "cd", "/tmp", ")", "\n", "{", "ls", ";", "}", ""},
+		},
+		{
+			name:   "quoted reserved word",
+			input:  `'if' "done"`,
+			kinds:  []Kind{Word, Word, EOF},
+			values: []string{"'if'", `"done"`, ""},
+		},
+		{
+			name:   "arithmetic",
+			input:  "for ((i = 0; i < (n); i++))",
+			kinds:  []Kind{Reserved, Arith, EOF},
+			values: []string{"for", "((i = 0; i < (n); i++))", ""},
+		},
+		{
+			name:   "nested subshells",
+			input:  "((cd /tmp) )",
+			kinds:  []Kind{LParen, LParen, Word, Word, RParen, RParen, EOF},
+			values: []string{"(", "(", "cd", "/tmp", ")", ")", ""},
+		},
+		{
+			name:   "comments",
+			input:  "echo a # comment\necho b#c",
+			kinds:  []Kind{Word, Word, Newline, Word, Word, EOF},
+			values: []string{"echo", "a", "\n", "echo", "b#c", ""},
+		},
+		{
+			name:   "line continuation",
+			input:  "echo a \\\n  b",
+			kinds:  []Kind{Word, Word, Word, EOF},
+			values: []string{"echo", "a", "b", ""},
+		},
+		{
+			name:   "expansions are one word",
+			input:  `echo $(ls "a b") ${x:-y z} ` + "`pwd`",
+			kinds:  []Kind{Word, Word, Word, Word, EOF},
+			values: []string{"echo", `$(ls "a b")`, "${x:-y z}", "`pwd`", ""},
+		},
+		{
+			name:   "case patterns in command substitution",
+			input:  "echo $(case x in a) echo a;; (x) echo $((1)) (x);; esac) $(echo case) b",
+			kinds:  []Kind{Word, Word, Word, Word, EOF},
+			values: []string{"echo", "$(case x in a) echo a;; (x) echo $((1)) (x);; esac)", "$(echo case)", "b", ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, err := Tokenize(tt.input)
+			if err != nil {
+				t.Fatalf("Tokenize() failed: %v", err)
+			}
+			if len(tokens) != len(tt.kinds) {
+				t.Fatalf("Tokenize() got %d tokens, want %d: %+v", len(tokens), len(tt.kinds), tokens)
+			}
+			for i, tok := range tokens {
+				if tok.Kind != tt.kinds[i] || tok.Value != tt.values[i] {
+					t.Errorf("token[%d] = %d %q, want %d %q", i, tok.Kind, tok.Value, tt.kinds[i], tt.values[i])
+				}
+			}
+		})
+	}
+}
+
+func TestTokenizeRedirects(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		kinds []Kind
+		fds   []int
+	}{
+		{
+			name:  "output",
+			input: "ls > out.txt",
+			kinds: []Kind{Word, Redirect, Word, EOF},
+			fds:   []int{-1, -1, -1, -1},
+		},
+		{
+			name:  "stderr to stdout",
+			input: "make 2>&1",
+			kinds: []Kind{Word, Redirect, Word, EOF},
+			fds:   []int{-1, 2, -1, -1},
+		},
+		{
+			name:  "no spaces",
+			input: "sort<in.txt>>out.txt",
+			kinds: []Kind{Word, Redirect, Word, Redirect, Word, EOF},
+			fds:   []int{-1, -1, -1, -1, -1, -1},
+		},
+		{
+			name:  "digits in a word are not a descriptor",
+			input: "echo a2>x",
+			kinds: []Kind{Word, Word, Redirect, Word, EOF},
+			fds:   []int{-1, -1, -1, -1, -1},
+		},
+		{
+			name:  "quoted operator",
+			input: `echo ">" '2>&1'`,
+			kinds: []Kind{Word, Word, Word, EOF},
+			fds:   []int{-1, -1, -1, -1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, err := Tokenize(tt.input)
+			if err != nil {
+				t.Fatalf("Tokenize() failed: %v", err)
+			}
+			if len(tokens) != len(tt.kinds) {
+				t.Fatalf("Tokenize() got %d tokens, want %d: %+v", len(tokens), len(tt.kinds), tokens)
+			}
+			for i, tok := range tokens {
+				if tok.Kind != tt.kinds[i] || tok.Fd != tt.fds[i] {
+					t.Errorf("token[%d] = %+v, want kind %d fd %d", i, tok, tt.kinds[i], tt.fds[i])
+				}
+			}
+		})
+	}
+}
+
+func TestTokenPositions(t *testing.T) {
+	tokens, err := Tokenize("echo a\n  ls  -l")
+	if err != nil {
+		t.Fatalf("Tokenize() failed: %v", err)
+	}
+
+	want := []Pos{
+		{Offset: 0, Line: 1, Col: 1},
+		{Offset: 5, Line: 1, Col: 6},
+		{Offset: 6, Line: 1, Col: 7},
+		{Offset: 9, Line: 2, Col: 3},
+		{Offset: 13, Line: 2, Col: 7},
+	}
+	for i, pos := range want {
+		if tokens[i].Pos != pos {
+			t.Errorf("token[%d] %q at %+v, want %+v", i, tokens[i].Value, tokens[i].Pos, pos)
+		}
+	}
+}
+
+func TestTokenizeHereDoc(t *testing.T) {
+	tokens, err := Tokenize("cat <<EOF; cat <<-'END'\nhello $USER\nEOF\n\tindented\n\tEND\necho done")
+	if err != nil {
+		t.Fatalf("Tokenize() failed: %v", err)
+	}
+
+	if tokens[2].Value != "EOF" || tokens[1].HereDoc != "hello $USER\n" {
+		t.Errorf("first here-document = %q %q", tokens[2].Value, tokens[1].HereDoc)
+	}
+	if !tokens[6].Quoted || tokens[5].HereDoc != "indented\n" {
+		t.Errorf("second here-document = %+v %q", tokens[6], tokens[5].HereDoc)
+	}
+	if tokens[8].Value != "echo" || tokens[8].Pos.Line != 6 {
+		t.Errorf("token after here-documents = %+v", tokens[8])
+	}
+}
+
+func TestTokenizeErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		incomplete bool
+		pos        Pos
+		want       string
+	}{
+		{
+			name:       "unclosed double quote",
+			input:      `echo "unclosed`,
+			incomplete: true,
+			pos:        Pos{Offset: 5, Line: 1, Col: 6},
+			want:       `"`,
+		},
+		{
+			name:       "unclosed single quote on second line",
+			input:      "ls\necho 'a",
+			incomplete: true,
+			pos:        Pos{Offset: 8, Line: 2, Col: 6},
+			want:       "'",
+		},
+		{
+			name:       "unclosed command substitution",
+			input:      "echo $(ls",
+			incomplete: true,
+			pos:        Pos{Offset: 5, Line: 1, Col: 6},
+			want:       ")",
+		},
+		{
+			name:       "unclosed arithmetic",
+			input:      "for ((i = 0;",
+			incomplete: true,
+			pos:        Pos{Offset: 4, Line: 1, Col: 5},
+			want:       ")",
+		},
+		{
+			name:       "unclosed array assignment",
+			input:      "arr=(a\nb",
+			incomplete: true,
+			pos:        Pos{Offset: 4, Line: 1, Col: 5},
+			want:       ")",
+		},
+		{
+			name:       "unterminated here-document",
+			input:      "cat <<EOF\nbody",
+			incomplete: true,
+			pos:        Pos{Offset: 4, Line: 1, Col: 5},
+			want:       "EOF",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Tokenize(tt.input)
+			var lexErr *Error
+			if !errors.As(err, &lexErr) {
+				t.Fatalf("Tokenize() error = %v, want *Error", err)
+			}
+			if errors.Is(err, ErrIncomplete) != tt.incomplete {
+				t.Errorf("Tokenize() incomplete = %v, want %v", !tt.incomplete, tt.incomplete)
+			}
+			if lexErr.Pos != tt.pos {
+				t.Errorf("Tokenize() error at %+v, want %+v", lexErr.Pos, tt.pos)
+			}
+			if lexErr.Want != tt.want {
+				t.Errorf("Tokenize() error wants %q, want %q", lexErr.Want, tt.want)
+			}
+		})
+	}
+}
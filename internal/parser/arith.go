@@ -0,0 +1,573 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gosh/internal/config"
+)
+
+// maxArithDepth limits how deeply variables whose values are themselves
+// expressions may refer to each other
+const maxArithDepth = 64
+
+// arithOperators lists the operators of arithmetic expressions, longest
+// first so that they match greedily
+var arithOperators = []string{
+	"<<=", ">>=",
+	"**", "<<", ">>", "<=", ">=", "==", "!=", "&&", "||", "++", "--",
+	"+=", "-=", "*=", "/=", "%=", "&=", "|=", "^=",
+	"+", "-", "*", "/", "%", "<", ">", "=", "!", "~", "&", "|", "^",
+	"?", ":", ",", "(", ")",
+}
+
+// arithLevels are the binary operators from the lowest precedence to the
+// highest, as in C. All of them group to the left.
+var arithLevels = [][]string{
+	{"||"},
+	{"&&"},
+	{"|"},
+	{"^"},
+	{"&"},
+	{"==", "!="},
+	{"<", "<=", ">", ">="},
+	{"<<", ">>"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+// arithAssignments maps the assignment operators to the binary operator
+// they apply, with "" for plain assignment
+var arithAssignments = map[string]string{
+	"=": "", "+=": "+", "-=": "-", "*=": "*", "/=": "/", "%=": "%",
+	"<<=": "<<", ">>=": ">>", "&=": "&", "|=": "|", "^=": "^",
+}
+
+// errArithSyntax marks a malformed arithmetic expression
+var errArithSyntax = errors.New("syntax error")
+
+// arithmetic evaluates an arithmetic expression as written between (( and
+// )). Parameter expansions and command substitutions in it are expanded
+// first. Variables may then be referenced by name; an unset or empty
+// variable is 0.
+func (p *Parser) arithmetic(ctx context.Context, expr string) (int64, error) {
+	parts, err := p.expandQuoted(ctx, expr, hereDocEscapes)
+	if err != nil {
+		return 0, err
+	}
+	return p.evalArithmetic(joinParts(parts), 0)
+}
+
+// evalArithmetic evaluates an expanded arithmetic expression
+func (p *Parser) evalArithmetic(expr string, depth int) (int64, error) {
+	if depth > maxArithDepth {
+		return 0, fmt.Errorf("%s: expression recursion level exceeded", strings.TrimSpace(expr))
+	}
+
+	tokens, err := arithTokens(expr)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", strings.TrimSpace(expr), err)
+	}
+	if len(tokens) == 0 {
+		return 0, nil
+	}
+
+	ap := &arithParser{parser: p, tokens: tokens, depth: depth}
+	value, err := ap.parseComma()
+	if err == nil && ap.pos < len(ap.tokens) {
+		err = fmt.Errorf("%w: invalid arithmetic operator (error token is %q)", errArithSyntax, ap.tokens[ap.pos])
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", strings.TrimSpace(expr), err)
+	}
+	return value, nil
+}
+
+// arithParser evaluates the tokens of an arithmetic expression while
+// parsing them by recursive descent
+type arithParser struct {
+	parser *Parser
+	tokens []string
+	pos    int
+	depth  int
+	// skip is non-zero while parsing an operand that is not evaluated, such
+	// as the right side of && after a false left side
+	skip int
+}
+
+// parseComma parses expressions separated by commas, which are evaluated
+// in turn for the value of the last one
+func (ap *arithParser) parseComma() (int64, error) {
+	value, err := ap.parseAssignment()
+	for err == nil && ap.at(",") {
+		ap.pos++
+		value, err = ap.parseAssignment()
+	}
+	return value, err
+}
+
+// parseAssignment parses an assignment, which groups to the right, or an
+// expression without one
+func (ap *arithParser) parseAssignment() (int64, error) {
+	if ap.pos+1 < len(ap.tokens) && isArithVariable(ap.tokens[ap.pos]) {
+		if op, ok := arithAssignments[ap.tokens[ap.pos+1]]; ok {
+			name := ap.tokens[ap.pos]
+			ap.pos += 2
+
+			value, err := ap.parseAssignment()
+			if err != nil {
+				return 0, err
+			}
+			if op != "" {
+				current, err := ap.variable(name)
+				if err != nil {
+					return 0, err
+				}
+				if value, err = ap.apply(op, current, value); err != nil {
+					return 0, err
+				}
+			}
+			return value, ap.assign(name, value)
+		}
+	}
+	return ap.parseConditional()
+}
+
+// parseConditional parses cond ? a : b, which only evaluates the operand it
+// chooses
+func (ap *arithParser) parseConditional() (int64, error) {
+	cond, err := ap.parseBinary(0)
+	if err != nil || !ap.at("?") {
+		return cond, err
+	}
+	ap.pos++
+
+	a, err := ap.operand(cond == 0, ap.parseAssignment)
+	if err != nil {
+		return 0, err
+	}
+	if !ap.at(":") {
+		return 0, fmt.Errorf("%w: `:' expected for conditional expression", errArithSyntax)
+	}
+	ap.pos++
+	b, err := ap.operand(cond != 0, ap.parseConditional)
+	if err != nil {
+		return 0, err
+	}
+
+	if cond != 0 {
+		return a, nil
+	}
+	return b, nil
+}
+
+// operand parses an operand with parse, without evaluating it if skip is
+// set
+func (ap *arithParser) operand(skip bool, parse func() (int64, error)) (int64, error) {
+	if skip {
+		ap.skip++
+		defer func() { ap.skip-- }()
+	}
+	return parse()
+}
+
+// parseBinary parses the operators of arithLevels[level] and those that
+// bind more tightly
+func (ap *arithParser) parseBinary(level int) (int64, error) {
+	if level == len(arithLevels) {
+		return ap.parsePower()
+	}
+
+	left, err := ap.parseBinary(level + 1)
+	if err != nil {
+		return 0, err
+	}
+
+	for ap.pos < len(ap.tokens) && slices.Contains(arithLevels[level], ap.tokens[ap.pos]) {
+		op := ap.tokens[ap.pos]
+		ap.pos++
+
+		// The right side of && and || is only evaluated when it decides
+		// the result
+		shortCircuit := (op == "&&" && left == 0) || (op == "||" && left != 0)
+		right, err := ap.operand(shortCircuit, func() (int64, error) { return ap.parseBinary(level + 1) })
+		if err != nil {
+			return 0, err
+		}
+
+		if left, err = ap.apply(op, left, right); err != nil {
+			return 0, err
+		}
+	}
+	return left, nil
+}
+
+// parsePower parses exponentiation, which groups to the right and binds
+// less tightly than the unary operators
+func (ap *arithParser) parsePower() (int64, error) {
+	base, err := ap.parseUnary()
+	if err != nil || !ap.at("**") {
+		return base, err
+	}
+	ap.pos++
+
+	exp, err := ap.parsePower()
+	if err != nil {
+		return 0, err
+	}
+	return ap.apply("**", base, exp)
+}
+
+// parseUnary parses the prefix operators ! ~ - + ++ and --
+func (ap *arithParser) parseUnary() (int64, error) {
+	if ap.pos >= len(ap.tokens) {
+		return 0, fmt.Errorf("%w: operand expected", errArithSyntax)
+	}
+
+	switch op := ap.tokens[ap.pos]; op {
+	case "!", "~", "-", "+":
+		ap.pos++
+		value, err := ap.parseUnary()
+		if err != nil {
+			return 0, err
+		}
+		switch op {
+		case "!":
+			return boolInt(value == 0), nil
+		case "~":
+			return ^value, nil
+		case "-":
+			return -value, nil
+		}
+		return value, nil
+	case "++", "--":
+		ap.pos++
+		if ap.pos >= len(ap.tokens) || !isArithVariable(ap.tokens[ap.pos]) {
+			return 0, fmt.Errorf("%w: variable expected after %s", errArithSyntax, op)
+		}
+		name := ap.tokens[ap.pos]
+		ap.pos++
+
+		value, err := ap.variable(name)
+		if err != nil {
+			return 0, err
+		}
+		value += arithStep(op)
+		return value, ap.assign(name, value)
+	}
+	return ap.parsePostfix()
+}
+
+// parsePostfix parses an operand followed by ++ or --
+func (ap *arithParser) parsePostfix() (int64, error) {
+	tok := ap.tokens[ap.pos]
+	if !isArithVariable(tok) || ap.pos+1 >= len(ap.tokens) || (ap.tokens[ap.pos+1] != "++" && ap.tokens[ap.pos+1] != "--") {
+		return ap.parsePrimary()
+	}
+	op := ap.tokens[ap.pos+1]
+	ap.pos += 2
+
+	value, err := ap.variable(tok)
+	if err != nil {
+		return 0, err
+	}
+	return value, ap.assign(tok, value+arithStep(op))
+}
+
+// parsePrimary parses a number, a variable or a parenthesized expression
+func (ap *arithParser) parsePrimary() (int64, error) {
+	tok := ap.tokens[ap.pos]
+	ap.pos++
+
+	switch {
+	case tok == "(":
+		value, err := ap.parseComma()
+		if err != nil {
+			return 0, err
+		}
+		if !ap.at(")") {
+			return 0, fmt.Errorf("%w: missing `)'", errArithSyntax)
+		}
+		ap.pos++
+		return value, nil
+	case isArithVariable(tok):
+		return ap.variable(tok)
+	case isDigit(tok[0]):
+		return parseArithNumber(tok)
+	default:
+		return 0, fmt.Errorf("%w: operand expected (error token is %q)", errArithSyntax, tok)
+	}
+}
+
+// at reports whether the next token is tok
+func (ap *arithParser) at(tok string) bool {
+	return ap.pos < len(ap.tokens) && ap.tokens[ap.pos] == tok
+}
+
+// variable returns the value of a variable or array element. A value that
+// is not a number is evaluated as an expression in turn.
+func (ap *arithParser) variable(ref string) (int64, error) {
+	if ap.skip > 0 {
+		return 0, nil
+	}
+
+	name, key, err := ap.element(ref)
+	if err != nil {
+		return 0, err
+	}
+	var value string
+	if key == "" {
+		value = ap.parser.getVariable(name)
+	} else {
+		v, _ := ap.parser.config.Variables.Get(name)
+		value, _ = v.Element(key)
+	}
+
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	if n, err := parseArithNumber(value); err == nil {
+		return n, nil
+	}
+	return ap.parser.evalArithmetic(value, ap.depth+1)
+}
+
+// assign sets a variable or array element to the result of an assignment,
+// unless the operand is not being evaluated
+func (ap *arithParser) assign(ref string, value int64) error {
+	if ap.skip > 0 {
+		return nil
+	}
+
+	name, key, err := ap.element(ref)
+	if err != nil {
+		return err
+	}
+	if key == "" {
+		return ap.parser.setVariable(name, strconv.FormatInt(value, 10))
+	}
+	return ap.parser.assignElement(name, key, strconv.FormatInt(value, 10))
+}
+
+// element splits a reference to an array element, written name[index],
+// into the name and the key of the element. For a plain variable name the
+// key is empty.
+func (ap *arithParser) element(ref string) (string, string, error) {
+	name, index, ok := strings.Cut(ref, "[")
+	if !ok {
+		return ref, "", nil
+	}
+	index = strings.TrimSuffix(index, "]")
+
+	v, _ := ap.parser.config.Variables.Get(name)
+	if v.Has(config.AttrAssoc) {
+		return name, index, nil
+	}
+	n, err := ap.parser.evalArithmetic(index, ap.depth+1)
+	if err != nil {
+		return "", "", err
+	}
+	key, err := elementIndex(v, name, index, n)
+	return name, key, err
+}
+
+// apply applies a binary operator
+func (ap *arithParser) apply(op string, left, right int64) (int64, error) {
+	switch op {
+	case "||":
+		return boolInt(left != 0 || right != 0), nil
+	case "&&":
+		return boolInt(left != 0 && right != 0), nil
+	case "|":
+		return left | right, nil
+	case "^":
+		return left ^ right, nil
+	case "&":
+		return left & right, nil
+	case "==":
+		return boolInt(left == right), nil
+	case "!=":
+		return boolInt(left != right), nil
+	case "<":
+		return boolInt(left < right), nil
+	case "<=":
+		return boolInt(left <= right), nil
+	case ">":
+		return boolInt(left > right), nil
+	case ">=":
+		return boolInt(left >= right), nil
+	case "<<":
+		return left << (uint64(right) & 63), nil
+	case ">>":
+		return left >> (uint64(right) & 63), nil
+	case "+":
+		return left + right, nil
+	case "-":
+		return left - right, nil
+	case "*":
+		return left * right, nil
+	case "**":
+		if right < 0 {
+			if ap.skip > 0 {
+				return 0, nil
+			}
+			return 0, errors.New("exponent less than 0")
+		}
+		// Exponentiation by squaring, so that large exponents are quick;
+		// the result wraps around like the other operators
+		result := int64(1)
+		for ; right > 0; right >>= 1 {
+			if right&1 == 1 {
+				result *= left
+			}
+			left *= left
+		}
+		return result, nil
+	case "/", "%":
+		if right == 0 {
+			if ap.skip > 0 {
+				return 0, nil
+			}
+			return 0, errors.New("division by 0")
+		}
+		if op == "/" {
+			return left / right, nil
+		}
+		return left % right, nil
+	}
+	return 0, fmt.Errorf("%w: unknown operator %s", errArithSyntax, op)
+}
+
+// isArithVariable reports whether a token names a variable or an array
+// element, which can be assigned
+func isArithVariable(tok string) bool {
+	name, _, _ := strings.Cut(tok, "[")
+	return isName(name)
+}
+
+// arithTokens splits an arithmetic expression into numbers, names and
+// operators. A name followed by a subscript, as in arr[i+1], is one token.
+func arithTokens(expr string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+			continue
+		case isNameByte(c, false):
+			// Names and numbers, including 0x1f and 16#ff
+			end := i + 1
+			for end < len(expr) && (isNameByte(expr[end], false) || (isDigit(c) && (expr[end] == '#' || expr[end] == '@'))) {
+				end++
+			}
+			if !isDigit(c) && end < len(expr) && expr[end] == '[' {
+				sub := subscriptEnd(expr[end:])
+				if sub < 0 {
+					return nil, fmt.Errorf("%w: missing `]'", errArithSyntax)
+				}
+				end += sub
+			}
+			tokens = append(tokens, expr[i:end])
+			i = end
+			continue
+		}
+
+		op := ""
+		for _, candidate := range arithOperators {
+			if strings.HasPrefix(expr[i:], candidate) {
+				op = candidate
+				break
+			}
+		}
+		if op == "" {
+			return nil, fmt.Errorf("%w: invalid arithmetic operator (error token is %q)", errArithSyntax, expr[i:])
+		}
+		tokens = append(tokens, op)
+		i += len(op)
+	}
+	return tokens, nil
+}
+
+// parseArithNumber parses a decimal number, an octal number with a leading
+// 0, a hexadecimal number with a leading 0x, or a number in any base from 2
+// to 64 written base#digits. Above base 10 the digits go on with the
+// letters, then @ and _; up to base 36 the case of the letters does not
+// matter.
+func parseArithNumber(s string) (int64, error) {
+	tooGreat := fmt.Errorf("%s: value too great for base (error token is %q)", s, s)
+
+	if baseText, digits, ok := strings.Cut(s, "#"); ok {
+		base, err := strconv.Atoi(baseText)
+		if err != nil || base < 2 || base > 64 {
+			return 0, fmt.Errorf("%s: invalid arithmetic base (error token is %q)", s, s)
+		}
+		if digits == "" {
+			return 0, tooGreat
+		}
+		var n int64
+		for i := 0; i < len(digits); i++ {
+			d := digitValue(digits[i], base)
+			if d < 0 || d >= base {
+				return 0, tooGreat
+			}
+			n = n*int64(base) + int64(d)
+		}
+		return n, nil
+	}
+
+	base, digits := 10, s
+	switch {
+	case strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X"):
+		base, digits = 16, s[2:]
+	case len(s) > 1 && s[0] == '0':
+		base, digits = 8, s[1:]
+	}
+
+	n, err := strconv.ParseInt(digits, base, 64)
+	if err != nil {
+		return 0, tooGreat
+	}
+	return n, nil
+}
+
+// digitValue returns the value of a digit in a number written base#digits,
+// or -1 if c is not a digit
+func digitValue(c byte, base int) int {
+	switch {
+	case isDigit(c):
+		return int(c - '0')
+	case c >= 'a' && c <= 'z':
+		return int(c-'a') + 10
+	case c >= 'A' && c <= 'Z' && base <= 36:
+		return int(c-'A') + 10
+	case c >= 'A' && c <= 'Z':
+		return int(c-'A') + 36
+	case c == '@':
+		return 62
+	case c == '_':
+		return 63
+	}
+	return -1
+}
+
+// arithStep returns the change made by ++ or --
+func arithStep(op string) int64 {
+	if op == "++" {
+		return 1
+	}
+	return -1
+}
+
+// boolInt converts a truth value to 1 or 0
+func boolInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,131 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"gosh/internal/config"
+)
+
+func TestArithmetic(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		want    int64
+		wantVar string
+		wantErr bool
+	}{
+		{name: "empty", expr: "  ", want: 0},
+		{name: "precedence", expr: "1 + 2 * 3 - 4 / 2", want: 5},
+		{name: "parentheses", expr: "(1 + 2) * 3", want: 9},
+		{name: "remainder", expr: "17 % 5", want: 2},
+		{name: "unary", expr: "-3 + +2 + !0 + !5", want: 0},
+		{name: "comparison", expr: "(2 < 3) + (3 <= 3) + (4 > 5) + (1 == 1) + (1 != 1)", want: 3},
+		{name: "logical", expr: "1 && 0 || 2", want: 1},
+		{name: "octal and hex", expr: "010 + 0x10", want: 24},
+		{name: "bases", expr: "2#1010 + 16#ff + 36#Z + 64#_ + 64#A", want: 10 + 255 + 35 + 63 + 36},
+		{name: "bitwise", expr: "(6 & 3) | (8 ^ 12) | ~-1", want: 6},
+		{name: "shifts", expr: "1 << 4 >> 2", want: 4},
+		{name: "C precedence", expr: "1 | 2 ^ 3 & 4 == 4 + 1 << 1", want: 3},
+		{name: "power groups to the right", expr: "2 ** 3 ** 2", want: 512},
+		{name: "power binds less than unary minus", expr: "-2 ** 2", want: 4},
+		{name: "large power wraps around", expr: "3 ** 999999999999", want: 2692973649790921387},
+		{name: "power of zero", expr: "7 ** 0", want: 1},
+		{name: "conditional", expr: "N > 5 ? N * 2 : 0", want: 14},
+		{name: "nested conditional", expr: "0 ? 1 : 0 ? 2 : 3", want: 3},
+		{name: "conditional skips the other side", expr: "1 ? x : x++", want: 10, wantVar: "10"},
+		{name: "comma", expr: "x = 1, x + 1", want: 2, wantVar: "1"},
+		{name: "bitwise assignment", expr: "x <<= 2, x |= 1", want: 41, wantVar: "41"},
+		{name: "array element", expr: "ARR[1] * ARR[-1]", want: 60},
+		{name: "variables by name", expr: "N * 2", want: 14},
+		{name: "variables by expansion", expr: "$N + 1", want: 8},
+		{name: "unset variable is zero", expr: "UNSET_ARITH_VAR + 1", want: 1},
+		{name: "variable holding an expression", expr: "EXPR * 2", want: 18},
+		{name: "assignment", expr: "x = 4 * 2", want: 8, wantVar: "8"},
+		{name: "add assignment", expr: "x += N", want: 17, wantVar: "17"},
+		{name: "chained assignment", expr: "y = x = 3", want: 3, wantVar: "3"},
+		{name: "pre-increment", expr: "++x", want: 11, wantVar: "11"},
+		{name: "post-decrement", expr: "x--", want: 10, wantVar: "9"},
+		{name: "short circuit skips side effects", expr: "0 && x++", want: 0, wantVar: "10"},
+		{name: "short circuit skips division by zero", expr: "1 || 1 / 0", want: 1},
+		{name: "division by zero", expr: "1 / 0", wantErr: true},
+		{name: "missing operand", expr: "1 +", wantErr: true},
+		{name: "unbalanced parenthesis", expr: "(1 + 2", wantErr: true},
+		{name: "invalid number", expr: "09", wantErr: true},
+		{name: "invalid character", expr: "1 @ 2", wantErr: true},
+		{name: "digit too great for base", expr: "2#102", wantErr: true},
+		{name: "invalid base", expr: "65#1", wantErr: true},
+		{name: "negative exponent", expr: "2 ** -1", wantErr: true},
+		{name: "missing colon", expr: "1 ? 2", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			_ = cfg.Variables.Set("N", "7")
+			_ = cfg.Variables.Set("EXPR", "N + 2")
+			_ = cfg.Variables.Set("x", "10")
+			_ = cfg.Variables.SetArray("ARR", map[string]string{"0": "4", "1": "5", "2": "12"})
+
+			got, err := New(cfg).arithmetic(context.Background(), tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("arithmetic(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got != tt.want {
+				t.Errorf("arithmetic(%q) = %d, want %d", tt.expr, got, tt.want)
+			}
+			if x, _ := cfg.Variables.Lookup("x"); tt.wantVar != "" && x != tt.wantVar {
+				t.Errorf("x = %q, want %q", x, tt.wantVar)
+			}
+		})
+	}
+}
+
+func TestArithmeticExecute(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		wantOutput string
+		wantStatus int
+	}{
+		{name: "expansion", input: "echo $((1 + 2)) $(( 16#10 ))x", wantOutput: "3 16x\n"},
+		{name: "expansion with variables", input: "n=4; echo $((n * $n)) \"$((n -= 1))\" $n", wantOutput: "16 3 3\n"},
+		{name: "nested parentheses", input: "echo $(( (1 + 2) * (3 + 4) ))", wantOutput: "21\n"},
+		{name: "not a subshell", input: "echo $( (echo sub) )", wantOutput: "sub\n"},
+		{name: "expansion error", input: "echo $((1 / 0))", wantStatus: 1},
+		{name: "command true", input: "(( 2 > 1 ))", wantStatus: 0},
+		{name: "command false", input: "(( 0 ))", wantStatus: 1},
+		{name: "command assigns", input: "(( x = 5, y = x * 2 )); echo $x $y", wantOutput: "5 10\n"},
+		{name: "command error", input: "(( 1 + ))", wantStatus: 1},
+		{name: "command in a condition", input: "i=0; while (( i++ < 3 )); do echo $i; done", wantOutput: "1\n2\n3\n"},
+		{name: "array elements", input: "a=(1 2); (( a[1] += 5, a[2] = a[0] )); echo ${a[@]}", wantOutput: "1 7 1\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			parser := New(cfg)
+
+			cmd, err := parser.Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse() failed: %v", err)
+			}
+
+			var stdout, stderr bytes.Buffer
+			ctx := WithIO(context.Background(), IO{Stdin: strings.NewReader(""), Stdout: &stdout, Stderr: &stderr})
+			ctx = WithState(ctx, NewState(DefaultShellName, nil))
+			status, err := cmd.Execute(ctx, cfg)
+			if status != tt.wantStatus {
+				t.Errorf("Execute() status = %d (%v), want %d", status, err, tt.wantStatus)
+			}
+			if stdout.String() != tt.wantOutput {
+				t.Errorf("output = %q, want %q (stderr %q)", stdout.String(), tt.wantOutput, stderr.String())
+			}
+		})
+	}
+}
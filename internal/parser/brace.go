@@ -0,0 +1,191 @@
+package parser
+
+import (
+	"strconv"
+	"strings"
+
+	"gosh/internal/lexer"
+)
+
+// maxBraceSequence limits the number of words a {x..y} sequence may produce
+const maxBraceSequence = 1 << 16
+
+// expandBraces performs brace expansion on the source text of a word, as in
+// file{1..3}.txt or {src,test}/*.go. Braces that are quoted, escaped, part of
+// a $ expansion or not a valid list or sequence are left alone.
+func expandBraces(word string) []string {
+	for open := 0; open < len(word); {
+		switch word[open] {
+		case '\\':
+			open += 2
+			continue
+		case '\'', '"':
+			end := lexer.QuoteEnd(word, open)
+			if end < 0 {
+				return []string{word}
+			}
+			open = end
+			continue
+		case '$', '`':
+			open = expansionEnd(word, open)
+			continue
+		case '{':
+		default:
+			open++
+			continue
+		}
+
+		alternatives, end := braceAlternatives(word, open)
+		if alternatives == nil {
+			open++
+			continue
+		}
+
+		prefix, suffix := word[:open], word[end:]
+		var words []string
+		for _, alt := range alternatives {
+			words = append(words, expandBraces(prefix+alt+suffix)...)
+		}
+		return words
+	}
+	return []string{word}
+}
+
+// braceAlternatives returns the words of the brace expression starting at
+// word[open] and the end of the expression, or nil if it is not valid
+func braceAlternatives(word string, open int) ([]string, int) {
+	var commas []int
+	depth := 0
+	for i := open + 1; i < len(word); {
+		switch word[i] {
+		case '\\':
+			i += 2
+		case '\'', '"':
+			end := lexer.QuoteEnd(word, i)
+			if end < 0 {
+				return nil, 0
+			}
+			i = end
+		case '$', '`':
+			i = expansionEnd(word, i)
+		case '{':
+			depth++
+			i++
+		case ',':
+			if depth == 0 {
+				commas = append(commas, i)
+			}
+			i++
+		case '}':
+			if depth > 0 {
+				depth--
+				i++
+				continue
+			}
+			if len(commas) == 0 {
+				return braceSequence(word[open+1 : i]), i + 1
+			}
+
+			var alternatives []string
+			start := open + 1
+			for _, comma := range commas {
+				alternatives = append(alternatives, word[start:comma])
+				start = comma + 1
+			}
+			return append(alternatives, word[start:i]), i + 1
+		default:
+			i++
+		}
+	}
+	return nil, 0
+}
+
+// braceSequence expands the inside of a {x..y} or {x..y..step} sequence of
+// integers or letters, or returns nil if it is not one. Integers written
+// with leading zeros are padded to the same width.
+func braceSequence(expr string) []string {
+	bounds := strings.Split(expr, "..")
+	if len(bounds) != 2 && len(bounds) != 3 {
+		return nil
+	}
+
+	step := 1
+	if len(bounds) == 3 {
+		n, err := strconv.Atoi(bounds[2])
+		if err != nil {
+			return nil
+		}
+		step = max(n, -n, 1)
+	}
+
+	var from, to int
+	format := braceLetter
+	if isLetter(bounds[0]) && isLetter(bounds[1]) {
+		from, to = int(bounds[0][0]), int(bounds[1][0])
+	} else {
+		var errFrom, errTo error
+		from, errFrom = strconv.Atoi(bounds[0])
+		to, errTo = strconv.Atoi(bounds[1])
+		if errFrom != nil || errTo != nil {
+			return nil
+		}
+
+		width := 0
+		if hasLeadingZero(bounds[0]) || hasLeadingZero(bounds[1]) {
+			width = max(len(bounds[0]), len(bounds[1]))
+		}
+		format = func(n int) string { return padNumber(n, width) }
+	}
+
+	if from > to {
+		step = -step
+	}
+	var words []string
+	for n := from; len(words) < maxBraceSequence; n += step {
+		if (step > 0 && n > to) || (step < 0 && n < to) {
+			break
+		}
+		words = append(words, format(n))
+	}
+	return words
+}
+
+// braceLetter formats a character of a letter sequence. The sequence runs
+// through the ASCII table, so {a..Z} includes [, \ and `; these are escaped
+// so that they stay literal when the word is expanded further.
+func braceLetter(n int) string {
+	c := string(rune(n))
+	if isNameByte(byte(n), false) {
+		return c
+	}
+	return `\` + c
+}
+
+// padNumber formats n with leading zeros up to width characters, keeping
+// a minus sign in front
+func padNumber(n, width int) string {
+	digits := strconv.Itoa(n)
+	if n < 0 {
+		digits = digits[1:]
+		width--
+	}
+	if len(digits) < width {
+		digits = strings.Repeat("0", width-len(digits)) + digits
+	}
+	if n < 0 {
+		return "-" + digits
+	}
+	return digits
+}
+
+// hasLeadingZero reports whether a sequence bound is written with a
+// leading zero, as in 01 or -05
+func hasLeadingZero(bound string) bool {
+	bound = strings.TrimPrefix(bound, "-")
+	return len(bound) > 1 && bound[0] == '0'
+}
+
+// isLetter reports whether s is a single ASCII letter
+func isLetter(s string) bool {
+	return len(s) == 1 && isNameByte(s[0], true) && s[0] != '_'
+}
@@ -0,0 +1,44 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpandBraces(t *testing.T) {
+	tests := []struct {
+		word string
+		want []string
+	}{
+		{"plain", []string{"plain"}},
+		{"{a,b,c}", []string{"a", "b", "c"}},
+		{"x{a,b}y", []string{"xay", "xby"}},
+		{"{a,b}{1,2}", []string{"a1", "a2", "b1", "b2"}},
+		{"{a,{b,c}}d", []string{"ad", "bd", "cd"}},
+		{"{a,}", []string{"a", ""}},
+		{"file{1..3}.txt", []string{"file1.txt", "file2.txt", "file3.txt"}},
+		{"{3..1}", []string{"3", "2", "1"}},
+		{"{-1..1}", []string{"-1", "0", "1"}},
+		{"{01..10..4}", []string{"01", "05", "09"}},
+		{"{1..10..-4}", []string{"1", "5", "9"}},
+		{"{a..e..2}", []string{"a", "c", "e"}},
+		{"{e..c}", []string{"e", "d", "c"}},
+		{"{Z..a..3}", []string{"Z", `\]`, "\\`"}},
+		{"{x}", []string{"{x}"}},
+		{"{}", []string{"{}"}},
+		{"{1..a}", []string{"{1..a}"}},
+		{"{a,b", []string{"{a,b"}},
+		{"{x{a,b}", []string{"{xa", "{xb"}},
+		{`"{a,b}"`, []string{`"{a,b}"`}},
+		{`\{a,b}`, []string{`\{a,b}`}},
+		{"${HOME}", []string{"${HOME}"}},
+		{"{$A,${B}}", []string{"$A", "${B}"}},
+		{"{'a,b',c}", []string{"'a,b'", "c"}},
+	}
+
+	for _, tt := range tests {
+		if got := expandBraces(tt.word); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("expandBraces(%q) = %q, want %q", tt.word, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,548 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/chzyer/readline"
+
+	"gosh/internal/config"
+)
+
+// unaryTests are the operators that take one operand in test, [ and [[ ]]
+var unaryTests = map[string]bool{
+	"-a": true, "-b": true, "-c": true, "-d": true, "-e": true, "-f": true,
+	"-g": true, "-h": true, "-k": true, "-L": true, "-n": true, "-N": true, "-o": true, "-O": true,
+	"-G": true, "-p": true, "-r": true, "-s": true, "-S": true, "-t": true,
+	"-u": true, "-v": true, "-w": true, "-x": true, "-z": true,
+}
+
+// binaryTests are the operators that compare two operands in test, [ and
+// [[ ]]. =~ is only recognized inside [[ ]].
+var binaryTests = map[string]bool{
+	"=": true, "==": true, "!=": true, "<": true, ">": true,
+	"-eq": true, "-ne": true, "-lt": true, "-le": true, "-gt": true, "-ge": true,
+	"-nt": true, "-ot": true, "-ef": true,
+}
+
+// rematchVariable is the array that [[ ]] fills with the text matched by
+// =~ and its parenthesized subexpressions
+const rematchVariable = "BASH_REMATCH"
+
+// TestCommand implements the test and [ built-in commands, which evaluate
+// a conditional expression for their exit status: 0 if it is true, 1 if it
+// is false and 2 if it is invalid
+type TestCommand struct {
+	// Name is "test" or "[", which requires a closing ]
+	Name   string
+	Args   []string
+	parser *Parser
+}
+
+// Execute implements the Command interface for TestCommand
+func (c *TestCommand) Execute(ctx context.Context, cfg *config.Config) (int, error) {
+	args := c.Args
+	if c.Name == "[" {
+		if len(args) == 0 || args[len(args)-1] != "]" {
+			return ExitUsage, errors.New("[: missing `]'")
+		}
+		args = args[:len(args)-1]
+	}
+
+	tp := &testParser{ctx: ctx, parser: c.parser.withConfig(cfg), args: args}
+	result, err := tp.eval()
+	if err != nil {
+		return ExitUsage, fmt.Errorf("%s: %w", c.Name, err)
+	}
+	return condStatus(result), nil
+}
+
+// testParser evaluates the arguments of test by recursive descent
+//
+//	or      := and ('-o' and)*
+//	and     := not ('-a' not)*
+//	not     := '!' not | primary
+//	primary := '(' or ')' | unary-op arg | arg binary-op arg | arg
+type testParser struct {
+	ctx    context.Context
+	parser *Parser
+	args   []string
+	pos    int
+}
+
+// eval evaluates the whole expression. Like POSIX test, up to four
+// arguments are taken apart by their number, so that [ -n ] and [ ! = x ]
+// mean what they do elsewhere; longer expressions are parsed.
+func (tp *testParser) eval() (bool, error) {
+	args := tp.args
+	switch len(args) {
+	case 0:
+		return false, nil
+	case 1:
+		return args[0] != "", nil
+	case 2:
+		if args[0] == "!" {
+			return args[1] == "", nil
+		}
+		if unaryTests[args[0]] {
+			return tp.parser.unaryTest(tp.ctx, args[0], args[1])
+		}
+		return false, fmt.Errorf("%s: unary operator expected", args[0])
+	case 3:
+		switch {
+		case binaryTests[args[1]]:
+			return binaryTest(tp.ctx, args[1], args[0], args[2])
+		case args[1] == "-a":
+			return args[0] != "" && args[2] != "", nil
+		case args[1] == "-o":
+			return args[0] != "" || args[2] != "", nil
+		case args[0] == "!":
+			return tp.negate(args[1:])
+		case args[0] == "(" && args[2] == ")":
+			return args[1] != "", nil
+		}
+	case 4:
+		switch {
+		case args[0] == "!":
+			return tp.negate(args[1:])
+		case args[0] == "(" && args[3] == ")":
+			sub := &testParser{ctx: tp.ctx, parser: tp.parser, args: args[1:3]}
+			return sub.eval()
+		}
+	}
+
+	result, err := tp.parseOr()
+	if err == nil && tp.pos < len(tp.args) {
+		err = errors.New("too many arguments")
+	}
+	return result, err
+}
+
+// negate evaluates args on their own and inverts the result
+func (tp *testParser) negate(args []string) (bool, error) {
+	sub := &testParser{ctx: tp.ctx, parser: tp.parser, args: args}
+	result, err := sub.eval()
+	return !result, err
+}
+
+// parseOr parses expressions joined by -o
+func (tp *testParser) parseOr() (bool, error) {
+	result, err := tp.parseAnd()
+	for err == nil && tp.at("-o") {
+		tp.pos++
+		var right bool
+		right, err = tp.parseAnd()
+		result = result || right
+	}
+	return result, err
+}
+
+// parseAnd parses expressions joined by -a
+func (tp *testParser) parseAnd() (bool, error) {
+	result, err := tp.parseNot()
+	for err == nil && tp.at("-a") {
+		tp.pos++
+		var right bool
+		right, err = tp.parseNot()
+		result = result && right
+	}
+	return result, err
+}
+
+// parseNot parses an expression negated with !
+func (tp *testParser) parseNot() (bool, error) {
+	if tp.at("!") && tp.pos+1 < len(tp.args) {
+		tp.pos++
+		result, err := tp.parseNot()
+		return !result, err
+	}
+	return tp.parsePrimary()
+}
+
+// parsePrimary parses a parenthesized expression, a unary or binary
+// operator with its operands, or a string that is true if it is not empty
+func (tp *testParser) parsePrimary() (bool, error) {
+	if tp.pos >= len(tp.args) {
+		return false, errors.New("argument expected")
+	}
+	arg := tp.args[tp.pos]
+
+	if arg == "(" {
+		tp.pos++
+		result, err := tp.parseOr()
+		if err != nil {
+			return false, err
+		}
+		if !tp.at(")") {
+			return false, errors.New("`)' expected")
+		}
+		tp.pos++
+		return result, nil
+	}
+
+	if tp.pos+2 < len(tp.args) && binaryTests[tp.args[tp.pos+1]] {
+		left, op, right := arg, tp.args[tp.pos+1], tp.args[tp.pos+2]
+		tp.pos += 3
+		return binaryTest(tp.ctx, op, left, right)
+	}
+
+	if unaryTests[arg] && tp.pos+1 < len(tp.args) {
+		operand := tp.args[tp.pos+1]
+		tp.pos += 2
+		return tp.parser.unaryTest(tp.ctx, arg, operand)
+	}
+
+	tp.pos++
+	return arg != "", nil
+}
+
+// at reports whether the next argument is arg
+func (tp *testParser) at(arg string) bool {
+	return tp.pos < len(tp.args) && tp.args[tp.pos] == arg
+}
+
+// unaryTest applies a unary operator to its operand: a file test such as
+// -f, a string test such as -z, -t for a terminal, -v for a set variable or
+// -o for a set -o option that is on
+func (p *Parser) unaryTest(ctx context.Context, op, operand string) (bool, error) {
+	switch op {
+	case "-o":
+		_, ok := config.LookupOption(config.OptionSet, operand)
+		return ok && p.config.OptionEnabled(operand), nil
+	case "-n":
+		return operand != "", nil
+	case "-z":
+		return operand == "", nil
+	case "-v":
+		v, ok := p.config.Variables.Get(operand)
+		return ok && v.Set, nil
+	case "-t":
+		fd, err := strconv.Atoi(strings.TrimSpace(operand))
+		if err != nil {
+			return false, fmt.Errorf("%s: integer expression expected", operand)
+		}
+		return isTerminal(ctx, fd), nil
+	}
+
+	// File tests look from the shell's directory
+	path := resolvePath(ctx, operand)
+	if op == "-h" || op == "-L" {
+		info, err := os.Lstat(path)
+		return err == nil && info.Mode()&os.ModeSymlink != 0, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, nil
+	}
+	mode := info.Mode()
+	switch op {
+	case "-a", "-e":
+		return true, nil
+	case "-b":
+		return mode&os.ModeDevice != 0 && mode&os.ModeCharDevice == 0, nil
+	case "-c":
+		return mode&os.ModeCharDevice != 0, nil
+	case "-d":
+		return mode.IsDir(), nil
+	case "-f":
+		return mode.IsRegular(), nil
+	case "-g":
+		return mode&os.ModeSetgid != 0, nil
+	case "-k":
+		return mode&os.ModeSticky != 0, nil
+	case "-p":
+		return mode&os.ModeNamedPipe != 0, nil
+	case "-s":
+		return info.Size() > 0, nil
+	case "-S":
+		return mode&os.ModeSocket != 0, nil
+	case "-u":
+		return mode&os.ModeSetuid != 0, nil
+	case "-r":
+		return accessible(path, accessRead), nil
+	case "-w":
+		return accessible(path, accessWrite), nil
+	case "-x":
+		return accessible(path, accessExecute), nil
+	case "-O":
+		return ownedByUser(info), nil
+	case "-G":
+		return ownedByGroup(info), nil
+	case "-N":
+		// Modified since it was last read
+		atime, ok := accessTime(info)
+		return ok && info.ModTime().After(atime), nil
+	}
+	return false, fmt.Errorf("%s: unary operator expected", op)
+}
+
+// isTerminal reports whether descriptor fd of the command is a terminal
+func isTerminal(ctx context.Context, fd int) bool {
+	streams := IOFromContext(ctx)
+	var stream interface{}
+	switch fd {
+	case 0:
+		stream = streams.Stdin
+	case 1:
+		stream = streams.Stdout
+	case 2:
+		stream = streams.Stderr
+	default:
+		stream = streams.Extra[fd]
+	}
+	f, ok := stream.(*os.File)
+	return ok && readline.IsTerminal(int(f.Fd()))
+}
+
+// binaryTest compares two strings, integers or files. = and == compare
+// strings, as do < and > by their order.
+func binaryTest(ctx context.Context, op, left, right string) (bool, error) {
+	switch op {
+	case "=", "==":
+		return left == right, nil
+	case "!=":
+		return left != right, nil
+	case "<":
+		return left < right, nil
+	case ">":
+		return left > right, nil
+	case "-nt", "-ot":
+		l, lErr := os.Stat(resolvePath(ctx, left))
+		r, rErr := os.Stat(resolvePath(ctx, right))
+		if op == "-ot" {
+			l, lErr, r, rErr = r, rErr, l, lErr
+		}
+		switch {
+		case lErr != nil:
+			return false, nil
+		case rErr != nil:
+			return true, nil
+		default:
+			return l.ModTime().After(r.ModTime()), nil
+		}
+	case "-ef":
+		l, lErr := os.Stat(resolvePath(ctx, left))
+		r, rErr := os.Stat(resolvePath(ctx, right))
+		return lErr == nil && rErr == nil && os.SameFile(l, r), nil
+	}
+
+	a, err := parseTestInt(left)
+	if err != nil {
+		return false, err
+	}
+	b, err := parseTestInt(right)
+	if err != nil {
+		return false, err
+	}
+	return compareInts(op, a, b), nil
+}
+
+// parseTestInt parses an operand of an integer comparison in test
+func parseTestInt(s string) (int64, error) {
+	n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s: integer expression expected", s)
+	}
+	return n, nil
+}
+
+// isIntegerTest reports whether op compares integers
+func isIntegerTest(op string) bool {
+	switch op {
+	case "-eq", "-ne", "-lt", "-le", "-gt", "-ge":
+		return true
+	}
+	return false
+}
+
+// compareInts applies an integer comparison operator such as -lt
+func compareInts(op string, a, b int64) bool {
+	switch op {
+	case "-eq":
+		return a == b
+	case "-ne":
+		return a != b
+	case "-lt":
+		return a < b
+	case "-le":
+		return a <= b
+	case "-gt":
+		return a > b
+	default:
+		return a >= b
+	}
+}
+
+// condStatus converts the result of a conditional expression into an exit
+// status
+func condStatus(result bool) int {
+	if result {
+		return 0
+	}
+	return ExitFailure
+}
+
+// condExpr is a node of the expression of a [[ ]] command. Its words are
+// kept as source text and expanded as the expression is evaluated, so that
+// && and || skip the expansions of the side they do not evaluate.
+type condExpr interface {
+	eval(ctx context.Context, p *Parser) (bool, error)
+}
+
+// condLogical joins two expressions with && or ||
+type condLogical struct {
+	Op    AndOrOp
+	Left  condExpr
+	Right condExpr
+}
+
+// eval implements condExpr for condLogical
+func (e *condLogical) eval(ctx context.Context, p *Parser) (bool, error) {
+	left, err := e.Left.eval(ctx, p)
+	if err != nil || left == (e.Op == OrIf) {
+		return left, err
+	}
+	return e.Right.eval(ctx, p)
+}
+
+// condNot inverts an expression, as written with !
+type condNot struct {
+	Expr condExpr
+}
+
+// eval implements condExpr for condNot
+func (e *condNot) eval(ctx context.Context, p *Parser) (bool, error) {
+	result, err := e.Expr.eval(ctx, p)
+	return !result, err
+}
+
+// condString is a word on its own, which is true if it is not empty
+type condString struct {
+	Word string
+}
+
+// eval implements condExpr for condString
+func (e *condString) eval(ctx context.Context, p *Parser) (bool, error) {
+	value, err := p.expandString(ctx, e.Word)
+	return value != "", err
+}
+
+// condUnary is a unary operator such as -f and its operand
+type condUnary struct {
+	Op   string
+	Word string
+}
+
+// eval implements condExpr for condUnary
+func (e *condUnary) eval(ctx context.Context, p *Parser) (bool, error) {
+	operand, err := p.expandString(ctx, e.Word)
+	if err != nil {
+		return false, err
+	}
+	return p.unaryTest(ctx, e.Op, operand)
+}
+
+// condBinary is a binary operator such as == and its operands. Unlike in
+// test, the right side of == and != is a pattern, that of =~ a regular
+// expression, and the operands of integer comparisons are arithmetic
+// expressions.
+type condBinary struct {
+	Op    string
+	Left  string
+	Right string
+}
+
+// eval implements condExpr for condBinary
+func (e *condBinary) eval(ctx context.Context, p *Parser) (bool, error) {
+	if isIntegerTest(e.Op) {
+		a, err := p.arithmetic(ctx, e.Left)
+		if err != nil {
+			return false, err
+		}
+		b, err := p.arithmetic(ctx, e.Right)
+		if err != nil {
+			return false, err
+		}
+		return compareInts(e.Op, a, b), nil
+	}
+
+	left, err := p.expandString(ctx, e.Left)
+	if err != nil {
+		return false, err
+	}
+
+	switch e.Op {
+	case "=", "==", "!=":
+		pattern, err := p.expandPattern(ctx, e.Right)
+		if err != nil {
+			return false, err
+		}
+		return matchPattern(pattern, left) == (e.Op != "!="), nil
+	case "=~":
+		return p.matchRegex(ctx, left, e.Right)
+	}
+
+	right, err := p.expandString(ctx, e.Right)
+	if err != nil {
+		return false, err
+	}
+	return binaryTest(ctx, e.Op, left, right)
+}
+
+// matchRegex matches s against the extended regular expression word, in
+// which quoted characters match only themselves. On a match BASH_REMATCH
+// is set to the matched text followed by that of each subexpression; it is
+// emptied otherwise.
+func (p *Parser) matchRegex(ctx context.Context, s, word string) (bool, error) {
+	parts, err := p.expandParts(ctx, word)
+	if err != nil {
+		return false, err
+	}
+	var b strings.Builder
+	for _, pt := range parts {
+		if pt.quoted {
+			b.WriteString(regexp.QuoteMeta(pt.text))
+		} else {
+			b.WriteString(pt.text)
+		}
+	}
+
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return false, fmt.Errorf("%s: invalid regular expression", b.String())
+	}
+
+	elements := make(map[string]string)
+	match := re.FindStringSubmatch(s)
+	for i, text := range match {
+		elements[strconv.Itoa(i)] = text
+	}
+	if err := p.config.Variables.SetArray(rematchVariable, elements); err != nil {
+		return false, err
+	}
+	return match != nil, nil
+}
+
+// CondCommand is the [[ expression ]] command. Its words are not split or
+// globbed, and its status is 0 if the expression is true, 1 if it is false
+// and 2 if it cannot be evaluated.
+type CondCommand struct {
+	Expr condExpr
+
+	parser *Parser
+}
+
+// Execute implements the Command interface for CondCommand
+func (c *CondCommand) Execute(ctx context.Context, cfg *config.Config) (int, error) {
+	result, err := c.Expr.eval(ctx, c.parser.withConfig(cfg))
+	if err != nil {
+		return checkErrexit(ctx, cfg, ExitUsage, fmt.Errorf("[[: %w", err))
+	}
+	return checkErrexit(ctx, cfg, condStatus(result), nil)
+}
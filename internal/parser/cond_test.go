@@ -0,0 +1,218 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gosh/internal/config"
+)
+
+func TestTestCommand(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	empty := filepath.Join(dir, "empty")
+	if err := os.WriteFile(empty, nil, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "link")
+	if err := os.Symlink(file, link); err != nil {
+		t.Fatal(err)
+	}
+	// file was modified after it was last read, empty read after it was
+	// modified
+	now := time.Now()
+	if err := os.Chtimes(file, now.Add(-time.Hour), now); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(empty, now, now.Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantStatus int
+	}{
+		{name: "no arguments", args: nil, wantStatus: 1},
+		{name: "non-empty string", args: []string{"x"}},
+		{name: "empty string", args: []string{""}, wantStatus: 1},
+		{name: "operator alone is a string", args: []string{"-n"}},
+		{name: "negated string", args: []string{"!", ""}},
+		{name: "-z", args: []string{"-z", ""}},
+		{name: "-n", args: []string{"-n", ""}, wantStatus: 1},
+		{name: "-d", args: []string{"-d", dir}},
+		{name: "-d on a file", args: []string{"-d", file}, wantStatus: 1},
+		{name: "-f", args: []string{"-f", file}},
+		{name: "-e missing", args: []string{"-e", filepath.Join(dir, "missing")}, wantStatus: 1},
+		{name: "-s", args: []string{"-s", file}},
+		{name: "-s empty", args: []string{"-s", empty}, wantStatus: 1},
+		{name: "-x", args: []string{"-x", empty}},
+		{name: "-L", args: []string{"-L", link}},
+		{name: "-h on a file", args: []string{"-h", file}, wantStatus: 1},
+		{name: "-N modified since read", args: []string{"-N", file}},
+		{name: "-N read since modified", args: []string{"-N", empty}, wantStatus: 1},
+		{name: "-ef", args: []string{link, "-ef", file}},
+		{name: "-o option off", args: []string{"-o", "errexit"}, wantStatus: 1},
+		{name: "-o unknown option", args: []string{"-o", "nosuchoption"}, wantStatus: 1},
+		{name: "-o option after -o", args: []string{"", "-o", "-o", "errexit"}, wantStatus: 1},
+		{name: "string equality", args: []string{"abc", "=", "abc"}},
+		{name: "string inequality", args: []string{"abc", "!=", "abc"}, wantStatus: 1},
+		{name: "equality takes no patterns", args: []string{"abc", "==", "a*"}, wantStatus: 1},
+		{name: "string order", args: []string{"abc", "<", "abd"}},
+		{name: "-lt", args: []string{"2", "-lt", "10"}},
+		{name: "-ge", args: []string{"-3", "-ge", "-2"}, wantStatus: 1},
+		{name: "operator as an operand", args: []string{"-n", "=", "-n"}},
+		{name: "negated comparison", args: []string{"!", "1", "-eq", "2"}},
+		{name: "parentheses", args: []string{"(", "x", ")"}},
+		{name: "-a", args: []string{"-n", "a", "-a", "-z", ""}},
+		{name: "-o", args: []string{"-z", "a", "-o", "-d", dir}},
+		{name: "-a binds more tightly than -o", args: []string{"x", "-o", "", "-a", ""}},
+		{name: "grouped", args: []string{"!", "(", "x", "-o", "", ")", "-a", "x"}, wantStatus: 1},
+		{name: "integer expected", args: []string{"a", "-eq", "1"}, wantStatus: 2},
+		{name: "unary operator expected", args: []string{"x", "y"}, wantStatus: 2},
+		{name: "too many arguments", args: []string{"a", "b", "c", "d", "e"}, wantStatus: 2},
+		{name: "unclosed parenthesis", args: []string{"(", "a", "=", "a"}, wantStatus: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			cmd := &TestCommand{Name: "test", Args: tt.args, parser: New(cfg)}
+			status, err := cmd.Execute(context.Background(), cfg)
+			if status != tt.wantStatus {
+				t.Errorf("test %q status = %d (%v), want %d", tt.args, status, err, tt.wantStatus)
+			}
+			if (err != nil) != (tt.wantStatus == ExitUsage) {
+				t.Errorf("test %q error = %v", tt.args, err)
+			}
+		})
+	}
+}
+
+func TestBracketCommand(t *testing.T) {
+	cfg := config.Default()
+	parser := New(cfg)
+
+	status, err := (&TestCommand{Name: "[", Args: []string{"a", "=", "a", "]"}, parser: parser}).Execute(context.Background(), cfg)
+	if status != 0 || err != nil {
+		t.Errorf("[ a = a ] = %d, %v, want 0", status, err)
+	}
+
+	status, err = (&TestCommand{Name: "[", Args: []string{"a", "=", "a"}, parser: parser}).Execute(context.Background(), cfg)
+	if status != ExitUsage || err == nil {
+		t.Errorf("[ a = a = %d, %v, want a missing ] error", status, err)
+	}
+}
+
+func TestParseCondErrors(t *testing.T) {
+	parser := New(config.Default())
+
+	incomplete := []string{
+		"[[",
+		"[[ -n x",
+		"[[ a ==",
+		"[[ a && ",
+		"[[ ( a",
+		"[[ a =~ (b",
+	}
+	for _, input := range incomplete {
+		if _, err := parser.Parse(input); !errors.Is(err, ErrIncomplete) {
+			t.Errorf("Parse(%q) error = %v, want ErrIncomplete", input, err)
+		}
+	}
+
+	invalid := []string{
+		"[[ ]]",
+		"[[ a b ]]",
+		"[[ a == ]]",
+		"[[ && a ]]",
+		"[[ ( a ]]",
+		"[[ a ; ]]",
+	}
+	for _, input := range invalid {
+		_, err := parser.Parse(input)
+		if err == nil || errors.Is(err, ErrIncomplete) {
+			t.Errorf("Parse(%q) error = %v, want a syntax error", input, err)
+		}
+	}
+}
+
+func TestCondExecute(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		wantOutput string
+		wantStatus int
+	}{
+		{name: "pattern match", input: "[[ main.go == *.go ]]"},
+		{name: "pattern mismatch", input: "[[ main.go != *.go ]]", wantStatus: 1},
+		{name: "quoted pattern is literal", input: `[[ main.go == "*.go" ]]`, wantStatus: 1},
+		{name: "pattern from a variable", input: "p='m*'; [[ main == $p ]]"},
+		{name: "words are not split", input: `v="a b"; [[ $v == "a b" ]]`},
+		{name: "empty variable needs no quotes", input: "unset v; [[ -z $v ]]"},
+		{name: "string order", input: "[[ apple < banana && cherry > banana ]]"},
+		{name: "integer comparison is arithmetic", input: "n=4; [[ n*2 -eq 8 ]]"},
+		{name: "and binds more tightly than or", input: "[[ x || '' && '' ]]"},
+		{name: "parentheses and negation", input: "[[ ! ( x && '' ) ]]"},
+		{name: "short circuit skips expansions", input: "[[ '' && $(echo run >&2) ]]; [[ x || ${unset?boom} ]]"},
+		{name: "newlines inside", input: "[[ a &&\n  b ]]"},
+		{name: "file test", input: "[[ -d / && ! -f / ]]"},
+		{name: "set variable", input: "v=; [[ -v v && ! -v nonexistent_var ]]"},
+		{name: "option", input: "set -o pipefail; [[ -o pipefail && ! -o errexit ]] && [ -o pipefail ]"},
+		{
+			name:       "regex with submatches",
+			input:      `[[ v1.23 =~ ^v([0-9]+)\.([0-9]+)$ ]] && echo "${BASH_REMATCH[@]}"`,
+			wantOutput: "v1.23 1 23\n",
+		},
+		{
+			name:       "regex alternation and groups",
+			input:      "[[ foobar =~ (foo|baz)(bar)? ]] && echo ${BASH_REMATCH[1]} ${#BASH_REMATCH[@]}",
+			wantOutput: "foo 3\n",
+		},
+		{name: "quoted regex is literal", input: `[[ a.c =~ "a.c" ]] && ! [[ abc =~ "a.c" ]]`},
+		{name: "regex from a variable", input: "re='^[0-9]+$'; [[ 123 =~ $re ]]"},
+		{
+			name:       "failed match empties BASH_REMATCH",
+			input:      "[[ ab =~ (a) ]]; [[ ab =~ z ]]; echo ${#BASH_REMATCH[@]}",
+			wantOutput: "0\n",
+		},
+		{name: "invalid regex", input: "re='a('; [[ a =~ $re ]]", wantStatus: 2},
+		{name: "in if", input: "if [[ -n x ]]; then echo yes; fi", wantOutput: "yes\n"},
+		{name: "test builtin in a list", input: "[ -d / ] && test 1 -lt 2 && echo ok", wantOutput: "ok\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			parser := New(cfg)
+
+			cmd, err := parser.Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse() failed: %v", err)
+			}
+
+			var stdout, stderr bytes.Buffer
+			ctx := WithIO(context.Background(), IO{Stdin: strings.NewReader(""), Stdout: &stdout, Stderr: &stderr})
+			ctx = WithState(ctx, NewState(DefaultShellName, nil))
+			status, err := cmd.Execute(ctx, cfg)
+			if status != tt.wantStatus {
+				t.Errorf("Execute() status = %d (%v), want %d", status, err, tt.wantStatus)
+			}
+			if stdout.String() != tt.wantOutput {
+				t.Errorf("output = %q, want %q", stdout.String(), tt.wantOutput)
+			}
+			if stderr.Len() > 0 && tt.wantStatus != ExitUsage {
+				t.Errorf("stderr = %q", stderr.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,328 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"gosh/internal/config"
+)
+
+// IfClause is a condition and the commands run when it succeeds
+type IfClause struct {
+	Cond Command
+	Body Command
+}
+
+// IfCommand runs the body of the first clause whose condition succeeds, or
+// its else part, as written with if, elif and else
+type IfCommand struct {
+	Clauses []IfClause
+	Else    Command
+}
+
+// Execute implements the Command interface for IfCommand
+func (c *IfCommand) Execute(ctx context.Context, cfg *config.Config) (int, error) {
+	for _, clause := range c.Clauses {
+		succeeded, status, err := runCondition(ctx, cfg, clause.Cond)
+		if err != nil {
+			return status, err
+		}
+		if succeeded {
+			return clause.Body.Execute(ctx, cfg)
+		}
+	}
+
+	if c.Else != nil {
+		return c.Else.Execute(ctx, cfg)
+	}
+	return 0, nil
+}
+
+// LoopCommand runs its body for as long as its condition succeeds, as
+// written with while, or until its condition succeeds, as written with until
+type LoopCommand struct {
+	Cond  Command
+	Body  Command
+	Until bool
+}
+
+// Execute implements the Command interface for LoopCommand
+func (c *LoopCommand) Execute(ctx context.Context, cfg *config.Config) (int, error) {
+	var status int
+	var result error
+	for {
+		if err := ctx.Err(); err != nil {
+			return ExitFailure, err
+		}
+
+		succeeded, condStatus, err := runCondition(ctx, cfg, c.Cond)
+		if err != nil {
+			return condStatus, err
+		}
+		if succeeded == c.Until {
+			return status, result
+		}
+
+		reportError(ctx, result)
+		var done bool
+		done, status, result = runIteration(ctx, cfg, c.Body)
+		if done {
+			return status, result
+		}
+	}
+}
+
+// ForCommand runs its body once for each word of its list, with the
+// variable Name set to the word
+type ForCommand struct {
+	Name  string
+	Words []string
+	Body  Command
+
+	parser *Parser
+}
+
+// Execute implements the Command interface for ForCommand
+func (c *ForCommand) Execute(ctx context.Context, cfg *config.Config) (int, error) {
+	p := c.parser.withConfig(cfg)
+	words, err := p.expandWords(ctx, c.Words)
+	if err != nil {
+		return ExitFailure, err
+	}
+
+	var status int
+	var result error
+	for _, word := range words {
+		if err := ctx.Err(); err != nil {
+			return ExitFailure, err
+		}
+
+		reportError(ctx, result)
+		if err := p.setVariable(c.Name, word); err != nil {
+			return ExitFailure, err
+		}
+		var done bool
+		done, status, result = runIteration(ctx, cfg, c.Body)
+		if done {
+			return status, result
+		}
+	}
+	return status, result
+}
+
+// ArithCommand is the (( expr )) command. Its status is 0 if the
+// expression is not zero, and 1 if it is.
+type ArithCommand struct {
+	Expr string
+
+	parser *Parser
+}
+
+// Execute implements the Command interface for ArithCommand
+func (c *ArithCommand) Execute(ctx context.Context, cfg *config.Config) (int, error) {
+	value, err := c.parser.withConfig(cfg).arithmetic(ctx, c.Expr)
+	if err != nil {
+		return checkErrexit(ctx, cfg, ExitFailure, fmt.Errorf("((: %w", err))
+	}
+	if value == 0 {
+		return checkErrexit(ctx, cfg, ExitFailure, nil)
+	}
+	return 0, nil
+}
+
+// ArithForCommand is the C-style for ((init; cond; step)) loop. An empty
+// condition is always true.
+type ArithForCommand struct {
+	Init string
+	Cond string
+	Step string
+	Body Command
+
+	parser *Parser
+}
+
+// Execute implements the Command interface for ArithForCommand
+func (c *ArithForCommand) Execute(ctx context.Context, cfg *config.Config) (int, error) {
+	p := c.parser.withConfig(cfg)
+	eval := func(expr string) (int64, error) {
+		value, err := p.arithmetic(ctx, expr)
+		if err != nil {
+			return 0, fmt.Errorf("((: %w", err)
+		}
+		return value, nil
+	}
+
+	if _, err := eval(c.Init); err != nil {
+		return ExitFailure, err
+	}
+
+	var status int
+	var result error
+	for {
+		if err := ctx.Err(); err != nil {
+			return ExitFailure, err
+		}
+
+		if c.Cond != "" {
+			value, err := eval(c.Cond)
+			if err != nil {
+				return ExitFailure, err
+			}
+			if value == 0 {
+				return status, result
+			}
+		}
+
+		reportError(ctx, result)
+		var done bool
+		done, status, result = runIteration(ctx, cfg, c.Body)
+		if done {
+			return status, result
+		}
+
+		if _, err := eval(c.Step); err != nil {
+			return ExitFailure, err
+		}
+	}
+}
+
+// CaseClause is a list of patterns and the commands run when one matches
+type CaseClause struct {
+	Patterns []string
+	Body     Command
+}
+
+// CaseCommand runs the body of the first clause with a pattern that matches
+// its word, as written with case ... esac
+type CaseCommand struct {
+	Word    string
+	Clauses []CaseClause
+
+	parser *Parser
+}
+
+// Execute implements the Command interface for CaseCommand
+func (c *CaseCommand) Execute(ctx context.Context, cfg *config.Config) (int, error) {
+	p := c.parser.withConfig(cfg)
+	word, err := p.expandString(ctx, c.Word)
+	if err != nil {
+		return ExitFailure, err
+	}
+
+	for _, clause := range c.Clauses {
+		for _, pattern := range clause.Patterns {
+			expanded, err := p.expandPattern(ctx, pattern)
+			if err != nil {
+				return ExitFailure, err
+			}
+			if matchPattern(expanded, word) {
+				return clause.Body.Execute(ctx, cfg)
+			}
+		}
+	}
+	return 0, nil
+}
+
+// BreakCommand implements the break built-in command, which leaves the
+// innermost loop or, given a count, that many enclosing loops
+type BreakCommand struct {
+	Args []string
+}
+
+// Execute implements the Command interface for BreakCommand
+func (c *BreakCommand) Execute(_ context.Context, _ *config.Config) (int, error) {
+	return ExitFailure, newLoopControl("break", c.Args)
+}
+
+// ContinueCommand implements the continue built-in command, which starts
+// the next pass of the innermost loop or, given a count, of an enclosing one
+type ContinueCommand struct {
+	Args []string
+}
+
+// Execute implements the Command interface for ContinueCommand
+func (c *ContinueCommand) Execute(_ context.Context, _ *config.Config) (int, error) {
+	return ExitFailure, newLoopControl("continue", c.Args)
+}
+
+// loopControl is returned by break and continue. It passes through the
+// commands that enclose them up to the loop it is aimed at, which is Levels
+// loops out.
+type loopControl struct {
+	Op     string
+	Levels int
+}
+
+// Error implements the error interface for loopControl. It is only seen
+// when break or continue is used outside a loop.
+func (e *loopControl) Error() string {
+	return fmt.Sprintf("%s: only meaningful in a `for', `while', or `until' loop", e.Op)
+}
+
+// newLoopControl parses the loop count of break or continue
+func newLoopControl(op string, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("%s: too many arguments", op)
+	}
+
+	levels := 1
+	if len(args) == 1 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf("%s: %s: numeric argument required", op, args[0])
+		}
+		if n < 1 {
+			return fmt.Errorf("%s: %d: loop count out of range", op, n)
+		}
+		levels = n
+	}
+	return &loopControl{Op: op, Levels: levels}
+}
+
+// isControlFlow reports whether err is a break, continue, return or exit
+// that must end the commands enclosing it rather than be reported as an
+// error
+func isControlFlow(err error) bool {
+	var ctl *loopControl
+	var ret *returnControl
+	var exit *ExitError
+	return errors.As(err, &ctl) || errors.As(err, &ret) || errors.As(err, &exit)
+}
+
+// runCondition runs the condition of an if or a loop and reports whether it
+// succeeded. Its error is reported, as it only decides what runs next,
+// unless it is a break, continue or return, which is returned with its
+// status.
+func runCondition(ctx context.Context, cfg *config.Config, cond Command) (bool, int, error) {
+	status, err := cond.Execute(withoutErrexit(ctx), cfg)
+	if isControlFlow(err) {
+		return false, status, err
+	}
+
+	setStatus(ctx, status)
+	reportError(ctx, err)
+	return status == 0, status, nil
+}
+
+// runIteration runs the body of a loop once. It reports whether the loop
+// must end, and returns the result of the body or, for a break or continue
+// aimed at an outer loop, the same with one level less. A return ends the
+// loop and is passed on.
+func runIteration(ctx context.Context, cfg *config.Config, body Command) (bool, int, error) {
+	status, err := body.Execute(ctx, cfg)
+
+	var ctl *loopControl
+	if !errors.As(err, &ctl) {
+		if isControlFlow(err) {
+			return true, status, err
+		}
+		setStatus(ctx, status)
+		return false, status, err
+	}
+	if ctl.Levels > 1 {
+		return true, 0, &loopControl{Op: ctl.Op, Levels: ctl.Levels - 1}
+	}
+	return ctl.Op == "break", 0, nil
+}
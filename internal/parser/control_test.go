@@ -0,0 +1,244 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"gosh/internal/config"
+)
+
+func TestParseControlErrors(t *testing.T) {
+	parser := New(config.Default())
+
+	incomplete := []string{
+		"if true",
+		"if true; then",
+		"if true; then echo a\nelse",
+		"while true; do",
+		"until false\n",
+		"for x",
+		"for x in a b",
+		"for x in a b; do echo $x",
+		"for ((i = 0; i < 3; i++))",
+		"case $x",
+		"case $x in",
+		"case $x in a)",
+		"case $x in a) echo a;;",
+	}
+	for _, input := range incomplete {
+		if _, err := parser.Parse(input); !errors.Is(err, ErrIncomplete) {
+			t.Errorf("Parse(%q) error = %v, want ErrIncomplete", input, err)
+		}
+	}
+
+	invalid := []string{
+		"if true; fi",
+		"if then echo; fi",
+		"if true; then fi",
+		"while true; done",
+		"for 1x in a; do echo; done",
+		"for x in a; echo; done",
+		"for ((i = 0; i < 3)); do echo; done",
+		"case a b in esac",
+		"case a in ;; esac",
+		"done",
+		"then echo",
+	}
+	for _, input := range invalid {
+		_, err := parser.Parse(input)
+		if err == nil || errors.Is(err, ErrIncomplete) {
+			t.Errorf("Parse(%q) error = %v, want a syntax error", input, err)
+		}
+	}
+}
+
+func TestSyntaxErrorMayComplete(t *testing.T) {
+	parser := New(config.Default())
+
+	tests := []struct {
+		input string
+		line  string
+		want  bool
+	}{
+		{"if true; then", "  echo a", false},
+		{"if true; then", "fi", true},
+		{"if true; then\n  while x; do", "  fi", false},
+		{"if true; then\n  while x; do", "  done", true},
+		{"case $x in", "  a) echo a;;", false},
+		{"echo 'open", "still open", false},
+		{"echo 'open", "closed'", true},
+		{"cat <<END", "body", false},
+		{"cat <<END", "END", true},
+		{"true &&", "anything", true},
+	}
+	for _, tt := range tests {
+		_, err := parser.Parse(tt.input)
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Fatalf("Parse(%q) error = %v, want a syntax error", tt.input, err)
+		}
+		if got := syntaxErr.MayComplete(tt.line); got != tt.want {
+			t.Errorf("Parse(%q) error MayComplete(%q) = %v, want %v", tt.input, tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestControlExecute(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		args       []string
+		wantOutput string
+		wantStatus int
+	}{
+		{
+			name:       "if runs the first true branch",
+			input:      "if false; then echo a; elif true; then echo b; else echo c; fi",
+			wantOutput: "b\n",
+		},
+		{
+			name:       "if runs else",
+			input:      "if false\nthen\n  echo a\nelse\n  echo c\nfi",
+			wantOutput: "c\n",
+		},
+		{
+			name:  "if without a true branch succeeds",
+			input: "if false; then echo a; fi",
+		},
+		{
+			name:       "if returns the status of its branch",
+			input:      "if true; then false; fi",
+			wantStatus: 1,
+		},
+		{
+			name:       "condition sees negation and lists",
+			input:      "if ! false && true; then echo yes; fi",
+			wantOutput: "yes\n",
+		},
+		{
+			name:       "for over words",
+			input:      "for x in a 'b c' d; do echo \"[$x]\"; done",
+			wantOutput: "[a]\n[b c]\n[d]\n",
+		},
+		{
+			name:       "for words are expanded",
+			input:      "for x in {1..3}; do echo $x; done",
+			wantOutput: "1\n2\n3\n",
+		},
+		{
+			name:       "for without in uses the positional parameters",
+			input:      "for x\ndo\n  echo $x\ndone",
+			args:       []string{"one", "two words"},
+			wantOutput: "one\ntwo words\n",
+		},
+		{
+			name:  "for over nothing",
+			input: "for x in; do echo $x; done",
+		},
+		{
+			name:       "C-style for",
+			input:      "for ((i = 0; i < 3; i++)); do echo $i; done",
+			wantOutput: "0\n1\n2\n",
+		},
+		{
+			name:       "C-style for with empty parts",
+			input:      "for ((;;)); do echo once; break; done",
+			wantOutput: "once\n",
+		},
+		{
+			name:       "while loop",
+			input:      "for ((n = 3; n > 0; n--)); do while false; do echo never; done; echo $n; done",
+			wantOutput: "3\n2\n1\n",
+		},
+		{
+			name:       "until loop",
+			input:      "until true; do echo never; done; echo done",
+			wantOutput: "done\n",
+		},
+		{
+			name:       "loop status is that of the last body",
+			input:      "for x in a b; do false; done",
+			wantStatus: 1,
+		},
+		{
+			name:       "break leaves the loop",
+			input:      "for x in a b c; do if test $x = b; then break; fi; echo $x; done",
+			wantOutput: "a\n",
+		},
+		{
+			name:       "continue skips the rest of the body",
+			input:      "for x in a b c; do test $x = b && continue; echo $x; done",
+			wantOutput: "a\nc\n",
+		},
+		{
+			name:       "continue runs the step of a C-style for",
+			input:      "for ((i = 0; i < 4; i++)); do test $i = 1 && continue; echo $i; done",
+			wantOutput: "0\n2\n3\n",
+		},
+		{
+			name:       "break with a count",
+			input:      "for a in 1 2; do for b in x y; do break 2; done; done; echo $a$b",
+			wantOutput: "1x\n",
+		},
+		{
+			name:       "continue with a count",
+			input:      "for a in 1 2; do for b in x y; do echo $a$b; continue 2; done; done",
+			wantOutput: "1x\n2x\n",
+		},
+		{
+			name:       "break inside a group",
+			input:      "while true; do { echo in; break; echo no; }; done; echo out",
+			wantOutput: "in\nout\n",
+		},
+		{
+			name:       "case matches patterns",
+			input:      "case main.go in *.md) echo doc;; *.go|*.c) echo code;; *) echo other;; esac",
+			wantOutput: "code\n",
+		},
+		{
+			name:       "case falls back to *",
+			input:      "case x in\n  a) echo a ;;\n  (*)\n    echo default\n    ;;\nesac",
+			wantOutput: "default\n",
+		},
+		{
+			name:       "case quoted pattern characters match literally",
+			input:      "case 'a*' in 'a*') echo literal;; esac; case ab in 'a*') echo no;; esac",
+			wantOutput: "literal\n",
+		},
+		{
+			name:  "case with an empty clause",
+			input: "case a in a) ;; *) echo no;; esac",
+		},
+		{
+			name:       "compound commands are piped and redirected",
+			input:      "for x in a b; do echo $x; done | tr a-z A-Z",
+			wantOutput: "A\nB\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			parser := New(cfg)
+
+			cmd, err := parser.Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse() failed: %v", err)
+			}
+
+			var stdout bytes.Buffer
+			ctx := WithIO(context.Background(), IO{Stdin: strings.NewReader(""), Stdout: &stdout})
+			ctx = WithState(ctx, NewState(DefaultShellName, tt.args))
+			status, err := cmd.Execute(ctx, cfg)
+			if status != tt.wantStatus {
+				t.Errorf("Execute() status = %d (%v), want %d", status, err, tt.wantStatus)
+			}
+			if stdout.String() != tt.wantOutput {
+				t.Errorf("output = %q, want %q", stdout.String(), tt.wantOutput)
+			}
+		})
+	}
+}
@@ -0,0 +1,510 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gosh/internal/config"
+	"gosh/internal/lexer"
+)
+
+const (
+	// doubleQuoteEscapes are the characters a backslash escapes inside
+	// double quotes
+	doubleQuoteEscapes = "$`\"\\\n"
+	// hereDocEscapes are the characters a backslash escapes in the body of
+	// an unquoted here-document
+	hereDocEscapes = "$`\\\n"
+	// backtickEscapes are the characters a backslash escapes inside an
+	// old-style `command` substitution
+	backtickEscapes = "$`\\"
+	// DefaultIFS is the field separator used when IFS is unset
+	DefaultIFS = " \t\n"
+)
+
+// part is a piece of an expanded word. Quoted parts come from quoted text
+// and are kept even when empty; split parts are the results of unquoted
+// expansions and are subject to field splitting. A part with brk set starts
+// a new field, as between the positional parameters of "$@".
+type part struct {
+	text   string
+	quoted bool
+	split  bool
+	brk    bool
+}
+
+// expandWords expands the words of a command into its arguments
+func (p *Parser) expandWords(ctx context.Context, words []string) ([]string, error) {
+	var fields []string
+	for _, word := range words {
+		expanded, err := p.expandWord(ctx, word)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, expanded...)
+	}
+	return fields, nil
+}
+
+// field is a word after field splitting. pattern is its text with the
+// quoted characters escaped, for pathname expansion when glob is set.
+type field struct {
+	text    string
+	pattern string
+	glob    bool
+}
+
+// expandWord expands the source text of a word into the fields it
+// produces. Braces are expanded first; then the results of unquoted
+// expansions are split on IFS, and fields with unquoted pattern characters
+// are replaced by the paths they match. An unquoted word that expands to
+// nothing produces no field.
+func (p *Parser) expandWord(ctx context.Context, word string) ([]string, error) {
+	var fields []string
+	for _, braced := range expandBraces(word) {
+		parts, err := p.expandParts(ctx, braced)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, f := range p.splitFields(parts) {
+			paths, err := p.expandPathname(ctx, f)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, paths...)
+		}
+	}
+	return fields, nil
+}
+
+// expandPathname replaces a field containing a pattern with the paths that
+// match it. Without a match the field is kept as it is, unless nullglob or
+// failglob is set. With noglob set no field is a pattern.
+func (p *Parser) expandPathname(ctx context.Context, f field) ([]string, error) {
+	if !f.glob || p.config.Noglob {
+		return []string{f.text}, nil
+	}
+
+	matches := glob(f.pattern, StateFromContext(ctx).WorkDir(), p.config)
+	switch {
+	case len(matches) > 0:
+		return matches, nil
+	case p.config.Failglob:
+		return nil, fmt.Errorf("no match: %s", f.text)
+	case p.config.Nullglob:
+		return nil, nil
+	default:
+		return []string{f.text}, nil
+	}
+}
+
+// expandString expands a word to a single string without field splitting,
+// as is done for redirection targets
+func (p *Parser) expandString(ctx context.Context, word string) (string, error) {
+	parts, err := p.expandParts(ctx, word)
+	if err != nil {
+		return "", err
+	}
+	return joinParts(parts), nil
+}
+
+// expandValue expands the value of an assignment to a single string. A
+// tilde may start the value or follow a colon in it, as in
+// PATH=~/bin:~/go/bin.
+func (p *Parser) expandValue(ctx context.Context, value string) (string, error) {
+	parts, err := p.expandPartsAt(ctx, value, 0)
+	if err != nil {
+		return "", err
+	}
+	return joinParts(parts), nil
+}
+
+// expandParts performs tilde expansion, parameter expansion, command
+// substitution and quote removal on a word
+func (p *Parser) expandParts(ctx context.Context, word string) ([]part, error) {
+	// In a word that looks like an assignment, a tilde may also start the
+	// value or follow a colon in it
+	return p.expandPartsAt(ctx, word, assignmentValue(word))
+}
+
+// expandPartsAt is expandParts for a word whose assignment value starts at
+// index value, or -1 if it is not an assignment
+func (p *Parser) expandPartsAt(ctx context.Context, word string, value int) ([]part, error) {
+	var parts []part
+	var literal strings.Builder
+
+	flush := func() {
+		if literal.Len() > 0 {
+			parts = append(parts, part{text: literal.String()})
+			literal.Reset()
+		}
+	}
+
+	for i := 0; i < len(word); {
+		switch c := word[i]; c {
+		case '\\':
+			flush()
+			if i+1 < len(word) && word[i+1] != '\n' {
+				parts = append(parts, part{text: word[i+1 : i+2], quoted: true})
+			}
+			i += 2
+		case '\'', '"':
+			flush()
+			end := lexer.QuoteEnd(word, i)
+			if end < 0 {
+				end = len(word) + 1
+			}
+			text := word[i+1 : end-1]
+			if c == '"' {
+				expanded, err := p.expandQuoted(ctx, text, doubleQuoteEscapes)
+				if err != nil {
+					return nil, err
+				}
+				parts = append(parts, expanded...)
+			} else {
+				parts = append(parts, part{text: text, quoted: true})
+			}
+			i = end
+		case '$', '`':
+			flush()
+			end := expansionEnd(word, i)
+			expanded, err := p.expandDollar(ctx, word[i:end], false)
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, expanded...)
+			i = end
+		case '~':
+			if i == 0 || (value >= 0 && (i == value || (i > value && word[i-1] == ':'))) {
+				if dir, end, ok := p.expandTilde(ctx, word, i, value >= 0); ok {
+					// The directory is not split or matched against file names
+					flush()
+					parts = append(parts, part{text: dir, quoted: true})
+					i = end
+					continue
+				}
+			}
+			literal.WriteByte(c)
+			i++
+		default:
+			literal.WriteByte(c)
+			i++
+		}
+	}
+	flush()
+
+	return parts, nil
+}
+
+// expandTilde expands the tilde prefix at word[start]: the characters up to
+// the next slash, or colon in an assignment (inList), which must not be
+// quoted. ~ is the home directory, ~user that of the user, ~+ the current
+// directory and ~- the previous one. It returns the directory and the end
+// of the prefix, or false if the prefix cannot be expanded.
+func (p *Parser) expandTilde(ctx context.Context, word string, start int, inList bool) (string, int, bool) {
+	end := start + 1
+	for end < len(word) && word[end] != '/' && !(inList && word[end] == ':') {
+		if strings.IndexByte("\\'\"$`", word[end]) >= 0 {
+			return "", 0, false
+		}
+		end++
+	}
+
+	var dir string
+	var ok bool
+	switch name := word[start+1 : end]; name {
+	case "":
+		if dir, ok = p.lookupVariable("HOME"); !ok {
+			dir, ok = config.HomeDir("")
+		}
+	case "+":
+		if dir, ok = p.lookupVariable("PWD"); !ok {
+			dir, ok = StateFromContext(ctx).WorkDir(), true
+		}
+	case "-":
+		dir, ok = p.lookupVariable("OLDPWD")
+	default:
+		dir, ok = config.HomeDir(name)
+	}
+	return dir, end, ok
+}
+
+// assignmentValue returns the start of the value if word has the form
+// name=value, name+=value or name[index]=value, or -1
+func assignmentValue(word string) int {
+	a, ok := parseAssignment(word)
+	if !ok {
+		return -1
+	}
+	return len(word) - len(a.value)
+}
+
+// expandQuoted expands text in which quotes have no special meaning, such
+// as the inside of double quotes or a here-document, into quoted parts. A
+// backslash only escapes the characters in escapes, and removes a following
+// newline. The text makes a field even when it is empty, unless it refers
+// to "$@".
+func (p *Parser) expandQuoted(ctx context.Context, text, escapes string) ([]part, error) {
+	var parts []part
+	var literal strings.Builder
+	multi := false
+
+	flush := func() {
+		if literal.Len() > 0 {
+			parts = append(parts, part{text: literal.String(), quoted: true})
+			literal.Reset()
+		}
+	}
+
+	for i := 0; i < len(text); {
+		switch c := text[i]; {
+		case c == '\\' && i+1 < len(text) && strings.IndexByte(escapes, text[i+1]) >= 0:
+			if text[i+1] != '\n' {
+				literal.WriteByte(text[i+1])
+			}
+			i += 2
+		case c == '$' || c == '`':
+			flush()
+			end := expansionEnd(text, i)
+			ref := text[i:end]
+			multi = multi || isMultiRef(ref)
+			expanded, err := p.expandDollar(ctx, ref, true)
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, expanded...)
+			i = end
+		default:
+			literal.WriteByte(c)
+			i++
+		}
+	}
+	flush()
+
+	if !multi {
+		parts = append([]part{{quoted: true}}, parts...)
+	}
+	return parts, nil
+}
+
+// isMultiRef reports whether a parameter reference stands for a list of
+// fields, as $@, ${arr[@]} and ${!arr[@]} do
+func isMultiRef(ref string) bool {
+	if ref == "$@" || strings.HasPrefix(ref, "${@") {
+		return true
+	}
+	if !strings.HasPrefix(ref, "${") {
+		return false
+	}
+	expr := strings.TrimPrefix(ref[2:], "!")
+	return strings.HasPrefix(expr[len(paramName(expr)):], "[@]")
+}
+
+// expandHereDoc expands the body of a here-document with an unquoted
+// delimiter
+func (p *Parser) expandHereDoc(ctx context.Context, body string) (string, error) {
+	parts, err := p.expandQuoted(ctx, body, hereDocEscapes)
+	if err != nil {
+		return "", err
+	}
+	return joinParts(parts), nil
+}
+
+// expandDollar expands a single $ expansion or backtick substitution, given
+// its source text. Inside double quotes (quoted) the result is not split.
+func (p *Parser) expandDollar(ctx context.Context, ref string, quoted bool) ([]part, error) {
+	var src string
+	switch {
+	case ref == "$":
+		return []part{{text: ref, quoted: quoted}}, nil
+	case !expansionClosed(ref):
+		return nil, fmt.Errorf("unexpected EOF while looking for matching `%c'", closingBracket(ref))
+	case strings.HasPrefix(ref, "`"):
+		src = unescape(ref[1:len(ref)-1], backtickEscapes)
+	case strings.HasPrefix(ref, "$((") && lexer.ArithEnd(ref, 1) == len(ref):
+		// $(( expr )) is replaced by the value of the expression
+		value, err := p.arithmetic(ctx, ref[3:len(ref)-2])
+		if err != nil {
+			return nil, expansionError(ctx, err)
+		}
+		return []part{{text: strconv.FormatInt(value, 10), quoted: quoted, split: !quoted}}, nil
+	case strings.HasPrefix(ref, "$("):
+		src = ref[2 : len(ref)-1]
+	default:
+		return p.expandParam(ctx, ref, quoted)
+	}
+
+	text, err := p.substitute(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+	return []part{{text: text, quoted: quoted, split: !quoted}}, nil
+}
+
+// substitute runs the commands of a command substitution in a subshell and
+// returns their output without trailing newlines. Its exit status becomes
+// $?. A command that fails still yields its output, and its error is
+// reported.
+func (p *Parser) substitute(ctx context.Context, src string) (string, error) {
+	cmd, err := p.Parse(src)
+	if err != nil {
+		return "", fmt.Errorf("command substitution: %w", err)
+	}
+
+	var out bytes.Buffer
+	streams := IOFromContext(ctx)
+	streams.Stdout = &out
+
+	// As in bash, set -e does not apply inside; the status of the
+	// substitution is checked by the command that uses it
+	status, err := (&SubshellCommand{Body: cmd}).run(WithIO(withoutErrexit(ctx), streams), p.config)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return "", ctxErr
+	}
+	setStatus(ctx, status)
+	StateFromContext(ctx).substituted = true
+	reportError(ctx, err)
+
+	return strings.TrimRight(out.String(), "\n"), nil
+}
+
+// splitFields joins the parts of a word into fields, splitting the text of
+// split parts on the characters of IFS. IFS whitespace separates fields and
+// is trimmed; any other IFS character ends a field, which may be empty.
+func (p *Parser) splitFields(parts []part) []field {
+	ifs, ok := p.lookupVariable("IFS")
+	if !ok {
+		ifs = DefaultIFS
+	}
+
+	var fields []field
+	var text, pattern strings.Builder
+	// inField is set once the current field has content or a quoted part,
+	// afterSpace once whitespace has ended a field
+	inField, afterSpace, isGlob := false, false, false
+
+	emit := func() {
+		fields = append(fields, field{text: text.String(), pattern: pattern.String(), glob: isGlob})
+		text.Reset()
+		pattern.Reset()
+		isGlob = false
+	}
+	// add appends unquoted text, which keeps its pattern meaning
+	add := func(s string) {
+		text.WriteString(s)
+		pattern.WriteString(s)
+		isGlob = isGlob || strings.ContainsAny(s, "*?[")
+	}
+
+	for _, pt := range parts {
+		if pt.brk {
+			if inField {
+				emit()
+			}
+			inField, afterSpace = false, false
+		}
+
+		if !pt.split {
+			if pt.quoted {
+				text.WriteString(pt.text)
+				pattern.WriteString(escapePattern(pt.text))
+			} else {
+				add(pt.text)
+			}
+			inField = inField || pt.quoted || pt.text != ""
+			afterSpace = afterSpace && pt.text == ""
+			continue
+		}
+
+		for i := 0; i < len(pt.text); i++ {
+			c := pt.text[i]
+			switch {
+			case strings.IndexByte(ifs, c) < 0:
+				add(pt.text[i : i+1])
+				inField, afterSpace = true, false
+			case c == ' ' || c == '\t' || c == '\n':
+				if inField {
+					emit()
+					inField, afterSpace = false, true
+				}
+			default:
+				if inField || !afterSpace {
+					emit()
+				}
+				inField, afterSpace = false, false
+			}
+		}
+	}
+
+	if inField {
+		emit()
+	}
+	return fields
+}
+
+// joinParts joins the text of parts into one string, with a space between
+// fields that "$@" would keep apart
+func joinParts(parts []part) string {
+	var b strings.Builder
+	for _, pt := range parts {
+		if pt.brk {
+			b.WriteByte(' ')
+		}
+		b.WriteString(pt.text)
+	}
+	return b.String()
+}
+
+// expansionEnd returns the end of the $ expansion or backtick substitution
+// starting at start, or the end of src if it is not terminated
+func expansionEnd(src string, start int) int {
+	var end int
+	if src[start] == '`' {
+		end = lexer.BacktickEnd(src, start)
+	} else {
+		end = lexer.DollarEnd(src, start)
+	}
+	if end < 0 {
+		return len(src)
+	}
+	return end
+}
+
+// expansionClosed reports whether the $ expansion or backtick substitution
+// ref ends where it is closed. Text the lexer has not checked, such as the
+// value of a variable, may hold one that is cut short.
+func expansionClosed(ref string) bool {
+	if ref[0] == '`' {
+		return lexer.BacktickEnd(ref, 0) == len(ref)
+	}
+	return lexer.DollarEnd(ref, 0) == len(ref)
+}
+
+// closingBracket returns the character that closes the expansion ref
+func closingBracket(ref string) byte {
+	switch {
+	case ref[0] == '`':
+		return '`'
+	case strings.HasPrefix(ref, "${"):
+		return '}'
+	default:
+		return ')'
+	}
+}
+
+// unescape removes the backslashes in text that escape one of the
+// characters in escapes
+func unescape(text, escapes string) string {
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\\' && i+1 < len(text) && strings.IndexByte(escapes, text[i+1]) >= 0 {
+			i++
+		}
+		b.WriteByte(text[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,253 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gosh/internal/config"
+)
+
+func TestCommandSubstitution(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		wantOutput string
+		wantErr    bool
+	}{
+		{
+			name:       "dollar form",
+			input:      "echo [$(echo hello)]",
+			wantOutput: "[hello]\n",
+		},
+		{
+			name:       "backticks",
+			input:      "echo [`echo hello`]",
+			wantOutput: "[hello]\n",
+		},
+		{
+			name:       "trailing newlines are stripped",
+			input:      `printf '[%s]\n' "$(printf 'a\n\nb\n\n\n')"`,
+			wantOutput: "[a\n\nb]\n",
+		},
+		{
+			name:       "unquoted results are split into fields",
+			input:      `printf '[%s]\n' $(printf 'a  b\nc')`,
+			wantOutput: "[a]\n[b]\n[c]\n",
+		},
+		{
+			name:       "quoted results are one field",
+			input:      `printf '[%s]\n' "$(echo 'a  b')"`,
+			wantOutput: "[a  b]\n",
+		},
+		{
+			name:       "backticks made by brace expansion are literal",
+			input:      "echo {a..Z}",
+			wantOutput: "a ` _ ^ ] \\ [ Z\n",
+		},
+		{
+			name:       "nested",
+			input:      "echo $(echo $(echo inner) outer)",
+			wantOutput: "inner outer\n",
+		},
+		{
+			name:       "nested backticks",
+			input:      "echo `echo \\`echo inner\\``",
+			wantOutput: "inner\n",
+		},
+		{
+			name:       "quotes inside the substitution",
+			input:      `echo "$(echo "a )b")"`,
+			wantOutput: "a )b\n",
+		},
+		{
+			name:       "runs builtins and pipelines in the same shell",
+			input:      "echo $(help | head -1)",
+			wantOutput: "Gosh - A modern shell written in Go\n",
+		},
+		{
+			name:       "changes stay in the substitution",
+			input:      "echo $(cd /; pwd) $(export SUBST_TEST=x) [$SUBST_TEST]",
+			wantOutput: "/ []\n",
+		},
+		{
+			name:       "empty unquoted result is no field",
+			input:      `printf '[%s]' $(true) x`,
+			wantOutput: "[x]",
+		},
+		{
+			name:       "redirection target",
+			input:      "echo ok > $(echo /dev/null); echo done",
+			wantOutput: "done\n",
+		},
+		{
+			name:       "here-document body",
+			input:      "cat <<EOF\n$(echo body)\nEOF",
+			wantOutput: "body\n",
+		},
+		{
+			name:    "syntax error",
+			input:   "echo $(ls |)",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			parser := New(cfg)
+
+			cmd, err := parser.Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse() failed: %v", err)
+			}
+
+			var stdout, stderr bytes.Buffer
+			ctx := WithIO(context.Background(), IO{Stdin: strings.NewReader(""), Stdout: &stdout, Stderr: &stderr})
+			_, err = cmd.Execute(ctx, cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Execute() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if stdout.String() != tt.wantOutput {
+				t.Errorf("output = %q, want %q (stderr %q)", stdout.String(), tt.wantOutput, stderr.String())
+			}
+		})
+	}
+}
+
+func TestExpandDollarUnterminated(t *testing.T) {
+	parser := New(config.Default())
+	for _, ref := range []string{"`", "`echo", "$(", "$(echo", "${x"} {
+		if _, err := parser.expandDollar(context.Background(), ref, false); err == nil {
+			t.Errorf("expandDollar(%q) succeeded, want an error", ref)
+		}
+	}
+}
+
+func TestSplitFields(t *testing.T) {
+	tests := []struct {
+		name  string
+		ifs   *string
+		parts []part
+		want  []string
+	}{
+		{
+			name:  "default IFS trims whitespace",
+			parts: []part{{text: "  a \t b\n", split: true}},
+			want:  []string{"a", "b"},
+		},
+		{
+			name:  "literal text joins the split text",
+			parts: []part{{text: "x"}, {text: "a b", split: true}, {text: "y"}},
+			want:  []string{"xa", "by"},
+		},
+		{
+			name:  "quoted empty part is a field",
+			parts: []part{{text: "", quoted: true}, {text: "", split: true}},
+			want:  []string{""},
+		},
+		{
+			name:  "unquoted empty expansion is no field",
+			parts: []part{{text: "", split: true}},
+			want:  nil,
+		},
+		{
+			name:  "non-whitespace separators delimit empty fields",
+			ifs:   strPtr(":"),
+			parts: []part{{text: ":a::b:", split: true}},
+			want:  []string{"", "a", "", "b"},
+		},
+		{
+			name:  "whitespace around a separator",
+			ifs:   strPtr(" :"),
+			parts: []part{{text: "a : b", split: true}},
+			want:  []string{"a", "b"},
+		},
+		{
+			name:  "a break starts a new field",
+			parts: []part{{text: "x"}, {text: "a", quoted: true}, {text: "b c", quoted: true, brk: true}},
+			want:  []string{"xa", "b c"},
+		},
+		{
+			name:  "empty IFS disables splitting",
+			ifs:   strPtr(""),
+			parts: []part{{text: "a b", split: true}},
+			want:  []string{"a b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			if tt.ifs != nil {
+				_ = cfg.Variables.Set("IFS", *tt.ifs)
+			}
+			parser := New(cfg)
+
+			var got []string
+			for _, f := range parser.splitFields(tt.parts) {
+				got = append(got, f.text)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitFields() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// strPtr returns a pointer to s
+func strPtr(s string) *string {
+	return &s
+}
+
+func TestTildeExpansion(t *testing.T) {
+	tests := []struct {
+		word string
+		want []string
+	}{
+		{"~", []string{"/home/gosh"}},
+		{"~/src", []string{"/home/gosh/src"}},
+		{"~+/x", []string{"/work/x"}},
+		{"~-", []string{"/previous"}},
+		{"~root", []string{rootHome(t)}},
+		{"~nosuchuser-gosh/x", []string{"~nosuchuser-gosh/x"}},
+		{"'~'/x", []string{"~/x"}},
+		{`\~`, []string{"~"}},
+		{`"~"`, []string{"~"}},
+		{"a~", []string{"a~"}},
+		{"~'x'", []string{"~x"}},
+		{"PATH=~/bin:~/go/bin:a~", []string{"PATH=/home/gosh/bin:/home/gosh/go/bin:a~"}},
+		{"x:~", []string{"x:~"}},
+		{"--dir=~", []string{"--dir=~"}},
+	}
+
+	cfg := config.Default()
+	_ = cfg.Variables.Set("HOME", "/home/gosh")
+	_ = cfg.Variables.Set("PWD", "/work")
+	_ = cfg.Variables.Set("OLDPWD", "/previous")
+	parser := New(cfg)
+
+	for _, tt := range tests {
+		got, err := parser.expandWord(context.Background(), tt.word)
+		if err != nil {
+			t.Errorf("expandWord(%q) failed: %v", tt.word, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("expandWord(%q) = %q, want %q", tt.word, got, tt.want)
+		}
+	}
+}
+
+// rootHome returns the home directory of root, skipping the test on systems
+// without that user
+func rootHome(t *testing.T) string {
+	t.Helper()
+	home, ok := config.HomeDir("root")
+	if !ok {
+		t.Skip("no root user")
+	}
+	return home
+}
@@ -0,0 +1,33 @@
+//go:build unix
+
+package parser
+
+import (
+	"os"
+	"syscall"
+)
+
+// The modes checked by accessible, as for access(2)
+const (
+	accessRead    = 4
+	accessWrite   = 2
+	accessExecute = 1
+)
+
+// accessible reports whether the shell may use path in the given mode,
+// judged with its real user and group IDs
+func accessible(path string, mode uint32) bool {
+	return syscall.Access(path, mode) == nil
+}
+
+// ownedByUser reports whether the file belongs to the effective user
+func ownedByUser(info os.FileInfo) bool {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	return ok && int(stat.Uid) == os.Geteuid()
+}
+
+// ownedByGroup reports whether the file belongs to the effective group
+func ownedByGroup(info os.FileInfo) bool {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	return ok && int(stat.Gid) == os.Getegid()
+}
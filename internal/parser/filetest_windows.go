@@ -0,0 +1,54 @@
+//go:build windows
+
+package parser
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// The modes checked by accessible
+const (
+	accessRead    = 4
+	accessWrite   = 2
+	accessExecute = 1
+)
+
+// accessible approximates access(2) from the permission bits Go reports
+// for path, as Windows has no equivalent
+func accessible(path string, mode uint32) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	switch mode {
+	case accessWrite:
+		return info.Mode().Perm()&0o200 != 0
+	case accessExecute:
+		return info.IsDir() || info.Mode().Perm()&0o100 != 0
+	default:
+		return true
+	}
+}
+
+// ownedByUser reports that any file belongs to the user, as Windows has
+// no file owner IDs
+func ownedByUser(_ os.FileInfo) bool {
+	return true
+}
+
+// ownedByGroup reports that any file belongs to the group, as Windows has
+// no file group IDs
+func ownedByGroup(_ os.FileInfo) bool {
+	return true
+}
+
+// accessTime returns when the file was last read
+func accessTime(info os.FileInfo) (time.Time, bool) {
+	data, ok := info.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(0, data.LastAccessTime.Nanoseconds()), true
+}
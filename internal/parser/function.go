@@ -0,0 +1,202 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"gosh/internal/config"
+)
+
+// maxFunctionDepth limits how deeply functions may call each other, so that
+// runaway recursion ends with an error
+const maxFunctionDepth = 1000
+
+// Function is a shell function
+type Function struct {
+	Name string
+	Body Command
+	// Text is the definition as written, shown by type and declare -f
+	Text string
+}
+
+// FunctionDefCommand defines a function, as written with name() { ...; }
+// or function name { ...; }
+type FunctionDefCommand struct {
+	Function *Function
+}
+
+// Execute implements the Command interface for FunctionDefCommand
+func (c *FunctionDefCommand) Execute(ctx context.Context, _ *config.Config) (int, error) {
+	state := StateFromContext(ctx)
+	if state.Functions == nil {
+		state.Functions = make(map[string]*Function)
+	}
+	state.Functions[c.Function.Name] = c.Function
+	return 0, nil
+}
+
+// FunctionCall runs a function with its own positional parameters and
+// scope for local variables
+type FunctionCall struct {
+	Function *Function
+	Args     []string
+}
+
+// Execute implements the Command interface for FunctionCall
+func (c *FunctionCall) Execute(ctx context.Context, cfg *config.Config) (int, error) {
+	state := StateFromContext(ctx)
+	if len(state.scopes) >= maxFunctionDepth {
+		return ExitFailure, fmt.Errorf("%s: maximum function nesting level exceeded (%d)", c.Function.Name, maxFunctionDepth)
+	}
+
+	args := state.Args
+	state.Args = c.Args
+	state.scopes = append(state.scopes, make(map[string]savedVariable))
+	defer func() {
+		scope := state.scopes[len(state.scopes)-1]
+		state.scopes = state.scopes[:len(state.scopes)-1]
+		for name, saved := range scope {
+			saved.restore(cfg, name)
+		}
+		state.Args = args
+	}()
+
+	status, err := c.Function.Body.Execute(ctx, cfg)
+
+	var ret *returnControl
+	if errors.As(err, &ret) {
+		status, err = ret.Status, nil
+	}
+	if !isControlFlow(err) {
+		setStatus(ctx, status)
+		if trapErr := runTrap(ctx, cfg, TrapReturn); trapErr != nil {
+			reportError(ctx, err)
+			return status, trapErr
+		}
+	}
+	return status, err
+}
+
+// savedVariable is the variable a local variable hid, restored when its
+// function returns
+type savedVariable struct {
+	variable config.Variable
+	ok       bool
+}
+
+// restore puts the saved variable name back in cfg
+func (v savedVariable) restore(cfg *config.Config, name string) {
+	cfg.Variables.Restore(name, v.variable, v.ok)
+}
+
+// makeLocal hides the variable name from the function being run, which
+// starts with it unset, until the function returns. A read-only variable
+// cannot be hidden.
+func makeLocal(ctx context.Context, cfg *config.Config, name string) error {
+	state := StateFromContext(ctx)
+	scope := state.scopes[len(state.scopes)-1]
+
+	// Only the variable from outside the function is kept, so declaring
+	// the same variable twice does not lose it
+	if _, ok := scope[name]; ok {
+		return nil
+	}
+	previous, ok := cfg.Variables.Get(name)
+	if previous.Has(config.AttrReadOnly) {
+		return &config.ReadOnlyError{Name: name}
+	}
+	scope[name] = savedVariable{variable: previous, ok: ok}
+	cfg.Variables.Restore(name, config.Variable{}, false)
+	return nil
+}
+
+// LocalCommand implements the local built-in command, which creates
+// variables that only exist until the function running it returns. It
+// takes the attribute options of declare.
+type LocalCommand struct {
+	Args   []string
+	parser *Parser
+}
+
+// Execute implements the Command interface for LocalCommand
+func (c *LocalCommand) Execute(ctx context.Context, _ *config.Config) (int, error) {
+	if len(StateFromContext(ctx).scopes) == 0 {
+		return ExitFailure, errors.New("local: can only be used in a function")
+	}
+
+	opts, names, err := parseDeclareOptions("local", "aAirx", c.Args)
+	if err != nil {
+		return ExitFailure, err
+	}
+	return builtinStatus(c.parser.declare(ctx, "local", opts, names, true))
+}
+
+// ReturnCommand implements the return built-in command, which leaves the
+// function being run with the given status or that of the last command
+type ReturnCommand struct {
+	Args []string
+}
+
+// Execute implements the Command interface for ReturnCommand
+func (c *ReturnCommand) Execute(ctx context.Context, _ *config.Config) (int, error) {
+	status := StateFromContext(ctx).Status
+	switch len(c.Args) {
+	case 0:
+	case 1:
+		n, err := strconv.Atoi(c.Args[0])
+		if err != nil {
+			return ExitFailure, fmt.Errorf("return: %s: numeric argument required", c.Args[0])
+		}
+		status = n & 0xff
+	default:
+		return ExitFailure, errors.New("return: too many arguments")
+	}
+	return ExitFailure, &returnControl{Status: status}
+}
+
+// returnControl is returned by return. It passes through the commands that
+// enclose it up to the function being run.
+type returnControl struct {
+	Status int
+}
+
+// Error implements the error interface for returnControl. It is only seen
+// when return is used outside a function.
+func (e *returnControl) Error() string {
+	return "return: can only `return' from a function"
+}
+
+// declareFunctions prints the named functions, or all of them, for
+// declare -f and -F. A name without a function makes the status a failure.
+func declareFunctions(ctx context.Context, out io.Writer, names []string, namesOnly bool) (int, error) {
+	state := StateFromContext(ctx)
+	if len(names) == 0 {
+		names = state.FunctionNames()
+	}
+
+	status := 0
+	for _, name := range names {
+		fn, ok := state.Functions[name]
+		if !ok {
+			status = ExitFailure
+			continue
+		}
+		if err := printFunction(out, fn, namesOnly); err != nil {
+			return ExitFailure, err
+		}
+	}
+	return status, nil
+}
+
+// printFunction prints a function for declare -f, or its name for declare -F
+func printFunction(out io.Writer, fn *Function, namesOnly bool) error {
+	if namesOnly {
+		_, err := fmt.Fprintf(out, "declare -f %s\n", fn.Name)
+		return err
+	}
+	_, err := fmt.Fprintln(out, fn.Text)
+	return err
+}
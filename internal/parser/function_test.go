@@ -0,0 +1,192 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"gosh/internal/config"
+)
+
+func TestParseFunctionErrors(t *testing.T) {
+	parser := New(config.Default())
+
+	incomplete := []string{
+		"f()",
+		"f() {",
+		"f()\n",
+		"function",
+		"function f",
+		"function f {",
+		"function f (",
+	}
+	for _, input := range incomplete {
+		if _, err := parser.Parse(input); !errors.Is(err, ErrIncomplete) {
+			t.Errorf("Parse(%q) error = %v, want ErrIncomplete", input, err)
+		}
+	}
+
+	invalid := []string{
+		"f() echo hi",
+		"function f echo hi",
+		"function if { echo; }",
+		"function f ( { echo; }",
+		"'f'() { echo; }",
+		"$f() { echo; }",
+		"f(x) { echo; }",
+	}
+	for _, input := range invalid {
+		_, err := parser.Parse(input)
+		if err == nil || errors.Is(err, ErrIncomplete) {
+			t.Errorf("Parse(%q) error = %v, want a syntax error", input, err)
+		}
+	}
+}
+
+func TestFunctionExecute(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		args       []string
+		wantOutput string
+		wantStatus int
+	}{
+		{
+			name:       "name() definition",
+			input:      "greet() { echo hello $1; }; greet world",
+			wantOutput: "hello world\n",
+		},
+		{
+			name:       "function keyword definition",
+			input:      "function greet {\n  echo hello\n}\ngreet",
+			wantOutput: "hello\n",
+		},
+		{
+			name:       "function keyword with parentheses",
+			input:      "function greet() { echo hello; }; greet",
+			wantOutput: "hello\n",
+		},
+		{
+			name:       "body on the next line",
+			input:      "greet()\n{\n  echo hello\n}\ngreet",
+			wantOutput: "hello\n",
+		},
+		{
+			name:       "subshell body",
+			input:      "f() (echo sub); f",
+			wantOutput: "sub\n",
+		},
+		{
+			name:       "if body",
+			input:      "f() if true; then echo yes; fi; f",
+			wantOutput: "yes\n",
+		},
+		{
+			name:       "positional parameters are the arguments",
+			input:      `f() { echo "$# $1 $2 $@"; }; f a b; echo "$# $1"`,
+			args:       []string{"outer"},
+			wantOutput: "2 a b a b\n1 outer\n",
+		},
+		{
+			name:       "local variables are restored",
+			input:      "f() { local x=outer; g; echo $x; }; g() { local x=inner; echo $x; }; f; echo \"[$x]\"",
+			wantOutput: "inner\nouter\n[]\n",
+		},
+		{
+			name:       "return sets the status",
+			input:      "f() { return 3; echo no; }; f",
+			wantStatus: 3,
+		},
+		{
+			name:       "return without a count keeps the last status",
+			input:      "f() { false; return; }; f || echo failed",
+			wantOutput: "failed\n",
+		},
+		{
+			name:       "return leaves a loop",
+			input:      "f() { for i in 1 2 3; do if test $i = 2; then return 5; fi; echo $i; done; echo no; }; f; echo $?",
+			wantOutput: "1\n5\n",
+		},
+		{
+			name:       "recursion",
+			input:      `count() { if test "$1" = xxx; then return; fi; echo $1; count ${1}x; }; count x`,
+			wantOutput: "x\nxx\n",
+		},
+		{
+			name:       "functions take precedence over built-ins",
+			input:      "pwd() { echo mine; }; pwd",
+			wantOutput: "mine\n",
+		},
+		{
+			name:       "redirection of the body applies on each call",
+			input:      "f() { echo a; echo b >&2; } 2>&1; f | tr a-z A-Z",
+			wantOutput: "A\nB\n",
+		},
+		{
+			name:       "functions defined in a subshell stay there",
+			input:      "(f() { echo in; }; f); f",
+			wantOutput: "in\n",
+			wantStatus: 127,
+		},
+		{
+			name:       "type",
+			input:      "f() { echo hi; }; type f if cd",
+			wantOutput: "f is a function\nf() { echo hi; }\nif is a shell keyword\ncd is a shell builtin\n",
+		},
+		{
+			name:       "type of an unknown name",
+			input:      "type no-such-command-here",
+			wantStatus: 1,
+		},
+		{
+			name:       "declare -f and -F",
+			input:      "b() { echo b; }; function a { echo a; }; declare -f b; declare -F",
+			wantOutput: "b() { echo b; }\ndeclare -f a\ndeclare -f b\n",
+		},
+		{
+			name:       "declare -f of an unknown function",
+			input:      "declare -f f",
+			wantStatus: 1,
+		},
+		{
+			name:       "unset -f",
+			input:      "f() { echo hi; }; unset -f f; f",
+			wantStatus: 127,
+		},
+		{
+			name:       "local outside a function",
+			input:      "local x=1",
+			wantStatus: 1,
+		},
+		{
+			name:       "return outside a function",
+			input:      "return 2",
+			wantStatus: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			parser := New(cfg)
+
+			cmd, err := parser.Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse() failed: %v", err)
+			}
+
+			var stdout, stderr bytes.Buffer
+			ctx := WithIO(context.Background(), IO{Stdin: strings.NewReader(""), Stdout: &stdout, Stderr: &stderr})
+			ctx = WithState(ctx, NewState(DefaultShellName, tt.args))
+			status, err := cmd.Execute(ctx, cfg)
+			if status != tt.wantStatus {
+				t.Errorf("Execute() status = %d (%v), want %d", status, err, tt.wantStatus)
+			}
+			if stdout.String() != tt.wantOutput {
+				t.Errorf("output = %q, want %q", stdout.String(), tt.wantOutput)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package parser
+
+import (
+	"os"
+	"sort"
+	"strings"
+
+	"gosh/internal/config"
+)
+
+// glob returns the sorted paths that match pattern, or nil if none do.
+// Names starting with a dot are only matched by a pattern that starts with
+// one, unless cfg.Dotglob is set. With cfg.Globstar a ** component matches
+// any number of directories. A relative pattern is matched in cwd, and
+// gives relative paths.
+func glob(pattern, cwd string, cfg *config.Config) []string {
+	dir := ""
+	if strings.HasPrefix(pattern, "/") {
+		dir = "/"
+		pattern = strings.TrimLeft(pattern, "/")
+	}
+
+	var matches []string
+	for _, match := range globSegments(cwd, dir, strings.Split(pattern, "/"), cfg) {
+		if match != "" {
+			matches = append(matches, match)
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+// globSegments matches the remaining slash-separated segments of a pattern
+// inside dir, which is empty or ends with a slash
+func globSegments(cwd, dir string, segments []string, cfg *config.Config) []string {
+	if len(segments) == 0 {
+		return []string{dir}
+	}
+	segment, rest := segments[0], segments[1:]
+
+	switch {
+	case segment == "":
+		// A doubled or trailing slash
+		return globSegments(cwd, dir, rest, cfg)
+	case segment == "**" && cfg.Globstar:
+		return globStar(cwd, dir, rest, cfg)
+	case !hasPattern(segment):
+		name := unescapePattern(segment)
+		if len(rest) == 0 {
+			if _, err := os.Lstat(inDir(cwd, dir+name)); err != nil {
+				return nil
+			}
+			return []string{dir + name}
+		}
+		return globSegments(cwd, dir+name+"/", rest, cfg)
+	}
+
+	var matches []string
+	for _, entry := range readDir(cwd, dir) {
+		name := entry.Name()
+		if !globVisible(name, segment, cfg) || !matchPattern(segment, name) {
+			continue
+		}
+		if len(rest) == 0 {
+			matches = append(matches, dir+name)
+		} else if isDir(inDir(cwd, dir+name)) {
+			matches = append(matches, globSegments(cwd, dir+name+"/", rest, cfg)...)
+		}
+	}
+	return matches
+}
+
+// globStar matches the segments after a ** in dir and in every directory
+// below it. A trailing ** matches every file and directory below dir.
+// Symbolic links to directories are not followed.
+func globStar(cwd, dir string, rest []string, cfg *config.Config) []string {
+	var matches []string
+	if len(rest) > 0 {
+		matches = globSegments(cwd, dir, rest, cfg)
+	}
+
+	for _, entry := range readDir(cwd, dir) {
+		name := entry.Name()
+		if !globVisible(name, "", cfg) {
+			continue
+		}
+		if len(rest) == 0 {
+			matches = append(matches, dir+name)
+		}
+		if entry.IsDir() {
+			matches = append(matches, globStar(cwd, dir+name+"/", rest, cfg)...)
+		}
+	}
+	return matches
+}
+
+// globVisible reports whether the directory entry name may be matched by
+// segment, which hides names starting with a dot unless it starts with one
+func globVisible(name, segment string, cfg *config.Config) bool {
+	return cfg.Dotglob || !strings.HasPrefix(name, ".") || strings.HasPrefix(segment, ".")
+}
+
+// readDir lists dir as seen from cwd, or cwd itself if dir is empty,
+// ignoring errors: a directory that cannot be read matches nothing
+func readDir(cwd, dir string) []os.DirEntry {
+	if dir = inDir(cwd, dir); dir == "" {
+		dir = "."
+	}
+	entries, _ := os.ReadDir(dir)
+	return entries
+}
+
+// isDir reports whether path is a directory, following symbolic links
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// hasPattern reports whether s contains an unescaped pattern character
+func hasPattern(s string) bool {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '*', '?', '[':
+			return true
+		}
+	}
+	return false
+}
+
+// unescapePattern removes the backslashes from a pattern without pattern
+// characters, giving the text it matches
+func unescapePattern(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
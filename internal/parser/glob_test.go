@@ -0,0 +1,105 @@
+package parser
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"gosh/internal/config"
+)
+
+func TestGlob(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.go", "b.go", "c.txt", ".hidden.go", "sub/d.go", "sub/deep/e.go", "sub/.dot/f.go"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		pattern  string
+		globstar bool
+		dotglob  bool
+		want     []string
+	}{
+		{name: "star", pattern: "*.go", want: []string{"a.go", "b.go"}},
+		{name: "question mark", pattern: "?.*", want: []string{"a.go", "b.go", "c.txt"}},
+		{name: "bracket", pattern: "[ac].*", want: []string{"a.go", "c.txt"}},
+		{name: "explicit dot", pattern: ".*.go", want: []string{".hidden.go"}},
+		{name: "dotglob", pattern: "*.go", dotglob: true, want: []string{".hidden.go", "a.go", "b.go"}},
+		{name: "directories only", pattern: "*/", want: []string{"sub/"}},
+		{name: "pattern in a directory", pattern: "s*/*.go", want: []string{"sub/d.go"}},
+		{name: "escaped", pattern: `\*.go`, want: nil},
+		{name: "no match", pattern: "*.rs", want: nil},
+		{name: "double star without globstar", pattern: "**/*.go", want: []string{"sub/d.go"}},
+		{name: "globstar", pattern: "**/*.go", globstar: true, want: []string{"a.go", "b.go", "sub/d.go", "sub/deep/e.go"}},
+		{name: "globstar directories", pattern: "**/", globstar: true, want: []string{"", "sub/", "sub/deep/"}},
+		{name: "trailing globstar", pattern: "sub/**", globstar: true, want: []string{"sub/d.go", "sub/deep", "sub/deep/e.go"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.Globstar = tt.globstar
+			cfg.Dotglob = tt.dotglob
+
+			var want []string
+			for _, name := range tt.want {
+				want = append(want, dir+"/"+name)
+			}
+			if got := glob(dir+"/"+tt.pattern, "", cfg); !reflect.DeepEqual(got, want) {
+				t.Errorf("glob(%q) = %q, want %q", tt.pattern, got, want)
+			}
+		})
+	}
+}
+
+func TestPathnameExpansion(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.go", "b.go"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		word     string
+		nullglob bool
+		failglob bool
+		want     []string
+		wantErr  bool
+	}{
+		{name: "matches", word: "$DIR/*.go", want: []string{dir + "/a.go", dir + "/b.go"}},
+		{name: "quoted pattern", word: `"$DIR/*.go"`, want: []string{dir + "/*.go"}},
+		{name: "braces", word: "$DIR/{b,a}.g?", want: []string{dir + "/b.go", dir + "/a.go"}},
+		{name: "no match is kept", word: "$DIR/*.rs", want: []string{dir + "/*.rs"}},
+		{name: "nullglob", word: "$DIR/*.rs", nullglob: true, want: nil},
+		{name: "failglob", word: "$DIR/*.rs", failglob: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			_ = cfg.Variables.Set("DIR", dir)
+			cfg.Nullglob = tt.nullglob
+			cfg.Failglob = tt.failglob
+			parser := New(cfg)
+
+			got, err := parser.expandWord(context.Background(), tt.word)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expandWord() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expandWord(%q) = %q, want %q", tt.word, got, tt.want)
+			}
+		})
+	}
+}
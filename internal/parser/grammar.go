@@ -0,0 +1,988 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"gosh/internal/lexer"
+)
+
+// commandParser is a recursive-descent parser over the tokens of one input.
+//
+//	program  := list
+//	list     := and_or ((';' | '&' | newline) and_or)* [';' | '&' | newline]
+//	and_or   := pipeline (('&&' | '||') newline* pipeline)*
+//	pipeline := ['!'] command (('|' | '|&') newline* command)*
+//	command  := simple | compound redirect* | function
+//	compound := '(' list ')' | '{' list '}' | '((' expr '))' | '[[' cond ']]'
+//	          | if | while | until | for | case
+//	if       := 'if' list 'then' list ('elif' list 'then' list)* ['else' list] 'fi'
+//	while    := ('while' | 'until') list 'do' list 'done'
+//	for      := 'for' name [newline* 'in' word*] (';' | newline) newline* 'do' list 'done'
+//	          | 'for' '((' expr ';' expr ';' expr '))' [';' | newline] newline* 'do' list 'done'
+//	case     := 'case' word newline* 'in' newline* clause* 'esac'
+//	clause   := ['('] word ('|' word)* ')' [list] [';;'] newline*
+//	cond     := cond_and ('||' cond_and)*
+//	cond_and := cond_not ('&&' cond_not)*
+//	cond_not := '!' cond_not | '(' cond ')' | unary-op word | word [binary-op word]
+//	function := name '(' ')' newline* command | 'function' name ['(' ')'] newline* command
+//	simple   := (word | redirect)+
+type commandParser struct {
+	parser *Parser
+	src    string
+	tokens []lexer.Token
+	pos    int
+	// aliases holds the aliases expanded for the current command, so that
+	// an alias that starts with its own name is not expanded again
+	aliases map[string]bool
+}
+
+// parseProgram parses the whole input as a command list
+func (cp *commandParser) parseProgram() (Command, error) {
+	cmd, err := cp.parseList()
+	if err != nil {
+		return nil, err
+	}
+
+	if !cp.atEnd() {
+		return nil, cp.unexpected(cp.peek())
+	}
+	return cmd, nil
+}
+
+// parseList parses commands separated by ; or newlines up to one of the
+// closing tokens of the enclosing construct, which is left unconsumed.
+// Without closers the list runs to the end of the input.
+func (cp *commandParser) parseList(closers ...string) (Command, error) {
+	var commands []Command
+	for {
+		cp.skipNewlines()
+		if cp.atEnd() || cp.atCloser(closers) {
+			break
+		}
+
+		start := cp.peek().Pos.Offset
+		cmd, err := cp.parseAndOr()
+		if err != nil {
+			return nil, err
+		}
+
+		switch tok := cp.peek(); tok.Kind {
+		case lexer.Amp:
+			cmd = &BackgroundCommand{
+				Command: cmd,
+				Text:    cp.text(start, tok.Pos.Offset),
+				Jobs:    cp.parser.jobManager,
+			}
+			fallthrough
+		case lexer.Semi, lexer.Newline:
+			commands = append(commands, cmd)
+			cp.pos++
+			continue
+		}
+		commands = append(commands, cmd)
+		break
+	}
+
+	if len(closers) > 0 {
+		if cp.atEnd() {
+			return nil, cp.missing(closers[len(closers)-1])
+		}
+		if !cp.atCloser(closers) || len(commands) == 0 {
+			return nil, cp.unexpected(cp.peek())
+		}
+	}
+
+	switch len(commands) {
+	case 0:
+		return &NoOpCommand{}, nil
+	case 1:
+		return commands[0], nil
+	default:
+		return &SequenceCommand{Commands: commands}, nil
+	}
+}
+
+// parseAndOr parses pipelines joined by && and ||, which group to the left
+func (cp *commandParser) parseAndOr() (Command, error) {
+	left, err := cp.parsePipeline()
+	if err != nil {
+		return nil, err
+	}
+
+	for cp.peek().Kind == lexer.AndIf || cp.peek().Kind == lexer.OrIf {
+		op := AndIf
+		if cp.next().Kind == lexer.OrIf {
+			op = OrIf
+		}
+
+		right, err := cp.parseContinuation(cp.parsePipeline)
+		if err != nil {
+			return nil, err
+		}
+		left = &AndOrCommand{Op: op, Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+// parsePipeline parses commands joined by | and |&, optionally negated
+// with !. A single command is returned as itself.
+func (cp *commandParser) parsePipeline() (Command, error) {
+	if cp.atReserved("!") {
+		cp.pos++
+		cmd, err := cp.parseContinuation(cp.parsePipeline)
+		if err != nil {
+			return nil, err
+		}
+		return &NotCommand{Command: cmd}, nil
+	}
+
+	first, err := cp.parseCommand()
+	if err != nil {
+		return nil, err
+	}
+
+	pipeline := &PipelineCommand{Stages: []Command{first}}
+	for cp.peek().Kind == lexer.Pipe || cp.peek().Kind == lexer.PipeAll {
+		pipeline.PipeStderr = append(pipeline.PipeStderr, cp.next().Kind == lexer.PipeAll)
+
+		stage, err := cp.parseContinuation(cp.parseCommand)
+		if err != nil {
+			return nil, err
+		}
+		pipeline.Stages = append(pipeline.Stages, stage)
+	}
+
+	if len(pipeline.Stages) == 1 {
+		return first, nil
+	}
+	pipeline.PipeStderr = append(pipeline.PipeStderr, false)
+	return pipeline, nil
+}
+
+// parseContinuation parses the operand that follows an operator, which may
+// start on a later line
+func (cp *commandParser) parseContinuation(parse func() (Command, error)) (Command, error) {
+	operator := cp.tokens[cp.pos-1].Value
+	cp.skipNewlines()
+	if cp.atEnd() {
+		return nil, cp.incomplete(fmt.Sprintf("expected a command after `%s'", operator))
+	}
+	return parse()
+}
+
+// parseCommand parses a compound command or a simple command
+func (cp *commandParser) parseCommand() (Command, error) {
+	cp.expandAliases()
+
+	var cmd Command
+	var err error
+	switch tok := cp.peek(); {
+	case tok.Kind == lexer.LParen:
+		cp.pos++
+		var body Command
+		if body, err = cp.parseBody(")"); err == nil {
+			cmd = &SubshellCommand{Body: body}
+		}
+	case cp.atReserved("{"):
+		cp.pos++
+		var body Command
+		if body, err = cp.parseBody("}"); err == nil {
+			cmd = &BraceGroupCommand{Body: body}
+		}
+	case tok.Kind == lexer.Arith:
+		cp.pos++
+		cmd = &ArithCommand{Expr: tok.Value[2 : len(tok.Value)-2], parser: cp.parser}
+	case cp.atReserved("[["):
+		cmd, err = cp.parseCond()
+	case cp.atReserved("if"):
+		cmd, err = cp.parseIf()
+	case cp.atReserved("while"), cp.atReserved("until"):
+		cmd, err = cp.parseLoop()
+	case cp.atReserved("for"):
+		cmd, err = cp.parseFor()
+	case cp.atReserved("case"):
+		cmd, err = cp.parseCase()
+	case cp.atReserved("function"), cp.atFunctionDef():
+		// The body keeps its own redirections, which apply on each call
+		return cp.parseFunction()
+	case tok.Kind == lexer.Reserved && tok.Value != "]]":
+		return nil, cp.unexpected(tok)
+	default:
+		return cp.parseSimple()
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return cp.parseCompoundRedirects(cmd)
+}
+
+// parseBody parses a command list and consumes the closing token that ends
+// it
+func (cp *commandParser) parseBody(closer string) (Command, error) {
+	body, err := cp.parseList(closer)
+	if err != nil {
+		return nil, err
+	}
+	cp.pos++
+	return body, nil
+}
+
+// parseIf parses an if command with its elif and else parts
+func (cp *commandParser) parseIf() (Command, error) {
+	cmd := &IfCommand{}
+	for cp.atReserved("if") || cp.atReserved("elif") {
+		cp.pos++
+		cond, err := cp.parseBody("then")
+		if err != nil {
+			return nil, err
+		}
+		body, err := cp.parseList("elif", "else", "fi")
+		if err != nil {
+			return nil, err
+		}
+		cmd.Clauses = append(cmd.Clauses, IfClause{Cond: cond, Body: body})
+	}
+
+	if cp.atReserved("else") {
+		cp.pos++
+		body, err := cp.parseList("fi")
+		if err != nil {
+			return nil, err
+		}
+		cmd.Else = body
+	}
+	cp.pos++
+	return cmd, nil
+}
+
+// parseLoop parses a while or until loop
+func (cp *commandParser) parseLoop() (Command, error) {
+	until := cp.next().Value == "until"
+	cond, err := cp.parseBody("do")
+	if err != nil {
+		return nil, err
+	}
+	body, err := cp.parseBody("done")
+	if err != nil {
+		return nil, err
+	}
+	return &LoopCommand{Cond: cond, Body: body, Until: until}, nil
+}
+
+// parseFor parses a for loop over a list of words or, written with (( )),
+// a C-style for loop. Without "in" the loop runs over "$@".
+func (cp *commandParser) parseFor() (Command, error) {
+	cp.pos++
+	if cp.peek().Kind == lexer.Arith {
+		return cp.parseArithFor()
+	}
+
+	tok := cp.peek()
+	if cp.atEnd() {
+		return nil, cp.incomplete("expected a variable name after `for'")
+	}
+	if !isWordToken(tok) || !isName(tok.Value) {
+		return nil, cp.errorAt(tok, fmt.Sprintf("`%s': not a valid identifier", tok.Value))
+	}
+	cp.pos++
+	cmd := &ForCommand{Name: tok.Value, Words: []string{`"$@"`}, parser: cp.parser}
+
+	// A newline may come before "in", but not the words that follow it
+	start := cp.pos
+	cp.skipNewlines()
+	if cp.atReserved("in") {
+		cp.pos++
+		cmd.Words = nil
+		for isWordToken(cp.peek()) {
+			cmd.Words = append(cmd.Words, cp.next().Value)
+		}
+	} else {
+		cp.pos = start
+	}
+
+	if err := cp.parseDoSeparator(); err != nil {
+		return nil, err
+	}
+	body, err := cp.parseDoGroup()
+	if err != nil {
+		return nil, err
+	}
+	cmd.Body = body
+	return cmd, nil
+}
+
+// parseArithFor parses the (( )) header and body of a C-style for loop
+func (cp *commandParser) parseArithFor() (Command, error) {
+	tok := cp.next()
+	inner := tok.Value[2 : len(tok.Value)-2]
+	exprs := splitArithFor(inner)
+	if len(exprs) != 3 {
+		return nil, cp.errorAt(tok, fmt.Sprintf("syntax error: expected 3 expressions in `%s'", tok.Value))
+	}
+
+	if cp.peek().Kind == lexer.Semi {
+		cp.pos++
+	}
+	body, err := cp.parseDoGroup()
+	if err != nil {
+		return nil, err
+	}
+	return &ArithForCommand{Init: exprs[0], Cond: exprs[1], Step: exprs[2], Body: body, parser: cp.parser}, nil
+}
+
+// parseDoSeparator consumes the ; or newline that must end the word list
+// of a for loop
+func (cp *commandParser) parseDoSeparator() error {
+	switch tok := cp.peek(); {
+	case tok.Kind == lexer.Semi || tok.Kind == lexer.Newline:
+		cp.pos++
+		return nil
+	case cp.atReserved("do"):
+		return nil
+	case cp.atEnd():
+		return cp.missing("do")
+	default:
+		return cp.unexpected(tok)
+	}
+}
+
+// parseDoGroup parses the do ... done body of a for loop
+func (cp *commandParser) parseDoGroup() (Command, error) {
+	cp.skipNewlines()
+	if cp.atEnd() {
+		return nil, cp.missing("do")
+	}
+	if !cp.atReserved("do") {
+		return nil, cp.unexpected(cp.peek())
+	}
+	cp.pos++
+	return cp.parseBody("done")
+}
+
+// parseCase parses a case command and its clauses
+func (cp *commandParser) parseCase() (Command, error) {
+	cp.pos++
+	tok := cp.peek()
+	if cp.atEnd() {
+		return nil, cp.incomplete("expected a word after `case'")
+	}
+	if !isWordToken(tok) {
+		return nil, cp.unexpected(tok)
+	}
+	cp.pos++
+	cmd := &CaseCommand{Word: tok.Value, parser: cp.parser}
+
+	cp.skipNewlines()
+	if cp.atEnd() {
+		return nil, cp.missing("in")
+	}
+	if !cp.atReserved("in") {
+		return nil, cp.unexpected(cp.peek())
+	}
+	cp.pos++
+
+	for {
+		cp.skipNewlines()
+		if cp.atEnd() {
+			return nil, cp.missing("esac")
+		}
+		if cp.atReserved("esac") {
+			cp.pos++
+			return cmd, nil
+		}
+
+		clause, err := cp.parseCaseClause()
+		if err != nil {
+			return nil, err
+		}
+		cmd.Clauses = append(cmd.Clauses, clause)
+	}
+}
+
+// parseCaseClause parses the patterns and commands of one case clause,
+// up to and including its ;;
+func (cp *commandParser) parseCaseClause() (CaseClause, error) {
+	var clause CaseClause
+	if cp.peek().Kind == lexer.LParen {
+		cp.pos++
+	}
+
+	for {
+		tok := cp.peek()
+		if cp.atEnd() {
+			return clause, cp.missing(")")
+		}
+		if !isWordToken(tok) {
+			return clause, cp.unexpected(tok)
+		}
+		clause.Patterns = append(clause.Patterns, tok.Value)
+		cp.pos++
+
+		if cp.peek().Kind != lexer.Pipe {
+			break
+		}
+		cp.pos++
+	}
+
+	if cp.atEnd() {
+		return clause, cp.missing(")")
+	}
+	if cp.peek().Kind != lexer.RParen {
+		return clause, cp.unexpected(cp.peek())
+	}
+	cp.pos++
+
+	// The commands may be left out, as in "*) ;;"
+	cp.skipNewlines()
+	clause.Body = &NoOpCommand{}
+	if !cp.atCloser([]string{";;", "esac"}) {
+		body, err := cp.parseList(";;", "esac")
+		if err != nil {
+			return clause, err
+		}
+		clause.Body = body
+	}
+
+	if cp.peek().Kind == lexer.DSemi {
+		cp.pos++
+	}
+	return clause, nil
+}
+
+// parseCond parses a [[ ]] command. Inside it, && and || join
+// expressions, < and > compare strings rather than redirect, and newlines
+// may separate the parts of the expression.
+func (cp *commandParser) parseCond() (Command, error) {
+	cp.pos++
+	expr, err := cp.parseCondOr()
+	if err != nil {
+		return nil, err
+	}
+
+	cp.skipNewlines()
+	if cp.atEnd() {
+		return nil, cp.missing("]]")
+	}
+	if !cp.atReserved("]]") {
+		return nil, cp.condUnexpected(cp.peek())
+	}
+	cp.pos++
+	return &CondCommand{Expr: expr, parser: cp.parser}, nil
+}
+
+// parseCondOr parses conditional expressions joined by ||
+func (cp *commandParser) parseCondOr() (condExpr, error) {
+	left, err := cp.parseCondAnd()
+	if err != nil {
+		return nil, err
+	}
+	for cp.skipNewlines(); cp.peek().Kind == lexer.OrIf; cp.skipNewlines() {
+		cp.pos++
+		right, err := cp.parseCondAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &condLogical{Op: OrIf, Left: left, Right: right}
+	}
+	return left, nil
+}
+
+// parseCondAnd parses conditional expressions joined by &&, which binds
+// more tightly than ||
+func (cp *commandParser) parseCondAnd() (condExpr, error) {
+	left, err := cp.parseCondNot()
+	if err != nil {
+		return nil, err
+	}
+	for cp.skipNewlines(); cp.peek().Kind == lexer.AndIf; cp.skipNewlines() {
+		cp.pos++
+		right, err := cp.parseCondNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &condLogical{Op: AndIf, Left: left, Right: right}
+	}
+	return left, nil
+}
+
+// parseCondNot parses a negated, parenthesized, unary or binary
+// conditional expression, or a word on its own
+func (cp *commandParser) parseCondNot() (condExpr, error) {
+	cp.skipNewlines()
+	tok := cp.peek()
+	switch {
+	case cp.atEnd():
+		return nil, cp.missing("]]")
+	case cp.atReserved("!"):
+		cp.pos++
+		expr, err := cp.parseCondNot()
+		if err != nil {
+			return nil, err
+		}
+		return &condNot{Expr: expr}, nil
+	case tok.Kind == lexer.LParen:
+		cp.pos++
+		expr, err := cp.parseCondOr()
+		if err != nil {
+			return nil, err
+		}
+		cp.skipNewlines()
+		if cp.atEnd() {
+			return nil, cp.missing(")")
+		}
+		if cp.peek().Kind != lexer.RParen {
+			return nil, cp.condUnexpected(cp.peek())
+		}
+		cp.pos++
+		return expr, nil
+	case !isCondWord(tok):
+		return nil, cp.condUnexpected(tok)
+	}
+	cp.pos++
+
+	if tok.Kind == lexer.Word && !tok.Quoted && unaryTests[tok.Value] && isCondWord(cp.peek()) {
+		return &condUnary{Op: tok.Value, Word: cp.next().Value}, nil
+	}
+
+	op, ok := cp.condBinaryOp()
+	if !ok {
+		return &condString{Word: tok.Value}, nil
+	}
+	if op == "=~" {
+		regex, err := cp.parseCondRegex()
+		if err != nil {
+			return nil, err
+		}
+		return &condBinary{Op: op, Left: tok.Value, Right: regex}, nil
+	}
+
+	right := cp.peek()
+	if cp.atEnd() {
+		return nil, cp.missing("]]")
+	}
+	if !isCondWord(right) {
+		return nil, cp.condUnexpected(right)
+	}
+	cp.pos++
+	return &condBinary{Op: op, Left: tok.Value, Right: right.Value}, nil
+}
+
+// condBinaryOp consumes the binary operator of a conditional expression,
+// if one is next. The lexer reads < and > as redirections.
+func (cp *commandParser) condBinaryOp() (string, bool) {
+	tok := cp.peek()
+	switch {
+	case tok.Kind == lexer.Word && !tok.Quoted && (binaryTests[tok.Value] || tok.Value == "=~"):
+	case tok.Kind == lexer.Redirect && tok.Fd < 0 && (tok.Value == "<" || tok.Value == ">"):
+	default:
+		return "", false
+	}
+	cp.pos++
+	return tok.Value, true
+}
+
+// parseCondRegex parses the regular expression after =~. The lexer splits
+// it at characters such as | and (, so it is taken from the source as the
+// run of tokens with nothing between them, or between its parentheses.
+func (cp *commandParser) parseCondRegex() (string, error) {
+	first := cp.peek()
+	if cp.atEnd() {
+		return "", cp.missing("]]")
+	}
+	if !isCondWord(first) && first.Kind != lexer.LParen {
+		return "", cp.condUnexpected(first)
+	}
+
+	end, depth := first.Pos.Offset, 0
+	for {
+		tok := cp.peek()
+		if tok.Kind == lexer.EOF || tok.Kind == lexer.Newline {
+			break
+		}
+		if depth == 0 && (tok.Pos.Offset != end || cp.atReserved("]]")) {
+			break
+		}
+		switch tok.Kind {
+		case lexer.LParen:
+			depth++
+		case lexer.RParen:
+			depth--
+		}
+		if depth < 0 {
+			break
+		}
+		cp.pos++
+		end = tok.Pos.Offset + len(tok.Value)
+	}
+
+	if depth > 0 {
+		if cp.atEnd() {
+			return "", cp.missing(")")
+		}
+		return "", cp.condUnexpected(cp.peek())
+	}
+	return cp.src[first.Pos.Offset:end], nil
+}
+
+// condUnexpected reports a token that is not allowed in a conditional
+// expression
+func (cp *commandParser) condUnexpected(tok lexer.Token) error {
+	value := tok.Value
+	if tok.Kind == lexer.Newline || tok.Kind == lexer.EOF {
+		value = "newline"
+	}
+	return cp.errorAt(tok, fmt.Sprintf("syntax error in conditional expression: unexpected token `%s'", value))
+}
+
+// parseFunction parses a function definition. The body must be a compound
+// command.
+func (cp *commandParser) parseFunction() (Command, error) {
+	start := cp.peek().Pos.Offset
+	keyword := cp.atReserved("function")
+	if keyword {
+		cp.pos++
+	}
+
+	nameTok := cp.peek()
+	if cp.atEnd() {
+		return nil, cp.incomplete("expected a function name")
+	}
+	if nameTok.Kind != lexer.Word {
+		return nil, cp.unexpected(nameTok)
+	}
+	if !isFunctionName(nameTok) {
+		return nil, cp.errorAt(nameTok, fmt.Sprintf("`%s': not a valid identifier", nameTok.Value))
+	}
+	cp.pos++
+
+	if cp.peek().Kind == lexer.LParen || !keyword {
+		cp.pos++
+		if cp.peek().Kind != lexer.RParen {
+			if cp.atEnd() {
+				return nil, cp.incomplete("expected `)'")
+			}
+			return nil, cp.unexpected(cp.peek())
+		}
+		cp.pos++
+	}
+
+	cp.skipNewlines()
+	if cp.atEnd() {
+		return nil, cp.incomplete("expected a function body")
+	}
+	if !cp.atCompound() {
+		return nil, cp.unexpected(cp.peek())
+	}
+	body, err := cp.parseCommand()
+	if err != nil {
+		return nil, err
+	}
+
+	last := cp.tokens[cp.pos-1]
+	fn := &Function{
+		Name: nameTok.Value,
+		Body: body,
+		Text: cp.text(start, last.Pos.Offset+len(last.Value)),
+	}
+	return &FunctionDefCommand{Function: fn}, nil
+}
+
+// parseSimple parses the assignments, words and redirections of a simple
+// command. Reserved words are ordinary words once the command name is read.
+func (cp *commandParser) parseSimple() (Command, error) {
+	cmd := &SimpleCommand{parser: cp.parser}
+	for {
+		tok := cp.peek()
+		if tok.Kind == lexer.Word && len(cmd.Words) == 0 && assignmentValue(tok.Value) > 0 {
+			cmd.Assigns = append(cmd.Assigns, tok.Value)
+			cp.pos++
+			continue
+		}
+		if tok.Kind == lexer.Word || tok.Kind == lexer.Reserved {
+			cmd.Words = append(cmd.Words, tok.Value)
+			cp.pos++
+			continue
+		}
+		if tok.Kind != lexer.Redirect {
+			break
+		}
+
+		r, err := cp.parseRedirect()
+		if err != nil {
+			return nil, err
+		}
+		cmd.Redirects = append(cmd.Redirects, r)
+	}
+
+	if len(cmd.Words) == 0 && len(cmd.Assigns) == 0 && len(cmd.Redirects) == 0 {
+		if cp.atEnd() {
+			return nil, cp.incomplete("expected a command")
+		}
+		return nil, cp.unexpected(cp.peek())
+	}
+	return cmd, nil
+}
+
+// expandAliases replaces an alias at the start of a command with the tokens
+// of its value
+func (cp *commandParser) expandAliases() {
+	if cp.pos == 0 || cp.tokens[cp.pos-1].Kind != lexer.Word {
+		// A new command starts, so its aliases may be expanded again
+		cp.aliases = nil
+	}
+
+	for {
+		tok := cp.peek()
+		if tok.Kind != lexer.Word || tok.Quoted || cp.aliases[tok.Value] {
+			return
+		}
+		expanded, ok := cp.parser.expandAlias(tok.Value)
+		if !ok {
+			return
+		}
+
+		replacement, err := lexer.Tokenize(expanded)
+		if err != nil {
+			// An alias that does not lex is used as a plain word
+			return
+		}
+		replacement = replacement[:len(replacement)-1]
+		for i := range replacement {
+			replacement[i].Pos = tok.Pos
+		}
+
+		if cp.aliases == nil {
+			cp.aliases = make(map[string]bool)
+		}
+		cp.aliases[tok.Value] = true
+
+		rest := append(replacement, cp.tokens[cp.pos+1:]...)
+		cp.tokens = append(cp.tokens[:cp.pos], rest...)
+	}
+}
+
+// parseCompoundRedirects parses the redirections that follow a subshell or
+// brace group and applies them to the whole group
+func (cp *commandParser) parseCompoundRedirects(cmd Command) (Command, error) {
+	var redirects []Redirect
+	for cp.peek().Kind == lexer.Redirect {
+		r, err := cp.parseRedirect()
+		if err != nil {
+			return nil, err
+		}
+		redirects = append(redirects, r)
+	}
+
+	if len(redirects) == 0 {
+		return cmd, nil
+	}
+	return &RedirectedCommand{Command: cmd, Redirects: redirects, parser: cp.parser}, nil
+}
+
+// parseRedirect parses a redirection operator and its target word
+func (cp *commandParser) parseRedirect() (Redirect, error) {
+	tok := cp.next()
+	target := cp.peek()
+	if target.Kind != lexer.Word && target.Kind != lexer.Reserved {
+		return Redirect{}, cp.unexpected(target)
+	}
+	cp.pos++
+
+	spec := redirectOps[tok.Value]
+	r := Redirect{Fd: spec.fd, Op: spec.op, Target: target.Value}
+	if tok.Fd >= 0 {
+		r.Fd = tok.Fd
+	}
+
+	// The document of a here-document replaces its delimiter and is only
+	// expanded when the delimiter is unquoted
+	if r.Op == RedirectHereDoc || r.Op == RedirectHereDocStrip {
+		r.Target = tok.HereDoc
+		r.Literal = target.Quoted
+	}
+
+	// Like bash, ">& file" without a descriptor number means "&> file"
+	if r.Op == RedirectDupOut && tok.Fd < 0 && !isDescriptor(r.Target) {
+		r.Op = RedirectAll
+	}
+
+	return r, nil
+}
+
+// text returns the source text between two offsets, as shown for a job
+func (cp *commandParser) text(start, end int) string {
+	if start < 0 || end > len(cp.src) || start > end {
+		return ""
+	}
+	return strings.TrimSpace(cp.src[start:end])
+}
+
+// atEnd reports whether all tokens have been consumed
+func (cp *commandParser) atEnd() bool {
+	return cp.peek().Kind == lexer.EOF
+}
+
+// peek returns the next token without consuming it
+func (cp *commandParser) peek() lexer.Token {
+	if cp.pos >= len(cp.tokens) {
+		return cp.tokens[len(cp.tokens)-1]
+	}
+	return cp.tokens[cp.pos]
+}
+
+// next consumes and returns the next token
+func (cp *commandParser) next() lexer.Token {
+	tok := cp.peek()
+	cp.pos++
+	return tok
+}
+
+// skipNewlines consumes any line breaks
+func (cp *commandParser) skipNewlines() {
+	for cp.peek().Kind == lexer.Newline {
+		cp.pos++
+	}
+}
+
+// atReserved reports whether the next token is the reserved word word
+func (cp *commandParser) atReserved(word string) bool {
+	tok := cp.peek()
+	return tok.Kind == lexer.Reserved && tok.Value == word
+}
+
+// atFunctionDef reports whether the next tokens start a definition written
+// as name()
+func (cp *commandParser) atFunctionDef() bool {
+	return cp.pos+2 < len(cp.tokens) &&
+		cp.tokens[cp.pos].Kind == lexer.Word &&
+		cp.tokens[cp.pos+1].Kind == lexer.LParen &&
+		cp.tokens[cp.pos+2].Kind == lexer.RParen
+}
+
+// atCompound reports whether the next token starts a compound command
+func (cp *commandParser) atCompound() bool {
+	if cp.peek().Kind == lexer.LParen {
+		return true
+	}
+	for _, word := range []string{"{", "[[", "if", "while", "until", "for", "case"} {
+		if cp.atReserved(word) {
+			return true
+		}
+	}
+	return false
+}
+
+// atCloser reports whether the next token is one of closers, the tokens
+// that end the enclosing construct
+func (cp *commandParser) atCloser(closers []string) bool {
+	for _, closer := range closers {
+		switch closer {
+		case ")":
+			if cp.peek().Kind == lexer.RParen {
+				return true
+			}
+		case ";;":
+			if cp.peek().Kind == lexer.DSemi {
+				return true
+			}
+		default:
+			if cp.atReserved(closer) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// unexpected reports a token that is not allowed where it appears
+func (cp *commandParser) unexpected(tok lexer.Token) error {
+	value := tok.Value
+	if tok.Kind == lexer.Newline || tok.Kind == lexer.EOF {
+		value = "newline"
+	}
+	return cp.errorAt(tok, fmt.Sprintf("syntax error near unexpected token `%s'", value))
+}
+
+// errorAt builds a syntax error located at tok
+func (cp *commandParser) errorAt(tok lexer.Token, msg string) error {
+	return &SyntaxError{Line: tok.Pos.Line, Column: tok.Pos.Col, Msg: msg}
+}
+
+// incomplete builds a syntax error for input that ends too early
+func (cp *commandParser) incomplete(msg string) error {
+	tok := cp.peek()
+	return &SyntaxError{Line: tok.Pos.Line, Column: tok.Pos.Col, Msg: msg, incomplete: true}
+}
+
+// missing builds a syntax error for input that ends before the word closer
+func (cp *commandParser) missing(closer string) error {
+	tok := cp.peek()
+	return &SyntaxError{
+		Line:       tok.Pos.Line,
+		Column:     tok.Pos.Col,
+		Msg:        fmt.Sprintf("missing `%s'", closer),
+		incomplete: true,
+		want:       closer,
+	}
+}
+
+// isWordToken reports whether tok is a word, reserved or not
+func isWordToken(tok lexer.Token) bool {
+	return tok.Kind == lexer.Word || tok.Kind == lexer.Reserved
+}
+
+// isCondWord reports whether tok may be an operand in a conditional
+// expression
+func isCondWord(tok lexer.Token) bool {
+	return tok.Kind == lexer.Word || (tok.Kind == lexer.Reserved && tok.Value != "]]")
+}
+
+// isFunctionName reports whether tok may name a function. Unlike a variable
+// name it may contain characters such as - and ., but not quotes,
+// expansions or =.
+func isFunctionName(tok lexer.Token) bool {
+	return !tok.Quoted && !strings.ContainsAny(tok.Value, "$`=")
+}
+
+// splitArithFor splits the inside of a for (( )) header at the semicolons
+// that are not nested in parentheses or quotes
+func splitArithFor(inner string) []string {
+	var exprs []string
+	start, depth := 0, 0
+	for i := 0; i < len(inner); i++ {
+		switch inner[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case '\'', '"':
+			if end := lexer.QuoteEnd(inner, i); end > 0 {
+				i = end - 1
+			}
+		case '$', '`':
+			i = expansionEnd(inner, i) - 1
+		case ';':
+			if depth == 0 {
+				exprs = append(exprs, inner[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(exprs, inner[start:])
+}
+
+// isDescriptor reports whether a duplication target names a descriptor
+func isDescriptor(target string) bool {
+	if target == "-" {
+		return true
+	}
+	for _, c := range target {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return target != ""
+}
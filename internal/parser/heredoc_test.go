@@ -0,0 +1,94 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"gosh/internal/config"
+)
+
+func TestHereDoc(t *testing.T) {
+	cfg := config.Default()
+	_ = cfg.Variables.Set("GREETING", "hello")
+
+	tests := []struct {
+		name       string
+		input      string
+		wantOutput string
+	}{
+		{
+			name:       "expanded body",
+			input:      "cat <<EOF\n$GREETING world\n${GREETING}!\nEOF",
+			wantOutput: "hello world\nhello!\n",
+		},
+		{
+			name:       "escaped dollar",
+			input:      "cat <<EOF\n\\$GREETING costs \\\\5\nEOF",
+			wantOutput: "$GREETING costs \\5\n",
+		},
+		{
+			name:       "quoted delimiter disables expansion",
+			input:      "cat <<'EOF'\n$GREETING \\$x\nEOF",
+			wantOutput: "$GREETING \\$x\n",
+		},
+		{
+			name:       "double quoted delimiter",
+			input:      "cat <<\"END\"\n$GREETING\nEND",
+			wantOutput: "$GREETING\n",
+		},
+		{
+			name:       "strip leading tabs",
+			input:      "cat <<-EOF\n\t\tindented\n\tnot\tinner\n\tEOF",
+			wantOutput: "indented\nnot\tinner\n",
+		},
+		{
+			name:       "empty body",
+			input:      "cat <<EOF\nEOF",
+			wantOutput: "",
+		},
+		{
+			name:       "heredoc in a pipeline",
+			input:      "cat <<EOF | tr a-z A-Z\nshout\nEOF",
+			wantOutput: "SHOUT\n",
+		},
+		{
+			name:       "two heredocs read in order",
+			input:      "cat <<ONE <<TWO\nfirst\nONE\nsecond\nTWO",
+			wantOutput: "second\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := New(cfg)
+			cmd, err := parser.Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse() failed: %v", err)
+			}
+
+			var stdout bytes.Buffer
+			ctx := WithIO(context.Background(), IO{Stdin: strings.NewReader(""), Stdout: &stdout})
+			if _, err := cmd.Execute(ctx, cfg); err != nil {
+				t.Fatalf("Execute() failed: %v", err)
+			}
+
+			if stdout.String() != tt.wantOutput {
+				t.Errorf("output = %q, want %q", stdout.String(), tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestHereDocIncomplete(t *testing.T) {
+	parser := New(config.Default())
+
+	for _, input := range []string{"cat <<EOF", "cat <<EOF\nline one", "cat <<EOF\nEOF2", `echo "open`} {
+		_, err := parser.Parse(input)
+		if !errors.Is(err, ErrIncomplete) {
+			t.Errorf("Parse(%q) error = %v, want ErrIncomplete", input, err)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package parser
+
+import (
+	"context"
+	"io"
+	"os"
+	"reflect"
+	"sync"
+)
+
+// IO holds the standard streams a command reads from and writes to
+type IO struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+	// Extra holds the descriptors above 2 that redirections opened, as in
+	// 3>file or 4<&0, by number. Each is an io.Reader, an io.Writer or a
+	// file, which is both.
+	Extra map[int]interface{}
+}
+
+// ioContextKey is the context key under which a command's IO is stored
+type ioContextKey struct{}
+
+// WithIO returns a context that carries the given streams to the commands
+// executed with it. Writers that are not files are made safe to share, as
+// the stages of a pipeline and background jobs write to them at the same
+// time, processes through a goroutine that copies their output.
+func WithIO(ctx context.Context, streams IO) context.Context {
+	stdout := streams.Stdout
+	streams.Stdout = lockWriter(stdout)
+	if sameWriter(streams.Stderr, stdout) {
+		streams.Stderr = streams.Stdout
+	} else {
+		streams.Stderr = lockWriter(streams.Stderr)
+	}
+	return context.WithValue(ctx, ioContextKey{}, streams)
+}
+
+// IOFromContext returns the streams carried by ctx, falling back to the
+// process's standard streams for any that are not set
+func IOFromContext(ctx context.Context) IO {
+	streams, _ := ctx.Value(ioContextKey{}).(IO)
+	if streams.Stdin == nil {
+		streams.Stdin = os.Stdin
+	}
+	if streams.Stdout == nil {
+		streams.Stdout = os.Stdout
+	}
+	if streams.Stderr == nil {
+		streams.Stderr = os.Stderr
+	}
+	return streams
+}
+
+// lockedWriter is a writer whose writes are serialized by a mutex
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// Write implements io.Writer for lockedWriter
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
+
+// lockWriter returns w with its writes serialized, unless it is a file,
+// which the system serializes, is already locked or is closed
+func lockWriter(w io.Writer) io.Writer {
+	switch w.(type) {
+	case nil, *os.File, *lockedWriter, closedStream:
+		return w
+	}
+	return &lockedWriter{w: w}
+}
+
+// sameWriter reports whether a and b are the same writer
+func sameWriter(a, b io.Writer) bool {
+	if a == nil || b == nil || reflect.TypeOf(a) != reflect.TypeOf(b) || !reflect.TypeOf(a).Comparable() {
+		return false
+	}
+	return a == b
+}
+
+// processStream returns a standard stream as a process is given it. A
+// closed descriptor becomes nil, which exec connects to the null device.
+func processStream[T any](stream T) T {
+	var none T
+	if _, closed := any(stream).(closedStream); closed {
+		return none
+	}
+	return stream
+}
+
+// extraFiles returns the descriptors above 2 for a process, indexed from 3
+// as exec.Cmd.ExtraFiles wants them. Redirections leave only files and
+// writers there; a writer that is not a file is fed through a pipe. Once
+// the process has exited, finish closes the pipes and waits for the output
+// copied through them.
+func extraFiles(streams IO) (files []*os.File, finish func(), err error) {
+	var writeEnds, readEnds []*os.File
+	var copies sync.WaitGroup
+	finish = func() {
+		for _, f := range writeEnds {
+			_ = f.Close()
+		}
+		copies.Wait()
+		for _, f := range readEnds {
+			_ = f.Close()
+		}
+	}
+
+	for fd, stream := range streams.Extra {
+		if fd-3 >= len(files) {
+			files = append(files, make([]*os.File, fd-3-len(files)+1)...)
+		}
+
+		switch s := stream.(type) {
+		case *os.File:
+			files[fd-3] = s
+		case io.Writer:
+			// Output is copied until the process and its children close
+			// the write end
+			r, w, pipeErr := os.Pipe()
+			if pipeErr != nil {
+				finish()
+				return nil, nil, pipeErr
+			}
+			files[fd-3] = w
+			writeEnds, readEnds = append(writeEnds, w), append(readEnds, r)
+			copies.Add(1)
+			go func() {
+				defer copies.Done()
+				_, _ = io.Copy(s, r)
+			}()
+		}
+	}
+	return files, finish, nil
+}
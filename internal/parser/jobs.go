@@ -0,0 +1,369 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"gosh/internal/config"
+	"gosh/internal/jobs"
+)
+
+// BackgroundCommand starts a command as a background job, as written with
+// a trailing &. The job runs with its own copy of the shell configuration.
+type BackgroundCommand struct {
+	Command Command
+	// Text is the command as written, shown by the jobs built-in
+	Text string
+	Jobs *jobs.Manager
+}
+
+// Execute implements the Command interface for BackgroundCommand
+func (c *BackgroundCommand) Execute(ctx context.Context, cfg *config.Config) (int, error) {
+	clone := cfg.Clone()
+	job := c.Jobs.Background(withStateClone(ctx), c.Text, func(ctx context.Context) (int, error) {
+		return runSubshell(ctx, clone, c.Command)
+	})
+
+	// Interactive shells announce the job number and process
+	if c.Jobs.JobControl() {
+		<-job.Started()
+		_, err := fmt.Fprintf(IOFromContext(ctx).Stderr, "[%d] %d\n", job.ID, job.Pid())
+		return builtinStatus(err)
+	}
+	return 0, nil
+}
+
+// JobsCommand implements the jobs built-in command
+type JobsCommand struct {
+	Args []string
+	Jobs *jobs.Manager
+}
+
+// Execute implements the Command interface for JobsCommand
+func (c *JobsCommand) Execute(ctx context.Context, _ *config.Config) (int, error) {
+	out := IOFromContext(ctx).Stdout
+
+	var long, pids bool
+	var specs []string
+	for _, arg := range c.Args {
+		switch arg {
+		case "-l":
+			long = true
+		case "-p":
+			pids = true
+		default:
+			if strings.HasPrefix(arg, "-") {
+				return ExitFailure, fmt.Errorf("jobs: %s: invalid option", arg)
+			}
+			specs = append(specs, arg)
+		}
+	}
+
+	list := c.Jobs.Jobs()
+	if len(specs) > 0 {
+		list = nil
+		for _, spec := range specs {
+			job, err := c.Jobs.Find(spec)
+			if err != nil {
+				return ExitFailure, fmt.Errorf("jobs: %w", err)
+			}
+			list = append(list, job)
+		}
+	}
+
+	if pids {
+		for _, job := range list {
+			if _, err := fmt.Fprintln(out, job.Pgid()); err != nil {
+				return ExitFailure, err
+			}
+		}
+		return 0, nil
+	}
+	return builtinStatus(c.Jobs.List(out, list, long))
+}
+
+// FgCommand implements the fg built-in command
+type FgCommand struct {
+	Args []string
+	Jobs *jobs.Manager
+}
+
+// Execute implements the Command interface for FgCommand
+func (c *FgCommand) Execute(ctx context.Context, _ *config.Config) (int, error) {
+	if !c.Jobs.JobControl() {
+		return ExitFailure, fmt.Errorf("fg: %w", jobs.ErrNoJobControl)
+	}
+
+	job, err := c.Jobs.Find(firstArg(c.Args))
+	if err != nil {
+		return ExitFailure, fmt.Errorf("fg: %w", err)
+	}
+
+	if _, err := fmt.Fprintln(IOFromContext(ctx).Stdout, job.Command); err != nil {
+		return ExitFailure, err
+	}
+	status, err := c.Jobs.Resume(job, true)
+	if err != nil {
+		return status, fmt.Errorf("fg: %w", err)
+	}
+	return status, nil
+}
+
+// BgCommand implements the bg built-in command
+type BgCommand struct {
+	Args []string
+	Jobs *jobs.Manager
+}
+
+// Execute implements the Command interface for BgCommand
+func (c *BgCommand) Execute(ctx context.Context, _ *config.Config) (int, error) {
+	if !c.Jobs.JobControl() {
+		return ExitFailure, fmt.Errorf("bg: %w", jobs.ErrNoJobControl)
+	}
+
+	specs := c.Args
+	if len(specs) == 0 {
+		specs = []string{""}
+	}
+
+	for _, spec := range specs {
+		job, err := c.Jobs.Find(spec)
+		if err != nil {
+			return ExitFailure, fmt.Errorf("bg: %w", err)
+		}
+		if _, err := c.Jobs.Resume(job, false); err != nil {
+			return ExitFailure, fmt.Errorf("bg: %w", err)
+		}
+		if _, err := fmt.Fprintf(IOFromContext(ctx).Stdout, "[%d] %s &\n", job.ID, job.Command); err != nil {
+			return ExitFailure, err
+		}
+	}
+	return 0, nil
+}
+
+// WaitCommand implements the wait built-in command. Without arguments it
+// waits for all jobs; otherwise its result is that of the last job named.
+type WaitCommand struct {
+	Args []string
+	Jobs *jobs.Manager
+}
+
+// Execute implements the Command interface for WaitCommand
+func (c *WaitCommand) Execute(ctx context.Context, _ *config.Config) (int, error) {
+	if len(c.Args) == 0 {
+		return builtinStatus(c.Jobs.WaitAll(ctx))
+	}
+
+	var status int
+	var result error
+	for _, arg := range c.Args {
+		job, err := c.findJob(arg)
+		if err != nil {
+			reportError(ctx, err)
+			status, result = ExitCommandNotFound, nil
+			continue
+		}
+		status, result = c.Jobs.Wait(ctx, job)
+	}
+	return status, result
+}
+
+// findJob resolves a job spec or process ID given to wait
+func (c *WaitCommand) findJob(arg string) (*jobs.Job, error) {
+	if strings.HasPrefix(arg, "%") {
+		job, err := c.Jobs.Find(arg)
+		if err != nil {
+			return nil, fmt.Errorf("wait: %w", err)
+		}
+		return job, nil
+	}
+
+	pid, err := strconv.Atoi(arg)
+	if err != nil {
+		return nil, fmt.Errorf("wait: `%s': not a pid or valid job spec", arg)
+	}
+	job := c.Jobs.FindPid(pid)
+	if job == nil {
+		return nil, fmt.Errorf("wait: pid %d is not a child of this shell", pid)
+	}
+	return job, nil
+}
+
+// DisownCommand implements the disown built-in command
+type DisownCommand struct {
+	Args []string
+	Jobs *jobs.Manager
+}
+
+// Execute implements the Command interface for DisownCommand
+func (c *DisownCommand) Execute(_ context.Context, _ *config.Config) (int, error) {
+	if len(c.Args) == 1 && c.Args[0] == "-a" {
+		for _, job := range c.Jobs.Jobs() {
+			c.Jobs.Disown(job)
+		}
+		return 0, nil
+	}
+
+	specs := c.Args
+	if len(specs) == 0 {
+		specs = []string{""}
+	}
+
+	for _, spec := range specs {
+		job, err := c.Jobs.Find(spec)
+		if err != nil {
+			return ExitFailure, fmt.Errorf("disown: %w", err)
+		}
+		c.Jobs.Disown(job)
+	}
+	return 0, nil
+}
+
+// killUsage is the error kill gives without a process or job to signal
+const killUsage = "kill: usage: kill [-s sigspec | -n signum | -sigspec] pid | jobspec ... or kill -l [sigspec]"
+
+// KillCommand implements the kill built-in command. It sends a signal, TERM
+// by default, to processes and jobs, or with -l lists the signals.
+type KillCommand struct {
+	Args   []string
+	parser *Parser
+}
+
+// Execute implements the Command interface for KillCommand
+func (c *KillCommand) Execute(ctx context.Context, _ *config.Config) (int, error) {
+	args := c.Args
+	if len(args) > 0 && (args[0] == "-l" || args[0] == "-L") {
+		return killList(ctx, args[1:])
+	}
+
+	sig := os.Signal(syscall.SIGTERM)
+	if len(args) > 0 && strings.HasPrefix(args[0], "-") && args[0] != "--" {
+		spec := args[0][1:]
+		args = args[1:]
+		if spec == "s" || spec == "n" {
+			if len(args) == 0 {
+				return ExitUsage, fmt.Errorf("kill: -%s: option requires an argument", spec)
+			}
+			spec, args = args[0], args[1:]
+		}
+
+		var ok bool
+		if sig, ok = killSignal(spec); !ok {
+			return ExitFailure, fmt.Errorf("kill: %s: invalid signal specification", spec)
+		}
+	}
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		return ExitUsage, errors.New(killUsage)
+	}
+
+	status := 0
+	for _, arg := range args {
+		if err := c.send(sig, arg); err != nil {
+			reportError(ctx, fmt.Errorf("kill: %w", err))
+			status = ExitFailure
+		}
+	}
+	return status, nil
+}
+
+// send sends sig to the process or job arg names. A signal the shell sends
+// itself, as with kill $$, is handled before send returns, so that its trap
+// runs before the next command.
+func (c *KillCommand) send(sig os.Signal, arg string) error {
+	if strings.HasPrefix(arg, "%") {
+		job, err := c.parser.jobManager.Find(arg)
+		if err == nil {
+			err = job.Signal(sig)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", arg, err)
+		}
+		return nil
+	}
+
+	pid, err := strconv.Atoi(arg)
+	if err != nil {
+		return fmt.Errorf("%s: arguments must be process or job IDs", arg)
+	}
+	if pid == os.Getpid() && c.parser.receiveSignal != nil && c.parser.receiveSignal(sig) {
+		return nil
+	}
+	// $! names a whole background job, as its process would in other shells
+	if job := c.parser.jobManager.FindPid(pid); job != nil && job.Pid() == pid {
+		if err := job.Signal(sig); err == nil {
+			return nil
+		}
+	}
+
+	process, err := os.FindProcess(pid)
+	if err == nil {
+		err = process.Signal(sig)
+	}
+	if errors.Is(err, os.ErrProcessDone) {
+		return fmt.Errorf("(%d) - No such process", pid)
+	}
+	if err != nil {
+		return fmt.Errorf("(%d) - %w", pid, err)
+	}
+	return nil
+}
+
+// killSignal finds the signal kill is given by name or number, including
+// 0, which only checks that a process exists
+func killSignal(spec string) (os.Signal, bool) {
+	if spec == "0" {
+		return syscall.Signal(0), true
+	}
+	return LookupSignal(spec)
+}
+
+// killList lists the signals, as kill -l does, or gives the name of each
+// signal number and the number of each signal name. An exit status of
+// 128+N names signal N.
+func killList(ctx context.Context, specs []string) (int, error) {
+	out := IOFromContext(ctx).Stdout
+	if len(specs) == 0 {
+		return builtinStatus(listSignals(out))
+	}
+
+	status := 0
+	for _, spec := range specs {
+		var line string
+		if n, err := strconv.Atoi(spec); err == nil {
+			if n > ExitSignalBase {
+				n -= ExitSignalBase
+			}
+			if sig, ok := LookupSignal(strconv.Itoa(n)); ok {
+				line = SignalName(sig)
+			}
+		} else if sig, ok := LookupSignal(spec); ok {
+			line = strconv.Itoa(int(sig.(syscall.Signal)))
+		}
+
+		if line == "" {
+			reportError(ctx, fmt.Errorf("kill: %s: invalid signal specification", spec))
+			status = ExitFailure
+			continue
+		}
+		if _, err := fmt.Fprintln(out, line); err != nil {
+			return ExitFailure, err
+		}
+	}
+	return status, nil
+}
+
+// firstArg returns the first argument, or "" if there is none
+func firstArg(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	return args[0]
+}
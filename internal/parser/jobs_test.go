@@ -0,0 +1,198 @@
+package parser
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gosh/internal/config"
+)
+
+func TestParseBackground(t *testing.T) {
+	parser := New(config.Default())
+
+	cmd, err := parser.Parse("sleep 1 | cat &  echo next & ")
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+
+	seq, ok := cmd.(*SequenceCommand)
+	if !ok || len(seq.Commands) != 2 {
+		t.Fatalf("got %#v, want a sequence of 2 commands", cmd)
+	}
+
+	want := []string{"sleep 1 | cat", "echo next"}
+	for i, text := range want {
+		bg, ok := seq.Commands[i].(*BackgroundCommand)
+		if !ok {
+			t.Fatalf("command %d = %T, want *BackgroundCommand", i, seq.Commands[i])
+		}
+		if bg.Text != text {
+			t.Errorf("command %d text = %q, want %q", i, bg.Text, text)
+		}
+	}
+
+	if _, err := parser.Parse("& ls"); err == nil {
+		t.Error("Parse(\"& ls\") succeeded, want a syntax error")
+	}
+}
+
+func TestJobsExecute(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current directory: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		input      string
+		wantOutput string
+		wantStatus int
+		wantErr    string
+	}{
+		{
+			name:       "wait for all jobs",
+			input:      "echo one > /dev/null & wait; echo done",
+			wantOutput: "done\n",
+		},
+		{
+			name:       "wait returns the job's status",
+			input:      "false & wait %1",
+			wantStatus: 1,
+		},
+		{
+			name:       "jobs lists running jobs",
+			input:      "sleep 0.2 & sleep 0.3 & jobs; wait",
+			wantOutput: "[1]-  Running                 sleep 0.2 &\n[2]+  Running                 sleep 0.3 &\n",
+		},
+		{
+			name:       "disowned jobs leave the table",
+			input:      "pwd > /dev/null & disown; jobs; wait %1",
+			wantOutput: "",
+			wantStatus: 127,
+			wantErr:    "%1: no such job",
+		},
+		{
+			name:       "wait for an unknown job",
+			input:      "wait %3",
+			wantStatus: 127,
+			wantErr:    "%3: no such job",
+		},
+		{
+			name:       "a job without a process can be waited for by $!",
+			input:      "{ exit 5; } & wait $!",
+			wantStatus: 5,
+		},
+		{
+			name:       "cd in a job leaves the shell where it is",
+			input:      "{ cd /; sleep 0.2; pwd; } & sleep 0.1; pwd; wait",
+			wantOutput: wd + "\n/\n",
+		},
+		{
+			name:       "kill a job",
+			input:      "sleep 5 & kill %1; wait %1",
+			wantStatus: 143,
+		},
+		{
+			name:       "kill $! ends the whole job",
+			input:      "{ sleep 5; echo no; } & sleep 0.1; kill -s INT $!; wait $!",
+			wantStatus: 130,
+		},
+		{
+			name:  "kill -0 checks that a process exists",
+			input: "kill -0 $$",
+		},
+		{
+			name:       "kill -l names signals and numbers",
+			input:      "kill -l 143 TERM 9",
+			wantOutput: "TERM\n15\nKILL\n",
+		},
+		{
+			name:       "kill without a process",
+			input:      "kill -9",
+			wantStatus: 2,
+			wantErr:    "kill: usage:",
+		},
+		{
+			name:       "kill with an invalid signal",
+			input:      "kill -NOPE $$",
+			wantStatus: 1,
+			wantErr:    "kill: NOPE: invalid signal specification",
+		},
+		{
+			name:       "kill a name",
+			input:      "kill abc",
+			wantStatus: 1,
+			wantErr:    "kill: abc: arguments must be process or job IDs",
+		},
+		{
+			name:       "fg needs job control",
+			input:      "true & fg",
+			wantStatus: 1,
+			wantErr:    "fg: no job control",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			parser := New(cfg)
+
+			cmd, err := parser.Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse() failed: %v", err)
+			}
+
+			// Jobs write to files: processes running concurrently cannot
+			// share an in-memory buffer
+			stdout := tempFile(t, "stdout")
+			stderr := tempFile(t, "stderr")
+			ctx := WithIO(context.Background(), IO{Stdin: tempFile(t, "stdin"), Stdout: stdout, Stderr: stderr})
+			status, err := cmd.Execute(ctx, cfg)
+			if waitErr := parser.jobManager.WaitAll(ctx); waitErr != nil {
+				t.Fatalf("WaitAll() failed: %v", waitErr)
+			}
+
+			output := readFile(t, stdout)
+			message := readFile(t, stderr)
+			if err != nil {
+				message = err.Error() + message
+			}
+			if status != tt.wantStatus {
+				t.Errorf("Execute() status = %d, want %d", status, tt.wantStatus)
+			}
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("Execute() error = %v", err)
+			}
+			if tt.wantErr != "" && !strings.Contains(message, tt.wantErr) {
+				t.Errorf("Execute() error = %q, want %q", message, tt.wantErr)
+			}
+			if output != tt.wantOutput {
+				t.Errorf("output = %q, want %q", output, tt.wantOutput)
+			}
+		})
+	}
+}
+
+// tempFile creates a file in the test's temporary directory
+func tempFile(t *testing.T, name string) *os.File {
+	t.Helper()
+	f, err := os.Create(filepath.Join(t.TempDir(), name))
+	if err != nil {
+		t.Fatalf("Failed to create %s: %v", name, err)
+	}
+	t.Cleanup(func() { _ = f.Close() })
+	return f
+}
+
+// readFile returns the contents written to f so far
+func readFile(t *testing.T, f *os.File) string {
+	t.Helper()
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", f.Name(), err)
+	}
+	return string(data)
+}
@@ -0,0 +1,263 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"gosh/internal/config"
+)
+
+// AndOrOp is the operator joining the two sides of an AndOrCommand
+type AndOrOp int
+
+const (
+	// AndIf runs the right side only if the left side succeeded (&&)
+	AndIf AndOrOp = iota
+	// OrIf runs the right side only if the left side failed (||)
+	OrIf
+)
+
+// SimpleCommand is a command name with its arguments and redirections.
+// Its words are expanded when it runs, so that it sees variables set by
+// the commands before it.
+type SimpleCommand struct {
+	// Assigns are the NAME=value words before the command name. Without a
+	// command name they set shell variables; otherwise they only apply to
+	// the command.
+	Assigns   []string
+	Words     []string
+	Redirects []Redirect
+	parser    *Parser
+}
+
+// Execute implements the Command interface for SimpleCommand
+func (c *SimpleCommand) Execute(ctx context.Context, cfg *config.Config) (int, error) {
+	if err := runDebugTrap(ctx, cfg, c.String()); err != nil {
+		return ExitFailure, err
+	}
+
+	status, err := c.run(ctx, cfg)
+	reportError(ctx, setPipeStatus(cfg, status))
+	if !isControlFlow(err) {
+		// Signals that arrived while the command ran are handled now
+		if trapErr := runPendingTraps(ctx, cfg); trapErr != nil {
+			reportError(ctx, err)
+			return status, trapErr
+		}
+	}
+	return checkErrexit(ctx, cfg, status, err)
+}
+
+// String returns the command as written, without its redirections
+func (c *SimpleCommand) String() string {
+	return strings.Join(append(slices.Clone(c.Assigns), c.Words...), " ")
+}
+
+// run expands the words of the command and runs it
+func (c *SimpleCommand) run(ctx context.Context, cfg *config.Config) (int, error) {
+	p := c.parser.withConfig(cfg)
+
+	words, err := p.expandCommandWords(ctx, c.Words)
+	if err != nil {
+		return ExitFailure, err
+	}
+
+	if cfg.Xtrace && len(words)+len(c.Assigns) > 0 {
+		p.trace(ctx, c.Assigns, words)
+	}
+
+	// Assignments before a command apply while it runs, and to finding it,
+	// as PATH=dir cmd does
+	if len(words) > 0 && len(c.Assigns) > 0 {
+		restore, err := p.assignTemporary(ctx, c.Assigns)
+		if err != nil {
+			return ExitFailure, err
+		}
+		defer restore()
+	}
+
+	var cmd Command
+	if len(words) == 0 && len(c.Assigns) > 0 {
+		cmd = &AssignCommand{Assigns: c.Assigns, parser: p}
+	} else if len(words) == 0 {
+		// A bare redirection such as "> file" only opens its files
+		cmd = &NoOpCommand{}
+	} else if fn, ok := StateFromContext(ctx).Functions[words[0]]; ok {
+		// Functions take precedence over built-ins and programs
+		cmd = &FunctionCall{Function: fn, Args: words[1:]}
+	} else if builtin := p.parseBuiltin(words); builtin != nil {
+		// Check for built-in commands
+		cmd = builtin
+	} else {
+		// Parse as external command
+		external, err := p.parseExternal(ctx, words)
+		if err != nil {
+			return ExitFailure, err
+		}
+		cmd = external
+	}
+
+	if len(c.Redirects) > 0 {
+		cmd = &RedirectedCommand{Command: cmd, Redirects: c.Redirects, parser: c.parser}
+	}
+	return cmd.Execute(ctx, cfg)
+}
+
+// declarationBuiltins are the built-ins that take NAME=value arguments as
+// assignments
+var declarationBuiltins = map[string]bool{
+	"declare":  true,
+	"export":   true,
+	"local":    true,
+	"readonly": true,
+}
+
+// expandCommandWords expands the words of a simple command. The arguments
+// of a declaration built-in that are written as assignments are left for
+// it to expand as assignments, so that their values are not split and
+// arr=(a b) makes an array.
+func (p *Parser) expandCommandWords(ctx context.Context, words []string) ([]string, error) {
+	if len(words) == 0 || !declarationBuiltins[words[0]] {
+		return p.expandWords(ctx, words)
+	}
+	if _, ok := StateFromContext(ctx).Functions[words[0]]; ok {
+		return p.expandWords(ctx, words)
+	}
+
+	fields := []string{words[0]}
+	for _, word := range words[1:] {
+		if assignmentValue(word) > 0 {
+			fields = append(fields, word)
+			continue
+		}
+		expanded, err := p.expandWord(ctx, word)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, expanded...)
+	}
+	return fields, nil
+}
+
+// SequenceCommand runs commands one after another, as separated by ; or
+// newlines. Its result is that of the last command.
+type SequenceCommand struct {
+	Commands []Command
+}
+
+// Execute implements the Command interface for SequenceCommand
+func (c *SequenceCommand) Execute(ctx context.Context, cfg *config.Config) (int, error) {
+	var status int
+	var err error
+	for i, cmd := range c.Commands {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ExitFailure, ctxErr
+		}
+
+		status, err = cmd.Execute(ctx, cfg)
+		if isControlFlow(err) {
+			return status, err
+		}
+		setStatus(ctx, status)
+		if i < len(c.Commands)-1 {
+			reportError(ctx, err)
+		}
+	}
+	return status, err
+}
+
+// AndOrCommand runs its right side depending on the exit status of its left
+// side. A skipped right side leaves the left side's result in place.
+type AndOrCommand struct {
+	Op    AndOrOp
+	Left  Command
+	Right Command
+}
+
+// Execute implements the Command interface for AndOrCommand
+func (c *AndOrCommand) Execute(ctx context.Context, cfg *config.Config) (int, error) {
+	// Only the command after the last && or || can end the shell under
+	// set -e
+	status, err := c.Left.Execute(withoutErrexit(ctx), cfg)
+	if isControlFlow(err) {
+		return status, err
+	}
+	setStatus(ctx, status)
+	succeeded := status == 0
+
+	if (c.Op == AndIf && !succeeded) || (c.Op == OrIf && succeeded) {
+		return status, err
+	}
+
+	reportError(ctx, err)
+	return c.Right.Execute(ctx, cfg)
+}
+
+// NotCommand inverts the exit status of a pipeline, as written with !
+type NotCommand struct {
+	Command Command
+}
+
+// Execute implements the Command interface for NotCommand
+func (c *NotCommand) Execute(ctx context.Context, cfg *config.Config) (int, error) {
+	status, err := c.Command.Execute(withoutErrexit(ctx), cfg)
+	if isControlFlow(err) {
+		return status, err
+	}
+
+	reportError(ctx, err)
+	if status == 0 {
+		return ExitFailure, nil
+	}
+	return 0, nil
+}
+
+// SubshellCommand runs a command list in an isolated copy of the shell
+// state: variable, alias, parameter and directory changes made inside
+// ( ... ) do not affect the shell that runs it.
+type SubshellCommand struct {
+	Body Command
+}
+
+// Execute implements the Command interface for SubshellCommand
+func (c *SubshellCommand) Execute(ctx context.Context, cfg *config.Config) (int, error) {
+	status, err := c.run(ctx, cfg)
+	return checkErrexit(ctx, cfg, status, err)
+}
+
+// run runs the body of the subshell. A set -e failure inside ends only the
+// subshell, which takes its status, and then its EXIT trap runs. Its cd
+// changes only the directory of its copy of the state.
+func (c *SubshellCommand) run(ctx context.Context, cfg *config.Config) (int, error) {
+	return runSubshell(withStateClone(ctx), cfg.Clone(), c.Body)
+}
+
+// BraceGroupCommand runs a command list in the current shell, as written
+// with { ...; }. It exists so that the list can be redirected or piped as
+// a unit.
+type BraceGroupCommand struct {
+	Body Command
+}
+
+// Execute implements the Command interface for BraceGroupCommand
+func (c *BraceGroupCommand) Execute(ctx context.Context, cfg *config.Config) (int, error) {
+	return c.Body.Execute(ctx, cfg)
+}
+
+// reportError prints the error of a command whose result is not returned to
+// the caller, such as one in the middle of a list. In a script the message
+// starts with the file and line of the command.
+func reportError(ctx context.Context, err error) {
+	if err == nil || stopOnBrokenPipe(ctx, err) {
+		return
+	}
+
+	stderr := IOFromContext(ctx).Stderr
+	if src, ok := SourceFromContext(ctx); ok {
+		_, _ = fmt.Fprintf(stderr, "gosh: %s:%d: %v\n", src.Name, src.Line, err)
+		return
+	}
+	_, _ = fmt.Fprintf(stderr, "gosh: %v\n", err)
+}
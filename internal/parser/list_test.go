@@ -0,0 +1,258 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"gosh/internal/config"
+)
+
+func TestParseLists(t *testing.T) {
+	parser := New(config.Default())
+
+	tests := []struct {
+		name  string
+		input string
+		check func(t *testing.T, cmd Command)
+	}{
+		{
+			name:  "sequence",
+			input: "cd /tmp; ls\npwd",
+			check: func(t *testing.T, cmd Command) {
+				seq, ok := cmd.(*SequenceCommand)
+				if !ok || len(seq.Commands) != 3 {
+					t.Errorf("got %#v, want a sequence of 3 commands", cmd)
+				}
+			},
+		},
+		{
+			name:  "trailing separator",
+			input: "ls;",
+			check: func(t *testing.T, cmd Command) {
+				if _, ok := cmd.(*SimpleCommand); !ok {
+					t.Errorf("got %T, want *SimpleCommand", cmd)
+				}
+			},
+		},
+		{
+			name:  "and-or groups to the left",
+			input: "make && ./bin/gosh || echo failed",
+			check: func(t *testing.T, cmd Command) {
+				or, ok := cmd.(*AndOrCommand)
+				if !ok || or.Op != OrIf {
+					t.Fatalf("got %#v, want || at the top", cmd)
+				}
+				if and, ok := or.Left.(*AndOrCommand); !ok || and.Op != AndIf {
+					t.Errorf("left side = %#v, want &&", or.Left)
+				}
+			},
+		},
+		{
+			name:  "pipeline binds tighter than &&",
+			input: "ps aux | grep go && echo found",
+			check: func(t *testing.T, cmd Command) {
+				and, ok := cmd.(*AndOrCommand)
+				if !ok {
+					t.Fatalf("got %T, want *AndOrCommand", cmd)
+				}
+				if _, ok := and.Left.(*PipelineCommand); !ok {
+					t.Errorf("left side = %T, want *PipelineCommand", and.Left)
+				}
+			},
+		},
+		{
+			name:  "subshell",
+			input: "(cd /tmp; ls)",
+			check: func(t *testing.T, cmd Command) {
+				sub, ok := cmd.(*SubshellCommand)
+				if !ok {
+					t.Fatalf("got %T, want *SubshellCommand", cmd)
+				}
+				if _, ok := sub.Body.(*SequenceCommand); !ok {
+					t.Errorf("body = %T, want *SequenceCommand", sub.Body)
+				}
+			},
+		},
+		{
+			name:  "redirected brace group",
+			input: "{ echo a; echo b; } > out.txt",
+			check: func(t *testing.T, cmd Command) {
+				redirected, ok := cmd.(*RedirectedCommand)
+				if !ok {
+					t.Fatalf("got %T, want *RedirectedCommand", cmd)
+				}
+				if _, ok := redirected.Command.(*BraceGroupCommand); !ok {
+					t.Errorf("command = %T, want *BraceGroupCommand", redirected.Command)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, err := parser.Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse() failed: %v", err)
+			}
+			tt.check(t, cmd)
+		})
+	}
+}
+
+func TestParseListErrors(t *testing.T) {
+	parser := New(config.Default())
+
+	incomplete := []string{"make &&", "false ||\n", "(echo a", "{ echo a;", "{ echo }", "ls |"}
+	for _, input := range incomplete {
+		if _, err := parser.Parse(input); !errors.Is(err, ErrIncomplete) {
+			t.Errorf("Parse(%q) error = %v, want ErrIncomplete", input, err)
+		}
+	}
+
+	invalid := []string{"; ls", "ls ;; pwd", "( )", "ls )", "&& ls", "echo (a)"}
+	for _, input := range invalid {
+		_, err := parser.Parse(input)
+		if err == nil || errors.Is(err, ErrIncomplete) {
+			t.Errorf("Parse(%q) error = %v, want a syntax error", input, err)
+		}
+	}
+}
+
+func TestListExecute(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		wantOutput string
+		wantStatus int
+	}{
+		{
+			name:       "variables set earlier in the list are expanded",
+			input:      "export LIST_TEST=one; echo $LIST_TEST",
+			wantOutput: "one\n",
+		},
+		{
+			name:       "and runs on success",
+			input:      "true && echo yes",
+			wantOutput: "yes\n",
+		},
+		{
+			name:       "and skips on failure",
+			input:      "false && echo no",
+			wantOutput: "",
+			wantStatus: 1,
+		},
+		{
+			name:       "or runs on failure",
+			input:      "false && echo no || echo fallback",
+			wantOutput: "fallback\n",
+		},
+		{
+			name:       "or skips on success",
+			input:      "true || echo no",
+			wantOutput: "",
+		},
+		{
+			name:       "sequence continues after failure",
+			input:      "false; echo next",
+			wantOutput: "next\n",
+		},
+		{
+			name:       "brace group output is piped as a unit",
+			input:      "{ echo a; echo b; } | tr a-z A-Z",
+			wantOutput: "A\nB\n",
+		},
+		{
+			name:       "multi-line group",
+			input:      "{\n  echo one\n  echo two\n}",
+			wantOutput: "one\ntwo\n",
+		},
+		{
+			name:       "subshell variables do not leak",
+			input:      "(export LIST_SUB=inner; echo $LIST_SUB); echo \"[$LIST_SUB]\"",
+			wantOutput: "inner\n[]\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			parser := New(cfg)
+
+			cmd, err := parser.Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse() failed: %v", err)
+			}
+
+			var stdout bytes.Buffer
+			ctx := WithIO(context.Background(), IO{Stdin: strings.NewReader(""), Stdout: &stdout})
+			status, err := cmd.Execute(ctx, cfg)
+			if err != nil {
+				t.Errorf("Execute() failed: %v", err)
+			}
+			if status != tt.wantStatus {
+				t.Errorf("Execute() status = %d, want %d", status, tt.wantStatus)
+			}
+			if stdout.String() != tt.wantOutput {
+				t.Errorf("output = %q, want %q", stdout.String(), tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestSubshellIsolatesDirectory(t *testing.T) {
+	originalDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current directory: %v", err)
+	}
+	defer os.Chdir(originalDir)
+
+	cfg := config.Default()
+	cmd, err := New(cfg).Parse("(cd " + t.TempDir() + ")")
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+	if _, err := cmd.Execute(context.Background(), cfg); err != nil {
+		t.Fatalf("Execute() failed: %v", err)
+	}
+
+	currentDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current directory: %v", err)
+	}
+	if currentDir != originalDir {
+		t.Errorf("subshell changed directory to %s", currentDir)
+	}
+}
+
+func TestSubshellUsesItsOwnDirectory(t *testing.T) {
+	originalDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current directory: %v", err)
+	}
+	dir := t.TempDir()
+
+	// Files, programs and pwd inside the subshell all follow its cd, while
+	// the process stays where it was
+	cfg := config.Default()
+	cmd, err := New(cfg).Parse("(cd " + dir + "; echo hi > out; pwd; cat out; [ -f out ] && echo found); pwd")
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+	var stdout bytes.Buffer
+	ctx := WithIO(WithState(context.Background(), NewState(DefaultShellName, nil)), IO{Stdout: &stdout})
+	if _, err := cmd.Execute(ctx, cfg); err != nil {
+		t.Fatalf("Execute() failed: %v", err)
+	}
+
+	want := dir + "\nhi\nfound\n" + originalDir + "\n"
+	if stdout.String() != want {
+		t.Errorf("output = %q, want %q", stdout.String(), want)
+	}
+	if currentDir, _ := os.Getwd(); currentDir != originalDir {
+		t.Errorf("subshell changed the process directory to %s", currentDir)
+	}
+}
@@ -0,0 +1,236 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"gosh/internal/config"
+)
+
+// DefaultPS4 is the prefix of the lines printed by xtrace when PS4 is unset
+const DefaultPS4 = "+ "
+
+// ExitError ends the shell with Status, as exit does, or set -e after a
+// command fails. Like a return it passes through the commands that enclose it, but
+// a subshell, pipeline stage or background job ends with its status rather
+// than the shell that started it.
+type ExitError struct {
+	Status int
+}
+
+// Error implements the error interface for ExitError
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit %d", e.Status)
+}
+
+// exitStatus turns an ExitError that reached the end of a subshell into
+// the status of the subshell. Other results are returned as they are.
+func exitStatus(status int, err error) (int, error) {
+	var exit *ExitError
+	if errors.As(err, &exit) {
+		return exit.Status, nil
+	}
+	return status, err
+}
+
+// errexitContextKey is the context key that marks commands whose failure
+// does not end the shell under set -e
+type errexitContextKey struct{}
+
+// withoutErrexit returns a context for running a command whose failure is
+// tested, such as the condition of an if or the left side of &&, which set
+// -e must not end the shell for
+func withoutErrexit(ctx context.Context) context.Context {
+	return context.WithValue(ctx, errexitContextKey{}, true)
+}
+
+// checkErrexit handles a command that failed where its status is not
+// tested: it runs the ERR trap, then ends the shell if set -e is on by
+// returning an ExitError for the status. The error of the command is
+// reported first.
+func checkErrexit(ctx context.Context, cfg *config.Config, status int, err error) (int, error) {
+	if status == 0 || isControlFlow(err) || ctx.Err() != nil {
+		return status, err
+	}
+	if ignored, _ := ctx.Value(errexitContextKey{}).(bool); ignored {
+		return status, err
+	}
+
+	if StateFromContext(ctx).Traps.ready(TrapErr) {
+		reportError(ctx, err)
+		err = nil
+		setStatus(ctx, status)
+		if trapErr := runTrap(ctx, cfg, TrapErr); trapErr != nil {
+			return status, trapErr
+		}
+	}
+
+	if !cfg.Errexit {
+		return status, err
+	}
+	reportError(ctx, err)
+	return status, &ExitError{Status: status}
+}
+
+// unboundError reports the use of an unset parameter under set -u. A shell
+// that is not interactive exits.
+func unboundError(ctx context.Context, name string) error {
+	return expansionError(ctx, fmt.Errorf("%s: unbound variable", name))
+}
+
+// expansionError returns an expansion error that ends a shell that is not
+// interactive, such as ${VAR:?message} with VAR unset or $((1/0)). It is
+// reported first, and the shell exits with status 1.
+func expansionError(ctx context.Context, err error) error {
+	if StateFromContext(ctx).Interactive || isControlFlow(err) {
+		return err
+	}
+	reportError(ctx, err)
+	return &ExitError{Status: ExitFailure}
+}
+
+// trace prints a command about to run under set -x, after the expanded
+// value of PS4. Its assignments are printed as written and its words
+// quoted where needed.
+func (p *Parser) trace(ctx context.Context, assigns, words []string) {
+	prefix, ok := p.lookupVariable("PS4")
+	if !ok {
+		prefix = DefaultPS4
+	} else if expanded, err := p.expandString(ctx, prefix); err == nil {
+		prefix = expanded
+	}
+
+	fields := append([]string(nil), assigns...)
+	for _, word := range words {
+		fields = append(fields, quoteIfNeeded(word))
+	}
+	_, _ = fmt.Fprintf(IOFromContext(ctx).Stderr, "%s%s\n", prefix, strings.Join(fields, " "))
+}
+
+// printOptions prints the options of a kind and whether they are on. With
+// reusable set each is printed as the command that restores it.
+func printOptions(out io.Writer, cfg *config.Config, kind config.OptionKind, names []string, reusable bool) error {
+	if len(names) == 0 {
+		for _, opt := range config.Options {
+			if opt.Kind == kind {
+				names = append(names, opt.Name)
+			}
+		}
+	}
+
+	for _, name := range names {
+		on := cfg.OptionEnabled(name)
+		var err error
+		switch {
+		case reusable && kind == config.OptionSet:
+			_, err = fmt.Fprintf(out, "set %co %s\n", "+-"[boolIndex(on)], name)
+		case reusable:
+			_, err = fmt.Fprintf(out, "shopt -%c %s\n", "us"[boolIndex(on)], name)
+		default:
+			_, err = fmt.Fprintf(out, "%-15s\t%s\n", name, map[bool]string{true: "on", false: "off"}[on])
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// boolIndex returns 1 for true and 0 for false
+func boolIndex(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// ShoptCommand implements the shopt built-in command, which sets (-s),
+// unsets (-u) and prints the options of shopt, or with -o those of set -o.
+// With -q it prints nothing, and its status tells whether the options are
+// all on.
+type ShoptCommand struct {
+	Args []string
+}
+
+// Execute implements the Command interface for ShoptCommand
+func (c *ShoptCommand) Execute(ctx context.Context, cfg *config.Config) (int, error) {
+	var set, unset, quiet, reusable bool
+	kind := config.OptionShopt
+	args := c.Args
+	for len(args) > 0 && len(args[0]) > 1 && args[0][0] == '-' {
+		if args[0] == "--" {
+			args = args[1:]
+			break
+		}
+		for _, flag := range args[0][1:] {
+			switch flag {
+			case 's':
+				set = true
+			case 'u':
+				unset = true
+			case 'q':
+				quiet = true
+			case 'p':
+				reusable = true
+			case 'o':
+				kind = config.OptionSet
+			default:
+				return ExitUsage, fmt.Errorf("shopt: -%c: invalid option", flag)
+			}
+		}
+		args = args[1:]
+	}
+	if set && unset {
+		return ExitFailure, errors.New("shopt: cannot set and unset shell options simultaneously")
+	}
+
+	for _, name := range args {
+		if _, ok := config.LookupOption(kind, name); !ok {
+			return ExitFailure, fmt.Errorf("shopt: %s: invalid shell option name", name)
+		}
+	}
+
+	if set || unset {
+		if len(args) == 0 {
+			// Without names, -s and -u list the options that are on or off
+			return c.list(ctx, cfg, kind, set)
+		}
+		for _, name := range args {
+			if err := cfg.SetOption(name, set); err != nil {
+				return ExitFailure, fmt.Errorf("shopt: %w", err)
+			}
+		}
+		return 0, nil
+	}
+
+	status := 0
+	for _, name := range args {
+		if !cfg.OptionEnabled(name) {
+			status = ExitFailure
+		}
+	}
+	if quiet {
+		return status, nil
+	}
+	if err := printOptions(IOFromContext(ctx).Stdout, cfg, kind, args, reusable); err != nil {
+		return ExitFailure, err
+	}
+	return status, nil
+}
+
+// list prints the options of a kind that are on, or that are off
+func (c *ShoptCommand) list(ctx context.Context, cfg *config.Config, kind config.OptionKind, on bool) (int, error) {
+	var names []string
+	for _, opt := range config.Options {
+		if opt.Kind == kind && cfg.OptionEnabled(opt.Name) == on {
+			names = append(names, opt.Name)
+		}
+	}
+	if len(names) == 0 {
+		return 0, nil
+	}
+	return builtinStatus(printOptions(IOFromContext(ctx).Stdout, cfg, kind, names, false))
+}
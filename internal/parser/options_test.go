@@ -0,0 +1,240 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gosh/internal/config"
+)
+
+func TestShellOptions(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		interactive bool
+		wantOutput  string
+		wantStderr  string
+		wantStatus  int
+	}{
+		{
+			name:       "errexit stops at a failing command",
+			input:      "set -e; echo a; false; echo b",
+			wantOutput: "a\n",
+			wantStatus: 1,
+		},
+		{
+			name:       "errexit ignores tested commands",
+			input:      "set -e; if false; then :; fi; while false; do :; done; false || echo or; ! true; false && true; echo end",
+			wantOutput: "or\nend\n",
+		},
+		{
+			name:       "errexit applies after the last && or ||",
+			input:      "set -e; true && false; echo no",
+			wantStatus: 1,
+		},
+		{
+			name:       "errexit applies to a function's status",
+			input:      "set -e; f() { return 3; }; f; echo no",
+			wantStatus: 3,
+		},
+		{
+			name:       "errexit ignores commands in a tested function",
+			input:      "set -e; f() { false; echo in f; }; f && echo tested",
+			wantOutput: "in f\ntested\n",
+		},
+		{
+			name:       "errexit ends a subshell, then the shell",
+			input:      "set -e; (false; echo in); echo out",
+			wantStatus: 1,
+		},
+		{
+			name:       "errexit checks the status of a pipeline",
+			input:      "set -e; false | true; echo piped; set -o pipefail; false | true; echo no",
+			wantOutput: "piped\n",
+			wantStatus: 1,
+		},
+		{
+			name:       "errexit checks an assignment from a substitution",
+			input:      "set -e; x=$(false; echo sub); echo $x; y=$(false); echo no",
+			wantOutput: "sub\n",
+			wantStatus: 1,
+		},
+		{
+			name:       "errexit applies to [[ and ((",
+			input:      "set -e; (( 1 )); [[ a == a ]]; (( 0 )); echo no",
+			wantStatus: 1,
+		},
+		{
+			name:       "errexit reports the error of the command",
+			input:      "set -e; cd /nonexistent; echo no",
+			wantStderr: "cd:",
+			wantStatus: 1,
+		},
+		{
+			name:       "nounset exits a script",
+			input:      "set -u; echo ${x-default} $#; echo $x; echo no",
+			wantOutput: "default 0\n",
+			wantStderr: "x: unbound variable",
+			wantStatus: 1,
+		},
+		{
+			name:        "nounset fails only the command when interactive",
+			input:       "set -u; echo $x; echo yes",
+			interactive: true,
+			wantOutput:  "yes\n",
+			wantStderr:  "x: unbound variable",
+		},
+		{
+			name:       "a failed ${x:?} exits a script",
+			input:      "echo a; : ${x:?not set}; echo no",
+			wantOutput: "a\n",
+			wantStderr: "x: not set",
+			wantStatus: 1,
+		},
+		{
+			name:        "a failed ${x:?} fails only the command when interactive",
+			input:       "echo ${x:?}; echo yes",
+			interactive: true,
+			wantOutput:  "yes\n",
+			wantStderr:  "x: parameter null or not set",
+		},
+		{
+			name:       "an arithmetic error in an expansion exits a script",
+			input:      "(( 1/0 )); echo $?; echo $((1/0)); echo no",
+			wantOutput: "1\n",
+			wantStderr: "1/0: division by 0",
+			wantStatus: 1,
+		},
+		{
+			name:       "colon expands its arguments and succeeds",
+			input:      ": ${x:=default}; echo $x; while :; do break; done && echo done",
+			wantOutput: "default\ndone\n",
+		},
+		{
+			name:       "nounset allows an empty $@",
+			input:      `set -u; echo "$@" ${#x[@]}`,
+			wantOutput: "0\n",
+		},
+		{
+			name:       "xtrace prints commands after PS4",
+			input:      `set -x; echo "a b" c; PS4='> '; x=1`,
+			wantOutput: "a b c\n",
+			wantStderr: "+ echo 'a b' c\n+ PS4='> '\n> x=1\n",
+		},
+		{
+			name:       "noglob keeps patterns",
+			input:      "set -f; echo *.txt; set +f; echo *.txt",
+			wantOutput: "*.txt\na.txt\n",
+		},
+		{
+			name:       "dollar dash shows the option letters",
+			input:      "set -eux; echo $-; set +eux; echo \"[$-]\"",
+			wantOutput: "eux\n[]\n",
+			wantStderr: "+ echo eux",
+		},
+		{
+			name:        "dollar dash is i when interactive",
+			input:       "echo $-",
+			interactive: true,
+			wantOutput:  "i\n",
+		},
+		{
+			name:       "set -o prints the options",
+			input:      "set -o pipefail; set -o | grep -e errexit -e pipefail",
+			wantOutput: "errexit        \toff\npipefail       \ton\n",
+		},
+		{
+			name:       "set +o prints commands",
+			input:      "set -u; set +o | grep nounset",
+			wantOutput: "set -o nounset\n",
+		},
+		{
+			name:       "options and positional parameters",
+			input:      "set -- a b; set -e; echo $#; set -e c; echo $# $1",
+			wantOutput: "2\n1 c\n",
+		},
+		{
+			name:       "invalid set option",
+			input:      "set -q",
+			wantStderr: "set: -q: invalid option",
+			wantStatus: 2,
+		},
+		{
+			name:       "shopt sets and prints options",
+			input:      "shopt -s nullglob dotglob; shopt nullglob globstar; shopt -p dotglob",
+			wantOutput: "nullglob       \ton\nglobstar       \toff\nshopt -s dotglob\n",
+			wantStatus: 0,
+		},
+		{
+			name:       "shopt -q reports the status",
+			input:      "shopt -q globstar || echo off; shopt -s globstar; shopt -q globstar && echo on",
+			wantOutput: "off\non\n",
+		},
+		{
+			name:       "shopt -s lists the options that are on",
+			input:      "shopt -s failglob; shopt -s",
+			wantOutput: "failglob       \ton\n",
+		},
+		{
+			name:       "shopt -o manages set options",
+			input:      "shopt -so noglob; echo $-; shopt -o noglob",
+			wantOutput: "f\nnoglob         \ton\n",
+		},
+		{
+			name:       "shopt with an unknown option",
+			input:      "shopt -s nosuchoption",
+			wantStderr: "nosuchoption: invalid shell option name",
+			wantStatus: 1,
+		},
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	originalDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = os.Chdir(originalDir)
+	})
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			parser := New(cfg)
+
+			cmd, err := parser.Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse() failed: %v", err)
+			}
+
+			var stdout, stderr bytes.Buffer
+			state := NewState(DefaultShellName, nil)
+			state.Interactive = tt.interactive
+			ctx := WithIO(context.Background(), IO{Stdin: strings.NewReader(""), Stdout: &stdout, Stderr: &stderr})
+			ctx = WithState(ctx, state)
+			status, err := exitStatus(cmd.Execute(ctx, cfg))
+			if status != tt.wantStatus {
+				t.Errorf("Execute() status = %d (%v), want %d", status, err, tt.wantStatus)
+			}
+			if stdout.String() != tt.wantOutput {
+				t.Errorf("output = %q, want %q", stdout.String(), tt.wantOutput)
+			}
+			if err != nil {
+				stderr.WriteString(err.Error())
+			}
+			if !strings.Contains(stderr.String(), tt.wantStderr) {
+				t.Errorf("stderr = %q, want it to contain %q", stderr.String(), tt.wantStderr)
+			}
+		})
+	}
+}
@@ -0,0 +1,597 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gosh/internal/config"
+	"gosh/internal/lexer"
+)
+
+// specialParams are the single-character parameters that are not names
+const specialParams = "@*#?$!-0"
+
+// paramOps are the operators that may follow the name in ${name...}, longest
+// first so that ":-" is not read as a substring
+var paramOps = []string{
+	":-", ":=", ":?", ":+", "-", "=", "?", "+",
+	"##", "#", "%%", "%",
+	"//", "/#", "/%", "/",
+	":",
+}
+
+// expandParam expands a parameter reference, $name or ${...}, into parts.
+// Inside double quotes (quoted) the result is not split, and "$@" gives one
+// field for each positional parameter, or none if there are none, as
+// "${arr[@]}" does for the elements of an array.
+func (p *Parser) expandParam(ctx context.Context, ref string, quoted bool) ([]part, error) {
+	if !strings.HasPrefix(ref, "${") {
+		name := ref[1:]
+		values, set := p.paramValues(ctx, name)
+		if !set && p.config.Nounset && name != "@" && name != "*" {
+			return nil, unboundError(ctx, name)
+		}
+		return p.valueParts(name, values, quoted), nil
+	}
+
+	expr := ref[2 : len(ref)-1]
+	badSubstitution := fmt.Errorf("%s: bad substitution", ref)
+
+	// ${!arr[@]} is the list of the keys of an array
+	if len(expr) > 1 && expr[0] == '!' {
+		name := paramName(expr[1:])
+		sub := expr[1+len(name):]
+		if !isName(name) || (sub != "[@]" && sub != "[*]") {
+			return nil, badSubstitution
+		}
+		v, _ := p.config.Variables.Get(name)
+		return p.valueParts(sub[1:2], v.Keys(), quoted), nil
+	}
+
+	// ${#name} is the length of the value, and ${#arr[@]} the number of
+	// elements of an array
+	if len(expr) > 1 && expr[0] == '#' {
+		if prm, rest, err := p.lookupParam(ctx, expr[1:]); err == nil && rest == "" {
+			if !prm.set && p.config.Nounset && prm.multi == "" {
+				return nil, unboundError(ctx, prm.name)
+			}
+			length := len(prm.values)
+			if prm.multi == "" {
+				length = utf8.RuneCountInString(prm.values[0])
+			}
+			return p.valueParts(prm.name, []string{strconv.Itoa(length)}, quoted), nil
+		}
+	}
+
+	prm, rest, err := p.lookupParam(ctx, expr)
+	if err != nil {
+		if errors.Is(err, errBadSubstitution) {
+			return nil, badSubstitution
+		}
+		return nil, err
+	}
+	name, values, set := prm.name, prm.values, prm.set
+	if rest == "" {
+		if !set && p.config.Nounset && prm.multi == "" {
+			return nil, unboundError(ctx, name)
+		}
+		return p.valueParts(prm.multi, values, quoted), nil
+	}
+
+	var op string
+	for _, candidate := range paramOps {
+		if strings.HasPrefix(rest, candidate) {
+			op = candidate
+			break
+		}
+	}
+	if op == "" {
+		return nil, badSubstitution
+	}
+	word := rest[len(op):]
+
+	// With a colon the operators treat a null value like an unset one
+	if strings.HasPrefix(op, ":") && op != ":" && strings.Join(values, "") == "" {
+		set = false
+	}
+
+	switch op {
+	case ":-", "-":
+		if set {
+			return p.valueParts(prm.multi, values, quoted), nil
+		}
+		return p.expandOperand(ctx, word, quoted)
+	case ":+", "+":
+		if !set {
+			return nil, nil
+		}
+		return p.expandOperand(ctx, word, quoted)
+	case ":=", "=":
+		if set {
+			return p.valueParts(prm.multi, values, quoted), nil
+		}
+		if !isName(name) || prm.multi != "" {
+			return nil, fmt.Errorf("$%s: cannot assign in this way", name)
+		}
+		value, err := p.expandString(ctx, word)
+		if err != nil {
+			return nil, err
+		}
+		if prm.key != "" {
+			err = p.assignElement(name, prm.key, value)
+		} else {
+			err = p.setVariable(name, value)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return p.valueParts(name, []string{value}, quoted), nil
+	case ":?", "?":
+		if set {
+			return p.valueParts(prm.multi, values, quoted), nil
+		}
+		msg, err := p.expandString(ctx, word)
+		if err != nil {
+			return nil, err
+		}
+		if msg == "" && op == "?" {
+			msg = "parameter not set"
+		} else if msg == "" {
+			msg = "parameter null or not set"
+		}
+		return nil, expansionError(ctx, fmt.Errorf("%s: %s", name, msg))
+	case ":":
+		sliced, err := p.substring(ctx, prm, word)
+		if err != nil {
+			return nil, expansionError(ctx, fmt.Errorf("%s: %w", ref, err))
+		}
+		return p.valueParts(prm.multi, sliced, quoted), nil
+	}
+
+	// The remaining operators match a pattern against each value
+	patternWord, replacement := word, ""
+	if strings.HasPrefix(op, "/") {
+		if slash := findUnquoted(word, '/'); slash >= 0 {
+			patternWord, replacement = word[:slash], word[slash+1:]
+		}
+	}
+	pattern, err := p.expandPattern(ctx, patternWord)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(op, "/") {
+		if replacement, err = p.expandString(ctx, replacement); err != nil {
+			return nil, err
+		}
+	}
+
+	results := make([]string, len(values))
+	for i, value := range values {
+		switch op {
+		case "#", "##", "%", "%%":
+			results[i] = removePattern(value, pattern, op)
+		default:
+			results[i] = replacePattern(value, pattern, replacement, op)
+		}
+	}
+	return p.valueParts(prm.multi, results, quoted), nil
+}
+
+// param is the parameter that an expansion refers to, with its values
+type param struct {
+	// name is the name of the parameter, without a subscript
+	name string
+	// multi is @ or * if the values are a list, as for $@ or ${arr[@]}
+	multi string
+	// array is set if the values are elements of an array
+	array bool
+	// key is the key of the array element referred to as ${name[index]}
+	key string
+	// indexes are the indexes of the elements of an indexed array
+	// referred to as ${name[@]}
+	indexes []int
+	values  []string
+	set     bool
+}
+
+// errBadSubstitution marks a parameter expansion that cannot be parsed
+var errBadSubstitution = errors.New("bad substitution")
+
+// lookupParam reads the parameter at the start of expr, a name optionally
+// followed by a subscript, and returns it with the rest of expr
+func (p *Parser) lookupParam(ctx context.Context, expr string) (param, string, error) {
+	name := paramName(expr)
+	if name == "" {
+		return param{}, "", errBadSubstitution
+	}
+	rest := expr[len(name):]
+
+	if !strings.HasPrefix(rest, "[") || !isName(name) {
+		values, set := p.paramValues(ctx, name)
+		prm := param{name: name, values: values, set: set}
+		if isMultiParam(name) {
+			prm.multi = name
+		}
+		return prm, rest, nil
+	}
+
+	end := subscriptEnd(rest)
+	if end < 0 {
+		return param{}, "", errBadSubstitution
+	}
+	index := rest[1 : end-1]
+	rest = rest[end:]
+
+	v, _ := p.config.Variables.Get(name)
+	if index == "@" || index == "*" {
+		values := v.Values()
+		prm := param{name: name, multi: index, array: true, values: values, set: len(values) > 0}
+		if !v.Has(config.AttrAssoc) {
+			for _, key := range v.Keys() {
+				n, _ := strconv.Atoi(key)
+				prm.indexes = append(prm.indexes, n)
+			}
+		}
+		return prm, rest, nil
+	}
+
+	key, err := p.arrayKey(ctx, name, index)
+	if err != nil {
+		return param{}, "", err
+	}
+	value, ok := v.Element(key)
+	return param{name: name, array: true, key: key, values: []string{value}, set: ok}, rest, nil
+}
+
+// paramValues returns the values of a parameter and whether it is set. Only
+// $@ and $* have more or fewer than one value: one per positional parameter.
+func (p *Parser) paramValues(ctx context.Context, name string) ([]string, bool) {
+	state := StateFromContext(ctx)
+
+	switch name {
+	case "@", "*":
+		return state.Args, len(state.Args) > 0
+	case "#":
+		return []string{strconv.Itoa(len(state.Args))}, true
+	case "?":
+		return []string{strconv.Itoa(state.Status)}, true
+	case "$":
+		return []string{strconv.Itoa(os.Getpid())}, true
+	case "0":
+		return []string{state.Name}, true
+	case "-":
+		flags := p.config.OptionLetters()
+		if state.Interactive {
+			flags += "i"
+		}
+		return []string{flags}, true
+	case "!":
+		job := p.jobManager.Last()
+		if job == nil {
+			return []string{""}, false
+		}
+		// The process may still be starting; its ID is known once it has
+		<-job.Started()
+		return []string{strconv.Itoa(job.Pid())}, true
+	}
+
+	if n, err := strconv.Atoi(name); err == nil {
+		if n < 1 || n > len(state.Args) {
+			return []string{""}, false
+		}
+		return []string{state.Args[n-1]}, true
+	}
+
+	value, ok := p.lookupVariable(name)
+	return []string{value}, ok
+}
+
+// valueParts turns the values of a parameter into parts. Unquoted values are
+// split; quoted ones are kept whole, except that "$*" joins the positional
+// parameters with the first character of IFS.
+func (p *Parser) valueParts(name string, values []string, quoted bool) []part {
+	if quoted && name == "*" {
+		ifs, ok := p.lookupVariable("IFS")
+		if !ok {
+			ifs = DefaultIFS
+		}
+		sep := ""
+		if ifs != "" {
+			r, _ := utf8.DecodeRuneInString(ifs)
+			sep = string(r)
+		}
+		return []part{{text: strings.Join(values, sep), quoted: true}}
+	}
+
+	parts := make([]part, len(values))
+	for i, value := range values {
+		parts[i] = part{text: value, quoted: quoted, split: !quoted, brk: i > 0}
+	}
+	return parts
+}
+
+// expandOperand expands the word of a ${name-word} style expansion. Its
+// unquoted text is split like the value of the parameter would be, and
+// inside double quotes all of it is quoted.
+func (p *Parser) expandOperand(ctx context.Context, word string, quoted bool) ([]part, error) {
+	parts, err := p.expandParts(ctx, word)
+	if err != nil {
+		return nil, err
+	}
+	for i := range parts {
+		if quoted {
+			parts[i].quoted, parts[i].split = true, false
+		} else if !parts[i].quoted {
+			parts[i].split = true
+		}
+	}
+	return parts, nil
+}
+
+// expandPattern expands a word used as a pattern. Quoted characters match
+// only themselves, while unquoted ones keep their pattern meaning.
+func (p *Parser) expandPattern(ctx context.Context, word string) (string, error) {
+	parts, err := p.expandParts(ctx, word)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	for _, pt := range parts {
+		if pt.quoted {
+			b.WriteString(escapePattern(pt.text))
+		} else {
+			b.WriteString(pt.text)
+		}
+	}
+	return b.String(), nil
+}
+
+// substring implements ${name:offset} and ${name:offset:length}. A negative
+// offset counts from the end, as does a negative length. For $@ and $* the
+// positional parameters are sliced, starting with $0 at offset 0. For
+// ${arr[@]} the offset of an indexed array is an index, and the slice
+// starts at the first element at or after it.
+func (p *Parser) substring(ctx context.Context, prm param, word string) ([]string, error) {
+	offsetWord, lengthWord, hasLength := word, "", false
+	if colon := findUnquoted(word, ':'); colon >= 0 {
+		offsetWord, lengthWord, hasLength = word[:colon], word[colon+1:], true
+	}
+
+	offset, err := p.expandInt(ctx, offsetWord)
+	if err != nil {
+		return nil, err
+	}
+	length := 0
+	if hasLength {
+		if length, err = p.expandInt(ctx, lengthWord); err != nil {
+			return nil, err
+		}
+	}
+
+	if prm.multi != "" {
+		values := prm.values
+		if !prm.array {
+			values = append([]string{StateFromContext(ctx).Name}, values...)
+		}
+		if len(prm.indexes) > 0 {
+			if offset < 0 {
+				offset += prm.indexes[len(prm.indexes)-1] + 1
+			}
+			if offset < 0 {
+				return nil, nil
+			}
+			offset = sort.SearchInts(prm.indexes, offset)
+		}
+		start, end, err := sliceBounds(len(values), offset, length, hasLength)
+		if err != nil {
+			return nil, err
+		}
+		return values[start:end], nil
+	}
+
+	runes := []rune(prm.values[0])
+	start, end, err := sliceBounds(len(runes), offset, length, hasLength)
+	if err != nil {
+		return nil, err
+	}
+	return []string{string(runes[start:end])}, nil
+}
+
+// sliceBounds turns the offset and length of a substring expansion into
+// bounds within a sequence of size elements
+func sliceBounds(size, offset, length int, hasLength bool) (int, int, error) {
+	if offset < 0 {
+		offset += size
+	}
+	if offset < 0 || offset > size {
+		return 0, 0, nil
+	}
+
+	end := size
+	if hasLength {
+		end = offset + length
+		if length < 0 {
+			end = size + length
+		}
+		if end < offset {
+			return 0, 0, errors.New("substring expression < 0")
+		}
+		end = min(end, size)
+	}
+	return offset, end, nil
+}
+
+// expandInt evaluates a word that is an arithmetic expression, such as the
+// offset in ${s:i+1}
+func (p *Parser) expandInt(ctx context.Context, word string) (int, error) {
+	n, err := p.arithmetic(ctx, word)
+	return int(n), err
+}
+
+// removePattern implements the # and ## prefix and % and %% suffix removals
+func removePattern(value, pattern, op string) string {
+	bounds := runeBounds(value)
+
+	switch op {
+	case "#":
+		for _, i := range bounds {
+			if matchPattern(pattern, value[:i]) {
+				return value[i:]
+			}
+		}
+	case "##":
+		for j := len(bounds) - 1; j >= 0; j-- {
+			if i := bounds[j]; matchPattern(pattern, value[:i]) {
+				return value[i:]
+			}
+		}
+	case "%":
+		for j := len(bounds) - 1; j >= 0; j-- {
+			if i := bounds[j]; matchPattern(pattern, value[i:]) {
+				return value[:i]
+			}
+		}
+	case "%%":
+		for _, i := range bounds {
+			if matchPattern(pattern, value[i:]) {
+				return value[:i]
+			}
+		}
+	}
+	return value
+}
+
+// replacePattern implements ${name/pattern/string} and its // (every match),
+// /# (match at the start) and /% (match at the end) forms. The longest
+// match at each position is replaced; empty matches are not.
+func replacePattern(value, pattern, replacement, op string) string {
+	bounds := runeBounds(value)
+
+	switch op {
+	case "/#":
+		for j := len(bounds) - 1; j > 0; j-- {
+			if i := bounds[j]; matchPattern(pattern, value[:i]) {
+				return replacement + value[i:]
+			}
+		}
+		return value
+	case "/%":
+		for _, i := range bounds[:len(bounds)-1] {
+			if matchPattern(pattern, value[i:]) {
+				return value[:i] + replacement
+			}
+		}
+		return value
+	}
+
+	var b strings.Builder
+	for s := 0; s < len(bounds)-1; {
+		start := bounds[s]
+		matched := -1
+		for e := len(bounds) - 1; e > s; e-- {
+			if matchPattern(pattern, value[start:bounds[e]]) {
+				matched = e
+				break
+			}
+		}
+		if matched < 0 {
+			b.WriteString(value[start:bounds[s+1]])
+			s++
+			continue
+		}
+
+		b.WriteString(replacement)
+		if op == "/" {
+			b.WriteString(value[bounds[matched]:])
+			return b.String()
+		}
+		s = matched
+	}
+	return b.String()
+}
+
+// runeBounds returns the byte offsets of the character boundaries in s,
+// including 0 and len(s)
+func runeBounds(s string) []int {
+	bounds := make([]int, 0, len(s)+1)
+	for i := range s {
+		bounds = append(bounds, i)
+	}
+	return append(bounds, len(s))
+}
+
+// findUnquoted returns the index of the first c in word that is not quoted,
+// escaped or inside an expansion, or -1 if there is none
+func findUnquoted(word string, c byte) int {
+	for i := 0; i < len(word); {
+		switch word[i] {
+		case c:
+			return i
+		case '\\':
+			i += 2
+		case '\'', '"':
+			end := lexer.QuoteEnd(word, i)
+			if end < 0 {
+				return -1
+			}
+			i = end
+		case '$', '`':
+			i = expansionEnd(word, i)
+		default:
+			i++
+		}
+	}
+	return -1
+}
+
+// paramName returns the parameter name at the start of expr: a name, a
+// number or one of the special parameters
+func paramName(expr string) string {
+	if expr == "" {
+		return ""
+	}
+	if strings.IndexByte(specialParams, expr[0]) >= 0 {
+		return expr[:1]
+	}
+
+	end := 0
+	if isDigit(expr[0]) {
+		for end < len(expr) && isDigit(expr[end]) {
+			end++
+		}
+		return expr[:end]
+	}
+	for end < len(expr) && isNameByte(expr[end], end == 0) {
+		end++
+	}
+	return expr[:end]
+}
+
+// isName reports whether s is a valid variable name
+func isName(s string) bool {
+	return s != "" && paramName(s) == s && !isDigit(s[0]) && strings.IndexByte(specialParams, s[0]) < 0
+}
+
+// isMultiParam reports whether name is $@ or $*, which stand for all of the
+// positional parameters
+func isMultiParam(name string) bool {
+	return name == "@" || name == "*"
+}
+
+// isNameByte reports whether c may appear in a variable name, at its start
+// if first is set
+func isNameByte(c byte, first bool) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (!first && isDigit(c))
+}
+
+// isDigit reports whether c is an ASCII digit
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
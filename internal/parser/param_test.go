@@ -0,0 +1,146 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"gosh/internal/config"
+)
+
+func TestParameterExpansion(t *testing.T) {
+	vars := map[string]string{
+		"P":     "/usr/local/lib/libfoo.so.1",
+		"EMPTY": "",
+		"WORDS": "a  b",
+		"STAR":  "*",
+	}
+
+	tests := []struct {
+		name       string
+		input      string
+		args       []string
+		wantOutput string
+		wantErr    bool
+		wantStderr string
+	}{
+		{name: "braces", input: "printf '[%s]' ${P}x", wantOutput: "[/usr/local/lib/libfoo.so.1x]"},
+		{name: "default when unset", input: "printf '[%s]' ${UNSET:-def} ${UNSET-def}", wantOutput: "[def][def]"},
+		{name: "default when null", input: "printf '[%s]' ${EMPTY:-def} \"${EMPTY-def}\"", wantOutput: "[def][]"},
+		{name: "default keeps quotes", input: `printf '[%s]' ${UNSET:-"a b"} c`, wantOutput: "[a b][c]"},
+		{name: "default is split unquoted", input: `printf '[%s]' ${UNSET:-a b}`, wantOutput: "[a][b]"},
+		{name: "default with expansions", input: `printf '[%s]' "${UNSET:-$P}"`, wantOutput: "[/usr/local/lib/libfoo.so.1]"},
+		{name: "alternative", input: `printf '[%s]' "${P:+set}" "${UNSET:+set}" "${EMPTY+set}"`, wantOutput: "[set][][set]"},
+		{name: "assign default", input: "printf '[%s]' ${NEW:=value} $NEW", wantOutput: "[value][value]"},
+		{name: "assign to positional", input: "echo ${1:=x}", wantErr: true},
+		{name: "error when unset", input: "echo ${UNSET:?is required}", wantErr: true},
+		{name: "default error when unset", input: "echo ${UNSET?}", wantErr: true, wantStderr: "gosh: UNSET: parameter not set\n"},
+		{name: "default error when null", input: "echo ${EMPTY:?}", wantErr: true, wantStderr: "gosh: EMPTY: parameter null or not set\n"},
+		{name: "no error when null without colon", input: "printf '[%s]' ${EMPTY?}", wantOutput: "[]"},
+		{name: "no error when set", input: "printf '[%s]' ${P:?x}", wantOutput: "[/usr/local/lib/libfoo.so.1]"},
+		{name: "length", input: "printf '[%s]' ${#P} ${#EMPTY} ${#UNSET}", wantOutput: "[26][0][0]"},
+		{name: "length in characters", input: "printf '[%s]' ${#X}", wantOutput: "[2]"},
+		{name: "shortest prefix", input: "printf '[%s]' ${P#*/}", wantOutput: "[usr/local/lib/libfoo.so.1]"},
+		{name: "longest prefix", input: "printf '[%s]' ${P##*/}", wantOutput: "[libfoo.so.1]"},
+		{name: "shortest suffix", input: "printf '[%s]' ${P%.*}", wantOutput: "[/usr/local/lib/libfoo.so]"},
+		{name: "longest suffix", input: "printf '[%s]' ${P%%.*}", wantOutput: "[/usr/local/lib/libfoo]"},
+		{name: "no match", input: "printf '[%s]' ${P#x}", wantOutput: "[/usr/local/lib/libfoo.so.1]"},
+		{name: "quoted pattern is literal", input: `printf '[%s]' ${STAR#"*"}x ${P#"*"}`, wantOutput: "[x][/usr/local/lib/libfoo.so.1]"},
+		{name: "pattern from a variable", input: "printf '[%s]' ${P##$STAR/}", wantOutput: "[libfoo.so.1]"},
+		{name: "replace first", input: "printf '[%s]' ${P/lib/LIB}", wantOutput: "[/usr/local/LIB/libfoo.so.1]"},
+		{name: "replace all", input: "printf '[%s]' ${P//lib/LIB}", wantOutput: "[/usr/local/LIB/LIBfoo.so.1]"},
+		{name: "replace at start", input: `printf '[%s]' ${P/#\/usr/X} ${P/#lib/X}`, wantOutput: "[X/local/lib/libfoo.so.1][/usr/local/lib/libfoo.so.1]"},
+		{name: "replace at end", input: "printf '[%s]' ${P/%.1/.2}", wantOutput: "[/usr/local/lib/libfoo.so.2]"},
+		{name: "delete", input: "printf '[%s]' ${P//\\//}", wantOutput: "[usrlocalliblibfoo.so.1]"},
+		{name: "replace longest match", input: "printf '[%s]' ${P/l*b/X}", wantOutput: "[/usr/Xfoo.so.1]"},
+		{name: "substring", input: "printf '[%s]' ${P:5:5} ${P:20}", wantOutput: "[local][o.so.1]"},
+		{name: "substring expressions", input: "i=1; printf '[%s]' ${P:i+4:5} ${P:(-2)} ${P:2*10:i}", wantOutput: "[local][.1][o]"},
+		{name: "negative offset with space", input: "printf '[%s]' ${P: -4}", wantOutput: "[so.1]"},
+		{name: "negative length", input: "printf '[%s]' ${P:1:-3}", wantOutput: "[usr/local/lib/libfoo.s]"},
+		{name: "offset past the end", input: "printf '[%s]' ${P:100}", wantOutput: "[]"},
+		{name: "negative substring", input: "echo ${P:5:-30}", wantErr: true},
+		{name: "bad substitution", input: "echo ${P!x}", wantErr: true},
+		{name: "quoted expansion is not split", input: `printf '[%s]' "${WORDS}" ${WORDS}`, wantOutput: "[a  b][a][b]"},
+		{name: "positional parameters", input: "printf '[%s]' $1 ${2} $3 ${10}", args: []string{"a", "b c"}, wantOutput: "[a][b][c]"},
+		{name: "count", input: "echo $#", args: []string{"a", "b"}, wantOutput: "2\n"},
+		{name: "quoted at", input: `printf '[%s]' "$@"`, args: []string{"a", "b c", ""}, wantOutput: "[a][b c][]"},
+		{name: "quoted at with text", input: `printf '[%s]' "x$@y"`, args: []string{"a", "b"}, wantOutput: "[xa][by]"},
+		{name: "quoted at without parameters", input: `printf '[%s]' x "$@"`, wantOutput: "[x]"},
+		{name: "unquoted at is split", input: `printf '[%s]' $@`, args: []string{"a", "b c", ""}, wantOutput: "[a][b][c]"},
+		{name: "quoted star is joined", input: `printf '[%s]' "$*"`, args: []string{"a", "b c"}, wantOutput: "[a b c]"},
+		{name: "at slice", input: `printf '[%s]' "${@:2}" "${@:0:1}"`, args: []string{"a", "b", "c"}, wantOutput: "[b][c][gosh]"},
+		{name: "at pattern", input: `printf '[%s]' "${@%.go}"`, args: []string{"a.go", "b.go"}, wantOutput: "[a][b]"},
+		{name: "at count", input: `printf '[%s]' ${#@}`, args: []string{"a", "b"}, wantOutput: "[2]"},
+		{name: "at default", input: `printf '[%s]' "${@:-none}"`, wantOutput: "[none]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			for name, value := range vars {
+				_ = cfg.Variables.Set(name, value)
+			}
+			_ = cfg.Variables.Set("X", "é€")
+			parser := New(cfg)
+
+			cmd, err := parser.Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse() failed: %v", err)
+			}
+
+			var stdout, stderr bytes.Buffer
+			ctx := WithIO(context.Background(), IO{Stdin: strings.NewReader(""), Stdout: &stdout, Stderr: &stderr})
+			ctx = WithState(ctx, NewState(DefaultShellName, tt.args))
+			_, err = cmd.Execute(ctx, cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Execute() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if stdout.String() != tt.wantOutput {
+				t.Errorf("output = %q, want %q (stderr %q)", stdout.String(), tt.wantOutput, stderr.String())
+			}
+			if tt.wantStderr != "" && stderr.String() != tt.wantStderr {
+				t.Errorf("stderr = %q, want %q", stderr.String(), tt.wantStderr)
+			}
+		})
+	}
+}
+
+func TestSpecialParameters(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		wantOutput string
+	}{
+		{name: "status of the last command", input: "false; echo $?; true; echo $?", wantOutput: "1\n0\n"},
+		{name: "status after and-or", input: "false || echo $?", wantOutput: "1\n"},
+		{name: "status of a negated pipeline", input: "! true; echo $?", wantOutput: "1\n"},
+		{name: "subshells see the status", input: "false; (echo $?; true); echo $?", wantOutput: "1\n0\n"},
+		{name: "shell name", input: "echo $0", wantOutput: "gosh\n"},
+		{name: "no background job", input: "echo [$!]", wantOutput: "[]\n"},
+		{name: "background job", input: "true </dev/null >/dev/null 2>&1 & test $! -gt 0 && echo ok", wantOutput: "ok\n"},
+		{name: "process ID", input: "test $$ -gt 0 && echo ok", wantOutput: "ok\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			parser := New(cfg)
+
+			cmd, err := parser.Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse() failed: %v", err)
+			}
+
+			var stdout, stderr bytes.Buffer
+			ctx := WithIO(context.Background(), IO{Stdin: strings.NewReader(""), Stdout: &stdout, Stderr: &stderr})
+			ctx = WithState(ctx, NewState(DefaultShellName, nil))
+			_, _ = cmd.Execute(ctx, cfg)
+			parser.jobManager.WaitAll(context.Background())
+
+			if stdout.String() != tt.wantOutput {
+				t.Errorf("output = %q, want %q (stderr %q)", stdout.String(), tt.wantOutput, stderr.String())
+			}
+		})
+	}
+}
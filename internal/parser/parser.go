@@ -5,15 +5,21 @@ package parser
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 
 	"gosh/internal/config"
 	"gosh/internal/history"
+	"gosh/internal/jobs"
+	"gosh/internal/lexer"
 )
 
 const (
@@ -21,21 +27,31 @@ const (
 	KeyValueParts = 2
 )
 
-// Command represents a parsed command
+// Command represents a parsed command. Execute returns the exit status of
+// the command; a command that runs and exits non-zero is not an error. The
+// error reports a command that could not run, such as one that is not found
+// or whose redirection fails, and the status is then that of the failure.
 type Command interface {
-	Execute(ctx context.Context, cfg *config.Config) error
+	Execute(ctx context.Context, cfg *config.Config) (int, error)
 }
 
 // Parser handles parsing of command lines
 type Parser struct {
 	config         *config.Config
 	historyManager *history.Manager
+	jobManager     *jobs.Manager
+	// hash is where the programs run by name were found
+	hash *hashTable
+	// receiveSignal takes the signals kill sends to the shell itself
+	receiveSignal func(os.Signal) bool
 }
 
 // New creates a new parser instance
 func New(cfg *config.Config) *Parser {
 	return &Parser{
-		config: cfg,
+		config:     cfg,
+		jobManager: jobs.New(),
+		hash:       newHashTable(),
 	}
 }
 
@@ -44,203 +60,251 @@ func (p *Parser) SetHistoryManager(hm *history.Manager) {
 	p.historyManager = hm
 }
 
-// Parse parses a command line and returns a Command
-func (p *Parser) Parse(input string) (Command, error) {
-	input = strings.TrimSpace(input)
-	if input == "" {
-		return &NoOpCommand{}, nil
-	}
+// SetJobManager sets the job manager that background jobs are added to
+func (p *Parser) SetJobManager(jm *jobs.Manager) {
+	p.jobManager = jm
+}
 
-	// Handle aliases
-	if expanded, ok := p.expandAlias(input); ok {
-		input = expanded
-	}
+// SetSignalReceiver sets the function that takes the signals kill sends to
+// the shell itself. It reports whether it handled a signal, which then acts
+// before kill returns rather than whenever the process receives it.
+func (p *Parser) SetSignalReceiver(receive func(os.Signal) bool) {
+	p.receiveSignal = receive
+}
 
-	// Split into tokens
-	tokens, err := p.tokenize(input)
-	if err != nil {
-		return nil, err
-	}
+// ErrIncomplete is wrapped by the error Parse returns when the input ends
+// inside a construct that continues on the next line, such as a
+// here-document or a quoted string. Interactive callers can read another
+// line and parse again.
+var ErrIncomplete = lexer.ErrIncomplete
+
+// SyntaxError is a parse error located at a line and column of the input
+type SyntaxError struct {
+	Line   int
+	Column int
+	Msg    string
+	// incomplete marks errors that more input could resolve
+	incomplete bool
+	// want is text that more input must contain to resolve an incomplete
+	// error, or "" if any input might
+	want string
+}
 
-	if len(tokens) == 0 {
-		return &NoOpCommand{}, nil
-	}
+// Error implements the error interface for SyntaxError
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s (line %d, column %d)", e.Msg, e.Line, e.Column)
+}
 
-	// Check for built-in commands
-	if builtin := p.parseBuiltin(tokens); builtin != nil {
-		return builtin, nil
+// Unwrap lets errors.Is match ErrIncomplete for incomplete input
+func (e *SyntaxError) Unwrap() error {
+	if e.incomplete {
+		return ErrIncomplete
 	}
-
-	// Parse as external command
-	return p.parseExternal(tokens)
+	return nil
 }
 
-// tokenize splits input into tokens, handling quotes and escapes
-func (p *Parser) tokenize(input string) ([]string, error) {
-	var tokens []string
-	var current strings.Builder
-	var inQuotes bool
-	var quoteChar rune
-	var escaped bool
-
-	for _, r := range input {
-		if escaped {
-			current.WriteRune(r)
-			escaped = false
-			continue
-		}
-
-		if r == '\\' {
-			escaped = true
-			continue
-		}
-
-		if !inQuotes && (r == '"' || r == '\'') {
-			inQuotes = true
-			quoteChar = r
-			continue
-		}
-
-		if inQuotes && r == quoteChar {
-			inQuotes = false
-			continue
-		}
+// MayComplete reports whether line, read after the input that e was returned
+// for, might complete it. Input missing a closing word or quote can only be
+// completed by a line containing it, so callers reading a long compound
+// command need not parse it again after every line.
+func (e *SyntaxError) MayComplete(line string) bool {
+	return !e.incomplete || strings.Contains(line, e.want)
+}
 
-		if !inQuotes && (r == ' ' || r == '\t') {
-			if current.Len() > 0 {
-				tokens = append(tokens, current.String())
-				current.Reset()
+// Parse parses a command line and returns a Command
+func (p *Parser) Parse(input string) (Command, error) {
+	tokens, err := lexer.Tokenize(input)
+	if err != nil {
+		var lexErr *lexer.Error
+		if errors.As(err, &lexErr) {
+			return nil, &SyntaxError{
+				Line:       lexErr.Pos.Line,
+				Column:     lexErr.Pos.Col,
+				Msg:        lexErr.Msg,
+				incomplete: lexErr.Incomplete,
+				want:       lexErr.Want,
 			}
-			continue
 		}
-
-		current.WriteRune(r)
-	}
-
-	if inQuotes {
-		return nil, fmt.Errorf("unclosed quote")
-	}
-
-	if current.Len() > 0 {
-		tokens = append(tokens, current.String())
+		return nil, err
 	}
 
-	return tokens, nil
+	cp := &commandParser{parser: p, src: input, tokens: tokens}
+	return cp.parseProgram()
 }
 
-// expandAlias expands aliases if present
-func (p *Parser) expandAlias(input string) (string, bool) {
-	tokens := strings.Fields(input)
-	if len(tokens) == 0 {
-		return input, false
-	}
-
-	if expansion, ok := p.config.Aliases[tokens[0]]; ok {
-		tokens[0] = expansion
-		return strings.Join(tokens, " "), true
-	}
-
-	return input, false
+// withConfig returns a copy of the parser that reads and updates cfg, so
+// that commands run in a subshell see its isolated configuration
+func (p *Parser) withConfig(cfg *config.Config) *Parser {
+	clone := *p
+	clone.config = cfg
+	return &clone
 }
 
-// parseBuiltin checks if the command is a built-in and returns it
-func (p *Parser) parseBuiltin(tokens []string) Command {
-	cmd := tokens[0]
-	args := tokens[1:]
+// expandAlias returns the value of the alias name, if one is defined
+func (p *Parser) expandAlias(name string) (string, bool) {
+	expansion, ok := p.config.Aliases[name]
+	return expansion, ok
+}
 
-	switch cmd {
-	case "cd":
+// builtins create the built-in commands by name, from the parser that runs
+// them, the name they were called by and their arguments
+var builtins = map[string]func(p *Parser, name string, args []string) Command{
+	"cd": func(_ *Parser, _ string, args []string) Command {
 		return &CdCommand{Args: args}
-	case "pwd":
+	},
+	"pwd": func(_ *Parser, _ string, _ []string) Command {
 		return &PwdCommand{}
-	case "exit":
-		return &ExitCommand{Args: args}
-	case "help":
+	},
+	":": func(_ *Parser, _ string, _ []string) Command {
+		return &ColonCommand{}
+	},
+	"exit": func(p *Parser, _ string, args []string) Command {
+		return &ExitCommand{Args: args, Jobs: p.jobManager}
+	},
+	"help": func(_ *Parser, _ string, args []string) Command {
 		return &HelpCommand{Args: args}
-	case "history":
+	},
+	"history": func(p *Parser, _ string, args []string) Command {
 		return &HistoryCommand{Args: args, Manager: p.historyManager}
-	case "alias":
+	},
+	"alias": func(p *Parser, _ string, args []string) Command {
 		return &AliasCommand{Args: args, Config: p.config}
-	case "export":
-		return &ExportCommand{Args: args, Config: p.config}
-	default:
-		return nil
-	}
+	},
+	"export": func(p *Parser, _ string, args []string) Command {
+		return &ExportCommand{Args: args, parser: p}
+	},
+	"readonly": func(p *Parser, _ string, args []string) Command {
+		return &ReadonlyCommand{Args: args, parser: p}
+	},
+	"set": func(_ *Parser, _ string, args []string) Command {
+		return &SetCommand{Args: args}
+	},
+	"env": func(_ *Parser, _ string, args []string) Command {
+		return &EnvCommand{Args: args}
+	},
+	"jobs": func(p *Parser, _ string, args []string) Command {
+		return &JobsCommand{Args: args, Jobs: p.jobManager}
+	},
+	"fg": func(p *Parser, _ string, args []string) Command {
+		return &FgCommand{Args: args, Jobs: p.jobManager}
+	},
+	"bg": func(p *Parser, _ string, args []string) Command {
+		return &BgCommand{Args: args, Jobs: p.jobManager}
+	},
+	"wait": func(p *Parser, _ string, args []string) Command {
+		return &WaitCommand{Args: args, Jobs: p.jobManager}
+	},
+	"disown": func(p *Parser, _ string, args []string) Command {
+		return &DisownCommand{Args: args, Jobs: p.jobManager}
+	},
+	"kill": func(p *Parser, _ string, args []string) Command {
+		return &KillCommand{Args: args, parser: p}
+	},
+	"break": func(_ *Parser, _ string, args []string) Command {
+		return &BreakCommand{Args: args}
+	},
+	"continue": func(_ *Parser, _ string, args []string) Command {
+		return &ContinueCommand{Args: args}
+	},
+	"local": func(p *Parser, _ string, args []string) Command {
+		return &LocalCommand{Args: args, parser: p}
+	},
+	"return": func(_ *Parser, _ string, args []string) Command {
+		return &ReturnCommand{Args: args}
+	},
+	"type": func(p *Parser, _ string, args []string) Command {
+		return &TypeCommand{Args: args, parser: p}
+	},
+	"declare": func(p *Parser, _ string, args []string) Command {
+		return &DeclareCommand{Args: args, parser: p}
+	},
+	"unset": func(p *Parser, _ string, args []string) Command {
+		return &UnsetCommand{Args: args, parser: p}
+	},
+	"source": func(p *Parser, name string, args []string) Command {
+		return &SourceCommand{Name: name, Args: args, parser: p}
+	},
+	".": func(p *Parser, name string, args []string) Command {
+		return &SourceCommand{Name: name, Args: args, parser: p}
+	},
+	"test": func(p *Parser, name string, args []string) Command {
+		return &TestCommand{Name: name, Args: args, parser: p}
+	},
+	"[": func(p *Parser, name string, args []string) Command {
+		return &TestCommand{Name: name, Args: args, parser: p}
+	},
+	"shopt": func(_ *Parser, _ string, args []string) Command {
+		return &ShoptCommand{Args: args}
+	},
+	"trap": func(p *Parser, _ string, args []string) Command {
+		return &TrapCommand{Args: args, parser: p}
+	},
+	"echo": func(_ *Parser, _ string, args []string) Command {
+		return &EchoCommand{Args: args}
+	},
+	"printf": func(p *Parser, _ string, args []string) Command {
+		return &PrintfCommand{Args: args, parser: p}
+	},
+	"read": func(p *Parser, _ string, args []string) Command {
+		return &ReadCommand{Args: args, parser: p}
+	},
+	"which": func(p *Parser, _ string, args []string) Command {
+		return &WhichCommand{Args: args, parser: p}
+	},
+	"command": func(p *Parser, _ string, args []string) Command {
+		return &CommandCommand{Args: args, parser: p}
+	},
+	"builtin": func(p *Parser, _ string, args []string) Command {
+		return &BuiltinCommand{Args: args, parser: p}
+	},
+	"hash": func(p *Parser, _ string, args []string) Command {
+		return &HashCommand{Args: args, parser: p}
+	},
 }
 
-// parseExternal parses an external command
-func (p *Parser) parseExternal(tokens []string) (Command, error) {
-	// Expand variables in tokens
-	expandedTokens := make([]string, len(tokens))
-	for i, token := range tokens {
-		expandedTokens[i] = p.expandVariables(token)
+// parseBuiltin checks if the command is a built-in and returns it
+func (p *Parser) parseBuiltin(tokens []string) Command {
+	build, ok := builtins[tokens[0]]
+	if !ok {
+		return nil
 	}
-
-	return &ExternalCommand{
-		Name: expandedTokens[0],
-		Args: expandedTokens[1:],
-	}, nil
+	return build(p, tokens[0], tokens[1:])
 }
 
-// expandVariables expands environment variables in a token
-func (p *Parser) expandVariables(token string) string {
-	// Simple variable expansion for $VAR and ${VAR}
-	result := token
-
-	// Handle $VAR format
-	for i := 0; i < len(result); i++ {
-		if result[i] == '$' && i+1 < len(result) {
-			if result[i+1] == '{' {
-				// Handle ${VAR} format
-				end := strings.Index(result[i+2:], "}")
-				if end != -1 {
-					varName := result[i+2 : i+2+end]
-					varValue := p.getVariable(varName)
-					result = result[:i] + varValue + result[i+3+end:]
-					i += len(varValue) - 1
-				}
-			} else {
-				// Handle $VAR format
-				start := i + 1
-				end := start
-				for end < len(result) && (isAlphaNumeric(result[end]) || result[end] == '_') {
-					end++
-				}
-				if end > start {
-					varName := result[start:end]
-					varValue := p.getVariable(varName)
-					result = result[:i] + varValue + result[end:]
-					i += len(varValue) - 1
-				}
-			}
-		}
-	}
-
-	return result
+// IsBuiltin reports whether name is a built-in command
+func IsBuiltin(name string) bool {
+	_, ok := builtins[name]
+	return ok
 }
 
-// getVariable gets a variable value from environment or config
-func (p *Parser) getVariable(name string) string {
-	// First check config environment
-	if value, ok := p.config.Environment[name]; ok {
-		return value
+// BuiltinNames returns the names of the built-in commands in order
+func BuiltinNames() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
 	}
-
-	// Then check system environment
-	return os.Getenv(name)
+	sort.Strings(names)
+	return names
 }
 
-// isAlphaNumeric checks if a character is alphanumeric
-func isAlphaNumeric(c byte) bool {
-	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+// parseExternal parses an external command from its expanded words. Its
+// program is found through the hash table; one that is not found is
+// reported when the command runs.
+func (p *Parser) parseExternal(ctx context.Context, tokens []string) (Command, error) {
+	program, _ := p.findProgram(ctx, tokens[0])
+	return &ExternalCommand{
+		Name: tokens[0],
+		Args: tokens[1:],
+		Path: program,
+	}, nil
 }
 
 // NoOpCommand represents a no-operation command
 type NoOpCommand struct{}
 
 // Execute implements the Command interface for NoOpCommand
-func (c *NoOpCommand) Execute(_ context.Context, _ *config.Config) error {
-	return nil
+func (c *NoOpCommand) Execute(_ context.Context, _ *config.Config) (int, error) {
+	return 0, nil
 }
 
 // CdCommand implements the cd built-in command
@@ -249,57 +313,128 @@ type CdCommand struct {
 }
 
 // Execute implements the Command interface for CdCommand
-func (c *CdCommand) Execute(_ context.Context, _ *config.Config) error {
+func (c *CdCommand) Execute(ctx context.Context, cfg *config.Config) (int, error) {
 	var dir string
 	if len(c.Args) == 0 {
 		// No arguments, go to home directory
-		homeDir, err := os.UserHomeDir()
-		if err != nil {
-			return fmt.Errorf("failed to get home directory: %w", err)
+		homeDir, ok := cfg.Variables.Lookup("HOME")
+		if !ok {
+			var err error
+			if homeDir, err = os.UserHomeDir(); err != nil {
+				return ExitFailure, fmt.Errorf("failed to get home directory: %w", err)
+			}
 		}
 		dir = homeDir
 	} else {
 		dir = c.Args[0]
 	}
+	if dir == "" {
+		// Like bash, cd "" stays where it is
+		return 0, nil
+	}
 
-	// Expand ~ to home directory
-	if strings.HasPrefix(dir, "~/") {
-		homeDir, err := os.UserHomeDir()
-		if err != nil {
-			return fmt.Errorf("failed to get home directory: %w", err)
+	state := StateFromContext(ctx)
+	previous := state.WorkDir()
+	target := filepath.Clean(resolvePath(ctx, dir))
+	info, err := os.Stat(target)
+	if err != nil {
+		var pathErr *os.PathError
+		if errors.As(err, &pathErr) {
+			err = pathErr.Err
 		}
-		dir = filepath.Join(homeDir, dir[2:])
+		return ExitFailure, fmt.Errorf("cd: %s: %w", dir, err)
 	}
-
-	if err := os.Chdir(dir); err != nil {
-		return fmt.Errorf("cd: %w", err)
+	if !info.IsDir() {
+		return ExitFailure, fmt.Errorf("cd: %s: not a directory", dir)
+	}
+	if err := state.chdir(target); err != nil {
+		return ExitFailure, fmt.Errorf("cd: %w", err)
 	}
 
-	return nil
+	// Keep PWD and OLDPWD current for ~+ and ~-
+	for name, value := range map[string]string{"PWD": target, "OLDPWD": previous} {
+		if err := cfg.Variables.Set(name, value); err != nil {
+			return ExitFailure, fmt.Errorf("cd: %w", err)
+		}
+	}
+	return 0, nil
 }
 
 // PwdCommand implements the pwd built-in command
 type PwdCommand struct{}
 
 // Execute implements the Command interface for PwdCommand
-func (c *PwdCommand) Execute(_ context.Context, _ *config.Config) error {
-	wd, err := os.Getwd()
-	if err != nil {
-		return fmt.Errorf("pwd: %w", err)
-	}
-	fmt.Println(wd)
-	return nil
+func (c *PwdCommand) Execute(ctx context.Context, _ *config.Config) (int, error) {
+	_, err := fmt.Fprintln(IOFromContext(ctx).Stdout, StateFromContext(ctx).WorkDir())
+	return builtinStatus(err)
+}
+
+// ColonCommand implements the : built-in command, which does nothing and
+// succeeds. Its arguments are still expanded, so it is used for the side
+// effects of expansions such as ${VAR:=default}.
+type ColonCommand struct{}
+
+// Execute implements the Command interface for ColonCommand
+func (c *ColonCommand) Execute(_ context.Context, _ *config.Config) (int, error) {
+	return 0, nil
 }
 
-// ExitCommand implements the exit built-in command
+// ExitCommand implements the exit built-in command, which leaves the shell
+// with the given status or that of the last command. The shell runs its
+// EXIT trap on the way out. In a subshell only the subshell exits.
 type ExitCommand struct {
 	Args []string
+	Jobs *jobs.Manager
 }
 
 // Execute implements the Command interface for ExitCommand
-func (c *ExitCommand) Execute(_ context.Context, _ *config.Config) error {
-	os.Exit(0)
-	return nil
+func (c *ExitCommand) Execute(ctx context.Context, _ *config.Config) (int, error) {
+	state := StateFromContext(ctx)
+	status := state.Status
+	switch len(c.Args) {
+	case 0:
+	case 1:
+		n, err := strconv.Atoi(c.Args[0])
+		if err != nil {
+			// Like bash, the shell still exits
+			reportError(ctx, fmt.Errorf("exit: %s: numeric argument required", c.Args[0]))
+			return ExitUsage, &ExitError{Status: ExitUsage}
+		}
+		status = n & 0xff
+	default:
+		return ExitFailure, errors.New("exit: too many arguments")
+	}
+
+	// An interactive shell warns about its jobs once, and leaves if exit is
+	// run again straight away
+	if state.Interactive && !state.subshell && !state.ExitWarned {
+		if warning := c.jobsWarning(); warning != "" {
+			state.ExitWarned = true
+			return ExitFailure, errors.New(warning)
+		}
+	}
+	return status, &ExitError{Status: status}
+}
+
+// jobsWarning returns the warning exit gives when jobs are stopped or
+// running, or "" if there are none
+func (c *ExitCommand) jobsWarning() string {
+	if c.Jobs == nil {
+		return ""
+	}
+	running := false
+	for _, job := range c.Jobs.Jobs() {
+		switch job.State() {
+		case jobs.Stopped:
+			return "There are stopped jobs."
+		case jobs.Running:
+			running = true
+		}
+	}
+	if running {
+		return "There are running jobs."
+	}
+	return ""
 }
 
 // HelpCommand implements the help built-in command
@@ -308,24 +443,73 @@ type HelpCommand struct {
 }
 
 // Execute implements the Command interface for HelpCommand
-func (c *HelpCommand) Execute(_ context.Context, _ *config.Config) error {
-	fmt.Println("Gosh - A modern shell written in Go")
-	fmt.Println()
-	fmt.Println("Built-in commands:")
-	fmt.Println("  cd [dir]     Change directory")
-	fmt.Println("  pwd          Print working directory")
-	fmt.Println("  exit         Exit the shell")
-	fmt.Println("  help         Show this help message")
-	fmt.Println("  history      Show command history")
-	fmt.Println("  alias        Manage command aliases")
-	fmt.Println("  export       Set environment variables")
-	fmt.Println()
-	fmt.Println("Features:")
-	fmt.Println("  - Tab completion (press Tab)")
-	fmt.Println("  - Command history (use arrow keys)")
-	fmt.Println("  - Git integration in prompt")
-	fmt.Println("  - Customizable configuration")
-	return nil
+func (c *HelpCommand) Execute(ctx context.Context, _ *config.Config) (int, error) {
+	var b strings.Builder
+	b.WriteString("Gosh - A modern shell written in Go\n")
+	b.WriteString("\n")
+	b.WriteString("Built-in commands:\n")
+	b.WriteString("  cd [dir]     Change directory\n")
+	b.WriteString("  pwd          Print working directory\n")
+	b.WriteString("  exit [n]     Exit the shell with status n, or that of the last command\n")
+	b.WriteString("  :            Do nothing and succeed, after expanding the arguments\n")
+	b.WriteString("  help         Show this help message\n")
+	b.WriteString("  history      Show command history\n")
+	b.WriteString("  alias        Manage command aliases\n")
+	b.WriteString("  export       Pass variables to the commands the shell runs (-n to stop)\n")
+	b.WriteString("  readonly     Stop variables from being changed or unset\n")
+	b.WriteString("  set          Print shell variables, set options (-e, -u, -x, -o name)\n")
+	b.WriteString("               or set the positional parameters\n")
+	b.WriteString("  env          Print the environment, or run a command with changes to it\n")
+	b.WriteString("  jobs         List background and stopped jobs\n")
+	b.WriteString("  fg, bg       Resume a job in the foreground or background\n")
+	b.WriteString("  wait         Wait for background jobs to finish\n")
+	b.WriteString("  disown       Remove a job from the job table\n")
+	b.WriteString("  kill         Send a signal, TERM by default, to processes or jobs (-l to list)\n")
+	b.WriteString("  break [n]    Leave the innermost loop, or n loops\n")
+	b.WriteString("  continue [n] Start the next pass of the innermost loop, or the nth\n")
+	b.WriteString("  local        Create variables that only exist inside a function\n")
+	b.WriteString("  return [n]   Leave a function with status n\n")
+	b.WriteString("  type         Show how each name would be run as a command (-a for all, -t, -p)\n")
+	b.WriteString("  which        Print the program each name runs from PATH (-a for all)\n")
+	b.WriteString("  command      Run a built-in or program, skipping functions, or describe\n")
+	b.WriteString("               names (-v, -V)\n")
+	b.WriteString("  builtin      Run a built-in, even if a function has its name\n")
+	b.WriteString("  hash         List, add (-p) or forget (-d, -r) the programs found in PATH\n")
+	b.WriteString("  declare      Give variables attributes (-x, -r, -i, -a, -A) and print them (-p)\n")
+	b.WriteString("               or print function definitions (-f, -F for names only)\n")
+	b.WriteString("  unset        Remove variables, or functions with -f\n")
+	b.WriteString("  source, .    Run the commands of a file in the current shell\n")
+	b.WriteString("  test, [      Evaluate a conditional expression, as in [ -d dir ]\n")
+	b.WriteString("  shopt        Set (-s), unset (-u) or print shell options\n")
+	b.WriteString("  trap         Run a command on a signal, EXIT, ERR, DEBUG or RETURN\n")
+	b.WriteString("  echo         Print arguments (-n without a newline, -e with escapes)\n")
+	b.WriteString("  printf       Print arguments with a format (-v to assign it)\n")
+	b.WriteString("  read         Read a line into variables (-r, -p, -a, -d, -n, -t, -s)\n")
+	b.WriteString("\n")
+	b.WriteString("Features:\n")
+	b.WriteString("  - Pipelines (cmd1 | cmd2, cmd1 |& cmd2)\n")
+	b.WriteString("  - Redirections (>, >>, <, 2>, 2>&1, &>, <<<)\n")
+	b.WriteString("  - Here-documents (<<EOF, <<-EOF, <<'EOF')\n")
+	b.WriteString("  - Tilde expansion (~, ~user, ~+, ~-)\n")
+	b.WriteString("  - Globbing (*.go, ?, [a-z], **) and brace expansion ({a,b}, {1..5})\n")
+	b.WriteString("  - Variables (NAME=value, and VAR=value cmd for one command)\n")
+	b.WriteString("  - Arrays (arr=(a b), ${arr[@]}, ${#arr[@]}, declare -A map)\n")
+	b.WriteString("  - Parameter expansion (${VAR:-default}, ${#VAR}, ${VAR%.txt}, $?, $@)\n")
+	b.WriteString("  - Command substitution ($(cmd), `cmd`)\n")
+	b.WriteString("  - Arithmetic ($((x * 2)), (( i++ < 10 )), 16#ff)\n")
+	b.WriteString("  - Command lists (cmd1; cmd2, cmd1 && cmd2, cmd1 || cmd2)\n")
+	b.WriteString("  - Grouping with ( ... ) subshells and { ...; } blocks\n")
+	b.WriteString("  - Control flow (if, while, until, for, for ((...)), case)\n")
+	b.WriteString("  - Conditionals ([[ $f == *.go && -r $f ]], [[ $s =~ ^v([0-9]+) ]])\n")
+	b.WriteString("  - Functions (name() { ...; }, function name { ...; }) with local and return\n")
+	b.WriteString("  - Background jobs (cmd &) and job control (Ctrl+Z, fg, bg)\n")
+	b.WriteString("  - Tab completion (press Tab)\n")
+	b.WriteString("  - Command history (use arrow keys)\n")
+	b.WriteString("  - Git integration in prompt\n")
+	b.WriteString("  - Customizable configuration\n")
+
+	_, err := io.WriteString(IOFromContext(ctx).Stdout, b.String())
+	return builtinStatus(err)
 }
 
 // HistoryCommand implements the history built-in command
@@ -335,131 +519,203 @@ type HistoryCommand struct {
 }
 
 // Execute implements the Command interface for HistoryCommand
-func (c *HistoryCommand) Execute(_ context.Context, _ *config.Config) error {
+func (c *HistoryCommand) Execute(ctx context.Context, _ *config.Config) (int, error) {
+	out := IOFromContext(ctx).Stdout
+
 	if c.Manager == nil {
-		fmt.Println("History functionality not available")
-		return nil
+		_, err := fmt.Fprintln(out, "History functionality not available")
+		return builtinStatus(err)
 	}
 
 	if len(c.Args) == 0 {
 		// Show all history
 		entries := c.Manager.GetAll()
 		for i, entry := range entries {
-			fmt.Printf("%4d  %s\n", i+1, entry.GetCommand())
+			if _, err := fmt.Fprintf(out, "%4d  %s\n", i+1, entry.GetCommand()); err != nil {
+				return ExitFailure, err
+			}
 		}
-		return nil
+		return 0, nil
 	}
 
 	// Handle history subcommands
 	switch c.Args[0] {
 	case "-c", "clear":
-		return c.Manager.Clear()
+		return builtinStatus(c.Manager.Clear())
 	default:
 		// Try to parse as number for recent entries
 		if n, err := strconv.Atoi(c.Args[0]); err == nil {
 			entries := c.Manager.GetRecent(n)
 			for i, entry := range entries {
-				fmt.Printf("%4d  %s\n", len(c.Manager.GetAll())-len(entries)+i+1, entry.GetCommand())
+				if _, err := fmt.Fprintf(out, "%4d  %s\n", len(c.Manager.GetAll())-len(entries)+i+1, entry.GetCommand()); err != nil {
+					return ExitFailure, err
+				}
 			}
-			return nil
+			return 0, nil
 		}
 
 		// Search for term
 		entries := c.Manager.Search(c.Args[0])
 		for _, entry := range entries {
-			fmt.Printf("  %s\n", entry.GetCommand())
+			if _, err := fmt.Fprintf(out, "  %s\n", entry.GetCommand()); err != nil {
+				return ExitFailure, err
+			}
 		}
 	}
 
-	return nil
+	return 0, nil
 }
 
-// AliasCommand implements the alias built-in command
+// AliasCommand implements the alias built-in command. Each argument of the
+// form name=value defines an alias; a name alone prints its alias. Without
+// arguments, or with -p, every alias is printed. The arguments have had
+// their quotes removed already, so value is the text as written.
 type AliasCommand struct {
 	Args   []string
 	Config *config.Config
 }
 
 // Execute implements the Command interface for AliasCommand
-func (c *AliasCommand) Execute(_ context.Context, _ *config.Config) error {
-	if len(c.Args) == 0 {
-		// Show all aliases
-		for name, value := range c.Config.Aliases {
-			fmt.Printf("alias %s='%s'\n", name, value)
-		}
-		return nil
+func (c *AliasCommand) Execute(ctx context.Context, _ *config.Config) (int, error) {
+	out := IOFromContext(ctx).Stdout
+	args := c.Args
+	if len(args) > 0 && args[0] == "-p" {
+		args = args[1:]
 	}
-
-	// Parse alias definition
-	arg := strings.Join(c.Args, " ")
-	parts := strings.SplitN(arg, "=", KeyValueParts)
-	if len(parts) != KeyValueParts {
-		return fmt.Errorf("alias: invalid format, use: alias name=value")
+	if len(args) == 0 {
+		names := make([]string, 0, len(c.Config.Aliases))
+		for name := range c.Config.Aliases {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		args = names
 	}
 
-	name := strings.TrimSpace(parts[0])
-	value := strings.Trim(strings.TrimSpace(parts[1]), "\"'")
+	status := 0
+	for _, arg := range args {
+		if name, value, ok := strings.Cut(arg, "="); ok && name != "" {
+			c.Config.Aliases[name] = value
+			continue
+		}
 
-	c.Config.Aliases[name] = value
-	return nil
+		value, ok := c.Config.Aliases[arg]
+		if !ok {
+			reportError(ctx, fmt.Errorf("alias: %s: not found", arg))
+			status = ExitFailure
+			continue
+		}
+		if _, err := fmt.Fprintf(out, "alias %s=%s\n", arg, quoteValue(value)); err != nil {
+			return ExitFailure, err
+		}
+	}
+	return status, nil
 }
 
-// ExportCommand implements the export built-in command
-type ExportCommand struct {
-	Args   []string
-	Config *config.Config
+// ExternalCommand represents an external command
+type ExternalCommand struct {
+	Name string
+	Args []string
+	// Env is the environment of the process, in key=value form. If it is
+	// nil the process gets the exported shell variables.
+	Env []string
+	// Path is the program to run, if it was found already. Otherwise Name
+	// is looked for in PATH.
+	Path string
 }
 
-// Execute implements the Command interface for ExportCommand
-func (c *ExportCommand) Execute(_ context.Context, _ *config.Config) error {
-	if len(c.Args) == 0 {
-		// Show all environment variables
-		for key, value := range c.Config.Environment {
-			fmt.Printf("export %s='%s'\n", key, value)
-		}
-		return nil
+// Execute implements the Command interface for ExternalCommand
+func (c *ExternalCommand) Execute(ctx context.Context, cfg *config.Config) (int, error) {
+	env, path := c.Env, ""
+	if env == nil {
+		env = cfg.Variables.Environ()
+		path, _ = cfg.Variables.Lookup("PATH")
+	} else {
+		path, _ = config.VariablesFromEnviron(env).Lookup("PATH")
 	}
 
-	// Parse export definition
-	arg := strings.Join(c.Args, " ")
-	parts := strings.SplitN(arg, "=", KeyValueParts)
-	if len(parts) != KeyValueParts {
-		return fmt.Errorf("export: invalid format, use: export NAME=value")
+	dir := StateFromContext(ctx).WorkDir()
+	name := c.Path
+	if name == "" {
+		var err error
+		if name, err = lookPath(c.Name, path, dir); err != nil {
+			return ExitCommandNotFound, fmt.Errorf("command not found: %s", c.Name)
+		}
 	}
+	// The process starts in the shell's directory, which is not always
+	// that of gosh, so a relative program is found from there too
+	name = inDir(dir, name)
 
-	name := strings.TrimSpace(parts[0])
-	value := strings.Trim(strings.TrimSpace(parts[1]), "\"'")
+	streams := IOFromContext(ctx)
+	extra, finish, err := extraFiles(streams)
+	if err != nil {
+		return ExitFailure, fmt.Errorf("failed to execute '%s': %w", c.Name, err)
+	}
+	defer finish()
+
+	build := func() *exec.Cmd {
+		cmd := exec.CommandContext(ctx, name, c.Args...)
+		// An interrupted command is sent SIGINT, as with Ctrl+C, rather
+		// than killed, so that it can clean up
+		cmd.Cancel = func() error {
+			if err := cmd.Process.Signal(os.Interrupt); err != nil {
+				return cmd.Process.Kill()
+			}
+			return nil
+		}
+		cmd.Args[0] = c.Name
+		cmd.Dir = dir
+		cmd.Env = env
+		cmd.Stdin = processStream(streams.Stdin)
+		cmd.Stdout = processStream(streams.Stdout)
+		cmd.Stderr = processStream(streams.Stderr)
+		cmd.ExtraFiles = extra
+		return cmd
+	}
 
-	c.Config.Environment[name] = value
-	if err := os.Setenv(name, value); err != nil {
-		return fmt.Errorf("failed to set environment variable %s: %w", name, err)
+	// The process joins the job running this command, if any
+	status, err := jobs.FromContext(ctx).Run(build)
+	if err != nil {
+		return ExitFailure, fmt.Errorf("failed to execute '%s': %w", c.Name, err)
 	}
-	return nil
+	return processExitCode(status), nil
 }
 
-// ExternalCommand represents an external command
-type ExternalCommand struct {
-	Name string
-	Args []string
+// lookPath finds the program a command name runs in the directories of
+// path, which is the shell's PATH rather than that of the gosh process.
+// Relative directories are taken from dir, the shell's current directory.
+// A name containing a slash is used as it is.
+func lookPath(name, path, dir string) (string, error) {
+	if hasPathSeparator(name) {
+		return name, nil
+	}
+	if found := searchPath(name, path, dir, false); len(found) > 0 {
+		return found[0], nil
+	}
+	return "", &exec.Error{Name: name, Err: exec.ErrNotFound}
 }
 
-// Execute implements the Command interface for ExternalCommand
-func (c *ExternalCommand) Execute(ctx context.Context, _ *config.Config) error {
-	cmd := exec.CommandContext(ctx, c.Name, c.Args...)
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+// searchPath returns the first program called name in the directories of
+// path, or with all set every one in order. Programs in relative
+// directories, including the empty entry for the current directory, are
+// looked for from dir and returned as absolute paths. A name containing a
+// slash is returned if it is an executable file.
+func searchPath(name, path, dir string, all bool) []string {
+	if hasPathSeparator(name) {
+		if _, err := exec.LookPath(inDir(dir, name)); err != nil {
+			return nil
+		}
+		return []string{name}
+	}
 
-	err := cmd.Run()
-	if err != nil {
-		// Provide more user-friendly error messages
-		if exitError, ok := err.(*exec.ExitError); ok {
-			return fmt.Errorf("command '%s' exited with code %d", c.Name, exitError.ExitCode())
+	var found []string
+	for _, entry := range filepath.SplitList(path) {
+		program, err := exec.LookPath(inDir(dir, filepath.Join(entry, name)))
+		if err != nil || slices.Contains(found, program) {
+			continue
 		}
-		if err.Error() == "exec: \""+c.Name+"\": executable file not found in $PATH" {
-			return fmt.Errorf("command not found: %s", c.Name)
+		if found = append(found, program); !all {
+			break
 		}
-		return fmt.Errorf("failed to execute '%s': %w", c.Name, err)
 	}
-	return nil
+	return found
 }
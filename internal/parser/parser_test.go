@@ -1,13 +1,16 @@
 package parser
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 
 	"gosh/internal/config"
+	"gosh/internal/lexer"
 )
 
 func TestTokenize(t *testing.T) {
@@ -72,25 +75,65 @@ func TestTokenize(t *testing.T) {
 			expected: []string{"echo", `hello "world"`},
 			wantErr:  false,
 		},
+		{
+			name:     "pipe operator",
+			input:    "ps aux|grep go",
+			expected: []string{"ps", "aux", "|", "grep", "go"},
+			wantErr:  false,
+		},
+		{
+			name:     "pipe with stderr",
+			input:    "make |& less",
+			expected: []string{"make", "|&", "less"},
+			wantErr:  false,
+		},
+		{
+			name:     "quoted pipe",
+			input:    `echo "a|b" 'c | d'`,
+			expected: []string{"echo", "a|b", "c | d"},
+			wantErr:  false,
+		},
+		{
+			name:     "empty quoted argument",
+			input:    `echo ""`,
+			expected: []string{"echo", ""},
+			wantErr:  false,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			tokens, err := parser.tokenize(tt.input)
+			tokens, err := lexer.Tokenize(tt.input)
 			if (err != nil) != tt.wantErr {
-				t.Errorf("tokenize() error = %v, wantErr %v", err, tt.wantErr)
+				t.Errorf("Tokenize() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 
 			if !tt.wantErr {
-				if len(tokens) != len(tt.expected) {
-					t.Errorf("tokenize() got %d tokens, want %d", len(tokens), len(tt.expected))
+				// Words are compared after expansion and quote removal
+				values := []string{}
+				for _, token := range tokens {
+					switch token.Kind {
+					case lexer.EOF:
+					case lexer.Word:
+						fields, err := parser.expandWord(context.Background(), token.Value)
+						if err != nil {
+							t.Fatalf("expandWord() failed: %v", err)
+						}
+						values = append(values, fields...)
+					default:
+						values = append(values, token.Value)
+					}
+				}
+
+				if len(values) != len(tt.expected) {
+					t.Errorf("Tokenize() got %d tokens, want %d", len(values), len(tt.expected))
 					return
 				}
 
-				for i, token := range tokens {
-					if token != tt.expected[i] {
-						t.Errorf("tokenize() token[%d] = %q, want %q", i, token, tt.expected[i])
+				for i, value := range values {
+					if value != tt.expected[i] {
+						t.Errorf("Tokenize() token[%d] = %q, want %q", i, value, tt.expected[i])
 					}
 				}
 			}
@@ -102,6 +145,7 @@ func TestExpandAlias(t *testing.T) {
 	cfg := config.Default()
 	cfg.Aliases["ll"] = "ls -la"
 	cfg.Aliases["gs"] = "git status"
+	cfg.Aliases["ls"] = "ls --color"
 
 	parser := New(cfg)
 
@@ -109,42 +153,47 @@ func TestExpandAlias(t *testing.T) {
 		name     string
 		input    string
 		expected string
-		expanded bool
 	}{
 		{
 			name:     "expand ll alias",
 			input:    "ll /home",
-			expected: "ls -la /home",
-			expanded: true,
+			expected: "ls --color -la /home",
 		},
 		{
 			name:     "expand gs alias",
 			input:    "gs",
 			expected: "git status",
-			expanded: true,
 		},
 		{
-			name:     "no alias",
+			name:     "alias referring to itself",
 			input:    "ls -la",
-			expected: "ls -la",
-			expanded: false,
+			expected: "ls --color -la",
 		},
 		{
-			name:     "empty input",
-			input:    "",
-			expected: "",
-			expanded: false,
+			name:     "not in command position",
+			input:    "echo ll",
+			expected: "echo ll",
+		},
+		{
+			name:     "quoted command name",
+			input:    "'ll' /home",
+			expected: "'ll' /home",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, expanded := parser.expandAlias(tt.input)
-			if result != tt.expected {
-				t.Errorf("expandAlias() result = %q, want %q", result, tt.expected)
+			cmd, err := parser.Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			simple, ok := cmd.(*SimpleCommand)
+			if !ok {
+				t.Fatalf("Parse() returned %T, want *SimpleCommand", cmd)
 			}
-			if expanded != tt.expanded {
-				t.Errorf("expandAlias() expanded = %v, want %v", expanded, tt.expanded)
+			if got := strings.Join(simple.Words, " "); got != tt.expected {
+				t.Errorf("Parse() words = %q, want %q", got, tt.expected)
 			}
 		})
 	}
@@ -251,7 +300,7 @@ func TestCdCommand(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			cmd := &CdCommand{Args: tt.args}
-			err := cmd.Execute(context.Background(), config.Default())
+			_, err := cmd.Execute(context.Background(), config.Default())
 
 			if (err != nil) != tt.wantErr {
 				t.Errorf("CdCommand.Execute() error = %v, wantErr %v", err, tt.wantErr)
@@ -300,8 +349,13 @@ func TestCdCommandTildeExpansion(t *testing.T) {
 	os.Mkdir(testDir, 0755)
 	defer os.RemoveAll(testDir)
 
-	cmd := &CdCommand{Args: []string{"~/gosh_test_dir"}}
-	err = cmd.Execute(context.Background(), config.Default())
+	// The tilde is expanded with the other words of the command
+	cfg := config.Default()
+	cmd, err := New(cfg).Parse("cd ~/gosh_test_dir")
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+	_, err = cmd.Execute(context.Background(), cfg)
 	if err != nil {
 		t.Errorf("CdCommand.Execute() with tilde expansion failed: %v", err)
 	}
@@ -318,63 +372,185 @@ func TestCdCommandTildeExpansion(t *testing.T) {
 
 func TestPwdCommand(t *testing.T) {
 	cmd := &PwdCommand{}
-	err := cmd.Execute(context.Background(), config.Default())
+	_, err := cmd.Execute(context.Background(), config.Default())
 	if err != nil {
 		t.Errorf("PwdCommand.Execute() failed: %v", err)
 	}
 }
 
+func TestExitCommand(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		interactive bool
+		wantOutput  string
+		wantStderr  string
+		wantExit    bool
+		wantStatus  int
+	}{
+		{
+			name:       "exit uses the status of the last command",
+			input:      "false; exit; echo no",
+			wantExit:   true,
+			wantStatus: 1,
+		},
+		{
+			name:       "exit with a status",
+			input:      "exit 3; echo no",
+			wantExit:   true,
+			wantStatus: 3,
+		},
+		{
+			name:       "exit keeps the low 8 bits",
+			input:      "exit 300",
+			wantExit:   true,
+			wantStatus: 44,
+		},
+		{
+			name:       "exit leaves from a function",
+			input:      "f() { exit 5; echo no; }; f; echo no",
+			wantExit:   true,
+			wantStatus: 5,
+		},
+		{
+			name:       "exit in a subshell ends only the subshell",
+			input:      "(exit 4); echo $?; echo $(exit 6; echo no)",
+			wantOutput: "4\n\n",
+		},
+		{
+			name:       "exit with a non-numeric argument",
+			input:      "exit abc; echo no",
+			wantStderr: "exit: abc: numeric argument required",
+			wantExit:   true,
+			wantStatus: 2,
+		},
+		{
+			name:       "exit with too many arguments does not exit",
+			input:      "exit 1 2; echo $?",
+			wantOutput: "1\n",
+			wantStderr: "exit: too many arguments",
+		},
+		{
+			name:        "exit warns about running jobs once",
+			input:       "sleep 1 & exit; echo $?; exit 7",
+			interactive: true,
+			wantOutput:  "1\n",
+			wantStderr:  "There are running jobs.",
+			wantExit:    true,
+			wantStatus:  7,
+		},
+		{
+			name:        "exit in a subshell does not warn about jobs",
+			input:       "sleep 1 & (exit 3); echo $?",
+			interactive: true,
+			wantOutput:  "3\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			parser := New(cfg)
+
+			cmd, err := parser.Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse() failed: %v", err)
+			}
+
+			var stdout, stderr bytes.Buffer
+			state := NewState(DefaultShellName, nil)
+			state.Interactive = tt.interactive
+			ctx := WithIO(context.Background(), IO{Stdin: strings.NewReader(""), Stdout: &stdout, Stderr: &stderr})
+			ctx = WithState(ctx, state)
+			status, err := cmd.Execute(ctx, cfg)
+
+			var exit *ExitError
+			if errors.As(err, &exit) != tt.wantExit {
+				t.Fatalf("Execute() error = %v, want exit %v", err, tt.wantExit)
+			}
+			if tt.wantExit && exit.Status != tt.wantStatus {
+				t.Errorf("exit status = %d, want %d", exit.Status, tt.wantStatus)
+			}
+			if !tt.wantExit && status != tt.wantStatus {
+				t.Errorf("status = %d, want %d", status, tt.wantStatus)
+			}
+			if stdout.String() != tt.wantOutput {
+				t.Errorf("stdout = %q, want %q", stdout.String(), tt.wantOutput)
+			}
+			if !strings.Contains(stderr.String(), tt.wantStderr) {
+				t.Errorf("stderr = %q, want it to contain %q", stderr.String(), tt.wantStderr)
+			}
+		})
+	}
+}
+
 func TestHelpCommand(t *testing.T) {
 	cmd := &HelpCommand{Args: []string{}}
-	err := cmd.Execute(context.Background(), config.Default())
+	_, err := cmd.Execute(context.Background(), config.Default())
 	if err != nil {
 		t.Errorf("HelpCommand.Execute() failed: %v", err)
 	}
 }
 
 func TestAliasCommand(t *testing.T) {
-	cfg := config.Default()
-
 	tests := []struct {
-		name    string
-		args    []string
-		wantErr bool
+		name       string
+		input      string
+		wantStatus int
+		wantOutput string
+		wantStderr string
 	}{
 		{
-			name:    "show all aliases",
-			args:    []string{},
-			wantErr: false,
+			name:       "define and print one alias",
+			input:      "alias ll='ls -l'; alias ll",
+			wantOutput: "alias ll='ls -l'\n",
 		},
 		{
-			name:    "create new alias",
-			args:    []string{"test=echo hello"},
-			wantErr: false,
+			name:       "quotes in the value are kept",
+			input:      "alias x='echo \"a  b\"'; alias x; x",
+			wantOutput: "alias x='echo \"a  b\"'\na  b\n",
 		},
 		{
-			name:    "invalid alias format",
-			args:    []string{"invalid"},
-			wantErr: true,
+			name:       "several definitions at once",
+			input:      "alias a=true b='echo b'; alias",
+			wantOutput: "alias a='true'\nalias b='echo b'\n",
+		},
+		{
+			name:       "unknown alias",
+			input:      "alias nope",
+			wantStatus: ExitFailure,
+			wantStderr: "alias: nope: not found",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			cmd := &AliasCommand{Args: tt.args, Config: cfg}
-			err := cmd.Execute(context.Background(), cfg)
-
-			if (err != nil) != tt.wantErr {
-				t.Errorf("AliasCommand.Execute() error = %v, wantErr %v", err, tt.wantErr)
+			cfg := config.Default()
+			cfg.Aliases = map[string]string{}
+			var stdout, stderr bytes.Buffer
+			ctx := WithIO(context.Background(), IO{Stdout: &stdout, Stderr: &stderr})
+
+			// Each line is parsed after the one before has run, so that
+			// the aliases it defines apply
+			var status int
+			for _, line := range strings.Split(tt.input, "; ") {
+				cmd, err := New(cfg).Parse(line)
+				if err != nil {
+					t.Fatalf("Parse(%q) failed: %v", line, err)
+				}
+				if status, err = cmd.Execute(ctx, cfg); err != nil {
+					t.Fatalf("Execute(%q) failed: %v", line, err)
+				}
 			}
 
-			// Check if alias was created
-			if !tt.wantErr && len(tt.args) > 0 && strings.Contains(tt.args[0], "=") {
-				parts := strings.SplitN(tt.args[0], "=", 2)
-				aliasName := parts[0]
-				expectedValue := parts[1]
-
-				if cfg.Aliases[aliasName] != expectedValue {
-					t.Errorf("Expected alias %s=%s, got %s", aliasName, expectedValue, cfg.Aliases[aliasName])
-				}
+			if status != tt.wantStatus {
+				t.Errorf("status = %d, want %d", status, tt.wantStatus)
+			}
+			if stdout.String() != tt.wantOutput {
+				t.Errorf("stdout = %q, want %q", stdout.String(), tt.wantOutput)
+			}
+			if !strings.Contains(stderr.String(), tt.wantStderr) {
+				t.Errorf("stderr = %q, want it to contain %q", stderr.String(), tt.wantStderr)
 			}
 		})
 	}
@@ -399,34 +575,41 @@ func TestExportCommand(t *testing.T) {
 			wantErr: false,
 		},
 		{
-			name:    "invalid export format",
-			args:    []string{"invalid"},
+			name:    "export without a value",
+			args:    []string{"TEST_DECLARED"},
+			wantErr: false,
+		},
+		{
+			name:    "invalid identifier",
+			args:    []string{"1invalid=x"},
 			wantErr: true,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			cmd := &ExportCommand{Args: tt.args, Config: cfg}
-			err := cmd.Execute(context.Background(), cfg)
+			var out bytes.Buffer
+			ctx := WithIO(context.Background(), IO{Stdin: strings.NewReader(""), Stdout: &out, Stderr: &out})
+			cmd := &ExportCommand{Args: tt.args, parser: New(cfg)}
+			_, err := cmd.Execute(ctx, cfg)
 
 			if (err != nil) != tt.wantErr {
 				t.Errorf("ExportCommand.Execute() error = %v, wantErr %v", err, tt.wantErr)
 			}
 
-			// Check if environment variable was set
-			if !tt.wantErr && len(tt.args) > 0 && strings.Contains(tt.args[0], "=") {
-				parts := strings.SplitN(tt.args[0], "=", 2)
-				varName := parts[0]
-				expectedValue := parts[1]
+			// Check if the variable was exported
+			if !tt.wantErr && len(tt.args) > 0 {
+				varName, expectedValue, _ := strings.Cut(tt.args[0], "=")
 
-				if cfg.Environment[varName] != expectedValue {
-					t.Errorf("Expected environment %s=%s, got %s", varName, expectedValue, cfg.Environment[varName])
+				v, _ := cfg.Variables.Get(varName)
+				if v.Value != expectedValue || !v.Has(config.AttrExport) {
+					t.Errorf("Expected exported %s=%s, got %+v", varName, expectedValue, v)
 				}
 
-				// Check if it was set in the actual environment
-				if os.Getenv(varName) != expectedValue {
-					t.Errorf("Expected os environment %s=%s, got %s", varName, expectedValue, os.Getenv(varName))
+				// The process environment is left alone: only commands run
+				// by the shell see the variable
+				if _, ok := os.LookupEnv(varName); ok {
+					t.Errorf("Export set %s in the process environment", varName)
 				}
 			}
 		})
@@ -435,7 +618,7 @@ func TestExportCommand(t *testing.T) {
 
 func TestNoOpCommand(t *testing.T) {
 	cmd := &NoOpCommand{}
-	err := cmd.Execute(context.Background(), config.Default())
+	_, err := cmd.Execute(context.Background(), config.Default())
 	if err != nil {
 		t.Errorf("NoOpCommand.Execute() failed: %v", err)
 	}
@@ -0,0 +1,151 @@
+package parser
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// patternSpecial are the characters with a meaning in a shell pattern
+const patternSpecial = "*?[\\"
+
+// charClasses are the named classes allowed in a bracket expression, as in
+// [[:alpha:]]
+var charClasses = map[string]func(rune) bool{
+	"alnum":  func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) },
+	"alpha":  unicode.IsLetter,
+	"blank":  func(r rune) bool { return r == ' ' || r == '\t' },
+	"cntrl":  unicode.IsControl,
+	"digit":  unicode.IsDigit,
+	"graph":  func(r rune) bool { return unicode.IsGraphic(r) && !unicode.IsSpace(r) },
+	"lower":  unicode.IsLower,
+	"print":  unicode.IsPrint,
+	"punct":  unicode.IsPunct,
+	"space":  unicode.IsSpace,
+	"upper":  unicode.IsUpper,
+	"xdigit": func(r rune) bool { return strings.ContainsRune("0123456789abcdefABCDEF", r) },
+}
+
+// matchPattern reports whether all of s matches the shell pattern. * matches
+// any string, ? any character and [...] any character in the bracket
+// expression; a backslash makes the next character literal. Unlike
+// path.Match, * and ? also match a slash.
+func matchPattern(pattern, s string) bool {
+	px, sx := 0, 0
+	// After a *, starP is where the pattern continues and starS is where
+	// the text it matches ends, so that the * can take one more character
+	// when the rest of the pattern fails to match
+	starP, starS := -1, 0
+
+	for px < len(pattern) || sx < len(s) {
+		if px < len(pattern) {
+			switch pattern[px] {
+			case '*':
+				px++
+				starP, starS = px, sx
+				continue
+			case '?':
+				if sx < len(s) {
+					_, size := utf8.DecodeRuneInString(s[sx:])
+					px++
+					sx += size
+					continue
+				}
+			case '[':
+				if sx < len(s) {
+					r, size := utf8.DecodeRuneInString(s[sx:])
+					matched, end, ok := matchBracket(pattern, px, r)
+					if !ok {
+						// An unterminated bracket is an ordinary character
+						matched, end = r == '[', px+1
+					}
+					if matched {
+						px = end
+						sx += size
+						continue
+					}
+				}
+			default:
+				lit, width := patternLiteral(pattern, px)
+				if sx < len(s) {
+					r, size := utf8.DecodeRuneInString(s[sx:])
+					if r == lit {
+						px += width
+						sx += size
+						continue
+					}
+				}
+			}
+		}
+
+		if starP >= 0 && starS < len(s) {
+			_, size := utf8.DecodeRuneInString(s[starS:])
+			starS += size
+			px, sx = starP, starS
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// patternLiteral returns the literal character at pattern[i], which may be
+// escaped with a backslash, and the number of bytes it takes
+func patternLiteral(pattern string, i int) (rune, int) {
+	if pattern[i] == '\\' && i+1 < len(pattern) {
+		r, size := utf8.DecodeRuneInString(pattern[i+1:])
+		return r, size + 1
+	}
+	return utf8.DecodeRuneInString(pattern[i:])
+}
+
+// matchBracket matches r against the bracket expression starting at
+// pattern[start], which is a '['. It returns whether r matched and the end
+// of the expression; ok is false if the expression is not terminated.
+func matchBracket(pattern string, start int, r rune) (matched bool, end int, ok bool) {
+	i := start + 1
+	negate := false
+	if i < len(pattern) && (pattern[i] == '!' || pattern[i] == '^') {
+		negate = true
+		i++
+	}
+
+	for first := true; i < len(pattern); first = false {
+		if pattern[i] == ']' && !first {
+			return matched != negate, i + 1, true
+		}
+
+		if strings.HasPrefix(pattern[i:], "[:") {
+			if n := strings.Index(pattern[i+2:], ":]"); n >= 0 {
+				if class, known := charClasses[pattern[i+2:i+2+n]]; known {
+					matched = matched || class(r)
+					i += n + 4
+					continue
+				}
+			}
+		}
+
+		lo, width := patternLiteral(pattern, i)
+		i += width
+		hi := lo
+		if i+1 < len(pattern) && pattern[i] == '-' && pattern[i+1] != ']' {
+			hi, width = patternLiteral(pattern, i+1)
+			i += width + 1
+		}
+		matched = matched || (lo <= r && r <= hi)
+	}
+	return false, 0, false
+}
+
+// escapePattern escapes the pattern characters in s so that it only
+// matches itself
+func escapePattern(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(patternSpecial, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,45 @@
+package parser
+
+import "testing"
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		s       string
+		want    bool
+	}{
+		{"", "", true},
+		{"abc", "abc", true},
+		{"abc", "abd", false},
+		{"*", "", true},
+		{"*", "a/b", true},
+		{"a*c", "abbbc", true},
+		{"a*c", "abbbd", false},
+		{"*.go", "main.go", true},
+		{"*.go", "main.go.bak", false},
+		{"a?c", "abc", true},
+		{"a?c", "ac", false},
+		{"?", "é", true},
+		{"[abc]x", "bx", true},
+		{"[abc]x", "dx", false},
+		{"[a-c]", "b", true},
+		{"[!a-c]", "b", false},
+		{"[^a-c]", "d", true},
+		{"[]]", "]", true},
+		{"[a-]", "-", true},
+		{"[[:digit:]]*", "1abc", true},
+		{"[[:upper:]]", "a", false},
+		{"[", "[", true},
+		{`\*`, "*", true},
+		{`\*`, "a", false},
+		{`a\`, `a\`, true},
+		{"*a*b*", "xxaxxbxx", true},
+		{"*a*b", "xxaxxbxxc", false},
+	}
+
+	for _, tt := range tests {
+		if got := matchPattern(tt.pattern, tt.s); got != tt.want {
+			t.Errorf("matchPattern(%q, %q) = %v, want %v", tt.pattern, tt.s, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"syscall"
+
+	"gosh/internal/config"
+)
+
+const (
+	// ExitFailure is the general status of a command that failed
+	ExitFailure = 1
+	// ExitUsage is the status of a built-in given invalid arguments, such
+	// as test with a malformed expression
+	ExitUsage = 2
+	// ExitCommandNotFound is the status reported when a command cannot be found
+	ExitCommandNotFound = 127
+	// ExitSignalBase is added to a signal number to form the status of a
+	// command killed by that signal
+	ExitSignalBase = 128
+)
+
+// builtinStatus returns the result of a built-in command that failed with
+// err, or succeeded if err is nil
+func builtinStatus(err error) (int, error) {
+	if err != nil {
+		return ExitFailure, err
+	}
+	return 0, nil
+}
+
+// processExitCode returns the status of a finished process, following the
+// shell convention of 128+N for a process killed by signal N. A process
+// stopped by signal N, which went on as a job of its own, also has 128+N.
+func processExitCode(status syscall.WaitStatus) int {
+	switch {
+	case status.Signaled():
+		return ExitSignalBase + int(status.Signal())
+	case status.Stopped():
+		return ExitSignalBase + int(status.StopSignal())
+	}
+	return status.ExitStatus()
+}
+
+// PipelineCommand connects the stdout of each stage to the stdin of the next
+type PipelineCommand struct {
+	Stages []Command
+	// PipeStderr marks stages joined to the next one with |&, which sends
+	// their stderr down the pipe as well
+	PipeStderr []bool
+}
+
+// Execute implements the Command interface for PipelineCommand.
+// All stages run concurrently. The pipeline's result is that of the last
+// stage, or of the rightmost failing stage when pipefail is enabled, and the
+// status of every stage is recorded in PIPESTATUS.
+func (c *PipelineCommand) Execute(ctx context.Context, cfg *config.Config) (int, error) {
+	streams := IOFromContext(ctx)
+	codes := make([]int, len(c.Stages))
+	errs := make([]error, len(c.Stages))
+
+	var wg sync.WaitGroup
+	// readEnd is the read end of the previous stage's pipe, which belongs
+	// to the current stage
+	var readEnd *os.File
+	for i, stage := range c.Stages {
+		stageIO := streams
+		if readEnd != nil {
+			stageIO.Stdin = readEnd
+		}
+
+		var nextRead, writeEnd *os.File
+		if i < len(c.Stages)-1 {
+			r, w, err := os.Pipe()
+			if err != nil {
+				closeFile(readEnd)
+				wg.Wait()
+				return ExitFailure, fmt.Errorf("pipe: %w", err)
+			}
+			stageIO.Stdout = w
+			if c.PipeStderr[i] {
+				stageIO.Stderr = w
+			}
+			nextRead, writeEnd = r, w
+		}
+
+		wg.Add(1)
+		go func(i int, stage Command, stageIO IO, readEnd, writeEnd *os.File) {
+			defer wg.Done()
+			// Like a subshell, each stage has its own parameters and
+			// variables
+			stageCtx, stop := context.WithCancelCause(withStateClone(ctx))
+			defer stop(nil)
+			stageCtx = context.WithValue(WithIO(stageCtx, stageIO), brokenPipeKey{}, stop)
+			codes[i], errs[i] = runSubshell(stageCtx, cfg.Clone(), stage)
+			if errors.Is(errs[i], syscall.EPIPE) || errors.Is(context.Cause(stageCtx), syscall.EPIPE) {
+				// Like a process killed by SIGPIPE, a stage that wrote to
+				// a pipe nobody reads any more stops quietly
+				codes[i], errs[i] = ExitSignalBase+int(syscall.SIGPIPE), nil
+			}
+			// Closing our ends lets the neighbors see EOF or EPIPE
+			closeFile(writeEnd)
+			closeFile(readEnd)
+		}(i, stage, stageIO, readEnd, writeEnd)
+		readEnd = nextRead
+	}
+	wg.Wait()
+
+	result := len(codes) - 1
+	for i, status := range codes {
+		if cfg.Pipefail && status != 0 {
+			result = i
+		}
+	}
+	reportError(ctx, setPipeStatus(cfg, codes...))
+
+	// Only the chosen error is reported by the caller, so surface the others
+	// the way a shell would print them from each stage
+	for i, err := range errs {
+		if i != result {
+			reportError(ctx, err)
+		}
+	}
+
+	return checkErrexit(ctx, cfg, codes[result], errs[result])
+}
+
+// setPipeStatus records the exit status of each stage of a pipeline in the
+// PIPESTATUS array. A simple command counts as a pipeline of one stage.
+func setPipeStatus(cfg *config.Config, codes ...int) error {
+	elements := make(map[string]string, len(codes))
+	for i, status := range codes {
+		elements[strconv.Itoa(i)] = strconv.Itoa(status)
+	}
+	return cfg.Variables.SetArray("PIPESTATUS", elements)
+}
+
+// brokenPipeKey is the context key under which a pipeline stage keeps the
+// function that stops it once it has written to a closed pipe
+type brokenPipeKey struct{}
+
+// stopOnBrokenPipe stops the pipeline stage running in ctx if err is a
+// write to a pipe that was closed, and reports whether it was. The stage
+// ends as a process would on SIGPIPE, without a message.
+func stopOnBrokenPipe(ctx context.Context, err error) bool {
+	if !errors.Is(err, syscall.EPIPE) {
+		return false
+	}
+	if stop, ok := ctx.Value(brokenPipeKey{}).(context.CancelCauseFunc); ok {
+		stop(syscall.EPIPE)
+	}
+	return true
+}
+
+// closeFile closes f if it is set, ignoring errors from an already closed pipe
+func closeFile(f *os.File) {
+	if f != nil {
+		_ = f.Close()
+	}
+}
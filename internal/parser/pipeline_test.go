@@ -0,0 +1,290 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"gosh/internal/config"
+)
+
+func TestParsePipeline(t *testing.T) {
+	parser := New(config.Default())
+
+	tests := []struct {
+		name       string
+		input      string
+		stages     int
+		pipeStderr []bool
+		wantErr    bool
+	}{
+		{
+			name:   "single command is not a pipeline",
+			input:  "ls -la",
+			stages: 0,
+		},
+		{
+			name:       "two stages",
+			input:      "ps aux | grep go",
+			stages:     2,
+			pipeStderr: []bool{false, false},
+		},
+		{
+			name:       "builtin stage",
+			input:      "history | grep git | wc -l",
+			stages:     3,
+			pipeStderr: []bool{false, false, false},
+		},
+		{
+			name:       "stderr pipe",
+			input:      "make |& tee build.log",
+			stages:     2,
+			pipeStderr: []bool{true, false},
+		},
+		{
+			name:    "leading pipe",
+			input:   "| grep go",
+			wantErr: true,
+		},
+		{
+			name:    "trailing pipe",
+			input:   "ps aux |",
+			wantErr: true,
+		},
+		{
+			name:    "empty stage",
+			input:   "ps aux | | grep go",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, err := parser.Parse(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			pipeline, ok := cmd.(*PipelineCommand)
+			if tt.stages == 0 {
+				if ok {
+					t.Errorf("Parse() returned a pipeline for %q", tt.input)
+				}
+				return
+			}
+			if !ok {
+				t.Fatalf("Parse() returned %T, want *PipelineCommand", cmd)
+			}
+
+			if len(pipeline.Stages) != tt.stages {
+				t.Errorf("got %d stages, want %d", len(pipeline.Stages), tt.stages)
+			}
+			for i, want := range tt.pipeStderr {
+				if pipeline.PipeStderr[i] != want {
+					t.Errorf("PipeStderr[%d] = %v, want %v", i, pipeline.PipeStderr[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestPipelineExecute(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		pipefail   bool
+		wantOutput string
+		wantStatus string
+		wantExit   int
+	}{
+		{
+			name:       "external to external",
+			input:      "echo hello world | tr a-z A-Z",
+			wantOutput: "HELLO WORLD\n",
+			wantStatus: "0 0",
+		},
+		{
+			name:       "builtin to external",
+			input:      "help | head -n 1",
+			wantOutput: "Gosh - A modern shell written in Go\n",
+			wantStatus: "0 0",
+		},
+		{
+			name:       "stderr through pipe",
+			input:      "sh -c 'echo oops >&2' |& cat",
+			wantOutput: "oops\n",
+			wantStatus: "0 0",
+		},
+		{
+			name:       "status of last stage",
+			input:      "false | true",
+			wantStatus: "1 0",
+		},
+		{
+			name:       "failing last stage",
+			input:      "true | false",
+			wantStatus: "0 1",
+			wantExit:   1,
+		},
+		{
+			name:       "pipefail reports rightmost failure",
+			input:      "sh -c 'exit 3' | false | true",
+			pipefail:   true,
+			wantStatus: "3 1 0",
+			wantExit:   1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.Pipefail = tt.pipefail
+			parser := New(cfg)
+
+			cmd, err := parser.Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse() failed: %v", err)
+			}
+
+			var stdout bytes.Buffer
+			ctx := WithIO(context.Background(), IO{Stdin: strings.NewReader(""), Stdout: &stdout})
+			status, err := cmd.Execute(ctx, cfg)
+			if err != nil {
+				t.Errorf("Execute() failed: %v", err)
+			}
+			if status != tt.wantExit {
+				t.Errorf("Execute() status = %d, want %d", status, tt.wantExit)
+			}
+
+			if tt.wantOutput != "" && stdout.String() != tt.wantOutput {
+				t.Errorf("output = %q, want %q", stdout.String(), tt.wantOutput)
+			}
+			pipeStatus, _ := cfg.Variables.Get("PIPESTATUS")
+			got := make([]string, len(pipeStatus.Elements))
+			for i := range got {
+				got[i] = pipeStatus.Elements[strconv.Itoa(i)]
+			}
+			if strings.Join(got, " ") != tt.wantStatus {
+				t.Errorf("PIPESTATUS = %q, want %q", got, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestPipeStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		wantOutput string
+	}{
+		{
+			name:       "a simple command sets it",
+			input:      "false | true; true; echo ${PIPESTATUS[@]}",
+			wantOutput: "0\n",
+		},
+		{
+			name:       "elements and length",
+			input:      "false | (exit 4) | true; echo ${#PIPESTATUS[@]} ${PIPESTATUS[1]} $PIPESTATUS",
+			wantOutput: "3 4 1\n",
+		},
+		{
+			name:       "compound commands keep the last pipeline",
+			input:      "if true; then false | true; fi; echo ${PIPESTATUS[@]}",
+			wantOutput: "1 0\n",
+		},
+		{
+			name:       "the status before ! is kept",
+			input:      "! sh -c 'exit 3'; echo ${PIPESTATUS[@]}",
+			wantOutput: "3\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			cmd, err := New(cfg).Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse() failed: %v", err)
+			}
+
+			var stdout bytes.Buffer
+			ctx := WithIO(context.Background(), IO{Stdin: strings.NewReader(""), Stdout: &stdout})
+			ctx = WithState(ctx, NewState(DefaultShellName, nil))
+			if _, err := cmd.Execute(ctx, cfg); err != nil {
+				t.Errorf("Execute() failed: %v", err)
+			}
+			if stdout.String() != tt.wantOutput {
+				t.Errorf("output = %q, want %q", stdout.String(), tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestExternalCommandStatus(t *testing.T) {
+	failing := &ExternalCommand{Name: "sh", Args: []string{"-c", "exit 2"}}
+	if status, err := failing.Execute(context.Background(), config.Default()); status != 2 || err != nil {
+		t.Errorf("Execute() = %d, %v, want status 2 without an error", status, err)
+	}
+	missing := &ExternalCommand{Name: "gosh-no-such-command"}
+	if status, err := missing.Execute(context.Background(), config.Default()); status != ExitCommandNotFound || err == nil {
+		t.Errorf("Execute() = %d, %v, want status %d with an error", status, err, ExitCommandNotFound)
+	}
+}
+
+func TestPipelineStagesRunApart(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current directory: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		input      string
+		wantOutput string
+	}{
+		{
+			name:       "cd in a stage leaves the shell where it is",
+			input:      "cd / | cat; pwd",
+			wantOutput: wd + "\n",
+		},
+		{
+			name:       "a built-in writing to a closed pipe stops quietly",
+			input:      "while true; do echo y; done | head -n 2",
+			wantOutput: "y\ny\n",
+		},
+		{
+			name:       "stages write to a shared buffer in turn",
+			input:      "echo a | cat; sh -c 'echo b >&2' |& cat | cat",
+			wantOutput: "a\nb\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			cmd, err := New(cfg).Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse() failed: %v", err)
+			}
+
+			var stdout, stderr bytes.Buffer
+			ctx := WithState(context.Background(), NewState(DefaultShellName, nil))
+			ctx = WithIO(ctx, IO{Stdin: strings.NewReader(""), Stdout: &stdout, Stderr: &stderr})
+			if _, err := cmd.Execute(ctx, cfg); err != nil {
+				t.Errorf("Execute() failed: %v", err)
+			}
+			if stdout.String() != tt.wantOutput {
+				t.Errorf("output = %q, want %q", stdout.String(), tt.wantOutput)
+			}
+			if stderr.Len() > 0 {
+				t.Errorf("stderr = %q, want nothing", stderr.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,379 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gosh/internal/config"
+)
+
+// EchoCommand implements the echo built-in command, which prints its
+// arguments separated by spaces. -n leaves out the final newline, -e
+// expands backslash escapes and -E, the default, does not.
+type EchoCommand struct {
+	Args []string
+}
+
+// Execute implements the Command interface for EchoCommand
+func (c *EchoCommand) Execute(ctx context.Context, _ *config.Config) (int, error) {
+	newline, escapes := true, false
+	args := c.Args
+	// Like bash, an argument is only taken as options if all its letters
+	// are valid, so echo -x prints -x
+	for len(args) > 0 && len(args[0]) > 1 && args[0][0] == '-' && strings.Trim(args[0][1:], "neE") == "" {
+		for _, flag := range args[0][1:] {
+			switch flag {
+			case 'n':
+				newline = false
+			case 'e':
+				escapes = true
+			case 'E':
+				escapes = false
+			}
+		}
+		args = args[1:]
+	}
+
+	text := strings.Join(args, " ")
+	if escapes {
+		var stop bool
+		if text, stop = expandEscapes(text, true); stop {
+			newline = false
+		}
+	}
+	if newline {
+		text += "\n"
+	}
+	_, err := fmt.Fprint(IOFromContext(ctx).Stdout, text)
+	return builtinStatus(err)
+}
+
+// expandEscapes expands the backslash escapes of printf and echo -e: \a
+// \b \e \f \n \r \t \v \\, \xHH, \uHHHH, \UHHHHHHHH and octal. Octal is
+// written \0NNN for echo and %b, and \NNN in a printf format. In echo mode
+// \c stops the output, which is reported as stop. Other backslashes are
+// kept.
+func expandEscapes(s string, echo bool) (expanded string, stop bool) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		i++
+		switch c := s[i]; c {
+		case 'a':
+			b.WriteByte('\a')
+		case 'b':
+			b.WriteByte('\b')
+		case 'e', 'E':
+			b.WriteByte(0x1b)
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'v':
+			b.WriteByte('\v')
+		case '\\':
+			b.WriteByte('\\')
+		case 'c':
+			if echo {
+				return b.String(), true
+			}
+			b.WriteString(`\c`)
+		case 'x', 'u', 'U':
+			digits := map[byte]int{'x': 2, 'u': 4, 'U': 8}[c]
+			n, end := parseDigits(s, i+1, digits, 16)
+			switch {
+			case end == i+1:
+				// No digits: the escape is kept
+				b.WriteByte('\\')
+				b.WriteByte(c)
+			case c == 'x':
+				b.WriteByte(byte(n))
+			default:
+				b.WriteRune(rune(n))
+			}
+			i = end - 1
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			start := i
+			if echo {
+				if c != '0' {
+					b.WriteByte('\\')
+					b.WriteByte(c)
+					continue
+				}
+				start++
+			}
+			n, end := parseDigits(s, start, 3, 8)
+			b.WriteByte(byte(n))
+			i = end - 1
+		default:
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), false
+}
+
+// parseDigits parses at most max digits of the given base in s from start,
+// returning their value and the index after the last one
+func parseDigits(s string, start, max, base int) (int, int) {
+	n, i := 0, start
+	for ; i < len(s) && i-start < max; i++ {
+		d, err := strconv.ParseUint(s[i:i+1], base, 8)
+		if err != nil {
+			break
+		}
+		n = n*base + int(d)
+	}
+	return n, i
+}
+
+// PrintfCommand implements the printf built-in command, which prints its
+// arguments under the control of a format as C's printf does. The format
+// is reused until all arguments are used. With -v the output is assigned
+// to a variable instead.
+type PrintfCommand struct {
+	Args   []string
+	parser *Parser
+}
+
+// Execute implements the Command interface for PrintfCommand
+func (c *PrintfCommand) Execute(ctx context.Context, cfg *config.Config) (int, error) {
+	args := c.Args
+	var name string
+	for len(args) > 0 && len(args[0]) > 1 && args[0][0] == '-' {
+		if args[0] == "--" {
+			args = args[1:]
+			break
+		}
+		if args[0] != "-v" {
+			return ExitUsage, fmt.Errorf("printf: %s: invalid option", args[0])
+		}
+		if len(args) < 2 {
+			return ExitUsage, errors.New("printf: -v: option requires an argument")
+		}
+		if name = args[1]; !isName(name) {
+			return ExitFailure, fmt.Errorf("printf: `%s': not a valid identifier", name)
+		}
+		args = args[2:]
+	}
+	if len(args) == 0 {
+		return ExitUsage, errors.New("printf: usage: printf [-v var] format [arguments]")
+	}
+
+	f := &formatter{ctx: ctx, args: args[1:]}
+	var out strings.Builder
+	for {
+		used := f.next
+		if stop := f.format(&out, args[0]); stop {
+			break
+		}
+		// The format is used again while it consumes arguments
+		if f.next == used || f.next >= len(f.args) {
+			break
+		}
+	}
+
+	status := 0
+	if f.failed {
+		status = ExitFailure
+	}
+	if name != "" {
+		if err := c.parser.withConfig(cfg).setVariable(name, out.String()); err != nil {
+			return ExitFailure, fmt.Errorf("printf: %w", err)
+		}
+		return status, nil
+	}
+	if _, err := fmt.Fprint(IOFromContext(ctx).Stdout, out.String()); err != nil {
+		return ExitFailure, err
+	}
+	return status, nil
+}
+
+// formatter applies a printf format to the arguments, taking them in turn
+type formatter struct {
+	ctx  context.Context
+	args []string
+	next int
+	// failed is set once an argument was not a valid number or the format
+	// was invalid
+	failed bool
+}
+
+// arg returns the next argument, or "" once they are all used
+func (f *formatter) arg() string {
+	if f.next >= len(f.args) {
+		return ""
+	}
+	f.next++
+	return f.args[f.next-1]
+}
+
+// fail reports an error, which printf goes on from
+func (f *formatter) fail(err error) {
+	reportError(f.ctx, fmt.Errorf("printf: %w", err))
+	f.failed = true
+}
+
+// format writes format to out once, with its conversions applied to the
+// next arguments. It reports whether printf must stop, after \c in a %b
+// argument or an invalid conversion.
+func (f *formatter) format(out *strings.Builder, format string) bool {
+	for i := 0; i < len(format); {
+		if format[i] != '%' {
+			// The text up to the next conversion, with its escapes expanded
+			end := strings.IndexByte(format[i:], '%')
+			if end < 0 {
+				end = len(format)
+			} else {
+				end += i
+			}
+			expanded, _ := expandEscapes(format[i:end], false)
+			out.WriteString(expanded)
+			i = end
+			continue
+		}
+
+		end, stop := f.convert(out, format, i)
+		if stop {
+			return true
+		}
+		i = end + 1
+	}
+	return false
+}
+
+// convert applies the conversion starting at format[start], such as
+// %-10.3s. It returns the index of its verb, and whether printf must stop.
+func (f *formatter) convert(out *strings.Builder, format string, start int) (int, bool) {
+	i := start + 1
+	var spec strings.Builder
+	spec.WriteByte('%')
+	for i < len(format) && strings.IndexByte("-+ #0", format[i]) >= 0 {
+		spec.WriteByte(format[i])
+		i++
+	}
+	// The width and precision are numbers, or * to take them from the
+	// arguments
+	for _, prefix := range []string{"", "."} {
+		if prefix != "" {
+			if i >= len(format) || format[i] != '.' {
+				break
+			}
+			spec.WriteByte('.')
+			i++
+		}
+		if i < len(format) && format[i] == '*' {
+			spec.WriteString(strconv.FormatInt(f.intArg(), 10))
+			i++
+			continue
+		}
+		for i < len(format) && format[i] >= '0' && format[i] <= '9' {
+			spec.WriteByte(format[i])
+			i++
+		}
+	}
+	if i >= len(format) {
+		f.fail(fmt.Errorf("`%s': missing format character", format[start:]))
+		return i, true
+	}
+
+	verb := format[i]
+	switch verb {
+	case '%':
+		out.WriteByte('%')
+	case 's':
+		fmt.Fprintf(out, spec.String()+"s", f.arg())
+	case 'b':
+		expanded, stop := expandEscapes(f.arg(), true)
+		fmt.Fprintf(out, spec.String()+"s", expanded)
+		if stop {
+			return i, true
+		}
+	case 'q':
+		fmt.Fprintf(out, spec.String()+"s", quoteIfNeeded(f.arg()))
+	case 'c':
+		arg := f.arg()
+		if r, size := utf8.DecodeRuneInString(arg); size > 0 {
+			arg = string(r)
+		}
+		fmt.Fprintf(out, spec.String()+"s", arg)
+	case 'd', 'i':
+		fmt.Fprintf(out, spec.String()+"d", f.intArg())
+	case 'u':
+		fmt.Fprintf(out, spec.String()+"d", uint64(f.intArg()))
+	case 'o', 'x', 'X':
+		fmt.Fprintf(out, spec.String()+string(verb), uint64(f.intArg()))
+	case 'e', 'E', 'f', 'F', 'g', 'G':
+		fmt.Fprintf(out, spec.String()+string(verb), f.floatArg())
+	default:
+		f.fail(fmt.Errorf("`%c': invalid format character", verb))
+		return i, true
+	}
+	return i, false
+}
+
+// intArg returns the next argument as an integer, which may be written in
+// octal with a leading 0, in hexadecimal with 0x, or as a quote followed
+// by a character to take its code. An invalid number is reported and
+// counts as the part of it that could be read, or 0.
+func (f *formatter) intArg() int64 {
+	arg := f.arg()
+	if code, ok := charCode(arg); ok {
+		return code
+	}
+	text := strings.TrimSpace(arg)
+	if text == "" {
+		return 0
+	}
+	n, err := strconv.ParseInt(text, 0, 64)
+	if err != nil {
+		var numErr *strconv.NumError
+		if errors.As(err, &numErr) && errors.Is(numErr.Err, strconv.ErrRange) {
+			f.fail(fmt.Errorf("%s: Numerical result out of range", arg))
+			return n
+		}
+		f.fail(fmt.Errorf("%s: invalid number", arg))
+		return 0
+	}
+	return n
+}
+
+// floatArg returns the next argument as a floating point number
+func (f *formatter) floatArg() float64 {
+	arg := f.arg()
+	if code, ok := charCode(arg); ok {
+		return float64(code)
+	}
+	text := strings.TrimSpace(arg)
+	if text == "" {
+		return 0
+	}
+	n, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		f.fail(fmt.Errorf("%s: invalid number", arg))
+		return 0
+	}
+	return n
+}
+
+// charCode returns the code of the character after a leading ' or ", as a
+// numeric argument of printf written that way stands for
+func charCode(arg string) (int64, bool) {
+	if len(arg) < 2 || (arg[0] != '\'' && arg[0] != '"') {
+		return 0, false
+	}
+	r, _ := utf8.DecodeRuneInString(arg[1:])
+	return int64(r), true
+}
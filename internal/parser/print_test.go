@@ -0,0 +1,162 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"gosh/internal/config"
+)
+
+func TestEchoCommand(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		wantOutput string
+	}{
+		{"arguments joined by spaces", []string{"a", "b c"}, "a b c\n"},
+		{"no arguments", nil, "\n"},
+		{"no newline", []string{"-n", "a"}, "a"},
+		{"escapes are kept by default", []string{`a\tb`}, "a\\tb\n"},
+		{"escapes", []string{"-e", `a\tb\x41\0101é`}, "a\tbAAé\n"},
+		{"escapes turned off again", []string{"-eE", `a\n`}, "a\\n\n"},
+		{"stop at \\c", []string{"-e", `a\cb`, "c"}, "a"},
+		{"combined options", []string{"-ne", `a\n`}, "a\n"},
+		{"unknown option is printed", []string{"-x", "-n"}, "-x -n\n"},
+		{"dashes are printed", []string{"-", "--"}, "- --\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			ctx := WithIO(context.Background(), IO{Stdout: &stdout})
+			status, err := (&EchoCommand{Args: tt.args}).Execute(ctx, config.Default())
+			if status != 0 || err != nil {
+				t.Fatalf("Execute() = %d, %v", status, err)
+			}
+			if stdout.String() != tt.wantOutput {
+				t.Errorf("stdout = %q, want %q", stdout.String(), tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestPrintfCommand(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		wantOutput string
+		wantStderr string
+		wantStatus int
+	}{
+		{
+			name:       "strings with width and precision",
+			input:      `printf '%s|%5s|%-5s|%.2s\n' abc de fg hijk`,
+			wantOutput: "abc|   de|fg   |hi\n",
+		},
+		{
+			name:       "integers",
+			input:      `printf '%d %i %05d %+d %x %X %o %#x %u\n' 42 -7 3 4 255 255 8 255 -1`,
+			wantOutput: "42 -7 00003 +4 ff FF 10 0xff 18446744073709551615\n",
+		},
+		{
+			name:       "numbers in other bases and character codes",
+			input:      `printf '%d %d %d %d\n' 0x10 010 "'A" ' 5'`,
+			wantOutput: "16 8 65 5\n",
+		},
+		{
+			name:       "floating point",
+			input:      `printf '%.3f %e %g %5.1f|\n' 3.14159 1234.5 0.0001 2`,
+			wantOutput: "3.142 1.234500e+03 0.0001   2.0|\n",
+		},
+		{
+			name:       "the format is reused for extra arguments",
+			input:      `printf '%s=%d\n' a 1 b 2 c`,
+			wantOutput: "a=1\nb=2\nc=0\n",
+		},
+		{
+			name:       "a format without conversions is printed once",
+			input:      `printf 'hi\n' a b`,
+			wantOutput: "hi\n",
+		},
+		{
+			name:       "width and precision from arguments",
+			input:      `printf '%*d|%-*s|%.*f\n' 5 42 4 ab 1 2.25`,
+			wantOutput: "   42|ab  |2.2\n",
+		},
+		{
+			name:       "quoted, escaped and character arguments",
+			input:      `printf '%q %q %b %c\n' 'a b' plain 'x\ty' hello`,
+			wantOutput: "'a b' plain x\ty h\n",
+		},
+		{
+			name:       "escapes in the format",
+			input:      `printf '\101\t\x42\\%%\n'`,
+			wantOutput: "A\tB\\%\n",
+		},
+		{
+			name:       "\\c in %b stops the output",
+			input:      `printf '%b|%s\n' 'a\cb' c`,
+			wantOutput: "a",
+		},
+		{
+			name:       "assign to a variable",
+			input:      `printf -v out '%s-%s' x y; echo "$out"`,
+			wantOutput: "x-y\n",
+		},
+		{
+			name:       "invalid number",
+			input:      `printf '%d|\n' abc`,
+			wantOutput: "0|\n",
+			wantStderr: "printf: abc: invalid number",
+			wantStatus: 1,
+		},
+		{
+			name:       "invalid format character",
+			input:      `printf 'a%zb'`,
+			wantOutput: "a",
+			wantStderr: "printf: `z': invalid format character",
+			wantStatus: 1,
+		},
+		{
+			name:       "missing format",
+			input:      `printf`,
+			wantStderr: "printf: usage:",
+			wantStatus: 2,
+		},
+		{
+			name:       "works in a pipeline",
+			input:      `printf '%s\n' b a | sort`,
+			wantOutput: "a\nb\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			parser := New(cfg)
+
+			cmd, err := parser.Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse() failed: %v", err)
+			}
+
+			var stdout, stderr bytes.Buffer
+			ctx := WithIO(context.Background(), IO{Stdin: strings.NewReader(""), Stdout: &stdout, Stderr: &stderr})
+			ctx = WithState(ctx, NewState(DefaultShellName, nil))
+			status, err := cmd.Execute(ctx, cfg)
+			reportError(ctx, err)
+
+			if status != tt.wantStatus {
+				t.Errorf("status = %d, want %d", status, tt.wantStatus)
+			}
+			if stdout.String() != tt.wantOutput {
+				t.Errorf("stdout = %q, want %q", stdout.String(), tt.wantOutput)
+			}
+			if !strings.Contains(stderr.String(), tt.wantStderr) {
+				t.Errorf("stderr = %q, want it to contain %q", stderr.String(), tt.wantStderr)
+			}
+		})
+	}
+}
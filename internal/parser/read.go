@@ -0,0 +1,427 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"gosh/internal/config"
+
+	"github.com/chzyer/readline"
+)
+
+// errReadTimeout is returned when the input of read -t does not arrive in
+// time
+var errReadTimeout = errors.New("read timed out")
+
+// readOptions are the options of the read built-in
+type readOptions struct {
+	// raw keeps backslashes, which otherwise escape the next character
+	raw    bool
+	silent bool
+	prompt string
+	// array is the name of the array the fields are assigned to, with -a
+	array string
+	delim byte
+	// chars is the number of characters to read with -n, or -1
+	chars   int
+	timeout time.Duration
+}
+
+// ReadCommand implements the read built-in command, which reads a line
+// from standard input, splits it into fields on the characters of IFS and
+// assigns them to the given variables, the last taking the rest of the
+// line. Without names the line is assigned to REPLY.
+type ReadCommand struct {
+	Args   []string
+	parser *Parser
+}
+
+// Execute implements the Command interface for ReadCommand. The status is
+// 1 at the end of the input, and 128 plus SIGALRM when -t times out; what
+// was read is assigned either way.
+func (c *ReadCommand) Execute(ctx context.Context, cfg *config.Config) (int, error) {
+	opts, names, err := parseReadOptions(c.Args)
+	if err != nil {
+		return ExitUsage, err
+	}
+	for _, name := range append(names, opts.array) {
+		if name != "" && !isName(name) {
+			return ExitFailure, fmt.Errorf("read: `%s': not a valid identifier", name)
+		}
+	}
+	// A zero timeout only checks for input, which gosh takes as there
+	if opts.timeout == 0 {
+		return 0, nil
+	}
+
+	streams := IOFromContext(ctx)
+	file, _ := streams.Stdin.(*os.File)
+	terminal := file != nil && readline.IsTerminal(int(file.Fd()))
+	if terminal && opts.prompt != "" {
+		_, _ = fmt.Fprint(streams.Stderr, opts.prompt)
+	}
+
+	// Characters counted with -n, and silent input, are read as they are
+	// typed
+	rawMode := terminal && (opts.silent || opts.chars >= 0)
+	if rawMode {
+		state, err := readline.MakeRaw(int(file.Fd()))
+		if err != nil {
+			return ExitFailure, fmt.Errorf("read: %w", err)
+		}
+		defer func() {
+			_ = readline.Restore(int(file.Fd()), state)
+		}()
+	}
+
+	in := newInputReader(ctx, streams.Stdin, opts.timeout)
+	defer in.close()
+	text, escaped, err := readInput(in, opts, rawMode, streams.Stderr)
+
+	status := 0
+	switch {
+	case errors.Is(err, io.EOF):
+		status = ExitFailure
+	case errors.Is(err, errReadTimeout):
+		status = ExitSignalBase + int(syscall.SIGALRM)
+	case errors.Is(err, context.Canceled) || ctx.Err() != nil:
+		// Interrupted, by Ctrl+C in raw mode or by the shell
+		return ExitSignalBase + int(syscall.SIGINT), ctx.Err()
+	case err != nil:
+		return ExitFailure, fmt.Errorf("read: %w", err)
+	}
+
+	if err := c.assign(cfg, opts, names, text, escaped); err != nil {
+		return ExitFailure, fmt.Errorf("read: %w", err)
+	}
+	return status, nil
+}
+
+// assign assigns the fields of the text read to the variables, or to the
+// elements of the array given with -a
+func (c *ReadCommand) assign(cfg *config.Config, opts readOptions, names []string, text []byte, escaped []bool) error {
+	p := c.parser.withConfig(cfg)
+	ifs, ok := p.lookupVariable("IFS")
+	if !ok {
+		ifs = DefaultIFS
+	}
+
+	if opts.array != "" {
+		elements := make(map[string]string)
+		for i, field := range readFields(text, escaped, ifs, -1) {
+			elements[strconv.Itoa(i)] = field
+		}
+		return cfg.Variables.SetArray(opts.array, elements)
+	}
+
+	// REPLY takes the line as it is
+	if len(names) == 0 {
+		return p.setVariable("REPLY", string(text))
+	}
+
+	fields := readFields(text, escaped, ifs, len(names))
+	for i, name := range names {
+		value := ""
+		if i < len(fields) {
+			value = fields[i]
+		}
+		if err := p.setVariable(name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseReadOptions parses the options of read, which may be combined as in
+// -rs, and returns them with the names of the variables
+func parseReadOptions(args []string) (readOptions, []string, error) {
+	opts := readOptions{delim: '\n', chars: -1, timeout: -1}
+	for len(args) > 0 && len(args[0]) > 1 && args[0][0] == '-' {
+		if args[0] == "--" {
+			args = args[1:]
+			break
+		}
+
+		flags := args[0][1:]
+		args = args[1:]
+		for i := 0; i < len(flags); i++ {
+			flag := flags[i]
+			switch flag {
+			case 'r':
+				opts.raw = true
+				continue
+			case 's':
+				opts.silent = true
+				continue
+			case 'a', 'd', 'n', 'p', 't':
+			default:
+				return opts, nil, fmt.Errorf("read: -%c: invalid option", flag)
+			}
+
+			// The value is the rest of the argument, or the next one
+			value := flags[i+1:]
+			if value == "" {
+				if len(args) == 0 {
+					return opts, nil, fmt.Errorf("read: -%c: option requires an argument", flag)
+				}
+				value, args = args[0], args[1:]
+			}
+			i = len(flags)
+
+			switch flag {
+			case 'a':
+				opts.array = value
+			case 'd':
+				// An empty delimiter reads up to a NUL byte
+				opts.delim = 0
+				if value != "" {
+					opts.delim = value[0]
+				}
+			case 'n':
+				n, err := strconv.Atoi(value)
+				if err != nil || n < 0 {
+					return opts, nil, fmt.Errorf("read: %s: invalid number", value)
+				}
+				opts.chars = n
+			case 'p':
+				opts.prompt = value
+			case 't':
+				seconds, err := strconv.ParseFloat(value, 64)
+				if err != nil || seconds < 0 {
+					return opts, nil, fmt.Errorf("read: %s: invalid timeout specification", value)
+				}
+				opts.timeout = time.Duration(seconds * float64(time.Second))
+			}
+		}
+	}
+	return opts, args, nil
+}
+
+// readInput reads up to the delimiter, or the number of characters of -n,
+// and returns the text with a flag for each byte escaped by a backslash.
+// Unless -r is given a backslash escapes the next character and a
+// backslash-newline is removed. In raw terminal mode the characters typed
+// are echoed to echo unless -s is given.
+func readInput(in *inputReader, opts readOptions, rawMode bool, echo io.Writer) ([]byte, []bool, error) {
+	var text []byte
+	var escaped []bool
+	chars := 0
+	escapeNext := false
+
+	for opts.chars < 0 || chars < opts.chars {
+		c, err := in.readByte()
+		if err != nil {
+			return text, escaped, err
+		}
+
+		if rawMode {
+			switch c {
+			case '\r':
+				c = '\n'
+			case 0x03:
+				// Ctrl+C does not raise SIGINT in raw mode
+				return text, escaped, context.Canceled
+			case 0x04:
+				if len(text) == 0 {
+					return text, escaped, io.EOF
+				}
+				continue
+			}
+			if !opts.silent {
+				_, _ = echo.Write([]byte{c})
+			}
+		}
+
+		isEscaped := false
+		switch {
+		case escapeNext:
+			escapeNext, isEscaped = false, true
+			if c == '\n' {
+				continue
+			}
+		case c == '\\' && !opts.raw:
+			escapeNext = true
+			continue
+		case c == opts.delim:
+			return text, escaped, nil
+		}
+
+		text = append(text, c)
+		escaped = append(escaped, isEscaped)
+		chars++
+
+		// A character is read whole with the rest of its UTF-8 encoding
+		for n := utf8SequenceLength(c); n > 1; n-- {
+			if c, err = in.readByte(); err != nil {
+				return text, escaped, err
+			}
+			text = append(text, c)
+			escaped = append(escaped, isEscaped)
+		}
+	}
+	return text, escaped, nil
+}
+
+// utf8SequenceLength returns the number of bytes of the UTF-8 encoding
+// that starts with c, or 1 for a byte that starts none
+func utf8SequenceLength(c byte) int {
+	switch {
+	case c >= 0xf0:
+		return 4
+	case c >= 0xe0:
+		return 3
+	case c >= 0xc0:
+		return 2
+	}
+	return 1
+}
+
+// readFields splits the text read by read into at most n fields on the
+// characters of ifs, with the last taking the rest of the text; n < 0
+// splits all of it. IFS whitespace around a field is dropped, and any other
+// IFS character ends a field, which may be empty. Escaped bytes never
+// split.
+func readFields(text []byte, escaped []bool, ifs string, n int) []string {
+	isIFS := func(i int) bool {
+		return !escaped[i] && strings.IndexByte(ifs, text[i]) >= 0
+	}
+	isSpace := func(i int) bool {
+		return isIFS(i) && (text[i] == ' ' || text[i] == '\t' || text[i] == '\n')
+	}
+
+	i := 0
+	skipSpace := func() {
+		for i < len(text) && isSpace(i) {
+			i++
+		}
+	}
+
+	var fields []string
+	skipSpace()
+	for i < len(text) {
+		if n >= 0 && len(fields) == n-1 {
+			end := len(text)
+			for end > i && isSpace(end-1) {
+				end--
+			}
+			return append(fields, string(text[i:end]))
+		}
+
+		start := i
+		for i < len(text) && !isIFS(i) {
+			i++
+		}
+		fields = append(fields, string(text[start:i]))
+
+		// A delimiter is IFS whitespace around at most one other IFS
+		// character
+		skipSpace()
+		if i < len(text) && isIFS(i) && !isSpace(i) {
+			i++
+			skipSpace()
+		}
+	}
+	return fields
+}
+
+// inputReader reads the input of read one byte at a time, so that nothing
+// after the delimiter is taken from a stream shared with the commands that
+// follow. A read ends at the timeout of -t, or when ctx is cancelled.
+type inputReader struct {
+	ctx context.Context
+	r   io.Reader
+	// deadline is when -t times out, or zero
+	deadline time.Time
+	// direct is set when r can be read without waiting in another
+	// goroutine: a regular file, or a copy of stdin that takes deadlines
+	direct  bool
+	release func()
+	stop    func() bool
+	buf     [1]byte
+}
+
+// newInputReader returns a reader of r for read
+func newInputReader(ctx context.Context, r io.Reader, timeout time.Duration) *inputReader {
+	in := &inputReader{ctx: ctx, r: r}
+	if timeout > 0 {
+		in.deadline = time.Now().Add(timeout)
+	}
+
+	f, ok := r.(*os.File)
+	if !ok {
+		return in
+	}
+	if info, err := f.Stat(); err == nil && info.Mode().IsRegular() {
+		// A regular file never makes a read wait
+		in.direct = true
+		return in
+	}
+	file, release, err := pollableFile(f)
+	if err != nil {
+		return in
+	}
+	if err := file.SetReadDeadline(in.deadline); err != nil {
+		release()
+		return in
+	}
+	in.r, in.direct, in.release = file, true, release
+	in.stop = context.AfterFunc(ctx, func() {
+		_ = file.SetReadDeadline(time.Unix(1, 0))
+	})
+	return in
+}
+
+// readByte reads the next byte of input
+func (in *inputReader) readByte() (byte, error) {
+	if in.direct {
+		_, err := io.ReadFull(in.r, in.buf[:])
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			if in.ctx.Err() != nil {
+				return 0, in.ctx.Err()
+			}
+			return 0, errReadTimeout
+		}
+		return in.buf[0], err
+	}
+
+	// Other streams are read in another goroutine, so that the wait can
+	// end. A byte that arrives after it ended is lost.
+	var b [1]byte
+	result := make(chan error, 1)
+	go func() {
+		_, err := io.ReadFull(in.r, b[:])
+		result <- err
+	}()
+
+	var timeout <-chan time.Time
+	if !in.deadline.IsZero() {
+		timer := time.NewTimer(time.Until(in.deadline))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case err := <-result:
+		return b[0], err
+	case <-timeout:
+		return 0, errReadTimeout
+	case <-in.ctx.Done():
+		return 0, in.ctx.Err()
+	}
+}
+
+// close releases the copy of stdin made for deadlines
+func (in *inputReader) close() {
+	if in.stop != nil {
+		in.stop()
+	}
+	if in.release != nil {
+		in.release()
+	}
+}
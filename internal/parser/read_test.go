@@ -0,0 +1,192 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"gosh/internal/config"
+)
+
+func TestReadCommand(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		stdin      string
+		wantOutput string
+		wantStderr string
+		wantStatus int
+	}{
+		{
+			name:       "fields are split on IFS, the last taking the rest",
+			input:      `read a b; echo "[$a][$b]"`,
+			stdin:      "  one two  three  \nnext\n",
+			wantOutput: "[one][two  three]\n",
+		},
+		{
+			name:       "missing fields are empty",
+			input:      `read a b c; echo "[$a][$b][$c]"`,
+			stdin:      "one\n",
+			wantOutput: "[one][][]\n",
+		},
+		{
+			name:       "REPLY keeps the whole line",
+			input:      `read; echo "[$REPLY]"`,
+			stdin:      "  as is  \n",
+			wantOutput: "[  as is  ]\n",
+		},
+		{
+			name:       "other IFS characters end fields",
+			input:      `IFS=: read x y z; echo "[$x][$y][$z]"`,
+			stdin:      "1::3\n",
+			wantOutput: "[1][][3]\n",
+		},
+		{
+			name:       "backslashes escape characters",
+			input:      `read a b; echo "[$a][$b]"`,
+			stdin:      `a\ b c\\d` + "\n",
+			wantOutput: `[a b][c\d]` + "\n",
+		},
+		{
+			name:       "backslash-newline continues the line",
+			input:      `read a; echo "[$a]"`,
+			stdin:      "one \\\ntwo\n",
+			wantOutput: "[one two]\n",
+		},
+		{
+			name:       "-r keeps backslashes",
+			input:      `read -r a; echo "[$a]"`,
+			stdin:      `a\b\` + "\n",
+			wantOutput: `[a\b\]` + "\n",
+		},
+		{
+			name:       "-a assigns an array",
+			input:      `read -a arr; echo "${#arr[@]} ${arr[1]} ${arr[2]}"`,
+			stdin:      "p q  r\n",
+			wantOutput: "3 q r\n",
+		},
+		{
+			name:       "-d sets the delimiter",
+			input:      `read -d , a; read b; echo "[$a][$b]"`,
+			stdin:      "x,y\n",
+			wantOutput: "[x][y]\n",
+		},
+		{
+			name:       "-n reads a number of characters",
+			input:      `read -n 2 a; read b; echo "[$a][$b]"`,
+			stdin:      "éàb\n",
+			wantOutput: "[éà][b]\n",
+		},
+		{
+			name:       "-n stops at the delimiter",
+			input:      `read -n 5 a; echo "[$a]"`,
+			stdin:      "ab\ncd\n",
+			wantOutput: "[ab]\n",
+		},
+		{
+			name:       "the prompt is only shown on a terminal",
+			input:      `read -p 'name: ' a; echo "[$a]"`,
+			stdin:      "x\n",
+			wantOutput: "[x]\n",
+		},
+		{
+			name:       "end of input without a newline",
+			input:      `read a; echo "$? [$a]"`,
+			stdin:      "partial",
+			wantOutput: "1 [partial]\n",
+		},
+		{
+			name:       "lines are read one at a time in a loop",
+			input:      `while read line; do echo "got $line"; done`,
+			stdin:      "l1\nl2\n",
+			wantOutput: "got l1\ngot l2\n",
+		},
+		{
+			name:       "read in a pipeline",
+			input:      `echo piped | { read a; echo "[$a]"; }`,
+			wantOutput: "[piped]\n",
+		},
+		{
+			name:       "invalid name",
+			input:      `read 1bad`,
+			stdin:      "x\n",
+			wantStderr: "read: `1bad': not a valid identifier",
+			wantStatus: 1,
+		},
+		{
+			name:       "invalid option",
+			input:      `read -z a`,
+			wantStderr: "read: -z: invalid option",
+			wantStatus: 2,
+		},
+		{
+			name:       "option without its argument",
+			input:      `read -d`,
+			wantStderr: "read: -d: option requires an argument",
+			wantStatus: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			parser := New(cfg)
+
+			cmd, err := parser.Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse() failed: %v", err)
+			}
+
+			var stdout, stderr bytes.Buffer
+			ctx := WithIO(context.Background(), IO{Stdin: strings.NewReader(tt.stdin), Stdout: &stdout, Stderr: &stderr})
+			ctx = WithState(ctx, NewState(DefaultShellName, nil))
+			status, err := cmd.Execute(ctx, cfg)
+			reportError(ctx, err)
+
+			if status != tt.wantStatus {
+				t.Errorf("status = %d, want %d", status, tt.wantStatus)
+			}
+			if stdout.String() != tt.wantOutput {
+				t.Errorf("stdout = %q, want %q", stdout.String(), tt.wantOutput)
+			}
+			if !strings.Contains(stderr.String(), tt.wantStderr) {
+				t.Errorf("stderr = %q, want it to contain %q", stderr.String(), tt.wantStderr)
+			}
+		})
+	}
+}
+
+func TestReadTimeout(t *testing.T) {
+	cfg := config.Default()
+	stdin, writer := io.Pipe()
+	defer func() {
+		_ = writer.Close()
+	}()
+
+	ctx := WithIO(context.Background(), IO{Stdin: stdin, Stdout: io.Discard, Stderr: io.Discard})
+	ctx = WithState(ctx, NewState(DefaultShellName, nil))
+	cmd := &ReadCommand{Args: []string{"-t", "0.05", "a"}, parser: New(cfg)}
+
+	start := time.Now()
+	status, err := cmd.Execute(ctx, cfg)
+	if err != nil {
+		t.Fatalf("Execute() failed: %v", err)
+	}
+	if status <= ExitSignalBase {
+		t.Errorf("status = %d, want more than %d", status, ExitSignalBase)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("read took %v to time out", elapsed)
+	}
+
+	// Cancelling the context interrupts a read that has no timeout
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	cmd.Args = []string{"a"}
+	if _, err := cmd.Execute(ctx, cfg); err == nil {
+		t.Error("Execute() with a cancelled context succeeded")
+	}
+}
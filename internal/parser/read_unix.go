@@ -0,0 +1,28 @@
+//go:build unix
+
+package parser
+
+import (
+	"os"
+	"syscall"
+)
+
+// pollableFile returns a copy of f in non-blocking mode, whose reads can
+// time out, and a function that restores the mode and closes the copy.
+// Like the terminal, the mode is shared with f while the copy is open.
+func pollableFile(f *os.File) (*os.File, func(), error) {
+	fd, err := syscall.Dup(int(f.Fd()))
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := syscall.SetNonblock(fd, true); err != nil {
+		_ = syscall.Close(fd)
+		return nil, nil, err
+	}
+
+	file := os.NewFile(uintptr(fd), f.Name())
+	return file, func() {
+		_ = syscall.SetNonblock(fd, false)
+		_ = file.Close()
+	}, nil
+}
@@ -0,0 +1,14 @@
+//go:build windows
+
+package parser
+
+import (
+	"errors"
+	"os"
+)
+
+// pollableFile reports that Windows has no way to make reads of f time
+// out, so read waits for them in another goroutine
+func pollableFile(_ *os.File) (*os.File, func(), error) {
+	return nil, nil, errors.New("reads cannot time out")
+}
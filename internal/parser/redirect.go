@@ -0,0 +1,363 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"gosh/internal/config"
+)
+
+const (
+	// RedirectFilePermissions is the mode used for files created by a
+	// redirection, before the umask is applied
+	RedirectFilePermissions = 0666
+
+	// maxDescriptor is the highest descriptor a redirection may use
+	maxDescriptor = 255
+)
+
+// RedirectOp identifies the kind of a redirection
+type RedirectOp int
+
+const (
+	// RedirectIn opens a file for reading (<)
+	RedirectIn RedirectOp = iota
+	// RedirectOut truncates or creates a file for writing (>)
+	RedirectOut
+	// RedirectClobber is like RedirectOut but ignores noclobber (>|)
+	RedirectClobber
+	// RedirectAppend opens a file for appending (>>)
+	RedirectAppend
+	// RedirectReadWrite opens a file for reading and writing (<>)
+	RedirectReadWrite
+	// RedirectDupIn duplicates or closes an input descriptor (<&)
+	RedirectDupIn
+	// RedirectDupOut duplicates or closes an output descriptor (>&)
+	RedirectDupOut
+	// RedirectAll sends both stdout and stderr to a file (&>)
+	RedirectAll
+	// RedirectAllAppend appends both stdout and stderr to a file (&>>)
+	RedirectAllAppend
+	// RedirectHereString feeds a word followed by a newline to stdin (<<<)
+	RedirectHereString
+	// RedirectHereDoc feeds the lines up to a delimiter to stdin (<<)
+	RedirectHereDoc
+	// RedirectHereDocStrip is a here-document with leading tabs removed (<<-)
+	RedirectHereDocStrip
+)
+
+// redirectOps maps operator text to its RedirectOp and default descriptor
+var redirectOps = map[string]struct {
+	op RedirectOp
+	fd int
+}{
+	"<":   {RedirectIn, 0},
+	">":   {RedirectOut, 1},
+	">|":  {RedirectClobber, 1},
+	">>":  {RedirectAppend, 1},
+	"<>":  {RedirectReadWrite, 0},
+	"<&":  {RedirectDupIn, 0},
+	">&":  {RedirectDupOut, 1},
+	"&>":  {RedirectAll, 1},
+	"&>>": {RedirectAllAppend, 1},
+	"<<<": {RedirectHereString, 0},
+	"<<":  {RedirectHereDoc, 0},
+	"<<-": {RedirectHereDocStrip, 0},
+}
+
+// Redirect describes one redirection of a command's file descriptor.
+// For here-documents Target holds the text of the document.
+type Redirect struct {
+	Fd     int
+	Op     RedirectOp
+	Target string
+	// Literal marks a here-document whose quoted delimiter disables expansion
+	Literal bool
+}
+
+// RedirectedCommand runs a command with its standard streams redirected.
+// Redirections are applied left to right, so "> out 2>&1" sends both
+// streams to out while "2>&1 > out" leaves stderr on the original stdout.
+type RedirectedCommand struct {
+	Command   Command
+	Redirects []Redirect
+	// parser expands the targets when the command runs; without one the
+	// targets are used as they are
+	parser *Parser
+}
+
+// Execute implements the Command interface for RedirectedCommand
+func (c *RedirectedCommand) Execute(ctx context.Context, cfg *config.Config) (status int, err error) {
+	streams := IOFromContext(ctx)
+	// The descriptors above 2 are shared with the caller until changed
+	streams.Extra = maps.Clone(streams.Extra)
+
+	var opened []*os.File
+	defer func() {
+		for _, f := range opened {
+			if closeErr := f.Close(); closeErr != nil && err == nil {
+				status, err = ExitFailure, closeErr
+			}
+		}
+	}()
+
+	for _, r := range c.Redirects {
+		if c.parser != nil {
+			r, err = c.parser.withConfig(cfg).expandRedirect(ctx, r)
+			if err != nil {
+				return ExitFailure, err
+			}
+		}
+
+		f, applyErr := r.apply(ctx, &streams, cfg)
+		if f != nil {
+			opened = append(opened, f)
+		}
+		if applyErr != nil {
+			return ExitFailure, applyErr
+		}
+	}
+
+	// The command's own errors go to its redirected stderr
+	ctx = WithIO(ctx, streams)
+	status, err = c.Command.Execute(ctx, cfg)
+	if isControlFlow(err) {
+		return status, err
+	}
+	reportError(ctx, err)
+	return status, nil
+}
+
+// expandRedirect expands the target of a redirection. A file or descriptor
+// is split and matched against file names like a command word, and must
+// come out as a single word.
+func (p *Parser) expandRedirect(ctx context.Context, r Redirect) (Redirect, error) {
+	var err error
+	switch {
+	case r.Literal:
+	case r.Op == RedirectHereDoc || r.Op == RedirectHereDocStrip:
+		r.Target, err = p.expandHereDoc(ctx, r.Target)
+	case r.Op == RedirectHereString:
+		r.Target, err = p.expandString(ctx, r.Target)
+	default:
+		var fields []string
+		if fields, err = p.expandWord(ctx, r.Target); err != nil {
+			break
+		}
+		if len(fields) != 1 {
+			return r, fmt.Errorf("%s: ambiguous redirect", r.Target)
+		}
+		r.Target = fields[0]
+	}
+	return r, err
+}
+
+// apply performs the redirection on streams. Any file it opens is returned
+// so that the caller can close it once the command has finished.
+func (r Redirect) apply(ctx context.Context, streams *IO, cfg *config.Config) (*os.File, error) {
+	if r.Fd < 0 || r.Fd > maxDescriptor {
+		return nil, fmt.Errorf("%d: bad file descriptor", r.Fd)
+	}
+
+	switch r.Op {
+	case RedirectHereString, RedirectHereDoc, RedirectHereDocStrip:
+		text := r.Target
+		if r.Op == RedirectHereString {
+			text += "\n"
+		}
+		if r.Fd <= 2 {
+			return nil, setStream(streams, r.Fd, strings.NewReader(text))
+		}
+		f, err := pipeFrom(strings.NewReader(text))
+		if err != nil {
+			return nil, err
+		}
+		return f, setStream(streams, r.Fd, f)
+	case RedirectDupIn, RedirectDupOut:
+		return r.duplicate(streams)
+	case RedirectAll, RedirectAllAppend:
+		f, err := openRedirectFile(ctx, r.Target, r.Op, cfg)
+		if err != nil {
+			return nil, err
+		}
+		streams.Stdout = f
+		streams.Stderr = f
+		return f, nil
+	default:
+		f, err := openRedirectFile(ctx, r.Target, r.Op, cfg)
+		if err != nil {
+			return nil, err
+		}
+		return f, setStream(streams, r.Fd, f)
+	}
+}
+
+// duplicate handles n<&m, n>&m and the n>&- close form. Duplicating input
+// that is not a file to a descriptor above 2 turns it into a pipe, which
+// both descriptors then share; the pipe is returned to be closed.
+func (r Redirect) duplicate(streams *IO) (*os.File, error) {
+	if r.Target == "-" {
+		if r.Fd > 2 {
+			delete(streams.Extra, r.Fd)
+			return nil, nil
+		}
+		return nil, setStream(streams, r.Fd, closedStream{})
+	}
+
+	source, err := strconv.Atoi(r.Target)
+	if err != nil || source < 0 {
+		return nil, fmt.Errorf("%s: ambiguous redirect", r.Target)
+	}
+	stream, ok := getStream(streams, source)
+	if !ok {
+		return nil, fmt.Errorf("%d: bad file descriptor", source)
+	}
+
+	// The copy must work in the direction of the operator
+	if _, ok := stream.(io.Writer); r.Op == RedirectDupOut && !ok {
+		return nil, fmt.Errorf("%d: bad file descriptor", source)
+	}
+	if r.Op == RedirectDupOut {
+		return nil, setStream(streams, r.Fd, stream)
+	}
+	reader, ok := stream.(io.Reader)
+	if !ok {
+		return nil, fmt.Errorf("%d: bad file descriptor", source)
+	}
+	if _, isFile := reader.(*os.File); isFile || r.Fd <= 2 {
+		return nil, setStream(streams, r.Fd, reader)
+	}
+
+	f, err := pipeFrom(reader)
+	if err != nil {
+		return nil, err
+	}
+	if err := setStream(streams, source, f); err != nil {
+		return f, err
+	}
+	return f, setStream(streams, r.Fd, f)
+}
+
+// pipeFrom returns the read end of a pipe that gives what r holds, for
+// input on a descriptor above 2, which a process can only be given as a
+// file. The copy ends when r is drained or the read end is closed.
+func pipeFrom(r io.Reader) (*os.File, error) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		_, _ = io.Copy(pw, r)
+		_ = pw.Close()
+	}()
+	return pr, nil
+}
+
+// openRedirectFile opens the target of a file redirection, honoring
+// noclobber. A relative target is in the shell's current directory.
+func openRedirectFile(ctx context.Context, target string, op RedirectOp, cfg *config.Config) (*os.File, error) {
+	if target == "" {
+		return nil, errors.New("ambiguous redirect")
+	}
+
+	var flags int
+	switch op {
+	case RedirectIn:
+		flags = os.O_RDONLY
+	case RedirectReadWrite:
+		flags = os.O_RDWR | os.O_CREATE
+	case RedirectAppend, RedirectAllAppend:
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	case RedirectOut, RedirectAll:
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		if cfg.Noclobber {
+			if info, err := os.Stat(resolvePath(ctx, target)); err == nil && info.Mode().IsRegular() {
+				return nil, fmt.Errorf("%s: cannot overwrite existing file", target)
+			}
+		}
+	default:
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+
+	f, err := os.OpenFile(resolvePath(ctx, target), flags, RedirectFilePermissions)
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) {
+		// Errors name the file as it was written
+		pathErr.Path = target
+	}
+	return f, err
+}
+
+// getStream returns the stream currently connected to descriptor fd, or
+// false if the descriptor is not open
+func getStream(streams *IO, fd int) (interface{}, bool) {
+	var stream interface{}
+	switch fd {
+	case 0:
+		stream = streams.Stdin
+	case 1:
+		stream = streams.Stdout
+	case 2:
+		stream = streams.Stderr
+	default:
+		stream = streams.Extra[fd]
+	}
+	if _, closed := stream.(closedStream); closed || stream == nil {
+		return nil, false
+	}
+	return stream, true
+}
+
+// setStream connects descriptor fd to stream, checking that a standard
+// stream can be used in the direction the descriptor needs
+func setStream(streams *IO, fd int, stream interface{}) error {
+	if fd > 2 {
+		if streams.Extra == nil {
+			streams.Extra = make(map[int]interface{})
+		}
+		streams.Extra[fd] = stream
+		return nil
+	}
+
+	if fd == 0 {
+		reader, ok := stream.(io.Reader)
+		if !ok {
+			return fmt.Errorf("%d: bad file descriptor", fd)
+		}
+		streams.Stdin = reader
+		return nil
+	}
+
+	writer, ok := stream.(io.Writer)
+	if !ok {
+		return fmt.Errorf("%d: bad file descriptor", fd)
+	}
+	if fd == 1 {
+		streams.Stdout = writer
+	} else {
+		streams.Stderr = writer
+	}
+	return nil
+}
+
+// closedStream stands for a standard descriptor closed with n>&- or n<&-.
+// Built-ins fail to use it; a process gets the null device instead, as it
+// cannot be started with a standard descriptor closed.
+type closedStream struct{}
+
+// Read implements io.Reader for closedStream
+func (closedStream) Read([]byte) (int, error) {
+	return 0, fmt.Errorf("read error: %w", syscall.EBADF)
+}
+
+// Write implements io.Writer for closedStream
+func (closedStream) Write([]byte) (int, error) {
+	return 0, fmt.Errorf("write error: %w", syscall.EBADF)
+}
@@ -0,0 +1,198 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gosh/internal/config"
+)
+
+func TestRedirectExecute(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "existing.txt")
+	if err := os.WriteFile(existing, []byte("old\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	input := filepath.Join(dir, "input.txt")
+	if err := os.WriteFile(input, []byte("from file\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		input      string
+		noclobber  bool
+		file       string
+		wantFile   string
+		wantStdout string
+		wantStatus int
+		wantErr    bool
+	}{
+		{
+			name:     "builtin output to file",
+			input:    "help > " + filepath.Join(dir, "help.txt"),
+			file:     filepath.Join(dir, "help.txt"),
+			wantFile: "Gosh - A modern shell written in Go\n",
+		},
+		{
+			name:     "append",
+			input:    "echo new >> " + existing,
+			file:     existing,
+			wantFile: "old\nnew\n",
+		},
+		{
+			name:       "input from file",
+			input:      "cat < " + input,
+			wantStdout: "from file\n",
+		},
+		{
+			name:     "stdout and stderr to file",
+			input:    "sh -c 'echo out; echo err >&2' > " + filepath.Join(dir, "both.txt") + " 2>&1",
+			file:     filepath.Join(dir, "both.txt"),
+			wantFile: "out\nerr\n",
+		},
+		{
+			name:     "ampersand redirect",
+			input:    "sh -c 'echo err >&2' &> " + filepath.Join(dir, "all.txt"),
+			file:     filepath.Join(dir, "all.txt"),
+			wantFile: "err\n",
+		},
+		{
+			name:       "duplicate before redirect keeps old stdout",
+			input:      "sh -c 'echo err >&2' 2>&1 > " + filepath.Join(dir, "out.txt"),
+			wantStdout: "err\n",
+		},
+		{
+			name:       "here-string",
+			input:      "cat <<< 'hello there'",
+			wantStdout: "hello there\n",
+		},
+		{
+			name:      "noclobber refuses to overwrite",
+			input:     "echo clobbered > " + input,
+			noclobber: true,
+			file:      input,
+			wantFile:  "from file\n",
+			wantErr:   true,
+		},
+		{
+			name:      "clobber operator overrides noclobber",
+			input:     "echo clobbered >| " + existing,
+			noclobber: true,
+			file:      existing,
+			wantFile:  "clobbered\n",
+		},
+		{
+			name:    "missing input file",
+			input:   "cat < " + filepath.Join(dir, "missing.txt"),
+			wantErr: true,
+		},
+		{
+			name:     "builtin writes to descriptor 3",
+			input:    "{ echo three >&3; } 3> " + filepath.Join(dir, "fd3.txt"),
+			file:     filepath.Join(dir, "fd3.txt"),
+			wantFile: "three\n",
+		},
+		{
+			name:       "process writes to descriptor 3 through a buffer",
+			input:      "sh -c 'echo three >&3' 3>&1",
+			wantStdout: "three\n",
+		},
+		{
+			name:       "swap stdout and stderr through descriptor 3",
+			input:      "sh -c 'echo out; echo err >&2' 2> " + filepath.Join(dir, "swap.txt") + " 3>&1 1>&2 2>&3 3>&-",
+			file:       filepath.Join(dir, "swap.txt"),
+			wantFile:   "out\n",
+			wantStdout: "err\n",
+		},
+		{
+			name:       "here-string on descriptor 3",
+			input:      "cat 3<<< three <&3",
+			wantStdout: "three\n",
+		},
+		{
+			name:    "descriptor that is not open",
+			input:   "echo x >&5",
+			wantErr: true,
+		},
+		{
+			name:    "pattern matching several files is ambiguous",
+			input:   "echo x > " + filepath.Join(dir, "*.txt"),
+			wantErr: true,
+		},
+		{
+			name:       "closed stdout fails a builtin",
+			input:      "echo a >&-",
+			wantStdout: "gosh: write error: bad file descriptor\n",
+			wantStatus: 1,
+		},
+		{
+			name:       "builtin error to redirected stderr",
+			input:      "cd " + filepath.Join(dir, "missing") + " 2>/dev/null",
+			wantStatus: 1,
+		},
+		{
+			name:       "command not found to redirected stderr",
+			input:      "nonexistentcommand123 2>/dev/null",
+			wantStatus: 127,
+		},
+		{
+			name:       "errors in a group to its stderr",
+			input:      "{ nonexistentcommand123; } 2> " + filepath.Join(dir, "group.txt"),
+			file:       filepath.Join(dir, "group.txt"),
+			wantFile:   "gosh: command not found: nonexistentcommand123\n",
+			wantStatus: 127,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.Noclobber = tt.noclobber
+			parser := New(cfg)
+
+			cmd, err := parser.Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse() failed: %v", err)
+			}
+
+			var stdout bytes.Buffer
+			ctx := WithIO(context.Background(), IO{Stdin: strings.NewReader(""), Stdout: &stdout, Stderr: &stdout})
+			status, err := cmd.Execute(ctx, cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Execute() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && status != tt.wantStatus {
+				t.Errorf("Execute() status = %d, want %d", status, tt.wantStatus)
+			}
+
+			if stdout.String() != tt.wantStdout {
+				t.Errorf("stdout = %q, want %q", stdout.String(), tt.wantStdout)
+			}
+
+			if tt.file != "" {
+				content, err := os.ReadFile(tt.file)
+				if err != nil {
+					t.Fatalf("Failed to read %s: %v", tt.file, err)
+				}
+				if !strings.HasPrefix(string(content), tt.wantFile) {
+					t.Errorf("file content = %q, want prefix %q", content, tt.wantFile)
+				}
+			}
+		})
+	}
+}
+
+func TestParseRedirectErrors(t *testing.T) {
+	parser := New(config.Default())
+
+	for _, input := range []string{"echo >", "cat < | wc", "ls 2>"} {
+		if _, err := parser.Parse(input); err == nil {
+			t.Errorf("Parse(%q) succeeded, want syntax error", input)
+		}
+	}
+}
@@ -0,0 +1,614 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"gosh/internal/config"
+	"gosh/internal/lexer"
+)
+
+// DefaultPath is the PATH that command -p searches, which finds the
+// standard utilities whatever PATH is set to
+const DefaultPath = "/usr/bin:/bin:/usr/sbin:/sbin"
+
+// CommandKind is what a command name refers to
+type CommandKind int
+
+const (
+	// KindAlias is an alias, expanded before the command is parsed
+	KindAlias CommandKind = iota
+	// KindKeyword is a reserved word such as if or while
+	KindKeyword
+	// KindFunction is a shell function
+	KindFunction
+	// KindBuiltin is a built-in command
+	KindBuiltin
+	// KindFile is a program found in PATH, or given by its path
+	KindFile
+)
+
+// String returns the word type -t prints for the kind
+func (k CommandKind) String() string {
+	switch k {
+	case KindAlias:
+		return "alias"
+	case KindKeyword:
+		return "keyword"
+	case KindFunction:
+		return "function"
+	case KindBuiltin:
+		return "builtin"
+	default:
+		return "file"
+	}
+}
+
+// Resolution is one of the things a command name refers to
+type Resolution struct {
+	Name string
+	Kind CommandKind
+	// Value is the text of an alias or function, or the path of a program
+	Value string
+	// Hashed is set for a program whose path was taken from the hash table
+	Hashed bool
+}
+
+// describe returns how type and command -V describe the resolution
+func (r Resolution) describe() string {
+	switch r.Kind {
+	case KindAlias:
+		return fmt.Sprintf("%s is aliased to `%s'", r.Name, r.Value)
+	case KindKeyword:
+		return fmt.Sprintf("%s is a shell keyword", r.Name)
+	case KindFunction:
+		return fmt.Sprintf("%s is a function\n%s", r.Name, r.Value)
+	case KindBuiltin:
+		return fmt.Sprintf("%s is a shell builtin", r.Name)
+	}
+	if r.Hashed {
+		return fmt.Sprintf("%s is hashed (%s)", r.Name, r.Value)
+	}
+	return fmt.Sprintf("%s is %s", r.Name, r.Value)
+}
+
+// resolveOptions select what resolve looks for
+type resolveOptions struct {
+	// all returns every resolution, with each program of the name in PATH,
+	// rather than the one the shell would run
+	all bool
+	// noFunctions skips shell functions, as type -f and command do
+	noFunctions bool
+	// pathOnly only searches PATH, as type -P does
+	pathOnly bool
+	// path is the PATH to search, if not the shell's
+	path string
+}
+
+// resolve tells what name runs as a command, looking in the order the
+// shell does: aliases, keywords, functions, built-ins and then programs.
+// The hash table is used but not changed.
+func (p *Parser) resolve(ctx context.Context, name string, opts resolveOptions) []Resolution {
+	var found []Resolution
+	if !opts.pathOnly {
+		if value, ok := p.config.Aliases[name]; ok {
+			found = append(found, Resolution{Name: name, Kind: KindAlias, Value: value})
+		}
+		if lexer.IsReserved(name) {
+			found = append(found, Resolution{Name: name, Kind: KindKeyword})
+		}
+		if fn, ok := StateFromContext(ctx).Functions[name]; ok && !opts.noFunctions {
+			found = append(found, Resolution{Name: name, Kind: KindFunction, Value: fn.Text})
+		}
+		if IsBuiltin(name) {
+			found = append(found, Resolution{Name: name, Kind: KindBuiltin})
+		}
+		if len(found) > 0 && !opts.all {
+			return found[:1]
+		}
+	}
+
+	path := opts.path
+	if path == "" {
+		path = p.getVariable("PATH")
+		if program, ok := p.hash.get(name, path, false); ok && !opts.all {
+			return append(found, Resolution{Name: name, Kind: KindFile, Value: program, Hashed: true})
+		}
+	}
+	for _, program := range searchPath(name, path, StateFromContext(ctx).WorkDir(), opts.all) {
+		found = append(found, Resolution{Name: name, Kind: KindFile, Value: program})
+	}
+	return found
+}
+
+// findProgram finds the program that runs for name, from the hash table
+// or by searching PATH, and hashes it for the next time
+func (p *Parser) findProgram(ctx context.Context, name string) (string, error) {
+	path, dir := p.getVariable("PATH"), StateFromContext(ctx).WorkDir()
+	if hasPathSeparator(name) {
+		return lookPath(name, path, dir)
+	}
+	if program, ok := p.hash.get(name, path, true); ok {
+		return program, nil
+	}
+
+	program, err := lookPath(name, path, dir)
+	if err != nil {
+		return "", err
+	}
+	p.hash.set(name, program, path, 1)
+	return program, nil
+}
+
+// hashEntry is a program in the hash table, with the number of times it
+// was run from there
+type hashEntry struct {
+	program string
+	hits    int
+}
+
+// hashTable remembers where the programs run by name were found, so that
+// PATH is not searched again for them. It is emptied when PATH changes.
+// Pipeline stages and background jobs share it with the shell.
+type hashTable struct {
+	mu      sync.Mutex
+	path    string
+	entries map[string]hashEntry
+}
+
+// newHashTable creates an empty hash table
+func newHashTable() *hashTable {
+	return &hashTable{entries: make(map[string]hashEntry)}
+}
+
+// sync empties the table if PATH is no longer the one it was filled from.
+// The caller holds the lock.
+func (h *hashTable) sync(path string) {
+	if path != h.path {
+		h.path = path
+		clear(h.entries)
+	}
+}
+
+// get returns the program hashed for name under path. A program that no
+// longer exists is forgotten. With hit set the lookup counts as a run.
+func (h *hashTable) get(name, path string, hit bool) (string, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sync(path)
+
+	entry, ok := h.entries[name]
+	if !ok {
+		return "", false
+	}
+	if _, err := os.Stat(entry.program); err != nil {
+		delete(h.entries, name)
+		return "", false
+	}
+	if hit {
+		entry.hits++
+		h.entries[name] = entry
+	}
+	return entry.program, true
+}
+
+// set hashes program for name under path
+func (h *hashTable) set(name, program, path string, hits int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sync(path)
+	h.entries[name] = hashEntry{program: program, hits: hits}
+}
+
+// remove forgets the program hashed for name and reports whether there was
+// one
+func (h *hashTable) remove(name, path string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sync(path)
+
+	_, ok := h.entries[name]
+	delete(h.entries, name)
+	return ok
+}
+
+// reset forgets every program
+func (h *hashTable) reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	clear(h.entries)
+}
+
+// names returns the names hashed under path in order
+func (h *hashTable) names(path string) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sync(path)
+
+	names := make([]string, 0, len(h.entries))
+	for name := range h.entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// entry returns the entry hashed for name
+func (h *hashTable) entry(name string) (hashEntry, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	entry, ok := h.entries[name]
+	return entry, ok
+}
+
+// TypeCommand implements the type built-in command, which tells how each
+// name would be run as a command. -a shows every alias, function, built-in
+// and program of the name, -f skips functions, -t prints only the kind,
+// -p prints only the path of a program and -P searches PATH even for the
+// name of an alias, function or built-in.
+type TypeCommand struct {
+	Args   []string
+	parser *Parser
+}
+
+// Execute implements the Command interface for TypeCommand
+func (c *TypeCommand) Execute(ctx context.Context, cfg *config.Config) (int, error) {
+	var opts resolveOptions
+	var kindOnly, pathOnly bool
+	args := c.Args
+	for len(args) > 0 && len(args[0]) > 1 && args[0][0] == '-' {
+		if args[0] == "--" {
+			args = args[1:]
+			break
+		}
+		for _, flag := range args[0][1:] {
+			switch flag {
+			case 'a':
+				opts.all = true
+			case 'f':
+				opts.noFunctions = true
+			case 't':
+				kindOnly = true
+			case 'p':
+				pathOnly = true
+			case 'P':
+				pathOnly, opts.pathOnly = true, true
+			default:
+				return ExitUsage, fmt.Errorf("type: -%c: invalid option", flag)
+			}
+		}
+		args = args[1:]
+	}
+
+	p := c.parser.withConfig(cfg)
+	out := IOFromContext(ctx).Stdout
+	status := 0
+	for _, name := range args {
+		found := p.resolve(ctx, name, opts)
+		if len(found) == 0 {
+			if !kindOnly && !pathOnly {
+				reportError(ctx, fmt.Errorf("type: %s: not found", name))
+			}
+			status = ExitFailure
+			continue
+		}
+
+		for _, r := range found {
+			var err error
+			switch {
+			case kindOnly:
+				_, err = fmt.Fprintln(out, r.Kind)
+			case pathOnly:
+				// Only programs have a path, and with -p only when they are
+				// what the name runs
+				if r.Kind == KindFile && (opts.pathOnly || opts.all || found[0].Kind == KindFile) {
+					_, err = fmt.Fprintln(out, r.Value)
+				}
+			default:
+				_, err = fmt.Fprintln(out, r.describe())
+			}
+			if err != nil {
+				return ExitFailure, err
+			}
+		}
+	}
+	return status, nil
+}
+
+// WhichCommand implements the which built-in command, which prints the
+// path of the program each name runs from PATH, or with -a of every
+// program of that name. The status is 1 if one is not found.
+type WhichCommand struct {
+	Args   []string
+	parser *Parser
+}
+
+// Execute implements the Command interface for WhichCommand
+func (c *WhichCommand) Execute(ctx context.Context, cfg *config.Config) (int, error) {
+	args := c.Args
+	all := false
+	for len(args) > 0 && len(args[0]) > 1 && args[0][0] == '-' {
+		if args[0] == "--" {
+			args = args[1:]
+			break
+		}
+		if args[0] != "-a" {
+			return ExitUsage, fmt.Errorf("which: %s: invalid option", args[0])
+		}
+		all = true
+		args = args[1:]
+	}
+
+	path := c.parser.withConfig(cfg).getVariable("PATH")
+	dir := StateFromContext(ctx).WorkDir()
+	out := IOFromContext(ctx).Stdout
+	status := 0
+	for _, name := range args {
+		programs := searchPath(name, path, dir, all)
+		if len(programs) == 0 {
+			status = ExitFailure
+			continue
+		}
+		for _, program := range programs {
+			if _, err := fmt.Fprintln(out, program); err != nil {
+				return ExitFailure, err
+			}
+		}
+	}
+	return status, nil
+}
+
+// CommandCommand implements the command built-in command, which runs a
+// built-in or program without looking for a function of the same name.
+// With -v it prints what each name runs, in a form that can be used again,
+// and with -V describes it as type does. -p searches DefaultPath instead of
+// PATH.
+type CommandCommand struct {
+	Args   []string
+	parser *Parser
+}
+
+// Execute implements the Command interface for CommandCommand
+func (c *CommandCommand) Execute(ctx context.Context, cfg *config.Config) (int, error) {
+	var defaultPath, short, verbose bool
+	args := c.Args
+	for len(args) > 0 && len(args[0]) > 1 && args[0][0] == '-' {
+		if args[0] == "--" {
+			args = args[1:]
+			break
+		}
+		for _, flag := range args[0][1:] {
+			switch flag {
+			case 'p':
+				defaultPath = true
+			case 'v':
+				short = true
+			case 'V':
+				verbose = true
+			default:
+				return ExitUsage, fmt.Errorf("command: -%c: invalid option", flag)
+			}
+		}
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		return 0, nil
+	}
+
+	p := c.parser.withConfig(cfg)
+	opts := resolveOptions{noFunctions: !short && !verbose}
+	if defaultPath {
+		opts.path = DefaultPath
+	}
+	if short || verbose {
+		return c.describe(ctx, p, args, opts, verbose)
+	}
+
+	if builtin := p.parseBuiltin(args); builtin != nil {
+		return builtin.Execute(ctx, cfg)
+	}
+	program, err := p.findProgram(ctx, args[0])
+	if defaultPath {
+		program, err = lookPath(args[0], DefaultPath, StateFromContext(ctx).WorkDir())
+	}
+	if err != nil {
+		return ExitCommandNotFound, fmt.Errorf("command not found: %s", args[0])
+	}
+	return (&ExternalCommand{Name: args[0], Args: args[1:], Path: program}).Execute(ctx, cfg)
+}
+
+// describe prints what each name runs for command -v, or -V when verbose
+// is set. Like bash, the status is 1 only if none of the names is found.
+func (c *CommandCommand) describe(ctx context.Context, p *Parser, names []string, opts resolveOptions, verbose bool) (int, error) {
+	out := IOFromContext(ctx).Stdout
+	status := ExitFailure
+	for _, name := range names {
+		found := p.resolve(ctx, name, opts)
+		if len(found) == 0 {
+			if verbose {
+				reportError(ctx, fmt.Errorf("command: %s: not found", name))
+			}
+			continue
+		}
+		status = 0
+
+		r := found[0]
+		text := r.describe()
+		if !verbose {
+			switch r.Kind {
+			case KindAlias:
+				text = "alias " + name + "=" + quoteValue(r.Value)
+			case KindFile:
+				text = r.Value
+			default:
+				text = name
+			}
+		}
+		if _, err := fmt.Fprintln(out, text); err != nil {
+			return ExitFailure, err
+		}
+	}
+	return status, nil
+}
+
+// BuiltinCommand implements the builtin built-in command, which runs a
+// built-in even when a function of the same name is defined
+type BuiltinCommand struct {
+	Args   []string
+	parser *Parser
+}
+
+// Execute implements the Command interface for BuiltinCommand
+func (c *BuiltinCommand) Execute(ctx context.Context, cfg *config.Config) (int, error) {
+	if len(c.Args) == 0 {
+		return 0, nil
+	}
+	builtin := c.parser.withConfig(cfg).parseBuiltin(c.Args)
+	if builtin == nil {
+		return ExitFailure, fmt.Errorf("builtin: %s: not a shell builtin", c.Args[0])
+	}
+	return builtin.Execute(ctx, cfg)
+}
+
+// HashCommand implements the hash built-in command, which manages the table
+// of programs found in PATH. Without arguments it lists them with the
+// number of times each was run; names are looked up and added, -r empties
+// the table, -d forgets names, -p path hashes a name to path, -t prints
+// the paths of names and -l lists the table as hash commands.
+type HashCommand struct {
+	Args   []string
+	parser *Parser
+}
+
+// Execute implements the Command interface for HashCommand
+func (c *HashCommand) Execute(ctx context.Context, cfg *config.Config) (int, error) {
+	var reset, remove, printPaths, reusable bool
+	var program string
+	args := c.Args
+options:
+	for len(args) > 0 && len(args[0]) > 1 && args[0][0] == '-' {
+		if args[0] == "--" {
+			args = args[1:]
+			break
+		}
+		flags := args[0][1:]
+		args = args[1:]
+		for i, flag := range flags {
+			switch flag {
+			case 'r':
+				reset = true
+			case 'd':
+				remove = true
+			case 't':
+				printPaths = true
+			case 'l':
+				reusable = true
+			case 'p':
+				// The path is the rest of the argument, or the next one
+				if program = flags[i+1:]; program == "" {
+					if len(args) == 0 {
+						return ExitUsage, errors.New("hash: -p: option requires an argument")
+					}
+					program, args = args[0], args[1:]
+				}
+				continue options
+			default:
+				return ExitUsage, fmt.Errorf("hash: -%c: invalid option", flag)
+			}
+		}
+	}
+
+	p := c.parser.withConfig(cfg)
+	path := p.getVariable("PATH")
+	out := IOFromContext(ctx).Stdout
+	if reset {
+		p.hash.reset()
+	}
+	if len(args) == 0 {
+		if reset || remove || printPaths || program != "" {
+			return 0, nil
+		}
+		return builtinStatus(c.list(out, p.hash, path, reusable))
+	}
+
+	status := 0
+	for _, name := range args {
+		var err error
+		switch {
+		case program != "":
+			p.hash.set(name, program, path, 0)
+		case remove:
+			if !p.hash.remove(name, path) {
+				err = fmt.Errorf("hash: %s: not found", name)
+			}
+		case printPaths:
+			hashed, ok := p.hash.get(name, path, false)
+			switch {
+			case !ok:
+				err = fmt.Errorf("hash: %s: not found", name)
+			case len(args) > 1:
+				_, err = fmt.Fprintf(out, "%s\t%s\n", name, hashed)
+			default:
+				_, err = fmt.Fprintln(out, hashed)
+			}
+		case IsBuiltin(name) || hasPathSeparator(name):
+			// Only programs found in PATH are hashed
+		default:
+			found, lookErr := lookPath(name, path, StateFromContext(ctx).WorkDir())
+			if lookErr != nil {
+				err = fmt.Errorf("hash: %s: not found", name)
+				break
+			}
+			p.hash.set(name, found, path, 0)
+		}
+		if err != nil {
+			reportError(ctx, err)
+			status = ExitFailure
+		}
+	}
+	return status, nil
+}
+
+// list prints the hashed programs with their hits, or with reusable set as
+// the commands that hash them again
+func (c *HashCommand) list(out io.Writer, hash *hashTable, path string, reusable bool) error {
+	names := hash.names(path)
+	if len(names) == 0 {
+		_, err := fmt.Fprintln(out, "hash: hash table empty")
+		return err
+	}
+
+	if !reusable {
+		if _, err := fmt.Fprintln(out, "hits\tcommand"); err != nil {
+			return err
+		}
+	}
+	for _, name := range names {
+		entry, ok := hash.entry(name)
+		if !ok {
+			continue
+		}
+		var err error
+		if reusable {
+			_, err = fmt.Fprintf(out, "builtin hash -p %s %s\n", quoteIfNeeded(entry.program), quoteIfNeeded(name))
+		} else {
+			_, err = fmt.Fprintf(out, "%4d\t%s\n", entry.hits, entry.program)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasPathSeparator reports whether a command name is a path, which is run
+// as it is rather than searched for
+func hasPathSeparator(name string) bool {
+	return strings.ContainsAny(name, `/`+string(os.PathSeparator))
+}
@@ -0,0 +1,238 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"gosh/internal/config"
+)
+
+// writeProgram writes an executable script called name to dir
+func writeProgram(t *testing.T, dir, name string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\necho "+name+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResolutionBuiltins(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test programs are shell scripts")
+	}
+
+	// BIN1 and BIN2 stand for two directories of PATH, which both have a
+	// program called both
+	bin1, bin2 := t.TempDir(), t.TempDir()
+	writeProgram(t, bin1, "prog")
+	writeProgram(t, bin1, "both")
+	writeProgram(t, bin2, "both")
+	writeProgram(t, bin2, "other")
+
+	tests := []struct {
+		name       string
+		input      string
+		wantOutput string
+		wantStderr string
+		wantStatus int
+	}{
+		{
+			name:       "type of each kind of name",
+			input:      "alias ll='ls -l'; type ll if cd prog",
+			wantOutput: "ll is aliased to `ls -l'\nif is a shell keyword\ncd is a shell builtin\nprog is BIN1/prog\n",
+		},
+		{
+			name:       "type -t",
+			input:      "f() { :; }; type -t f cd while prog",
+			wantOutput: "function\nbuiltin\nkeyword\nfile\n",
+		},
+		{
+			name:       "type -a lists every match",
+			input:      "both() { :; }; type -a -f both",
+			wantOutput: "both is BIN1/both\nboth is BIN2/both\n",
+		},
+		{
+			name:       "type -p prints only programs",
+			input:      "type -p cd prog",
+			wantOutput: "BIN1/prog\n",
+		},
+		{
+			name:       "type -P searches PATH for any name",
+			input:      "prog() { :; }; type -P prog",
+			wantOutput: "BIN1/prog\n",
+		},
+		{
+			name:       "type of an unknown name",
+			input:      "type prog missing",
+			wantOutput: "prog is BIN1/prog\n",
+			wantStderr: "type: missing: not found",
+			wantStatus: 1,
+		},
+		{
+			name:       "which",
+			input:      "which prog other",
+			wantOutput: "BIN1/prog\nBIN2/other\n",
+		},
+		{
+			name:       "which -a and a missing program",
+			input:      "which -a both missing",
+			wantOutput: "BIN1/both\nBIN2/both\n",
+			wantStatus: 1,
+		},
+		{
+			name:       "which ignores built-ins",
+			input:      "which cd",
+			wantStatus: 1,
+		},
+		{
+			name:       "command -v",
+			input:      "alias ll='ls -l'; f() { :; }; command -v ll f cd if prog missing",
+			wantOutput: "alias ll='ls -l'\nf\ncd\nif\nBIN1/prog\n",
+		},
+		{
+			name:       "command -v of a missing name",
+			input:      "command -v missing",
+			wantStatus: 1,
+		},
+		{
+			name:       "command -V",
+			input:      "command -V cd prog missing",
+			wantOutput: "cd is a shell builtin\nprog is BIN1/prog\n",
+			wantStderr: "command: missing: not found",
+		},
+		{
+			name:       "command skips functions",
+			input:      "prog() { builtin echo function; }; echo() { :; }; prog; command prog; command echo builtin",
+			wantOutput: "function\nprog\nbuiltin\n",
+		},
+		{
+			name:       "command with a missing program",
+			input:      "command missing",
+			wantStderr: "command not found: missing",
+			wantStatus: 127,
+		},
+		{
+			name:       "builtin skips functions",
+			input:      "echo() { :; }; echo function; builtin echo builtin",
+			wantOutput: "builtin\n",
+		},
+		{
+			name:       "builtin of a program",
+			input:      "builtin prog",
+			wantStderr: "builtin: prog: not a shell builtin",
+			wantStatus: 1,
+		},
+		{
+			name:       "hash counts the runs of programs",
+			input:      "hash; prog; prog; other; hash",
+			wantOutput: "hash: hash table empty\nprog\nprog\nother\nhits\tcommand\n   1\tBIN2/other\n   2\tBIN1/prog\n",
+		},
+		{
+			name:       "hashed programs are shown by type",
+			input:      "hash prog; type prog; hash -t prog",
+			wantOutput: "prog is hashed (BIN1/prog)\nBIN1/prog\n",
+		},
+		{
+			name:       "hash -t of several names",
+			input:      "hash prog other; hash -t prog other",
+			wantOutput: "prog\tBIN1/prog\nother\tBIN2/other\n",
+		},
+		{
+			name:       "hash -p runs the given program",
+			input:      "hash -p BIN2/other prog; prog; hash -l",
+			wantOutput: "other\nbuiltin hash -p BIN2/other prog\n",
+		},
+		{
+			name:       "hash -d forgets a program",
+			input:      "hash prog other; hash -d prog missing; hash -t prog",
+			wantStderr: "hash: missing: not found",
+			wantStatus: 1,
+		},
+		{
+			name:       "hash -r forgets every program",
+			input:      "prog; hash -r; hash",
+			wantOutput: "prog\nhash: hash table empty\n",
+		},
+		{
+			name:       "hash of a missing program",
+			input:      "hash missing",
+			wantStderr: "hash: missing: not found",
+			wantStatus: 1,
+		},
+		{
+			name:       "changing PATH empties the table",
+			input:      "prog; PATH=BIN2; hash; both",
+			wantOutput: "prog\nhash: hash table empty\nboth\n",
+		},
+		{
+			name:       "a program that is gone is looked for again",
+			input:      "hash -p BIN1/gone both; both; hash -t both",
+			wantOutput: "both\nBIN1/both\n",
+		},
+		{
+			name:       "a temporary PATH finds the program",
+			input:      "PATH=BIN2 other",
+			wantOutput: "other\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dirs := strings.NewReplacer("BIN1", bin1, "BIN2", bin2)
+			cfg := config.Default()
+			if err := cfg.Variables.Set("PATH", bin1+string(os.PathListSeparator)+bin2); err != nil {
+				t.Fatal(err)
+			}
+			parser := New(cfg)
+
+			cmd, err := parser.Parse(dirs.Replace(tt.input))
+			if err != nil {
+				t.Fatalf("Parse() failed: %v", err)
+			}
+
+			var stdout, stderr bytes.Buffer
+			ctx := WithIO(context.Background(), IO{Stdin: strings.NewReader(""), Stdout: &stdout, Stderr: &stderr})
+			ctx = WithState(ctx, NewState(DefaultShellName, nil))
+			status, err := cmd.Execute(ctx, cfg)
+			reportError(ctx, err)
+
+			if status != tt.wantStatus {
+				t.Errorf("status = %d, want %d", status, tt.wantStatus)
+			}
+			if want := dirs.Replace(tt.wantOutput); stdout.String() != want {
+				t.Errorf("stdout = %q, want %q", stdout.String(), want)
+			}
+			if !strings.Contains(stderr.String(), tt.wantStderr) {
+				t.Errorf("stderr = %q, want it to contain %q", stderr.String(), tt.wantStderr)
+			}
+		})
+	}
+}
+
+func TestBuiltinNames(t *testing.T) {
+	names := BuiltinNames()
+	for _, name := range []string{"cd", "type", "which", "command", "builtin", "hash"} {
+		if !IsBuiltin(name) {
+			t.Errorf("IsBuiltin(%q) = false", name)
+		}
+		found := false
+		for _, n := range names {
+			found = found || n == name
+		}
+		if !found {
+			t.Errorf("BuiltinNames() is missing %q", name)
+		}
+	}
+	if IsBuiltin("ls") {
+		t.Error(`IsBuiltin("ls") = true`)
+	}
+	for i := 1; i < len(names); i++ {
+		if names[i-1] >= names[i] {
+			t.Errorf("BuiltinNames() is not sorted: %q before %q", names[i-1], names[i])
+		}
+	}
+}
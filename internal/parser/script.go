@@ -0,0 +1,73 @@
+package parser
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+)
+
+// RunScript reads the commands of a script from r and calls run with each
+// one as soon as it is complete, along with its source text and the line it
+// starts on. Commands are parsed only once those before them have run, so
+// that an alias a script defines applies to the lines after the definition,
+// and a command spanning several lines is only parsed again once a line
+// might complete it.
+//
+// It stops when run returns false, at the end of r, or at a syntax error,
+// which ends the script like in other shells. The syntax error is returned
+// with its line counted from the start of the script.
+func (p *Parser) RunScript(r io.Reader, run func(cmd Command, text string, line int) bool) error {
+	reader := bufio.NewReader(r)
+	var input string
+	var incomplete *SyntaxError
+	line, start := 0, 0
+
+	for {
+		text, readErr := reader.ReadString('\n')
+		if readErr != nil && readErr != io.EOF {
+			return readErr
+		}
+		if text != "" || readErr == nil {
+			line++
+			text = strings.TrimSuffix(text, "\n")
+			if input == "" {
+				input, start = text, line
+			} else {
+				input += "\n" + text
+			}
+		}
+
+		// Wait for the rest of a command that spans several lines, unless
+		// the script ends first
+		if incomplete != nil && !incomplete.MayComplete(text) && readErr == nil {
+			continue
+		}
+		cmd, err := p.Parse(input)
+		if errors.Is(err, ErrIncomplete) && readErr == nil {
+			incomplete = nil
+			errors.As(err, &incomplete)
+			continue
+		}
+		incomplete = nil
+
+		var syntaxErr *SyntaxError
+		if errors.As(err, &syntaxErr) {
+			located := *syntaxErr
+			located.Line += start - 1
+			return &located
+		}
+		if err != nil {
+			return err
+		}
+
+		if strings.TrimSpace(input) != "" && !run(cmd, input, start) {
+			return nil
+		}
+		input = ""
+
+		if readErr == io.EOF {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,23 @@
+//go:build windows
+
+package parser
+
+import "syscall"
+
+// signals are the signals the trap built-in knows, by name without the SIG
+// prefix, in the order trap -l lists them. Windows only has these.
+var signals = []namedSignal{
+	{"HUP", syscall.SIGHUP},
+	{"INT", syscall.SIGINT},
+	{"QUIT", syscall.SIGQUIT},
+	{"ILL", syscall.SIGILL},
+	{"TRAP", syscall.SIGTRAP},
+	{"ABRT", syscall.SIGABRT},
+	{"BUS", syscall.SIGBUS},
+	{"FPE", syscall.SIGFPE},
+	{"KILL", syscall.SIGKILL},
+	{"SEGV", syscall.SIGSEGV},
+	{"PIPE", syscall.SIGPIPE},
+	{"ALRM", syscall.SIGALRM},
+	{"TERM", syscall.SIGTERM},
+}
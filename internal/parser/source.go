@@ -0,0 +1,142 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gosh/internal/config"
+)
+
+// maxSourceDepth limits how deeply sourced files may source others, so
+// that a file sourcing itself without a guard ends with an error
+const maxSourceDepth = 1000
+
+// SourceCommand implements the source and . built-in commands, which run
+// the commands of a file in the current shell, so that the variables,
+// aliases, functions and directory changes it makes are kept
+type SourceCommand struct {
+	// Name is "source" or "."
+	Name   string
+	Args   []string
+	parser *Parser
+}
+
+// Execute implements the Command interface for SourceCommand. Arguments
+// after the file name become the positional parameters while it runs.
+func (c *SourceCommand) Execute(ctx context.Context, cfg *config.Config) (int, error) {
+	if len(c.Args) == 0 {
+		return ExitUsage, fmt.Errorf("%s: filename argument required", c.Name)
+	}
+
+	p := c.parser.withConfig(cfg)
+	path, err := findSourceFile(c.Args[0], p.getVariable("PATH"), StateFromContext(ctx).WorkDir())
+	if err != nil {
+		return ExitFailure, fmt.Errorf("%s: %s: %w", c.Name, c.Args[0], err)
+	}
+
+	// A file that sources itself without a guard would never finish
+	state := StateFromContext(ctx)
+	if state.sourceDepth >= maxSourceDepth {
+		return ExitFailure, fmt.Errorf("%s: %s: maximum source nesting level exceeded (%d)", c.Name, path, maxSourceDepth)
+	}
+
+	content, err := os.ReadFile(resolvePath(ctx, path)) // #nosec G304 -- the file is chosen by the user
+	if err != nil {
+		var pathErr *os.PathError
+		if errors.As(err, &pathErr) {
+			err = pathErr.Err
+		}
+		return ExitFailure, fmt.Errorf("%s: %s: %w", c.Name, path, err)
+	}
+
+	state.sourceDepth++
+	defer func() {
+		state.sourceDepth--
+	}()
+
+	if len(c.Args) > 1 {
+		args := state.Args
+		state.Args = c.Args[1:]
+		defer func() {
+			state.Args = args
+		}()
+	}
+
+	status, err := p.runSource(ctx, cfg, path, string(content))
+
+	// return leaves the sourced file rather than a function calling source
+	var ret *returnControl
+	if errors.As(err, &ret) {
+		status, err = ret.Status, nil
+	}
+	if !isControlFlow(err) {
+		setStatus(ctx, status)
+		if trapErr := runTrap(ctx, cfg, TrapReturn); trapErr != nil {
+			reportError(ctx, err)
+			return status, trapErr
+		}
+	}
+	return status, err
+}
+
+// runSource runs the commands of a sourced file one at a time, so that an
+// alias it defines applies to the lines after the definition. Errors are
+// reported with the name of the file and the line they occur on, and the
+// file goes on, except after a syntax error, which ends it with status 2.
+// Its status is otherwise that of the last command.
+func (p *Parser) runSource(ctx context.Context, cfg *config.Config, name, content string) (int, error) {
+	status := 0
+	var result error
+	err := p.RunScript(strings.NewReader(content), func(cmd Command, _ string, line int) bool {
+		if result = ctx.Err(); result != nil {
+			status = ExitFailure
+			return false
+		}
+
+		lineCtx := WithSource(ctx, Source{Name: name, Line: line})
+		status, result = cmd.Execute(lineCtx, cfg)
+		if isControlFlow(result) {
+			return false
+		}
+		setStatus(ctx, status)
+		reportError(lineCtx, result)
+		result = nil
+		return true
+	})
+
+	var syntaxErr *SyntaxError
+	if errors.As(err, &syntaxErr) {
+		reportError(WithSource(ctx, Source{Name: name, Line: syntaxErr.Line}), errors.New(syntaxErr.Msg))
+		return ExitUsage, nil
+	}
+	if err != nil {
+		return ExitFailure, err
+	}
+	return status, result
+}
+
+// findSourceFile finds the file a source command names. Like bash, a name
+// without a slash is looked for in the directories of path, and then in
+// the current directory, cwd.
+func findSourceFile(name, path, cwd string) (string, error) {
+	if !strings.ContainsAny(name, `/`+string(filepath.Separator)) {
+		for _, dir := range filepath.SplitList(path) {
+			if dir == "" {
+				continue
+			}
+			candidate := filepath.Join(dir, name)
+			if info, err := os.Stat(inDir(cwd, candidate)); err == nil && info.Mode().IsRegular() {
+				return candidate, nil
+			}
+		}
+	}
+
+	if _, err := os.Stat(inDir(cwd, name)); err != nil {
+		return "", errors.New("no such file or directory")
+	}
+	return name, nil
+}
@@ -0,0 +1,155 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gosh/internal/config"
+)
+
+func TestSourceCommand(t *testing.T) {
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "bin")
+	if err := os.Mkdir(bin, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"lib.sh":      "greeting=hello\ngreet() {\n  echo \"$greeting $1\"\n}\n",
+		"args.sh":     "echo \"$# $1 $2\"\n",
+		"return.sh":   "echo before\nreturn 3\necho after\n",
+		"alias.sh":    "alias hi='echo hi'\nhi\n",
+		"errors.sh":   "false\nif then\necho next\n",
+		"self.sh":     ". ./self.sh\n",
+		"guarded.sh":  "[ -n \"$loaded\" ] && return\nloaded=1\necho once\n. ./guarded.sh\necho done\n",
+		"bin/path.sh": "echo from path\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		input      string
+		args       []string
+		wantOutput string
+		wantStderr string
+		wantStatus int
+	}{
+		{
+			name:       "definitions are kept",
+			input:      "source ./lib.sh; greet world; echo $greeting",
+			wantOutput: "hello world\nhello\n",
+		},
+		{
+			name:       "dot is source",
+			input:      ". ./lib.sh && greet dot",
+			wantOutput: "hello dot\n",
+		},
+		{
+			name:       "arguments replace the positional parameters while it runs",
+			input:      "source ./args.sh a b; echo $#",
+			args:       []string{"x"},
+			wantOutput: "2 a b\n1\n",
+		},
+		{
+			name:       "without arguments the positional parameters are seen",
+			input:      "source ./args.sh",
+			args:       []string{"x", "y"},
+			wantOutput: "2 x y\n",
+		},
+		{
+			name:       "return leaves the file",
+			input:      "source ./return.sh",
+			wantOutput: "before\n",
+			wantStatus: 3,
+		},
+		{
+			name:       "aliases apply to later lines",
+			input:      "source ./alias.sh",
+			wantOutput: "hi\n",
+		},
+		{
+			name:       "a syntax error ends the file",
+			input:      "source ./errors.sh",
+			wantStderr: "errors.sh:2: syntax error near unexpected token `then'",
+			wantStatus: 2,
+		},
+		{
+			name:       "name without a slash is found on PATH",
+			input:      "PATH=" + bin + ":$PATH; source path.sh",
+			wantOutput: "from path\n",
+		},
+		{
+			name:       "name without a slash falls back to the current directory",
+			input:      "PATH=" + bin + ":$PATH; source lib.sh; echo $greeting",
+			wantOutput: "hello\n",
+		},
+		{
+			name:       "recursive sourcing stops",
+			input:      "source ./self.sh",
+			wantStderr: "maximum source nesting level exceeded",
+			wantStatus: 1,
+		},
+		{
+			name:       "guarded sourcing of itself",
+			input:      "source ./guarded.sh",
+			wantOutput: "once\ndone\n",
+		},
+		{
+			name:       "missing file",
+			input:      "source ./missing.sh",
+			wantStatus: 1,
+		},
+		{
+			name:       "missing file name",
+			input:      "source",
+			wantStatus: 2,
+		},
+	}
+
+	originalDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = os.Chdir(originalDir)
+	})
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			parser := New(cfg)
+
+			cmd, err := parser.Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse() failed: %v", err)
+			}
+
+			var stdout, stderr bytes.Buffer
+			ctx := WithIO(context.Background(), IO{Stdin: strings.NewReader(""), Stdout: &stdout, Stderr: &stderr})
+			ctx = WithState(ctx, NewState(DefaultShellName, tt.args))
+			status, err := cmd.Execute(ctx, cfg)
+			if status != tt.wantStatus {
+				t.Errorf("Execute() status = %d (%v), want %d", status, err, tt.wantStatus)
+			}
+			if stdout.String() != tt.wantOutput {
+				t.Errorf("output = %q, want %q", stdout.String(), tt.wantOutput)
+			}
+			if err != nil {
+				stderr.WriteString(err.Error())
+			}
+			if !strings.Contains(stderr.String(), tt.wantStderr) {
+				t.Errorf("stderr = %q, want it to contain %q", stderr.String(), tt.wantStderr)
+			}
+		})
+	}
+}
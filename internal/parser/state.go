@@ -0,0 +1,173 @@
+package parser
+
+import (
+	"context"
+	"maps"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// DefaultShellName is $0 when the shell is not running a script
+const DefaultShellName = "gosh"
+
+// State holds the parts of a shell that are not variables: $0, the
+// positional parameters, the exit status of the last command, the
+// functions and the traps
+type State struct {
+	// Name is $0, the name of the shell or script
+	Name string
+	// Args are the positional parameters $1, $2, ...
+	Args []string
+	// Status is $?, the exit status of the last command
+	Status int
+	// Functions are the shell functions by name
+	Functions map[string]*Function
+	// Interactive is set when the shell reads commands from a terminal
+	Interactive bool
+	// Traps are the actions set with the trap built-in
+	Traps *Traps
+	// ExitWarned is set when exit warned about jobs instead of leaving, so
+	// that exit leaves if it is run again straight away. The shell clears
+	// it after any other command.
+	ExitWarned bool
+
+	// scopes holds, for each function being run, the values that its local
+	// variables hid, innermost last
+	scopes []map[string]savedVariable
+	// substituted is set when a command substitution runs, so that an
+	// assignment can take its status
+	substituted bool
+	// subshell is set in the copy of the state that a subshell, pipeline
+	// stage or background job runs with
+	subshell bool
+	// sourceDepth counts the files being run by source, one inside the
+	// other
+	sourceDepth int
+	// dir is the current directory of the shell, or "" to use that of the
+	// process. A subshell has its own, so that its cd does not move the
+	// shell that started it even while both run.
+	dir string
+}
+
+// NewState creates the state of a shell called name with the given
+// positional parameters
+func NewState(name string, args []string) *State {
+	return &State{Name: name, Args: args, Functions: make(map[string]*Function), Traps: NewTraps()}
+}
+
+// Clone returns a copy of the state for a subshell, so that changes made in
+// it do not reach the shell that started it
+func (s *State) Clone() *State {
+	clone := *s
+	clone.subshell = true
+	clone.dir = s.WorkDir()
+	clone.Args = append([]string(nil), s.Args...)
+	clone.Functions = maps.Clone(s.Functions)
+	clone.Traps = s.Traps.clone()
+	clone.scopes = make([]map[string]savedVariable, len(s.scopes))
+	for i, scope := range s.scopes {
+		clone.scopes[i] = maps.Clone(scope)
+	}
+	return &clone
+}
+
+// WorkDir returns the current directory of the shell
+func (s *State) WorkDir() string {
+	if s.dir != "" {
+		return s.dir
+	}
+	wd, _ := os.Getwd()
+	return wd
+}
+
+// chdir makes dir, which must be absolute, the current directory of the
+// shell. Only the top-level shell, which runs one command at a time, moves
+// the process with it; subshells run alongside it and keep their own.
+func (s *State) chdir(dir string) error {
+	if !s.subshell {
+		if err := os.Chdir(dir); err != nil {
+			return err
+		}
+	}
+	s.dir = dir
+	return nil
+}
+
+// FunctionNames returns the names of the defined functions in order
+func (s *State) FunctionNames() []string {
+	names := make([]string, 0, len(s.Functions))
+	for name := range s.Functions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// stateContextKey is the context key under which the shell state is stored
+type stateContextKey struct{}
+
+// WithState returns a context that carries the shell state to the commands
+// executed with it
+func WithState(ctx context.Context, state *State) context.Context {
+	return context.WithValue(ctx, stateContextKey{}, state)
+}
+
+// StateFromContext returns the shell state carried by ctx. Without one a
+// fresh state is returned, so changes made to it are not kept.
+func StateFromContext(ctx context.Context) *State {
+	if state, ok := ctx.Value(stateContextKey{}).(*State); ok {
+		return state
+	}
+	return NewState(DefaultShellName, nil)
+}
+
+// withStateClone returns a context carrying a copy of the shell state in ctx
+func withStateClone(ctx context.Context) context.Context {
+	return WithState(ctx, StateFromContext(ctx).Clone())
+}
+
+// resolvePath returns path as seen from the current directory of the shell
+// in ctx. Files are always opened through it rather than relative to the
+// process, whose directory belongs to the top-level shell.
+func resolvePath(ctx context.Context, path string) string {
+	if path == "" {
+		return path
+	}
+	return inDir(StateFromContext(ctx).WorkDir(), path)
+}
+
+// inDir returns path as seen from the directory dir
+func inDir(dir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+// setStatus records the exit status of a command for $?
+func setStatus(ctx context.Context, status int) {
+	StateFromContext(ctx).Status = status
+}
+
+// Source is the position in a script of the command being run, used to
+// locate its error messages
+type Source struct {
+	Name string
+	Line int
+}
+
+// sourceContextKey is the context key under which the Source is stored
+type sourceContextKey struct{}
+
+// WithSource returns a context that marks the commands executed with it as
+// coming from the given position in a script
+func WithSource(ctx context.Context, src Source) context.Context {
+	return context.WithValue(ctx, sourceContextKey{}, src)
+}
+
+// SourceFromContext returns the script position carried by ctx, if any
+func SourceFromContext(ctx context.Context) (Source, bool) {
+	src, ok := ctx.Value(sourceContextKey{}).(Source)
+	return src, ok
+}
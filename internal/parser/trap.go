@@ -0,0 +1,469 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"gosh/internal/config"
+)
+
+// The conditions a trap can be set for besides signals
+const (
+	// TrapExit runs when the shell exits
+	TrapExit = "EXIT"
+	// TrapErr runs when a command fails where its status is not tested,
+	// as set -e would exit
+	TrapErr = "ERR"
+	// TrapDebug runs before each simple command
+	TrapDebug = "DEBUG"
+	// TrapReturn runs when a function or a sourced file returns
+	TrapReturn = "RETURN"
+)
+
+// namedSignal is a signal with the name trap uses for it, without the SIG
+// prefix
+type namedSignal struct {
+	name string
+	sig  syscall.Signal
+}
+
+// LookupSignal finds a signal by its name, with or without the SIG prefix
+// and in any case, or by its number
+func LookupSignal(spec string) (os.Signal, bool) {
+	if n, err := strconv.Atoi(spec); err == nil {
+		for _, s := range signals {
+			if int(s.sig) == n {
+				return s.sig, true
+			}
+		}
+		return nil, false
+	}
+
+	name := strings.TrimPrefix(strings.ToUpper(spec), "SIG")
+	for _, s := range signals {
+		if s.name == name {
+			return s.sig, true
+		}
+	}
+	return nil, false
+}
+
+// SignalName returns the name trap uses for sig, such as INT, or "" if
+// trap does not know it
+func SignalName(sig os.Signal) string {
+	for _, s := range signals {
+		if s.sig == sig {
+			return s.name
+		}
+	}
+	return ""
+}
+
+// trapCondition returns the name of the condition a trap spec refers to:
+// a signal given by name or number, EXIT or 0, ERR, DEBUG or RETURN
+func trapCondition(spec string) (string, bool) {
+	switch name := strings.ToUpper(spec); name {
+	case "0", TrapExit, "SIG" + TrapExit:
+		return TrapExit, true
+	case TrapErr, TrapDebug, TrapReturn:
+		return name, true
+	}
+	if sig, ok := LookupSignal(spec); ok {
+		return SignalName(sig), true
+	}
+	return "", false
+}
+
+// trapOrder returns the position of a condition in the output of trap -p:
+// EXIT first, then the signals by number, then the other conditions
+func trapOrder(name string) int {
+	switch name {
+	case TrapExit:
+		return 0
+	case TrapDebug:
+		return 1000
+	case TrapErr:
+		return 1001
+	case TrapReturn:
+		return 1002
+	}
+	for _, s := range signals {
+		if s.name == name {
+			return int(s.sig)
+		}
+	}
+	return len(signals)
+}
+
+// untrappable are the signals that cannot be given an action or ignored.
+// KILL and STOP cannot be caught, and the Go runtime sends URG to the
+// shell itself to preempt goroutines, so its action would run at random.
+var untrappable = map[string]bool{"KILL": true, "STOP": true, "URG": true}
+
+// trap is the action set for a condition
+type trap struct {
+	// text is the action as it was given to trap, empty for an ignored
+	// signal
+	text    string
+	command Command
+}
+
+// Traps holds the actions set with the trap built-in and the signals that
+// arrived for them. The shell delivers signals from its own goroutine; the
+// actions run between commands, in the goroutine running them.
+type Traps struct {
+	mu      sync.Mutex
+	traps   map[string]trap
+	pending []string
+	// running is set while an action runs, so that it does not set off
+	// others
+	running bool
+	// handler is told about each signal whose trap changes
+	handler func(os.Signal)
+}
+
+// NewTraps creates an empty trap table
+func NewTraps() *Traps {
+	return &Traps{traps: make(map[string]trap)}
+}
+
+// clone returns the traps a subshell starts with. As in other shells,
+// signals ignored by the shell stay ignored and the other traps are reset.
+// The copy has no handler, so a subshell does not change how the process
+// handles signals.
+func (t *Traps) clone() *Traps {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	clone := NewTraps()
+	for name, tr := range t.traps {
+		if tr.command == nil {
+			clone.traps[name] = tr
+		}
+	}
+	return clone
+}
+
+// SetHandler sets the function told about each signal whose trap is set or
+// reset, so that the shell can catch, ignore or restore the signal
+func (t *Traps) SetHandler(handler func(os.Signal)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.handler = handler
+}
+
+// Action returns the action of the trap for a condition, such as INT or
+// EXIT, and whether one is set. An ignored signal has an empty action.
+func (t *Traps) Action(name string) (string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tr, ok := t.traps[name]
+	return tr.text, ok
+}
+
+// Deliver records that sig arrived. If it has a trap with an action,
+// Deliver reports true and the action runs before the next command.
+func (t *Traps) Deliver(sig os.Signal) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	name := SignalName(sig)
+	if tr, ok := t.traps[name]; !ok || tr.command == nil {
+		return false
+	}
+	t.pending = append(t.pending, name)
+	return true
+}
+
+// set makes command, written as text, the action of the trap for name. A
+// nil command ignores the signal.
+func (t *Traps) set(name, text string, command Command) {
+	t.mu.Lock()
+	t.traps[name] = trap{text: text, command: command}
+	t.mu.Unlock()
+	t.changed(name)
+}
+
+// reset removes the trap for name
+func (t *Traps) reset(name string) {
+	t.mu.Lock()
+	delete(t.traps, name)
+	t.mu.Unlock()
+	t.changed(name)
+}
+
+// changed tells the handler that the trap of a signal changed
+func (t *Traps) changed(name string) {
+	t.mu.Lock()
+	handler := t.handler
+	t.mu.Unlock()
+
+	if sig, ok := LookupSignal(name); ok && handler != nil {
+		handler(sig)
+	}
+}
+
+// ready reports whether the trap for name has an action that can run now,
+// as no other action is running
+func (t *Traps) ready(name string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.traps[name].command != nil && !t.running
+}
+
+// begin returns the action of the trap for name and marks it as running,
+// unless it has none or another action is running
+func (t *Traps) begin(name string) (Command, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	command := t.traps[name].command
+	if command == nil || t.running {
+		return nil, false
+	}
+	t.running = true
+	return command, true
+}
+
+// end marks the running action as finished
+func (t *Traps) end() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.running = false
+}
+
+// nextPending returns the next signal that arrived with a trap, unless an
+// action is running
+func (t *Traps) nextPending() (string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.pending) == 0 || t.running {
+		return "", false
+	}
+	name := t.pending[0]
+	t.pending = t.pending[1:]
+	return name, true
+}
+
+// snapshot returns a copy of the traps that are set
+func (t *Traps) snapshot() map[string]trap {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return maps.Clone(t.traps)
+}
+
+// runTrap runs the action of the trap for name, if it has one. $? is kept
+// as it was. A break, continue, return or exit in the action is returned;
+// other errors are reported.
+func runTrap(ctx context.Context, cfg *config.Config, name string) error {
+	state := StateFromContext(ctx)
+	command, ok := state.Traps.begin(name)
+	if !ok {
+		return nil
+	}
+	defer state.Traps.end()
+
+	status := state.Status
+	_, err := command.Execute(ctx, cfg)
+	if isControlFlow(err) {
+		return err
+	}
+	reportError(ctx, err)
+	state.Status = status
+	return nil
+}
+
+// runPendingTraps runs the actions of the signals that arrived since it
+// last ran
+func runPendingTraps(ctx context.Context, cfg *config.Config) error {
+	traps := StateFromContext(ctx).Traps
+	for {
+		name, ok := traps.nextPending()
+		if !ok {
+			return nil
+		}
+		if err := runTrap(ctx, cfg, name); err != nil {
+			return err
+		}
+	}
+}
+
+// runDebugTrap runs the DEBUG trap before a simple command, with
+// BASH_COMMAND set to the command as written
+func runDebugTrap(ctx context.Context, cfg *config.Config, command string) error {
+	if !StateFromContext(ctx).Traps.ready(TrapDebug) {
+		return nil
+	}
+	reportError(ctx, cfg.Variables.Set("BASH_COMMAND", command))
+	return runTrap(ctx, cfg, TrapDebug)
+}
+
+// RunPendingTraps runs the actions of the signals that arrived while no
+// command was running to run them
+func (p *Parser) RunPendingTraps(ctx context.Context) error {
+	return runPendingTraps(ctx, p.config)
+}
+
+// runSubshell runs cmd as the commands of a subshell, a pipeline stage or a
+// background job, whose state ctx carries and whose configuration is cfg.
+// An exit ends only the subshell, and its EXIT trap runs as it ends.
+func runSubshell(ctx context.Context, cfg *config.Config, cmd Command) (int, error) {
+	status, err := exitStatus(cmd.Execute(ctx, cfg))
+	if !StateFromContext(ctx).Traps.ready(TrapExit) {
+		return status, err
+	}
+
+	reportError(ctx, err)
+	setStatus(ctx, status)
+	if err := runTrap(ctx, cfg, TrapExit); err != nil {
+		return exitStatus(status, err)
+	}
+	return status, nil
+}
+
+// RunExitTrap runs the EXIT trap as the shell exits. The trap is removed
+// afterwards, so that it runs once.
+func (p *Parser) RunExitTrap(ctx context.Context) error {
+	defer StateFromContext(ctx).Traps.reset(TrapExit)
+	return runTrap(ctx, p.config, TrapExit)
+}
+
+// TrapCommand implements the trap built-in command. trap action sig...
+// runs action when one of the signals arrives or, for EXIT, ERR, DEBUG and
+// RETURN, when the shell exits, a command fails, before each simple
+// command, or when a function or sourced file returns. An empty action
+// ignores the signals, and - or no action resets them. Without arguments,
+// or with -p, the traps are printed; -l lists the signals.
+type TrapCommand struct {
+	Args   []string
+	parser *Parser
+}
+
+// Execute implements the Command interface for TrapCommand
+func (c *TrapCommand) Execute(ctx context.Context, cfg *config.Config) (int, error) {
+	traps := StateFromContext(ctx).Traps
+	out := IOFromContext(ctx).Stdout
+
+	args := c.Args
+	var printTraps bool
+options:
+	for len(args) > 0 && len(args[0]) > 1 && args[0][0] == '-' {
+		switch args[0] {
+		case "--":
+			args = args[1:]
+			break options
+		case "-p":
+			printTraps = true
+		case "-l":
+			return builtinStatus(listSignals(out))
+		default:
+			return ExitUsage, fmt.Errorf("trap: %s: invalid option", args[0])
+		}
+		args = args[1:]
+	}
+
+	if len(args) == 0 || printTraps {
+		return c.print(ctx, out, traps, args)
+	}
+
+	// A lone signal, or - as the action, resets the traps
+	action, specs := args[0], args[1:]
+	reset := action == "-"
+	if len(args) == 1 {
+		reset, specs = true, args
+	}
+
+	var command Command
+	if !reset && action != "" {
+		parsed, err := c.parser.withConfig(cfg).Parse(action)
+		if err != nil {
+			return ExitFailure, fmt.Errorf("trap: %w", err)
+		}
+		command = parsed
+	}
+
+	status := 0
+	for _, spec := range specs {
+		name, ok := trapCondition(spec)
+		if !ok {
+			// The other signals are still set
+			reportError(ctx, fmt.Errorf("trap: %s: invalid signal specification", spec))
+			status = ExitFailure
+			continue
+		}
+		if untrappable[name] && !reset {
+			reportError(ctx, fmt.Errorf("trap: %s: signal cannot be trapped", spec))
+			status = ExitFailure
+			continue
+		}
+		if reset {
+			traps.reset(name)
+		} else {
+			traps.set(name, action, command)
+		}
+	}
+	return status, nil
+}
+
+// print prints the traps for the given conditions, or all that are set, as
+// the commands that set them again
+func (c *TrapCommand) print(ctx context.Context, out io.Writer, traps *Traps, specs []string) (int, error) {
+	set := traps.snapshot()
+
+	var names []string
+	status := 0
+	if len(specs) == 0 {
+		for name := range set {
+			names = append(names, name)
+		}
+		sort.Slice(names, func(a, b int) bool { return trapOrder(names[a]) < trapOrder(names[b]) })
+	}
+	for _, spec := range specs {
+		name, ok := trapCondition(spec)
+		if !ok {
+			reportError(ctx, fmt.Errorf("trap: %s: invalid signal specification", spec))
+			status = ExitFailure
+			continue
+		}
+		names = append(names, name)
+	}
+
+	for _, name := range names {
+		tr, ok := set[name]
+		if !ok {
+			continue
+		}
+		if _, err := fmt.Fprintf(out, "trap -- %s %s\n", quoteValue(tr.text), trapDisplayName(name)); err != nil {
+			return ExitFailure, err
+		}
+	}
+	return status, nil
+}
+
+// trapDisplayName returns the name trap -p shows for a condition: SIGINT
+// for a signal, and EXIT, ERR, DEBUG or RETURN as they are
+func trapDisplayName(name string) string {
+	if _, ok := LookupSignal(name); ok {
+		return "SIG" + name
+	}
+	return name
+}
+
+// listSignals prints the number and name of each signal, as trap -l does
+func listSignals(out io.Writer) error {
+	for _, s := range signals {
+		if _, err := fmt.Fprintf(out, "%2d) SIG%s\n", int(s.sig), s.name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
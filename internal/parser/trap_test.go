@@ -0,0 +1,182 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"gosh/internal/config"
+)
+
+func TestTrapCommand(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		wantOutput string
+		wantStderr string
+		wantStatus int
+	}{
+		{
+			name:       "print the traps that are set",
+			input:      "trap 'echo bye' EXIT; trap '' sigint 15; trap",
+			wantOutput: "trap -- 'echo bye' EXIT\ntrap -- '' SIGINT\ntrap -- '' SIGTERM\n",
+		},
+		{
+			name:       "print chosen traps",
+			input:      `trap 'echo "it'\''s"' INT HUP; trap -p INT`,
+			wantOutput: `trap -- 'echo "it'\''s"' SIGINT` + "\n",
+		},
+		{
+			name:       "dash resets traps",
+			input:      "trap 'echo x' INT TERM; trap - INT; trap -p",
+			wantOutput: "trap -- 'echo x' SIGTERM\n",
+		},
+		{
+			name:       "a lone signal resets its trap",
+			input:      "trap 'echo x' TERM; trap TERM; trap -p",
+			wantOutput: "",
+		},
+		{
+			name:       "list signals",
+			input:      "trap -l | head -2",
+			wantOutput: " 1) SIGHUP\n 2) SIGINT\n",
+		},
+		{
+			name:       "invalid signal",
+			input:      "trap 'echo x' NOPE INT; trap -p",
+			wantOutput: "trap -- 'echo x' SIGINT\n",
+			wantStderr: "trap: NOPE: invalid signal specification",
+		},
+		{
+			name:       "invalid option",
+			input:      "trap -x",
+			wantStderr: "trap: -x: invalid option",
+			wantStatus: 2,
+		},
+		{
+			name:       "syntax error in the action",
+			input:      "trap 'echo (' INT",
+			wantStderr: "trap:",
+			wantStatus: 1,
+		},
+		{
+			name:       "ERR runs for untested failures with their status",
+			input:      "trap 'echo err $?' ERR; false; true; if false; then :; fi; false || true; f() { return 3; }; (f); echo $?",
+			wantOutput: "err 1\nerr 3\n3\n",
+		},
+		{
+			name:       "ERR runs before errexit",
+			input:      "set -e; trap 'echo err' ERR; false; echo no",
+			wantOutput: "err\n",
+			wantStatus: 1,
+		},
+		{
+			name:       "DEBUG runs before each simple command",
+			input:      "trap 'echo \"> $BASH_COMMAND\"' DEBUG; x=1; echo $x",
+			wantOutput: "> x=1\n> echo $x\n1\n",
+		},
+		{
+			name:       "RETURN runs when a function returns",
+			input:      "trap 'echo returned $?' RETURN; f() { return 4; }; f; echo $?",
+			wantOutput: "returned 4\n4\n",
+		},
+		{
+			name:       "actions keep $?",
+			input:      "trap 'true' DEBUG; false; echo $?",
+			wantOutput: "1\n",
+		},
+		{
+			name:       "KILL, STOP and URG cannot be trapped",
+			input:      "trap 'echo x' KILL INT; trap '' 19; trap 'echo x' URG; trap - KILL; trap -p",
+			wantOutput: "trap -- 'echo x' SIGINT\n",
+			wantStderr: "trap: KILL: signal cannot be trapped",
+		},
+		{
+			name:       "the EXIT trap of a subshell runs as it ends",
+			input:      "(trap 'echo bye $?' EXIT; echo in; false); echo $?; (trap 'exit 7' EXIT; true); echo $?",
+			wantOutput: "in\nbye 1\n1\n7\n",
+		},
+		{
+			name:       "subshells reset caught signals and keep ignored ones",
+			input:      "trap 'echo x' INT; trap '' TERM; (trap -p)",
+			wantOutput: "trap -- '' SIGTERM\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			parser := New(cfg)
+
+			cmd, err := parser.Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse() failed: %v", err)
+			}
+
+			var stdout, stderr bytes.Buffer
+			ctx := WithIO(context.Background(), IO{Stdin: strings.NewReader(""), Stdout: &stdout, Stderr: &stderr})
+			ctx = WithState(ctx, NewState(DefaultShellName, nil))
+			status, err := exitStatus(cmd.Execute(ctx, cfg))
+			if status != tt.wantStatus {
+				t.Errorf("Execute() status = %d (%v), want %d", status, err, tt.wantStatus)
+			}
+			if stdout.String() != tt.wantOutput {
+				t.Errorf("output = %q, want %q", stdout.String(), tt.wantOutput)
+			}
+			if err != nil {
+				stderr.WriteString(err.Error())
+			}
+			if !strings.Contains(stderr.String(), tt.wantStderr) {
+				t.Errorf("stderr = %q, want it to contain %q", stderr.String(), tt.wantStderr)
+			}
+		})
+	}
+}
+
+func TestTrapSignals(t *testing.T) {
+	cfg := config.Default()
+	parser := New(cfg)
+	state := NewState(DefaultShellName, nil)
+
+	var stdout bytes.Buffer
+	ctx := WithIO(context.Background(), IO{Stdin: strings.NewReader(""), Stdout: &stdout, Stderr: &stdout})
+	ctx = WithState(ctx, state)
+	run := func(input string) {
+		t.Helper()
+		cmd, err := parser.Parse(input)
+		if err != nil {
+			t.Fatalf("Parse() failed: %v", err)
+		}
+		if _, err := cmd.Execute(ctx, cfg); err != nil {
+			t.Fatalf("Execute() failed: %v", err)
+		}
+	}
+
+	var changed []string
+	state.Traps.SetHandler(func(sig os.Signal) {
+		changed = append(changed, SignalName(sig))
+	})
+	run("trap 'echo got TERM' TERM; trap 'echo bye' EXIT")
+	if got := strings.Join(changed, " "); got != "TERM" {
+		t.Errorf("handler told about %q, want TERM", got)
+	}
+
+	term, _ := LookupSignal("SIGTERM")
+	hup, _ := LookupSignal("1")
+	if !state.Traps.Deliver(term) || state.Traps.Deliver(hup) {
+		t.Fatal("Deliver() reports a trap only for TERM")
+	}
+	run("echo next; echo last")
+	if err := parser.RunExitTrap(ctx); err != nil {
+		t.Fatalf("RunExitTrap() failed: %v", err)
+	}
+	if err := parser.RunExitTrap(ctx); err != nil {
+		t.Fatalf("RunExitTrap() failed: %v", err)
+	}
+
+	if want := "next\ngot TERM\nlast\nbye\n"; stdout.String() != want {
+		t.Errorf("output = %q, want %q", stdout.String(), want)
+	}
+}
@@ -0,0 +1,790 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"gosh/internal/config"
+	"gosh/internal/lexer"
+)
+
+// getVariable returns the value of a shell variable, or "" if it is unset
+func (p *Parser) getVariable(name string) string {
+	value, _ := p.lookupVariable(name)
+	return value
+}
+
+// lookupVariable returns the value of a shell variable and reports whether
+// it is set
+func (p *Parser) lookupVariable(name string) (string, bool) {
+	return p.config.Variables.Lookup(name)
+}
+
+// assignValue assigns a value to a shell variable. The value of a variable
+// with the integer attribute is evaluated as an arithmetic expression.
+func (p *Parser) assignValue(name, value string) error {
+	v, _ := p.config.Variables.Get(name)
+	value, err := p.integerValue(v, value)
+	if err != nil {
+		return err
+	}
+	return p.config.Variables.Set(name, value)
+}
+
+// setVariable assigns a value to a shell variable as the shell does for
+// NAME=value. A GOSH_ variable also changes the matching setting.
+func (p *Parser) setVariable(name, value string) error {
+	if err := p.assignValue(name, value); err != nil {
+		return err
+	}
+	p.config.ApplyVariable(name, value)
+	return nil
+}
+
+// assignment is a NAME=value word taken apart, before expansion
+type assignment struct {
+	name string
+	// index is the subscript of NAME[index]=value, if hasIndex is set
+	index    string
+	hasIndex bool
+	// add is set for NAME+=value, which appends to the variable
+	add   bool
+	value string
+}
+
+// parseAssignment takes apart a word of the form NAME=value, NAME+=value or
+// NAME[index]=value, and reports whether it has that form
+func parseAssignment(word string) (assignment, bool) {
+	end := 0
+	for end < len(word) && isNameByte(word[end], end == 0) {
+		end++
+	}
+	if end == 0 {
+		return assignment{}, false
+	}
+
+	a := assignment{name: word[:end]}
+	rest := word[end:]
+	if strings.HasPrefix(rest, "[") {
+		sub := subscriptEnd(rest)
+		if sub < 0 {
+			return assignment{}, false
+		}
+		a.index, a.hasIndex = rest[1:sub-1], true
+		rest = rest[sub:]
+	}
+	if strings.HasPrefix(rest, "+=") {
+		a.add = true
+		rest = rest[1:]
+	}
+	if !strings.HasPrefix(rest, "=") {
+		return assignment{}, false
+	}
+	a.value = rest[1:]
+	return a, true
+}
+
+// subscriptEnd returns the index just past the ] that closes the [ at the
+// start of s, or -1 if there is none. Brackets inside quotes and
+// expansions do not count.
+func subscriptEnd(s string) int {
+	depth := 0
+	for i := 0; i < len(s); {
+		switch s[i] {
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		case '\\':
+			i += 2
+			continue
+		case '\'', '"':
+			end := lexer.QuoteEnd(s, i)
+			if end < 0 {
+				return -1
+			}
+			i = end
+			continue
+		case '$', '`':
+			i = expansionEnd(s, i)
+			continue
+		}
+		i++
+	}
+	return -1
+}
+
+// isArrayList reports whether the value of an assignment is a list of
+// elements in parentheses, as in arr=(a b c)
+func isArrayList(value string) bool {
+	return len(value) >= 2 && value[0] == '(' && value[len(value)-1] == ')'
+}
+
+// assign performs an assignment word: NAME=value, NAME+=value,
+// NAME[index]=value or NAME=(elements). The value is not split or matched
+// against file names. A value that is not an array is assigned with set.
+// It returns the name of the variable.
+func (p *Parser) assign(ctx context.Context, word string, set func(name, value string) error) (string, error) {
+	a, ok := parseAssignment(word)
+	if !ok {
+		return "", fmt.Errorf("`%s': not a valid identifier", word)
+	}
+	if !a.hasIndex && isArrayList(a.value) {
+		return a.name, p.assignList(ctx, a)
+	}
+
+	value, err := p.expandValue(ctx, a.value)
+	if err != nil {
+		return a.name, err
+	}
+
+	if a.hasIndex {
+		key, err := p.arrayKey(ctx, a.name, a.index)
+		if err != nil {
+			return a.name, err
+		}
+		if a.add {
+			v, _ := p.config.Variables.Get(a.name)
+			old, _ := v.Element(key)
+			value = p.appendValue(a.name, old, value)
+		}
+		return a.name, p.assignElement(a.name, key, value)
+	}
+
+	if a.add {
+		value = p.appendValue(a.name, p.getVariable(a.name), value)
+	}
+	return a.name, set(a.name, value)
+}
+
+// appendValue returns the value that NAME+=value assigns: the sum for a
+// variable with the integer attribute, or the two strings joined
+func (p *Parser) appendValue(name, old, value string) string {
+	if v, _ := p.config.Variables.Get(name); v.Has(config.AttrInteger) {
+		if old == "" {
+			old = "0"
+		}
+		return "(" + old + ")+(" + value + ")"
+	}
+	return old + value
+}
+
+// assignList assigns the elements of NAME=(elements) to an array, or adds
+// them to it for NAME+=(elements). Each element is expanded like a command
+// argument, except that one written [key]=value sets the given key. The
+// elements of an associative array must all be written that way.
+func (p *Parser) assignList(ctx context.Context, a assignment) error {
+	tokens, err := lexer.Tokenize(a.value[1 : len(a.value)-1])
+	if err != nil {
+		return err
+	}
+
+	v, _ := p.config.Variables.Get(a.name)
+	assoc := v.Has(config.AttrAssoc)
+	elements := make(map[string]string)
+	next := 0
+	if a.add {
+		for _, key := range v.Keys() {
+			elements[key], _ = v.Element(key)
+			if n, err := strconv.Atoi(key); err == nil && !assoc {
+				next = n + 1
+			}
+		}
+	}
+
+	for _, tok := range tokens {
+		switch tok.Kind {
+		case lexer.Newline, lexer.EOF:
+			continue
+		case lexer.Word, lexer.Reserved:
+		default:
+			return fmt.Errorf("%s: syntax error in array assignment near `%s'", a.name, tok.Value)
+		}
+
+		if index, raw, ok := elementAssignment(tok.Value); ok {
+			key, err := p.arrayKey(ctx, a.name, index)
+			if err != nil {
+				return err
+			}
+			value, err := p.expandValue(ctx, raw)
+			if err != nil {
+				return err
+			}
+			if elements[key], err = p.integerValue(v, value); err != nil {
+				return err
+			}
+			if n, err := strconv.Atoi(key); err == nil && !assoc {
+				next = n + 1
+			}
+			continue
+		}
+
+		if assoc {
+			return fmt.Errorf("%s: %s: must use subscript when assigning associative array", a.name, tok.Value)
+		}
+		fields, err := p.expandWord(ctx, tok.Value)
+		if err != nil {
+			return err
+		}
+		for _, f := range fields {
+			if elements[strconv.Itoa(next)], err = p.integerValue(v, f); err != nil {
+				return err
+			}
+			next++
+		}
+	}
+	return p.config.Variables.SetArray(a.name, elements)
+}
+
+// elementAssignment takes apart an element of an array list written
+// [index]=value
+func elementAssignment(word string) (string, string, bool) {
+	if !strings.HasPrefix(word, "[") {
+		return "", "", false
+	}
+	end := subscriptEnd(word)
+	if end < 0 || !strings.HasPrefix(word[end:], "=") {
+		return "", "", false
+	}
+	return word[1 : end-1], word[end+1:], true
+}
+
+// arrayKey expands the subscript of an element of the array name into its
+// key. An associative array takes the subscript as a string; otherwise it
+// is an arithmetic expression, and a negative index counts from the end.
+func (p *Parser) arrayKey(ctx context.Context, name, index string) (string, error) {
+	v, _ := p.config.Variables.Get(name)
+	if v.Has(config.AttrAssoc) {
+		key, err := p.expandString(ctx, index)
+		if err != nil {
+			return "", err
+		}
+		if key == "" {
+			return "", fmt.Errorf("%s[%s]: bad array subscript", name, index)
+		}
+		return key, nil
+	}
+
+	n, err := p.arithmetic(ctx, index)
+	if err != nil {
+		return "", err
+	}
+	return elementIndex(v, name, index, n)
+}
+
+// elementIndex returns the key of element n of an indexed array. A
+// negative n counts back from the end.
+func elementIndex(v config.Variable, name, index string, n int64) (string, error) {
+	if n < 0 {
+		if keys := v.Keys(); len(keys) > 0 {
+			last, _ := strconv.ParseInt(keys[len(keys)-1], 10, 64)
+			n += last + 1
+		}
+		if n < 0 {
+			return "", fmt.Errorf("%s[%s]: bad array subscript", name, index)
+		}
+	}
+	return strconv.FormatInt(n, 10), nil
+}
+
+// assignElement assigns a value to an element of an array, evaluating it
+// if the array has the integer attribute
+func (p *Parser) assignElement(name, key, value string) error {
+	v, _ := p.config.Variables.Get(name)
+	value, err := p.integerValue(v, value)
+	if err != nil {
+		return err
+	}
+	return p.config.Variables.SetElement(name, key, value)
+}
+
+// integerValue evaluates a value being assigned to a variable with the
+// integer attribute as an arithmetic expression. Other values are
+// returned as they are.
+func (p *Parser) integerValue(v config.Variable, value string) (string, error) {
+	if !v.Has(config.AttrInteger) {
+		return value, nil
+	}
+	n, err := p.evalArithmetic(value, 0)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(n, 10), nil
+}
+
+// assignTemporary applies the assignments written before a command name
+// for the duration of the command, exporting them to it. The function it
+// returns undoes them.
+func (p *Parser) assignTemporary(ctx context.Context, assigns []string) (func(), error) {
+	vars := p.config.Variables
+	var undo []func()
+	restore := func() {
+		for i := len(undo) - 1; i >= 0; i-- {
+			undo[i]()
+		}
+	}
+
+	for _, assign := range assigns {
+		a, _ := parseAssignment(assign)
+		saved, ok := vars.Get(a.name)
+		undo = append(undo, func() { vars.Restore(a.name, saved, ok) })
+		if _, err := p.assign(ctx, assign, p.assignValue); err != nil {
+			restore()
+			return nil, err
+		}
+		vars.Declare(a.name, config.AttrExport)
+	}
+	return restore, nil
+}
+
+// AssignCommand sets shell variables, as written with NAME=value and no
+// command name. Its status is that of the last command substitution in the
+// values, or 0.
+type AssignCommand struct {
+	Assigns []string
+	parser  *Parser
+}
+
+// Execute implements the Command interface for AssignCommand
+func (c *AssignCommand) Execute(ctx context.Context, _ *config.Config) (int, error) {
+	state := StateFromContext(ctx)
+	state.substituted = false
+
+	// Each value sees the variables assigned before it
+	for _, assign := range c.Assigns {
+		if _, err := c.parser.assign(ctx, assign, c.parser.setVariable); err != nil {
+			return ExitFailure, err
+		}
+	}
+
+	if state.substituted {
+		return state.Status, nil
+	}
+	return 0, nil
+}
+
+// declareOptions are the options shared by declare, local, export and
+// readonly
+type declareOptions struct {
+	// add and remove are the attributes given with -x and +x and so on
+	add    config.Attr
+	remove config.Attr
+	// print lists the variables instead of changing them (-p)
+	print bool
+	// functions and namesOnly select functions instead of variables (-f,
+	// -F)
+	functions bool
+	namesOnly bool
+	// global keeps declare in a function from creating a local (-g)
+	global bool
+}
+
+// declareAttrs maps the option letters of declare to attributes
+var declareAttrs = map[byte]config.Attr{
+	'a': config.AttrArray,
+	'A': config.AttrAssoc,
+	'i': config.AttrInteger,
+	'r': config.AttrReadOnly,
+	'x': config.AttrExport,
+}
+
+// parseDeclareOptions parses the leading options of a declare-like built-in
+// that accepts the option letters in allowed. It returns the options and
+// the remaining arguments.
+func parseDeclareOptions(builtin, allowed string, args []string) (declareOptions, []string, error) {
+	var opts declareOptions
+	for len(args) > 0 {
+		arg := args[0]
+		if arg == "--" {
+			return opts, args[1:], nil
+		}
+		if len(arg) < 2 || (arg[0] != '-' && arg[0] != '+') {
+			break
+		}
+		args = args[1:]
+
+		for i := 1; i < len(arg); i++ {
+			letter := arg[i]
+			if strings.IndexByte(allowed, letter) < 0 {
+				return opts, nil, fmt.Errorf("%s: %c%c: invalid option", builtin, arg[0], letter)
+			}
+
+			attr, isAttr := declareAttrs[letter]
+			switch {
+			case isAttr && arg[0] == '-':
+				opts.add |= attr
+			case isAttr:
+				opts.remove |= attr
+			case letter == 'n':
+				// export -n stops exporting the variable
+				opts.remove |= config.AttrExport
+			case letter == 'p':
+				opts.print = true
+			case letter == 'f':
+				opts.functions = true
+			case letter == 'F':
+				opts.functions = true
+				opts.namesOnly = true
+			case letter == 'g':
+				opts.global = true
+			}
+		}
+	}
+	return opts, args, nil
+}
+
+// declare gives each NAME[=value] argument the attributes in opts, and the
+// value if there is one. With local set the variables only exist until the
+// function being run returns.
+func (p *Parser) declare(ctx context.Context, builtin string, opts declareOptions, args []string, local bool) error {
+	vars := p.config.Variables
+	for _, arg := range args {
+		name := arg
+		if a, ok := parseAssignment(arg); ok {
+			name = a.name
+		}
+		if !isName(name) {
+			return fmt.Errorf("%s: `%s': not a valid identifier", builtin, arg)
+		}
+
+		if local {
+			if err := makeLocal(ctx, p.config, name); err != nil {
+				return fmt.Errorf("%s: %w", builtin, err)
+			}
+		}
+		if err := vars.Undeclare(name, opts.remove); err != nil {
+			return fmt.Errorf("%s: %w", builtin, err)
+		}
+
+		// An array cannot change between indexed and associative
+		v, _ := vars.Get(name)
+		if v.Has(config.AttrAssoc) && opts.add&config.AttrArray != 0 {
+			return fmt.Errorf("%s: %s: cannot convert associative to indexed array", builtin, name)
+		}
+		if v.Has(config.AttrArray) && opts.add&config.AttrAssoc != 0 {
+			return fmt.Errorf("%s: %s: cannot convert indexed to associative array", builtin, name)
+		}
+
+		// A value is assigned after the integer attribute is given and
+		// before the variable becomes read-only
+		vars.Declare(name, opts.add&^config.AttrReadOnly)
+		if name != arg {
+			if _, err := p.assign(ctx, arg, p.setVariable); err != nil {
+				return fmt.Errorf("%s: %w", builtin, err)
+			}
+		}
+		vars.Declare(name, opts.add&config.AttrReadOnly)
+	}
+	return nil
+}
+
+// printVariables prints the named variables, or all those with the
+// attributes in filter, in the form declare -p uses so that they can be
+// read back. A name without a variable makes the result an error.
+func printVariables(out io.Writer, builtin string, vars *config.Variables, names []string, filter config.Attr) error {
+	all := len(names) == 0
+	if all {
+		names = vars.Names()
+	}
+
+	var missing error
+	for _, name := range names {
+		v, ok := vars.Get(name)
+		if !ok {
+			missing = fmt.Errorf("%s: %s: not found", builtin, name)
+			continue
+		}
+		if all && !v.Has(filter) {
+			continue
+		}
+
+		line := "declare " + attrFlags(v.Attrs) + " " + name
+		if v.Set {
+			line += "=" + formatValue(v, quoteValue)
+		}
+		if _, err := fmt.Fprintln(out, line); err != nil {
+			return err
+		}
+	}
+	return missing
+}
+
+// attrFlags returns the declare options that give a variable its
+// attributes, or -- if it has none
+func attrFlags(attrs config.Attr) string {
+	flags := "-"
+	for _, letter := range "aAirx" {
+		if attrs&declareAttrs[byte(letter)] != 0 {
+			flags += string(letter)
+		}
+	}
+	if flags == "-" {
+		return "--"
+	}
+	return flags
+}
+
+// formatValue returns the value of a variable as an assignment would give
+// it, quoting with quote. An array is written as a list of its elements,
+// ([key]=value ...).
+func formatValue(v config.Variable, quote func(string) string) string {
+	if !v.IsArray() {
+		return quote(v.Value)
+	}
+
+	elements := make([]string, 0, len(v.Elements))
+	for _, key := range v.Keys() {
+		elements = append(elements, "["+quoteIfNeeded(key)+"]="+quote(v.Elements[key]))
+	}
+	return "(" + strings.Join(elements, " ") + ")"
+}
+
+// quoteValue quotes a value with single quotes so that the shell reads it
+// back unchanged
+func quoteValue(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// quoteIfNeeded quotes a value only if it contains characters that the
+// shell would treat specially
+func quoteIfNeeded(value string) string {
+	if value == "" {
+		return quoteValue(value)
+	}
+	for _, c := range value {
+		if c > unicode.MaxASCII || !(isNameByte(byte(c), false) || strings.ContainsRune("@%+=:,./-", c)) {
+			return quoteValue(value)
+		}
+	}
+	return value
+}
+
+// DeclareCommand implements the declare built-in command. It gives
+// variables attributes and values, prints them with -p, and prints
+// functions with -f or -F. In a function the variables are local unless
+// -g is given.
+type DeclareCommand struct {
+	Args   []string
+	parser *Parser
+}
+
+// Execute implements the Command interface for DeclareCommand
+func (c *DeclareCommand) Execute(ctx context.Context, _ *config.Config) (int, error) {
+	opts, names, err := parseDeclareOptions("declare", "aAifFgprx", c.Args)
+	if err != nil {
+		return ExitFailure, err
+	}
+
+	out := IOFromContext(ctx).Stdout
+	if opts.functions {
+		return declareFunctions(ctx, out, names, opts.namesOnly)
+	}
+	if opts.print || len(names) == 0 {
+		return builtinStatus(printVariables(out, "declare", c.parser.config.Variables, names, opts.add))
+	}
+
+	local := !opts.global && len(StateFromContext(ctx).scopes) > 0
+	return builtinStatus(c.parser.declare(ctx, "declare", opts, names, local))
+}
+
+// ExportCommand implements the export built-in command, which marks
+// variables to be passed to the commands the shell runs. -n stops
+// exporting them.
+type ExportCommand struct {
+	Args   []string
+	parser *Parser
+}
+
+// Execute implements the Command interface for ExportCommand
+func (c *ExportCommand) Execute(ctx context.Context, _ *config.Config) (int, error) {
+	opts, names, err := parseDeclareOptions("export", "np", c.Args)
+	if err != nil {
+		return ExitFailure, err
+	}
+
+	if opts.print || len(names) == 0 {
+		return builtinStatus(printVariables(IOFromContext(ctx).Stdout, "export", c.parser.config.Variables, nil, config.AttrExport))
+	}
+	if opts.remove == 0 {
+		opts.add = config.AttrExport
+	}
+	return builtinStatus(c.parser.declare(ctx, "export", opts, names, false))
+}
+
+// ReadonlyCommand implements the readonly built-in command, which stops
+// variables from being assigned or unset
+type ReadonlyCommand struct {
+	Args   []string
+	parser *Parser
+}
+
+// Execute implements the Command interface for ReadonlyCommand
+func (c *ReadonlyCommand) Execute(ctx context.Context, _ *config.Config) (int, error) {
+	opts, names, err := parseDeclareOptions("readonly", "aAp", c.Args)
+	if err != nil {
+		return ExitFailure, err
+	}
+
+	if opts.print || len(names) == 0 {
+		return builtinStatus(printVariables(IOFromContext(ctx).Stdout, "readonly", c.parser.config.Variables, nil, config.AttrReadOnly))
+	}
+	opts.add |= config.AttrReadOnly
+	return builtinStatus(c.parser.declare(ctx, "readonly", opts, names, false))
+}
+
+// UnsetCommand implements the unset built-in command. It removes variables,
+// elements of arrays written NAME[index] or, with -f, functions.
+type UnsetCommand struct {
+	Args   []string
+	parser *Parser
+}
+
+// Execute implements the Command interface for UnsetCommand
+func (c *UnsetCommand) Execute(ctx context.Context, cfg *config.Config) (int, error) {
+	functions := false
+	status := 0
+	for _, arg := range c.Args {
+		switch arg {
+		case "-f":
+			functions = true
+			continue
+		case "-v":
+			functions = false
+			continue
+		}
+		if strings.HasPrefix(arg, "-") {
+			return ExitFailure, fmt.Errorf("unset: %s: invalid option", arg)
+		}
+
+		if functions {
+			delete(StateFromContext(ctx).Functions, arg)
+			continue
+		}
+		name, index, hasIndex := strings.Cut(arg, "[")
+		if !isName(name) || (hasIndex && !strings.HasSuffix(index, "]")) {
+			return ExitFailure, fmt.Errorf("unset: `%s': not a valid identifier", arg)
+		}
+		index = strings.TrimSuffix(index, "]")
+
+		var err error
+		if hasIndex && index != "@" && index != "*" {
+			var key string
+			if key, err = c.parser.withConfig(cfg).arrayKey(ctx, name, index); err != nil {
+				return ExitFailure, fmt.Errorf("unset: %w", err)
+			}
+			err = cfg.Variables.UnsetElement(name, key)
+		} else {
+			err = cfg.Variables.Unset(name)
+		}
+		if err != nil {
+			// The other names are still removed
+			reportError(ctx, fmt.Errorf("unset: %s: cannot unset: readonly variable", name))
+			status = ExitFailure
+		}
+	}
+	return status, nil
+}
+
+// SetCommand implements the set built-in command. Without arguments it
+// prints the shell variables. Options such as -e or -o pipefail turn shell
+// options on, and +e or +o pipefail off; set -o alone prints them, and
+// set +o prints the commands that restore them. The arguments after the
+// options, or after --, replace the positional parameters.
+type SetCommand struct {
+	Args []string
+}
+
+// Execute implements the Command interface for SetCommand
+func (c *SetCommand) Execute(ctx context.Context, cfg *config.Config) (int, error) {
+	if len(c.Args) == 0 {
+		out := IOFromContext(ctx).Stdout
+		for _, name := range cfg.Variables.Names() {
+			v, _ := cfg.Variables.Get(name)
+			if !v.Set {
+				continue
+			}
+			if _, err := fmt.Fprintf(out, "%s=%s\n", name, formatValue(v, quoteIfNeeded)); err != nil {
+				return ExitFailure, err
+			}
+		}
+		return 0, nil
+	}
+
+	if len(c.Args) == 1 && (c.Args[0] == "-o" || c.Args[0] == "+o") {
+		out := IOFromContext(ctx).Stdout
+		return builtinStatus(printOptions(out, cfg, config.OptionSet, nil, c.Args[0] == "+o"))
+	}
+
+	args, replace, err := cfg.SetFlags(c.Args)
+	if err != nil {
+		return ExitUsage, fmt.Errorf("set: %w", err)
+	}
+	if replace {
+		StateFromContext(ctx).Args = append([]string(nil), args...)
+	}
+	return 0, nil
+}
+
+// EnvCommand implements the env built-in command. Without a command it
+// prints the environment that commands receive: the exported variables.
+// Otherwise it runs the command with that environment, changed by -i, -u
+// NAME and NAME=value arguments.
+type EnvCommand struct {
+	Args []string
+}
+
+// Execute implements the Command interface for EnvCommand
+func (c *EnvCommand) Execute(ctx context.Context, cfg *config.Config) (int, error) {
+	env := config.VariablesFromEnviron(cfg.Variables.Environ())
+
+	args := c.Args
+options:
+	for len(args) > 0 {
+		switch arg := args[0]; {
+		case arg == "--":
+			args = args[1:]
+			break options
+		case arg == "-i" || arg == "-":
+			env = config.NewVariables()
+		case arg == "-u":
+			if len(args) < 2 {
+				return ExitFailure, errors.New("env: option requires an argument -- 'u'")
+			}
+			args = args[1:]
+			_ = env.Unset(args[0])
+		case strings.HasPrefix(arg, "-"):
+			return ExitFailure, fmt.Errorf("env: %s: invalid option", arg)
+		default:
+			break options
+		}
+		args = args[1:]
+	}
+
+	// NAME=value arguments are added up to the command name
+	for len(args) > 0 && strings.Index(args[0], "=") > 0 {
+		name, value, _ := strings.Cut(args[0], "=")
+		_ = env.Set(name, value)
+		env.Declare(name, config.AttrExport)
+		args = args[1:]
+	}
+
+	if len(args) > 0 {
+		return (&ExternalCommand{Name: args[0], Args: args[1:], Env: env.Environ()}).Execute(ctx, cfg)
+	}
+
+	out := IOFromContext(ctx).Stdout
+	for _, entry := range env.Environ() {
+		if _, err := fmt.Fprintln(out, entry); err != nil {
+			return ExitFailure, err
+		}
+	}
+	return 0, nil
+}
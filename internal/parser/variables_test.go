@@ -0,0 +1,379 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"gosh/internal/config"
+)
+
+func TestParseAssignments(t *testing.T) {
+	parser := New(config.Default())
+
+	tests := []struct {
+		input       string
+		wantAssigns []string
+		wantWords   []string
+	}{
+		{"x=1", []string{"x=1"}, nil},
+		{"a=1 b=$a cmd c=3", []string{"a=1", "b=$a"}, []string{"cmd", "c=3"}},
+		{"x='a b' >out", []string{"x='a b'"}, nil},
+		{"'x'=1", nil, []string{"'x'=1"}},
+		{"1x=1 =y", nil, []string{"1x=1", "=y"}},
+	}
+
+	for _, tt := range tests {
+		cmd, err := parser.Parse(tt.input)
+		if err != nil {
+			t.Fatalf("Parse(%q) failed: %v", tt.input, err)
+		}
+		simple, ok := cmd.(*SimpleCommand)
+		if !ok {
+			t.Fatalf("Parse(%q) = %T, want *SimpleCommand", tt.input, cmd)
+		}
+		if strings.Join(simple.Assigns, "|") != strings.Join(tt.wantAssigns, "|") {
+			t.Errorf("Parse(%q) assignments = %q, want %q", tt.input, simple.Assigns, tt.wantAssigns)
+		}
+		if strings.Join(simple.Words, "|") != strings.Join(tt.wantWords, "|") {
+			t.Errorf("Parse(%q) words = %q, want %q", tt.input, simple.Words, tt.wantWords)
+		}
+	}
+}
+
+func TestVariablesExecute(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		wantOutput string
+		wantStatus int
+	}{
+		{
+			name:       "assignment",
+			input:      "x=hello; y=\"$x world\"; echo $y",
+			wantOutput: "hello world\n",
+		},
+		{
+			name:       "assignments see the ones before them",
+			input:      "a=1 b=$a; echo $b",
+			wantOutput: "1\n",
+		},
+		{
+			name:       "shell variables are not exported",
+			input:      "GOSH_TEST_VAR=1; env | grep -c GOSH_TEST_VAR",
+			wantOutput: "0\n",
+			wantStatus: 1,
+		},
+		{
+			name:       "exported variables reach commands",
+			input:      "GOSH_TEST_VAR=1; export GOSH_TEST_VAR; sh -c 'echo $GOSH_TEST_VAR'",
+			wantOutput: "1\n",
+		},
+		{
+			name:       "export -n",
+			input:      "export GOSH_TEST_VAR=1; export -n GOSH_TEST_VAR; env | grep -c GOSH_TEST_VAR",
+			wantOutput: "0\n",
+			wantStatus: 1,
+		},
+		{
+			name:       "prefix assignments only apply to the command",
+			input:      "v=outer; v=inner GOSH_TEST_VAR=x sh -c 'echo $v $GOSH_TEST_VAR'; echo $v \"[$GOSH_TEST_VAR]\"",
+			wantOutput: "inner x\nouter []\n",
+		},
+		{
+			name:       "prefix assignments for a function",
+			input:      "f() { echo $v; }; v=temp f; echo \"[$v]\"",
+			wantOutput: "temp\n[]\n",
+		},
+		{
+			name:       "arguments are expanded before prefix assignments",
+			input:      "v=old; v=new echo $v",
+			wantOutput: "old\n",
+		},
+		{
+			name:       "status of an assignment with a command substitution",
+			input:      "x=$(sh -c 'exit 3'); echo $?; false; y=1; echo $?",
+			wantOutput: "3\n0\n",
+		},
+		{
+			name:       "readonly",
+			input:      "readonly r=1; r=2; echo $? $r",
+			wantOutput: "1 1\n",
+		},
+		{
+			name:       "readonly variables cannot be unset",
+			input:      "readonly r=1; unset r; echo $? $r",
+			wantOutput: "1 1\n",
+		},
+		{
+			name:       "readonly prefix assignment does not run the command",
+			input:      "declare -r r=1; r=2 echo run",
+			wantStatus: 1,
+		},
+		{
+			name:       "unset",
+			input:      "x=1; unset x; echo \"[${x-unset}]\"",
+			wantOutput: "[unset]\n",
+		},
+		{
+			name:       "declare -i evaluates assignments",
+			input:      "declare -i n=2+3; n=n*2; echo $n",
+			wantOutput: "10\n",
+		},
+		{
+			name:       "declare -x",
+			input:      "declare -x GOSH_TEST_VAR=x; sh -c 'echo $GOSH_TEST_VAR'",
+			wantOutput: "x\n",
+		},
+		{
+			name:       "declare -p",
+			input:      "declare -ix n=1; declare -r s=\"it's\"; declare -a arr; declare -p n s arr",
+			wantOutput: "declare -ix n='1'\ndeclare -r s='it'\\''s'\ndeclare -a arr\n",
+		},
+		{
+			name:       "declare -p of an unknown variable",
+			input:      "declare -p no_such_variable",
+			wantStatus: 1,
+		},
+		{
+			name:       "declare in a function is local",
+			input:      "f() { declare a=in; declare -g b=in; }; a=out; f; echo $a $b",
+			wantOutput: "out in\n",
+		},
+		{
+			name:       "local takes attributes",
+			input:      "f() { local -i n=1+1; echo $n; }; f",
+			wantOutput: "2\n",
+		},
+		{
+			name:       "local hides a variable's attributes",
+			input:      "export GOSH_TEST_VAR=out; f() { local GOSH_TEST_VAR=in; env | grep -c GOSH_TEST_VAR; }; f; env | grep GOSH_TEST_VAR",
+			wantOutput: "0\nGOSH_TEST_VAR=out\n",
+		},
+		{
+			name:       "set prints the variables",
+			input:      "GOSH_A='a b'; GOSH_B=plain; set | grep ^GOSH_",
+			wantOutput: "GOSH_A='a b'\nGOSH_B=plain\n",
+		},
+		{
+			name:       "set replaces the positional parameters",
+			input:      "set -- a 'b c'; echo $# $2",
+			wantOutput: "2 b c\n",
+		},
+		{
+			name:       "env prints the exported variables",
+			input:      "export GOSH_TEST_VAR=1; GOSH_OTHER=2; env | grep GOSH_",
+			wantOutput: "GOSH_TEST_VAR=1\n",
+		},
+		{
+			name:       "env runs a command with changes to the environment",
+			input:      "export GOSH_TEST_VAR=1; env -u GOSH_TEST_VAR GOSH_OTHER=2 sh -c 'echo \"[$GOSH_TEST_VAR] $GOSH_OTHER\"'",
+			wantOutput: "[] 2\n",
+		},
+		{
+			name:       "PATH is the shell's",
+			input:      "PATH=/nonexistent; ls",
+			wantStatus: 127,
+		},
+		{
+			name:       "subshells have their own variables",
+			input:      "x=1; (x=2; export x); echo $x; env | grep -c ^x=",
+			wantOutput: "1\n0\n",
+			wantStatus: 1,
+		},
+		{
+			name:       "pipeline stages have their own variables",
+			input:      "x=1; x=2 | true; echo $x",
+			wantOutput: "1\n",
+		},
+		{
+			name:       "GOSH_ variables change settings",
+			input:      "GOSH_NULLGLOB=true; echo /nonexistent-gosh/*",
+			wantOutput: "\n",
+		},
+		{
+			name:       "invalid identifier",
+			input:      "export 1x=1",
+			wantStatus: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			parser := New(cfg)
+
+			cmd, err := parser.Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse() failed: %v", err)
+			}
+
+			var stdout, stderr bytes.Buffer
+			ctx := WithIO(context.Background(), IO{Stdin: strings.NewReader(""), Stdout: &stdout, Stderr: &stderr})
+			ctx = WithState(ctx, NewState(DefaultShellName, nil))
+			status, err := cmd.Execute(ctx, cfg)
+			if status != tt.wantStatus {
+				t.Errorf("Execute() status = %d (%v), want %d", status, err, tt.wantStatus)
+			}
+			if stdout.String() != tt.wantOutput {
+				t.Errorf("output = %q, want %q (stderr %q)", stdout.String(), tt.wantOutput, stderr.String())
+			}
+		})
+	}
+}
+
+func TestArrays(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		wantOutput string
+		wantStatus int
+	}{
+		{
+			name:       "elements",
+			input:      `arr=(a "b c" d); printf '[%s]' "${arr[@]}" ${arr[1]} $arr`,
+			wantOutput: "[a][b c][d][b][c][a]",
+		},
+		{
+			name:       "elements on several lines",
+			input:      "arr=(\n  a # first\n  b\n); echo ${arr[1]}",
+			wantOutput: "b\n",
+		},
+		{
+			name:       "elements are split and globbed",
+			input:      "w='x y'; arr=($w $(echo z) /nonexistent-gosh/*); echo ${#arr[@]}",
+			wantOutput: "4\n",
+		},
+		{
+			name:       "star joins the elements",
+			input:      `arr=(a b); IFS=,; echo "${arr[*]}"`,
+			wantOutput: "a,b\n",
+		},
+		{
+			name:       "append",
+			input:      "arr=(a); arr+=(b c); arr[5]=f; arr+=(g); echo ${arr[@]} ${!arr[@]}",
+			wantOutput: "a b c f g 0 1 2 5 6\n",
+		},
+		{
+			name:       "count and length",
+			input:      "arr=(one three); echo ${#arr[@]} ${#arr[1]} ${#arr}",
+			wantOutput: "2 5 3\n",
+		},
+		{
+			name:       "arithmetic and negative subscripts",
+			input:      "arr=(a b c); i=1; echo ${arr[i+1]} ${arr[$i]} ${arr[-1]}",
+			wantOutput: "c b c\n",
+		},
+		{
+			name:       "bad subscript",
+			input:      "arr=(a); arr[-5]=x",
+			wantStatus: 1,
+		},
+		{
+			name:       "empty array",
+			input:      `arr=(); printf '[%s]' "${arr[@]}" "${#arr[@]}"`,
+			wantOutput: "[0]",
+		},
+		{
+			name:       "unset an element",
+			input:      "arr=(a b c); unset 'arr[1]'; echo ${arr[@]} ${!arr[@]}",
+			wantOutput: "a c 0 2\n",
+		},
+		{
+			name:       "slice by index",
+			input:      "arr=(a b c d); unset 'arr[0]'; echo ${arr[@]:1:2}",
+			wantOutput: "b c\n",
+		},
+		{
+			name:       "for over the elements",
+			input:      `arr=("a b" c); for x in "${arr[@]}"; do echo "[$x]"; done`,
+			wantOutput: "[a b]\n[c]\n",
+		},
+		{
+			name:       "associative",
+			input:      `declare -A m=([b]=2 ["a key"]=1); m[c]=3; for k in "${!m[@]}"; do echo "$k=${m[$k]}"; done`,
+			wantOutput: "a key=1\nb=2\nc=3\n",
+		},
+		{
+			name:       "associative needs subscripts",
+			input:      "declare -A m=(a b)",
+			wantStatus: 1,
+		},
+		{
+			name:       "indexed cannot become associative",
+			input:      "arr=(a); declare -A arr",
+			wantStatus: 1,
+		},
+		{
+			name:       "integer elements",
+			input:      "declare -ai n=(1+1 2*3); n+=(4); n[0]+=1; echo ${n[@]}",
+			wantOutput: "3 6 4\n",
+		},
+		{
+			name:       "declare -p",
+			input:      `arr=(a "b c"); declare -A m=([k]=v); declare -p arr m`,
+			wantOutput: "declare -a arr=([0]='a' [1]='b c')\ndeclare -A m=([k]='v')\n",
+		},
+		{
+			name:       "local array",
+			input:      "arr=(out); f() { local -a arr=(in side); echo ${arr[@]}; }; f; echo ${arr[@]}",
+			wantOutput: "in side\nout\n",
+		},
+		{
+			name:       "arrays are not exported",
+			input:      "export GOSH_TEST_VAR=(a b); env | grep -c GOSH_TEST_VAR",
+			wantOutput: "0\n",
+			wantStatus: 1,
+		},
+		{
+			name:       "readonly array",
+			input:      "readonly -a r=(a); r[1]=b; echo $? ${r[@]}",
+			wantOutput: "1 a\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			parser := New(cfg)
+
+			cmd, err := parser.Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse() failed: %v", err)
+			}
+
+			var stdout, stderr bytes.Buffer
+			ctx := WithIO(context.Background(), IO{Stdin: strings.NewReader(""), Stdout: &stdout, Stderr: &stderr})
+			ctx = WithState(ctx, NewState(DefaultShellName, nil))
+			status, err := cmd.Execute(ctx, cfg)
+			if status != tt.wantStatus {
+				t.Errorf("Execute() status = %d (%v), want %d", status, err, tt.wantStatus)
+			}
+			if stdout.String() != tt.wantOutput {
+				t.Errorf("output = %q, want %q (stderr %q)", stdout.String(), tt.wantOutput, stderr.String())
+			}
+		})
+	}
+}
+
+func TestQuoteIfNeeded(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"plain", "plain"},
+		{"/usr/bin:/bin", "/usr/bin:/bin"},
+		{"", "''"},
+		{"a b", "'a b'"},
+		{"it's", `'it'\''s'`},
+		{"$HOME", "'$HOME'"},
+		{"é", "'é'"},
+	}
+
+	for _, tt := range tests {
+		if got := quoteIfNeeded(tt.value); got != tt.want {
+			t.Errorf("quoteIfNeeded(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/user"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -25,6 +26,8 @@ const (
 type Manager struct {
 	config     *config.Config
 	gitManager *git.Manager
+	// exitStatus returns the exit status of the last command, for %?
+	exitStatus func() int
 }
 
 // New creates a new prompt manager
@@ -40,6 +43,11 @@ func New(cfg *config.Config) (*Manager, error) {
 	}, nil
 }
 
+// SetExitStatus sets where the exit status of the last command comes from
+func (m *Manager) SetExitStatus(status func() int) {
+	m.exitStatus = status
+}
+
 // Generate generates the current prompt string
 func (m *Manager) Generate() (string, error) {
 	format := m.getPromptFormat()
@@ -96,6 +104,8 @@ func (m *Manager) expandEscapeSequence(char byte) string {
 		return m.getTimestampSafe()
 	case '$':
 		return m.getPromptChar()
+	case '?':
+		return m.getExitStatus()
 	case '%':
 		return "%"
 	default:
@@ -124,6 +134,14 @@ func (m *Manager) getTimestampSafe() string {
 	return m.getTimestamp()
 }
 
+// getExitStatus returns the exit status of the last command
+func (m *Manager) getExitStatus() string {
+	if m.exitStatus == nil {
+		return "0"
+	}
+	return strconv.Itoa(m.exitStatus())
+}
+
 // getUsername returns the current username
 func (m *Manager) getUsername() string {
 	if currentUser, err := user.Current(); err == nil {
@@ -315,6 +333,7 @@ func (m *Manager) GetAvailableFormats() map[string]string {
 		"%g": "Git information",
 		"%t": "Timestamp (HH:MM:SS)",
 		"%$": "Prompt character ($ or # for root)",
+		"%?": "Exit status of the last command",
 		"%%": "Literal % character",
 	}
 }
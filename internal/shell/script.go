@@ -0,0 +1,172 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gosh/internal/parser"
+)
+
+const (
+	// ProfileFile is the startup script of login shells, in the home directory
+	ProfileFile = ".gosh_profile"
+
+	// RCFile is the startup script of interactive shells, in the home
+	// directory or, without the leading dot, in the configuration directory
+	RCFile = ".goshrc"
+)
+
+// SetLogin marks the shell as a login shell, which runs ~/.gosh_profile
+// before its other startup scripts
+func (s *Shell) SetLogin(login bool) {
+	s.login = login
+}
+
+// SetArgs sets $0 and the positional parameters of the shell
+func (s *Shell) SetArgs(name string, args []string) {
+	s.state.Name = name
+	s.state.Args = args
+}
+
+// RunCommand runs command as a script, as gosh -c does, and returns the
+// exit status of its last command
+func (s *Shell) RunCommand(command string) int {
+	return s.runNonInteractive("-c", strings.NewReader(command))
+}
+
+// RunFile runs the script at path and returns the exit status of its last
+// command, or 127 if it cannot be opened
+func (s *Shell) RunFile(path string) int {
+	file, err := os.Open(path) // #nosec G304 -- the script is chosen by the user
+	if err != nil {
+		var pathErr *os.PathError
+		if errors.As(err, &pathErr) {
+			err = pathErr.Err
+		}
+		_, _ = fmt.Fprintf(os.Stderr, "gosh: %s: %v\n", path, err)
+		return parser.ExitCommandNotFound
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	return s.runNonInteractive(path, file)
+}
+
+// RunStdin runs the script read from standard input and returns the exit
+// status of its last command
+func (s *Shell) RunStdin() int {
+	return s.runNonInteractive("stdin", lineReader{os.Stdin})
+}
+
+// runNonInteractive runs a script without the line editor, prompts or job
+// control. Like other shells it skips .goshrc, and reports errors on stderr
+// so that they do not mix with the output of the script.
+func (s *Shell) runNonInteractive(name string, r io.Reader) int {
+	defer s.cancel()
+	s.writer = os.Stderr
+	s.setupSignalHandling()
+
+	if s.login {
+		if home, err := os.UserHomeDir(); err == nil {
+			s.runScriptFile(filepath.Join(home, ProfileFile))
+		}
+	}
+	s.runScript(name, r)
+	s.runExitTrap()
+	return s.state.Status
+}
+
+// lineReader reads one byte at a time, so that reading a script line by
+// line never consumes input meant for the commands it runs
+type lineReader struct {
+	r io.Reader
+}
+
+// Read implements io.Reader for lineReader
+func (l lineReader) Read(p []byte) (int, error) {
+	if len(p) > 1 {
+		p = p[:1]
+	}
+	return l.r.Read(p)
+}
+
+// runScript runs the commands of a script read from r, as soon as each is
+// complete, the way the interactive loop runs a line and its continuation
+// lines. Errors are reported with name and the line of the command. It
+// returns the exit status of the last command, or 2 after a syntax error,
+// which ends the script.
+func (s *Shell) runScript(name string, r io.Reader) int {
+	err := s.parser.RunScript(r, func(cmd parser.Command, text string, line int) bool {
+		ctx := parser.WithSource(s.ctx, parser.Source{Name: name, Line: line})
+		if err := s.runCommand(ctx, text, cmd); err != nil {
+			s.printScriptError(name, line, err)
+		}
+		return s.ctx.Err() == nil
+	})
+
+	var syntaxErr *parser.SyntaxError
+	switch {
+	case errors.As(err, &syntaxErr):
+		s.state.Status = parser.ExitUsage
+		s.printErrorWithDebug(fmt.Sprintf("%s:%d: %s", name, syntaxErr.Line, syntaxErr.Msg), "")
+	case err != nil:
+		s.state.Status = parser.ExitFailure
+		s.printErrorWithDebug(fmt.Sprintf("%s: %v", name, err), "")
+	}
+	return s.state.Status
+}
+
+// runScriptFile runs the script at path if it exists, reporting any error
+// opening it
+func (s *Shell) runScriptFile(path string) {
+	file, err := os.Open(path) // #nosec G304 -- startup scripts are chosen by the user
+	if err != nil {
+		if !os.IsNotExist(err) {
+			s.printErrorWithDebug(err.Error(), "")
+		}
+		return
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	s.runScript(path, file)
+}
+
+// printScriptError prints an error of the command starting at line of a
+// script
+func (s *Shell) printScriptError(name string, line int, err error) {
+	s.printErrorWithDebug(fmt.Sprintf("%s:%d: %v", name, line, err), "")
+}
+
+// loadConfigFiles runs the startup scripts: ~/.gosh_profile for a login
+// shell, then the first of goshrc in the configuration directory and
+// ~/.goshrc
+func (s *Shell) loadConfigFiles() {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		if s.config.Debug {
+			s.printDebugWarning(fmt.Sprintf("Warning: failed to find home directory: %v", err))
+		}
+		return
+	}
+
+	if s.login {
+		s.runScriptFile(filepath.Join(home, ProfileFile))
+	}
+
+	for _, path := range []string{
+		filepath.Join(s.config.ConfigDir, strings.TrimPrefix(RCFile, ".")),
+		filepath.Join(home, RCFile),
+	} {
+		if _, err := os.Stat(path); err == nil {
+			s.runScriptFile(path)
+			break
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package shell
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"gosh/internal/config"
+	"gosh/internal/jobs"
+	"gosh/internal/parser"
+)
+
+func TestRunScript(t *testing.T) {
+	tests := []struct {
+		name       string
+		script     string
+		args       []string
+		wantStdout string
+		wantStderr string
+		wantOut    string
+		wantStatus int
+	}{
+		{
+			name:       "commands run in order",
+			script:     "echo a\necho b\n",
+			wantStdout: "a\nb\n",
+		},
+		{
+			name:       "last line without a newline",
+			script:     "echo a",
+			wantStdout: "a\n",
+		},
+		{
+			name:       "commands spanning lines",
+			script:     "cat <<EOF\nbody\nEOF\necho after",
+			wantStdout: "body\nafter\n",
+		},
+		{
+			name:       "long compound command",
+			script:     "if true; then\n  for x in a b; do\n    echo $x\n  done\n  cat <<EOF\nbody\nEOF\n  echo '\nfi'\nfi\necho after",
+			wantStdout: "a\nb\nbody\n\nfi\nafter\n",
+		},
+		{
+			name:       "aliases apply to later lines",
+			script:     "alias greet='echo hi'\ngreet there",
+			wantStdout: "hi there\n",
+		},
+		{
+			name:       "failed command reports file and line",
+			script:     "echo ok\nnosuchcommand-gosh\n",
+			wantStdout: "ok\n",
+			wantOut:    "gosh: test.sh:2: command not found: nosuchcommand-gosh\n",
+			wantStatus: parser.ExitCommandNotFound,
+		},
+		{
+			name:       "error inside a list",
+			script:     "\n\nnosuchcommand-gosh; echo next",
+			wantStdout: "next\n",
+			wantStderr: "gosh: test.sh:3: command not found: nosuchcommand-gosh\n",
+		},
+		{
+			name:       "syntax error ends the script",
+			script:     "echo ok\ncat <<EOF\nbody\nEOF\nls | | wc\necho after",
+			wantStdout: "ok\nbody\n",
+			wantOut:    "gosh: test.sh:5: syntax error near unexpected token `|'\n",
+			wantStatus: parser.ExitUsage,
+		},
+		{
+			name:       "syntax error in a compound command",
+			script:     "echo one\nif then\necho after",
+			wantStdout: "one\n",
+			wantOut:    "gosh: test.sh:2: syntax error near unexpected token `then'\n",
+			wantStatus: parser.ExitUsage,
+		},
+		{
+			name:       "unterminated command at the end",
+			script:     "echo ok\necho 'open",
+			wantStdout: "ok\n",
+			wantOut:    "gosh: test.sh:2: unexpected end of input looking for the match of `''\n",
+			wantStatus: parser.ExitUsage,
+		},
+		{
+			name:       "positional parameters",
+			script:     "echo $0 $# $2",
+			args:       []string{"a", "b"},
+			wantStdout: "test.sh 2 b\n",
+		},
+		{
+			name:       "exit status of the last command",
+			script:     "true\nfalse",
+			wantStatus: 1,
+		},
+		{
+			name:       "errexit ends the script",
+			script:     "set -e\necho a\nfalse\necho b",
+			wantStdout: "a\n",
+			wantStatus: 1,
+		},
+		{
+			name:       "exit ends the script with its status",
+			script:     "echo a\nexit 3\necho b",
+			wantStdout: "a\n",
+			wantStatus: 3,
+		},
+		{
+			name:       "noexec only checks the syntax",
+			script:     "set -n\necho no\nls | | wc\n",
+			wantOut:    "gosh: test.sh:3: syntax error near unexpected token `|'\n",
+			wantStatus: parser.ExitUsage,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out, stdout, stderr bytes.Buffer
+			s := newTestShell(&out, &stdout, &stderr)
+			s.SetArgs("test.sh", tt.args)
+
+			status := s.runScript("test.sh", strings.NewReader(tt.script))
+			if status != tt.wantStatus {
+				t.Errorf("runScript() = %d, want %d", status, tt.wantStatus)
+			}
+			if stdout.String() != tt.wantStdout {
+				t.Errorf("stdout = %q, want %q", stdout.String(), tt.wantStdout)
+			}
+			if stderr.String() != tt.wantStderr {
+				t.Errorf("stderr = %q, want %q", stderr.String(), tt.wantStderr)
+			}
+			if out.String() != tt.wantOut {
+				t.Errorf("shell output = %q, want %q", out.String(), tt.wantOut)
+			}
+		})
+	}
+}
+
+func TestLineReader(t *testing.T) {
+	input := strings.NewReader("first\nsecond\n")
+	line, err := bufio.NewReader(lineReader{input}).ReadString('\n')
+	if err != nil {
+		t.Fatalf("ReadString() error = %v", err)
+	}
+	if line != "first\n" {
+		t.Errorf("ReadString() = %q, want %q", line, "first\n")
+	}
+
+	// The rest of the input is left for the commands of the script
+	rest, _ := io.ReadAll(input)
+	if string(rest) != "second\n" {
+		t.Errorf("remaining input = %q, want %q", rest, "second\n")
+	}
+}
+
+// newTestShell creates a shell that runs commands without a terminal,
+// printing its own messages to out and connecting commands to stdout and
+// stderr
+func newTestShell(out, stdout, stderr *bytes.Buffer) *Shell {
+	cfg := config.Default()
+	jobMgr := jobs.New()
+	p := parser.New(cfg)
+	p.SetJobManager(jobMgr)
+
+	state := parser.NewState(parser.DefaultShellName, nil)
+	ctx := parser.WithState(context.Background(), state)
+	ctx = parser.WithIO(ctx, parser.IO{Stdin: strings.NewReader(""), Stdout: stdout, Stderr: stderr})
+	ctx, cancel := context.WithCancel(ctx)
+
+	s := &Shell{
+		config: cfg,
+		parser: p,
+		jobs:   jobMgr,
+		state:  state,
+		writer: out,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	p.SetSignalReceiver(s.receiveSignal)
+	return s
+}
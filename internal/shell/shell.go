@@ -5,16 +5,18 @@ package shell
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
-	"os/signal"
 	"strings"
+	"sync/atomic"
 	"syscall"
 
 	"gosh/internal/completion"
 	"gosh/internal/config"
 	"gosh/internal/history"
+	"gosh/internal/jobs"
 	"gosh/internal/parser"
 	"gosh/internal/prompt"
 
@@ -24,6 +26,9 @@ import (
 const (
 	// MinSimilarityLength is the minimum length for similarity checks
 	MinSimilarityLength = 2
+
+	// ContinuationPrompt is shown while reading the rest of a multi-line command
+	ContinuationPrompt = "> "
 )
 
 // shellCompleter implements readline.AutoCompleter for tab completion
@@ -75,10 +80,22 @@ type Shell struct {
 	prompt     *prompt.Manager
 	completion *completion.Manager
 	parser     *parser.Parser
+	jobs       *jobs.Manager
+	state      *parser.State
+	login      bool
 	readline   *readline.Instance
 	writer     io.Writer
 	ctx        context.Context
 	cancel     context.CancelFunc
+
+	// signals receives the signals the shell catches
+	signals chan os.Signal
+	// stopSignals stops the goroutine dispatching signals, which closes
+	// signalsDone as it returns
+	stopSignals context.CancelFunc
+	signalsDone chan struct{}
+	// exitSignal is the signal that is ending the shell, if any
+	exitSignal atomic.Int32
 }
 
 // New creates a new shell instance with the given configuration
@@ -107,21 +124,16 @@ func New(cfg *config.Config) (*Shell, error) {
 	}
 
 	// Initialize parser
+	jobMgr := jobs.New()
 	parserInst := parser.New(cfg)
 	parserInst.SetHistoryManager(historyMgr)
+	parserInst.SetJobManager(jobMgr)
 
-	// Create readline instance with completion
-	rl, err := readline.NewEx(&readline.Config{
-		Prompt:          "> ",
-		HistoryFile:     cfg.HistoryFile,
-		AutoComplete:    &shellCompleter{completion: completionMgr},
-		InterruptPrompt: "^C",
-		EOFPrompt:       "exit",
-	})
-	if err != nil {
-		cancel()
-		return nil, fmt.Errorf("failed to create readline: %w", err)
-	}
+	// Commands find $?, $0 and the positional parameters in the context
+	state := parser.NewState(parser.DefaultShellName, nil)
+	ctx = parser.WithState(ctx, state)
+	completionMgr.SetFunctionNames(state.FunctionNames)
+	promptMgr.SetExitStatus(func() int { return state.Status })
 
 	shell := &Shell{
 		config:     cfg,
@@ -129,18 +141,36 @@ func New(cfg *config.Config) (*Shell, error) {
 		prompt:     promptMgr,
 		completion: completionMgr,
 		parser:     parserInst,
-		readline:   rl,
+		jobs:       jobMgr,
+		state:      state,
 		writer:     os.Stdout,
 		ctx:        ctx,
 		cancel:     cancel,
 	}
+	parserInst.SetSignalReceiver(shell.receiveSignal)
 
 	return shell, nil
 }
 
-// Run starts the main shell loop
-func (s *Shell) Run() error {
+// Run starts the main shell loop. It returns the status the shell exits
+// with: that given to exit, or the status of the last command.
+func (s *Shell) Run() (int, error) {
 	defer s.cancel()
+	s.state.Interactive = true
+
+	// Create the line editor here rather than in New, as it reads from
+	// stdin as soon as it exists and would consume a script's input
+	rl, err := readline.NewEx(&readline.Config{
+		Prompt:          "> ",
+		HistoryFile:     s.config.HistoryFile,
+		AutoComplete:    &shellCompleter{completion: s.completion},
+		InterruptPrompt: "^C",
+		EOFPrompt:       "exit",
+	})
+	if err != nil {
+		return parser.ExitFailure, fmt.Errorf("failed to create readline: %w", err)
+	}
+	s.readline = rl
 	defer func() {
 		if err := s.readline.Close(); err != nil && s.config.Debug {
 			s.printDebugWarning(fmt.Sprintf("Warning: failed to close readline: %v", err))
@@ -150,8 +180,15 @@ func (s *Shell) Run() error {
 	// Setup signal handling
 	s.setupSignalHandling()
 
-	// Load configuration files
+	// Enable job control when attached to a terminal
+	s.setupJobControl()
+
+	// Run the startup scripts, which may move the history file
+	historyFile := s.config.HistoryFile
 	s.loadConfigFiles()
+	if s.config.HistoryFile != historyFile {
+		s.reloadHistory()
+	}
 
 	// Print welcome message if configured
 	if s.config.ShowWelcome {
@@ -159,7 +196,14 @@ func (s *Shell) Run() error {
 	}
 
 	// Main shell loop
-	return s.mainLoop()
+	err = s.mainLoop()
+	s.runExitTrap()
+
+	// Write out the history before the line editor closes
+	if saveErr := s.history.Save(); saveErr != nil && s.config.Debug {
+		s.printDebugWarning(fmt.Sprintf("Warning: failed to save history: %v", saveErr))
+	}
+	return s.state.Status, err
 }
 
 // mainLoop implements the main read-eval-print loop
@@ -169,6 +213,11 @@ func (s *Shell) mainLoop() error {
 		case <-s.ctx.Done():
 			return nil
 		default:
+			// Report jobs that finished or stopped since the last prompt
+			if err := s.jobs.Report(s.writer); err != nil && s.config.Debug {
+				s.printDebugWarning(fmt.Sprintf("Warning: failed to report jobs: %v", err))
+			}
+
 			// Read input (readline handles prompt generation)
 			input, err := s.readInput()
 			if err != nil {
@@ -188,11 +237,24 @@ func (s *Shell) mainLoop() error {
 				continue
 			}
 
+			// Keep reading while the command spans several lines
+			input, err = s.readContinuation(input)
+			if err != nil {
+				if err == readline.ErrInterrupt {
+					continue
+				}
+				if err == io.EOF {
+					s.handleError(fmt.Errorf("parse error: %w", parser.ErrIncomplete), input)
+					continue
+				}
+				return fmt.Errorf("failed to read input: %w", err)
+			}
+
 			// Add to history
 			s.history.Add(input)
 
 			// Parse and execute command
-			if err := s.executeCommand(input); err != nil {
+			if err := s.executeCommand(s.ctx, input); err != nil {
 				// Enhanced error handling with context
 				s.handleError(err, input)
 			}
@@ -216,60 +278,100 @@ func (s *Shell) readInput() (string, error) {
 	return line, nil
 }
 
-// executeCommand parses and executes a command
-func (s *Shell) executeCommand(input string) error {
-	// Parse the command
+// readContinuation reads further lines with the continuation prompt for as
+// long as the parser reports that input is incomplete, such as an open
+// here-document, and returns the whole command
+func (s *Shell) readContinuation(input string) (string, error) {
+	for {
+		if _, err := s.parser.Parse(input); !errors.Is(err, parser.ErrIncomplete) {
+			return input, nil
+		}
+
+		s.readline.SetPrompt(ContinuationPrompt)
+		line, err := s.readline.Readline()
+		if err != nil {
+			return input, err
+		}
+		input += "\n" + line
+	}
+}
+
+// executeCommand parses and executes a command, recording its exit status
+// for $?. The error is only set when the command could not be run; a
+// command that exits non-zero is not an error.
+func (s *Shell) executeCommand(ctx context.Context, input string) error {
 	cmd, err := s.parser.Parse(input)
 	if err != nil {
+		s.state.ExitWarned = false
+		s.state.Status = parser.ExitUsage
 		return fmt.Errorf("parse error: %w", err)
 	}
-
-	// Execute the command
-	return cmd.Execute(s.ctx, s.config)
+	return s.runCommand(ctx, input, cmd)
 }
 
-// setupSignalHandling sets up signal handlers for the shell
-func (s *Shell) setupSignalHandling() {
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-
-	go func() {
-		for {
-			select {
-			case sig := <-sigChan:
-				switch sig {
-				case syscall.SIGINT:
-					// Handle Ctrl+C gracefully
-					s.printWithDebugWarning("^C\n", "interrupt message")
-					// Don't exit, just interrupt current operation and continue
-					// The main loop will continue and show a new prompt
-				case syscall.SIGTERM:
-					// Handle termination
-					s.printWithDebugWarning("\nTerminating gosh...\n", "termination message")
-					s.cancel()
-					return
-				}
-			case <-s.ctx.Done():
-				return
-			}
+// runCommand runs cmd, parsed from input, as a foreground job and records
+// its exit status
+func (s *Shell) runCommand(ctx context.Context, input string, cmd parser.Command) error {
+	// exit warns about jobs only until another command runs
+	if s.state.ExitWarned {
+		defer func() {
+			s.state.ExitWarned = false
+		}()
+	}
+
+	// With noexec a script is only checked for syntax errors
+	if s.config.Noexec && !s.state.Interactive {
+		return nil
+	}
+
+	// Execute the command as a foreground job
+	status, err := s.jobs.Foreground(ctx, input, func(ctx context.Context) (int, error) {
+		// Signals that arrived while the shell waited for input come first
+		if err := s.parser.RunPendingTraps(ctx); err != nil {
+			return parser.ExitFailure, err
 		}
-	}()
+		return cmd.Execute(ctx, s.config)
+	})
+
+	// An interrupted command ends with the status of SIGINT
+	if errors.Is(err, context.Canceled) {
+		status, err = parser.ExitSignalBase+int(syscall.SIGINT), nil
+	}
+
+	// exit, or a command that failed under set -e, ends the shell
+	var exit *parser.ExitError
+	if errors.As(err, &exit) {
+		s.state.Status = exit.Status
+		s.cancel()
+		return nil
+	}
+	s.state.Status = status
+	return err
 }
 
-// loadConfigFiles loads .goshrc and .gosh_profile files
-func (s *Shell) loadConfigFiles() {
-	// Load .gosh_profile (login shell)
-	s.loadConfigFileWithWarning(".gosh_profile")
+// setupJobControl enables job control when stdin is a terminal
+func (s *Shell) setupJobControl() {
+	fd := int(os.Stdin.Fd())
+	if !readline.IsTerminal(fd) {
+		return
+	}
 
-	// Load .goshrc (interactive shell)
-	s.loadConfigFileWithWarning(".goshrc")
+	if err := s.jobs.EnableJobControl(fd); err != nil && s.config.Debug {
+		s.printDebugWarning(fmt.Sprintf("Warning: %v", err))
+	}
 }
 
-// loadConfigFileWithWarning loads a config file and prints warning on error if debug is enabled
-func (s *Shell) loadConfigFileWithWarning(filename string) {
-	if err := s.loadConfigFile(filename); err != nil && s.config.Debug {
-		s.printDebugWarning(fmt.Sprintf("Warning: failed to load %s: %v", filename, err))
+// reloadHistory reopens the command history after the history file
+// setting has changed
+func (s *Shell) reloadHistory() {
+	historyMgr, err := history.New(s.config)
+	if err != nil {
+		s.printErrorWithDebug(fmt.Sprintf("failed to load history: %v", err), "")
+		return
 	}
+	s.history = historyMgr
+	s.parser.SetHistoryManager(historyMgr)
+	s.readline.SetHistoryPath(s.config.HistoryFile)
 }
 
 // printDebugWarning prints a debug warning message, ignoring print errors
@@ -277,13 +379,6 @@ func (s *Shell) printDebugWarning(message string) {
 	_, _ = fmt.Fprintf(s.writer, "%s\n", message)
 }
 
-// loadConfigFile loads a specific configuration file
-func (s *Shell) loadConfigFile(_ string) error {
-	// Implementation will be added when we implement the config system
-	// For now, just return nil
-	return nil
-}
-
 // printWelcome prints the welcome message
 func (s *Shell) printWelcome() {
 	s.printWithDebugWarning("Welcome to gosh - A modern shell written in Go\n", "welcome message")
@@ -309,8 +404,13 @@ func (s *Shell) printErrorWithDebug(errorMsg, debugInfo string) {
 func (s *Shell) handleError(err error, input string) {
 	// Categorize and handle different types of errors
 	errorMsg := err.Error()
+	var syntaxErr *parser.SyntaxError
 
 	switch {
+	case errors.As(err, &syntaxErr):
+		s.printErrorWithDebug(syntaxErr.Error(), "Check your command syntax")
+		s.printErrorLocation(input, syntaxErr.Line, syntaxErr.Column)
+
 	case strings.Contains(errorMsg, "command not found"):
 		s.printErrorWithDebug(errorMsg, fmt.Sprintf("Input was '%s'", input))
 		s.suggestSimilarCommands(input)
@@ -330,6 +430,32 @@ func (s *Shell) handleError(err error, input string) {
 	}
 }
 
+// printErrorLocation prints the input line containing a syntax error with
+// a caret under the offending column
+func (s *Shell) printErrorLocation(input string, line, column int) {
+	lines := strings.Split(input, "\n")
+	if line < 1 || line > len(lines) {
+		return
+	}
+
+	text := lines[line-1]
+	if column < 1 || column > len(text)+1 {
+		return
+	}
+
+	// Keep tabs in the padding so the caret lines up with the text above
+	var pad strings.Builder
+	for _, c := range text[:column-1] {
+		if c == '\t' {
+			pad.WriteRune('\t')
+		} else {
+			pad.WriteRune(' ')
+		}
+	}
+
+	s.printWithDebugWarning(fmt.Sprintf("  %s\n  %s^\n", text, pad.String()), "error location")
+}
+
 // suggestSimilarCommands suggests similar commands when a command is not found
 func (s *Shell) suggestSimilarCommands(input string) {
 	tokens := strings.Fields(input)
@@ -341,8 +467,7 @@ func (s *Shell) suggestSimilarCommands(input string) {
 	suggestions := []string{}
 
 	// Check built-in commands for similarity
-	builtins := []string{"cd", "pwd", "exit", "help", "history", "alias", "export"}
-	for _, builtin := range builtins {
+	for _, builtin := range parser.BuiltinNames() {
 		if s.isSimilar(command, builtin) {
 			suggestions = append(suggestions, builtin)
 		}
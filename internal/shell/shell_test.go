@@ -1,10 +1,12 @@
 package shell
 
 import (
+	"bytes"
 	"testing"
 
 	"gosh/internal/completion"
 	"gosh/internal/config"
+	"gosh/internal/parser"
 )
 
 func TestShellCompleter_Do(t *testing.T) {
@@ -124,3 +126,22 @@ func completionsToStrings(completions [][]rune) []string {
 	}
 	return result
 }
+
+func TestHandleErrorSyntaxLocation(t *testing.T) {
+	var out bytes.Buffer
+	s := &Shell{config: config.Default(), writer: &out}
+
+	input := "echo ok\nls | | wc"
+	_, err := parser.New(s.config).Parse(input)
+	if err == nil {
+		t.Fatal("Parse() succeeded, want a syntax error")
+	}
+	s.handleError(err, input)
+
+	want := "gosh: syntax error near unexpected token `|' (line 2, column 6)\n" +
+		"  ls | | wc\n" +
+		"       ^\n"
+	if out.String() != want {
+		t.Errorf("handleError() printed %q, want %q", out.String(), want)
+	}
+}
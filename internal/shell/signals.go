@@ -0,0 +1,163 @@
+package shell
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+
+	"gosh/internal/parser"
+)
+
+// setupSignalHandling catches the signals the shell handles itself, and
+// from then on those given a trap, and dispatches them as they arrive
+func (s *Shell) setupSignalHandling() {
+	s.signals = make(chan os.Signal, 8)
+	signal.Notify(s.signals, s.handledSignals()...)
+	s.state.Traps.SetHandler(s.updateSignal)
+
+	ctx, stop := context.WithCancel(s.ctx)
+	s.stopSignals = stop
+	s.signalsDone = make(chan struct{})
+	go func() {
+		defer close(s.signalsDone)
+		for {
+			select {
+			case sig := <-s.signals:
+				s.handleSignal(sig)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// drainSignals stops dispatching signals, then handles those that arrived
+// but were not dispatched yet, so that their traps still run as the shell
+// exits
+func (s *Shell) drainSignals() {
+	if s.signals == nil {
+		return
+	}
+	s.stopSignals()
+	<-s.signalsDone
+
+	for {
+		select {
+		case sig := <-s.signals:
+			s.handleSignal(sig)
+		default:
+			return
+		}
+	}
+}
+
+// receiveSignal handles a signal that kill sends to the shell itself as if
+// it had arrived, and reports whether it did. The process is left to act
+// on the signals the shell neither catches nor ignores.
+func (s *Shell) receiveSignal(sig os.Signal) bool {
+	action, set := s.state.Traps.Action(parser.SignalName(sig))
+	switch {
+	case set && action == "":
+		return true
+	case !set && !slices.Contains(s.handledSignals(), sig):
+		return false
+	}
+	s.handleSignal(sig)
+	return true
+}
+
+// handledSignals returns the signals the shell acts on without a trap.
+// Without a terminal they end the shell; an interactive shell only
+// interrupts the foreground job on SIGINT and exits on SIGHUP.
+func (s *Shell) handledSignals() []os.Signal {
+	names := []string{"INT", "TERM", "HUP"}
+	if s.state.Interactive {
+		names = append(names, "QUIT", "TSTP")
+	}
+
+	var sigs []os.Signal
+	for _, name := range names {
+		if sig, ok := parser.LookupSignal(name); ok {
+			sigs = append(sigs, sig)
+		}
+	}
+	return sigs
+}
+
+// updateSignal catches, ignores or restores a signal after its trap was
+// set or reset. Processes the shell starts inherit an ignored signal; a
+// caught one is back to its default action in them.
+func (s *Shell) updateSignal(sig os.Signal) {
+	action, set := s.state.Traps.Action(parser.SignalName(sig))
+	switch {
+	case set && action == "" && parser.SignalName(sig) != "CHLD":
+		// SIGCHLD is still needed to wait for commands
+		signal.Ignore(sig)
+	case set || slices.Contains(s.handledSignals(), sig):
+		signal.Notify(s.signals, sig)
+	default:
+		signal.Reset(sig)
+	}
+}
+
+// handleSignal runs the trap of a signal that arrived, or otherwise acts
+// on it as the shell does by default
+func (s *Shell) handleSignal(sig os.Signal) {
+	if s.state.Traps.Deliver(sig) {
+		// The trap runs between commands
+		return
+	}
+	if !slices.Contains(s.handledSignals(), sig) {
+		// A signal whose trap was reset before it was dispatched
+		return
+	}
+
+	if s.state.Interactive {
+		switch sig {
+		case syscall.SIGINT:
+			// Ctrl+C stops the foreground job, and the shell goes on
+			s.printWithDebugWarning("^C\n", "interrupt message")
+			s.jobs.Interrupt()
+			return
+		case syscall.SIGHUP:
+		default:
+			return
+		}
+	}
+	s.terminate(sig)
+}
+
+// terminate ends the shell because of sig. The foreground job is
+// interrupted, and the shell exits with status 128+N after its EXIT trap.
+func (s *Shell) terminate(sig os.Signal) {
+	if n, ok := sig.(syscall.Signal); ok {
+		s.exitSignal.Store(int32(n))
+	}
+	s.cancel()
+}
+
+// runExitTrap runs the EXIT trap as the shell exits. It runs even when a
+// signal is ending the shell, so it does not use the shell's context.
+func (s *Shell) runExitTrap() {
+	s.drainSignals()
+	if n := s.exitSignal.Load(); n != 0 {
+		s.state.Status = parser.ExitSignalBase + int(n)
+	}
+
+	// Signals that arrived as the last command ended are handled first
+	ctx := context.WithoutCancel(s.ctx)
+	err := s.parser.RunPendingTraps(ctx)
+	if err == nil {
+		err = s.parser.RunExitTrap(ctx)
+	}
+	var exit *parser.ExitError
+	if errors.As(err, &exit) {
+		s.state.Status = exit.Status
+	} else if err != nil {
+		s.printErrorWithDebug(fmt.Sprint(err), "")
+	}
+}
@@ -0,0 +1,139 @@
+package shell
+
+import (
+	"bytes"
+	"strings"
+	"syscall"
+	"testing"
+
+	"gosh/internal/parser"
+)
+
+func TestHandleSignal(t *testing.T) {
+	tests := []struct {
+		name        string
+		script      string
+		interactive bool
+		signal      syscall.Signal
+		wantStdout  string
+		wantStatus  int
+		wantEnded   bool
+	}{
+		{
+			name:       "a trap runs before the next command",
+			script:     "trap 'echo got TERM' TERM",
+			signal:     syscall.SIGTERM,
+			wantStdout: "got TERM\n",
+		},
+		{
+			name:       "a script ends with 128+N after its EXIT trap",
+			script:     "trap 'echo bye $?' EXIT",
+			signal:     syscall.SIGTERM,
+			wantStdout: "bye 143\n",
+			wantStatus: 143,
+			wantEnded:  true,
+		},
+		{
+			name:       "exit in the EXIT trap sets the status",
+			script:     "trap 'exit 4' EXIT",
+			signal:     syscall.SIGTERM,
+			wantStatus: 4,
+			wantEnded:  true,
+		},
+		{
+			name:        "an interactive shell ignores SIGTERM",
+			script:      "true",
+			interactive: true,
+			signal:      syscall.SIGTERM,
+		},
+		{
+			name:        "an interactive shell survives SIGINT",
+			script:      "true",
+			interactive: true,
+			signal:      syscall.SIGINT,
+		},
+		{
+			name:        "an interactive shell exits on SIGHUP",
+			script:      "true",
+			interactive: true,
+			signal:      syscall.SIGHUP,
+			wantStatus:  parser.ExitSignalBase + int(syscall.SIGHUP),
+			wantEnded:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out, stdout, stderr bytes.Buffer
+			s := newTestShell(&out, &stdout, &stderr)
+			s.state.Interactive = tt.interactive
+
+			s.runScript("test.sh", strings.NewReader(tt.script))
+			s.handleSignal(tt.signal)
+			if s.ctx.Err() == nil {
+				s.runScript("test.sh", strings.NewReader("true"))
+			}
+			s.runExitTrap()
+
+			if ended := s.ctx.Err() != nil; ended != tt.wantEnded {
+				t.Errorf("shell ended = %v, want %v", ended, tt.wantEnded)
+			}
+			if s.state.Status != tt.wantStatus {
+				t.Errorf("status = %d, want %d", s.state.Status, tt.wantStatus)
+			}
+			if stdout.String() != tt.wantStdout {
+				t.Errorf("stdout = %q, want %q", stdout.String(), tt.wantStdout)
+			}
+		})
+	}
+}
+
+func TestKillShell(t *testing.T) {
+	tests := []struct {
+		name       string
+		script     string
+		wantStdout string
+		wantStatus int
+	}{
+		{
+			name:       "the trap runs before the next command",
+			script:     "trap 'echo got INT' INT\nkill -INT $$; echo after",
+			wantStdout: "got INT\nafter\n",
+		},
+		{
+			name:       "exit in the trap ends the script",
+			script:     "trap 'exit 9' TERM\nkill -TERM $$; echo not",
+			wantStatus: 9,
+		},
+		{
+			name:       "an ignored signal does nothing",
+			script:     "trap '' TERM\nkill $$; echo after",
+			wantStdout: "after\n",
+		},
+		{
+			name:       "SIGTERM ends a script",
+			script:     "kill $$\necho not",
+			wantStatus: parser.ExitSignalBase + int(syscall.SIGTERM),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out, stdout, stderr bytes.Buffer
+			s := newTestShell(&out, &stdout, &stderr)
+
+			status := s.runScript("test.sh", strings.NewReader(tt.script))
+			if s.ctx.Err() != nil {
+				s.runExitTrap()
+				status = s.state.Status
+			}
+
+			if status != tt.wantStatus {
+				t.Errorf("status = %d, want %d", status, tt.wantStatus)
+			}
+			if stdout.String() != tt.wantStdout {
+				t.Errorf("stdout = %q, want %q", stdout.String(), tt.wantStdout)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+//go:build unix
+
+package shell
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestDispatchSignal(t *testing.T) {
+	var out, stdout, stderr bytes.Buffer
+	s := newTestShell(&out, &stdout, &stderr)
+	s.setupSignalHandling()
+	t.Cleanup(func() { signal.Stop(s.signals) })
+
+	// A signal from another process runs its trap once it is dispatched
+	s.runScript("test.sh", strings.NewReader("trap 'echo got USR1' USR1"))
+	if err := syscall.Kill(os.Getpid(), syscall.SIGUSR1); err != nil {
+		t.Fatalf("Kill() failed: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for stdout.Len() == 0 && time.Now().Before(deadline) {
+		if err := s.parser.RunPendingTraps(context.WithoutCancel(s.ctx)); err != nil {
+			t.Fatalf("RunPendingTraps() failed: %v", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	s.runExitTrap()
+
+	if want := "got USR1\n"; stdout.String() != want {
+		t.Errorf("stdout = %q, want %q", stdout.String(), want)
+	}
+}